@@ -0,0 +1,259 @@
+// access guards the HTTP surfaces of the SDK, such as the watch event stream,
+// the audit log and the smart home handlers, with API tokens carrying roles.
+// A wall-mounted dashboard can be given a Reader token which shows state
+// without being able to start cleaning or change schedules.
+//
+// Tokens are supplied as a bearer token, an X-API-Key header, or the token
+// query parameter for clients such as browser EventSources which cannot set
+// headers. Only a hash of each token is kept. Clients may instead present a
+// TLS client certificate, verified by the server, whose common name has been
+// granted a Role. Each client may be rate limited, and the whole arrangement
+// can be loaded from a Config file.
+
+package access
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/richlj/neato/nucleo"
+)
+
+const (
+	bearerPrefix = "Bearer "
+	apiKeyHeader = "X-API-Key"
+	tokenLength  = 32
+)
+
+// Role is the level of access granted to a Token. Each Role includes the
+// access of those below it.
+type Role int
+
+// The Roles, from least to most privileged
+const (
+	Reader Role = iota + 1
+	Operator
+	Admin
+)
+
+var (
+	roleNames = map[Role]string{
+		Reader:   "reader",
+		Operator: "operator",
+		Admin:    "admin",
+	}
+)
+
+func (r Role) String() string {
+	if s, ok := roleNames[r]; ok {
+		return s
+	}
+	return fmt.Sprintf("role(%d)", int(r))
+}
+
+// ParseRole returns the Role with the supplied name, e.g. "operator"
+func ParseRole(s string) (Role, error) {
+	for r, name := range roleNames {
+		if strings.EqualFold(s, name) {
+			return r, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown role %q", s)
+}
+
+// Token identifies an API client and its Role
+type Token struct {
+	Name string
+	Role Role
+}
+
+type tokenKey struct{}
+
+// FromContext returns the Token which authorised a request, as stored in its
+// context by a Tokens handler
+func FromContext(ctx context.Context) (Token, bool) {
+	t, ok := ctx.Value(tokenKey{}).(Token)
+	return t, ok
+}
+
+// Tokens is a set of API tokens
+type Tokens struct {
+	mu     sync.RWMutex
+	tokens map[[sha256.Size]byte]Token
+	certs  map[string]Token
+	limits map[string]*bucket
+}
+
+// NewTokens returns an empty set of Tokens
+func NewTokens() *Tokens {
+	return &Tokens{
+		tokens: make(map[[sha256.Size]byte]Token),
+		certs:  make(map[string]Token),
+		limits: make(map[string]*bucket),
+	}
+}
+
+// Add registers secret as the token of the named client with the supplied
+// Role
+func (t *Tokens) Add(name, secret string, role Role) error {
+	if secret == "" {
+		return fmt.Errorf("empty token for %q", name)
+	}
+	if _, ok := roleNames[role]; !ok {
+		return fmt.Errorf("invalid role %v for %q", role, name)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tokens[sha256.Sum256([]byte(secret))] = Token{Name: name, Role: role}
+	return nil
+}
+
+// Generate registers and returns a new random token for the named client
+func (t *Tokens) Generate(name string, role Role) (string, error) {
+	b := make([]byte, tokenLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	secret := hex.EncodeToString(b)
+	if err := t.Add(name, secret, role); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// AddCertificate grants role to clients presenting a verified TLS
+// certificate with the supplied common name, which also names the client
+func (t *Tokens) AddCertificate(commonName string, role Role) error {
+	if commonName == "" {
+		return fmt.Errorf("empty certificate common name")
+	}
+	if _, ok := roleNames[role]; !ok {
+		return fmt.Errorf("invalid role %v for %q", role, commonName)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.certs[commonName] = Token{Name: commonName, Role: role}
+	return nil
+}
+
+// SetLimit rate limits the requests of the named client
+func (t *Tokens) SetLimit(name string, l Limit) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.limits[name] = newBucket(l)
+}
+
+// Revoke removes every token and certificate of the named client
+func (t *Tokens) Revoke(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for k, v := range t.tokens {
+		if v.Name == name {
+			delete(t.tokens, k)
+		}
+	}
+	delete(t.certs, name)
+	delete(t.limits, name)
+}
+
+// Lookup returns the Token registered for secret
+func (t *Tokens) Lookup(secret string) (Token, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	result, ok := t.tokens[sha256.Sum256([]byte(secret))]
+	return result, ok
+}
+
+// MethodRole requires Reader access for safe methods such as GET, and
+// Operator access otherwise
+func MethodRole(req *http.Request) Role {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return Reader
+	}
+	return Operator
+}
+
+// Require returns a handler which passes requests carrying a token with at
+// least the supplied Role on to h
+func (t *Tokens) Require(role Role, h http.Handler) http.Handler {
+	return t.Guard(h, func(*http.Request) Role { return role })
+}
+
+// Guard returns a handler which passes requests on to h if they carry a
+// token or client certificate with at least the Role that required returns
+// for them. Requests without a known token are refused with 401
+// Unauthorized, those with too little access with 403 Forbidden, and those
+// over the client's rate limit with 429 Too Many Requests. The Token is
+// stored in the context of the request passed on, and its name recorded as
+// the "client" request metadata.
+func (t *Tokens) Guard(h http.Handler,
+	required func(*http.Request) Role) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token, ok := t.authenticate(req)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if token.Role < required(req) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if !t.allow(token.Name) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		ctx := context.WithValue(req.Context(), tokenKey{}, token)
+		ctx = nucleo.WithRequestMetadata(ctx, map[string]string{
+			"client": token.Name,
+		})
+		h.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// authenticate identifies the client of a request by its verified client
+// certificate, or failing that, by the token it supplies
+func (t *Tokens) authenticate(req *http.Request) (Token, bool) {
+	if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 {
+		cn := req.TLS.VerifiedChains[0][0].Subject.CommonName
+		t.mu.RLock()
+		token, ok := t.certs[cn]
+		t.mu.RUnlock()
+		if ok {
+			return token, true
+		}
+	}
+	s := secret(req)
+	if s == "" {
+		return Token{}, false
+	}
+	return t.Lookup(s)
+}
+
+// allow reports whether the named client is within its rate limit
+func (t *Tokens) allow(name string) bool {
+	t.mu.RLock()
+	b, ok := t.limits[name]
+	t.mu.RUnlock()
+	return !ok || b.allow(time.Now())
+}
+
+// secret returns the token supplied with a request
+func secret(req *http.Request) string {
+	if a := req.Header.Get("Authorization"); strings.HasPrefix(a,
+		bearerPrefix) {
+		return strings.TrimPrefix(a, bearerPrefix)
+	}
+	if k := req.Header.Get(apiKeyHeader); k != "" {
+		return k
+	}
+	return req.URL.Query().Get("token")
+}
@@ -0,0 +1,130 @@
+package access
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Config describes the clients permitted to use a handler, so that access
+// can be configured from a file rather than in code
+type Config struct {
+	// Keys are static API keys
+	Keys []KeyConfig `json:"keys"`
+
+	// ClientCA is the path of a PEM file of certificate authorities
+	// trusted to issue client certificates for mutual TLS
+	ClientCA string `json:"client_ca"`
+
+	// RequireClientCert refuses TLS connections without a valid client
+	// certificate, rather than falling back to API keys
+	RequireClientCert bool `json:"require_client_cert"`
+
+	// Certificates grant Roles to clients presenting certificates
+	Certificates []CertificateConfig `json:"certificates"`
+}
+
+// KeyConfig is a single API key. The key is given directly, or for
+// preference, as the name of an environment variable holding it.
+type KeyConfig struct {
+	Name   string `json:"name"`
+	Key    string `json:"key"`
+	KeyEnv string `json:"key_env"`
+	Role   string `json:"role"`
+	Limit  *Limit `json:"limit"`
+}
+
+// CertificateConfig grants a Role to the client whose certificate has the
+// supplied common name
+type CertificateConfig struct {
+	CommonName string `json:"common_name"`
+	Role       string `json:"role"`
+	Limit      *Limit `json:"limit"`
+}
+
+// LoadConfig reads a Config from the JSON file at path
+func LoadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var result Config
+	d := json.NewDecoder(f)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&result); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &result, nil
+}
+
+// Tokens returns the Tokens described by the Config
+func (c *Config) Tokens() (*Tokens, error) {
+	t := NewTokens()
+	for _, k := range c.Keys {
+		role, err := ParseRole(k.Role)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %v", k.Name, err)
+		}
+		key := k.Key
+		if k.KeyEnv != "" {
+			if key = os.Getenv(k.KeyEnv); key == "" {
+				return nil, fmt.Errorf("key %q: %s is not set",
+					k.Name, k.KeyEnv)
+			}
+		}
+		if err := t.Add(k.Name, key, role); err != nil {
+			return nil, err
+		}
+		if k.Limit != nil {
+			t.SetLimit(k.Name, *k.Limit)
+		}
+	}
+	for _, cc := range c.Certificates {
+		role, err := ParseRole(cc.Role)
+		if err != nil {
+			return nil, fmt.Errorf("certificate %q: %v",
+				cc.CommonName, err)
+		}
+		if err := t.AddCertificate(cc.CommonName, role); err != nil {
+			return nil, err
+		}
+		if cc.Limit != nil {
+			t.SetLimit(cc.CommonName, *cc.Limit)
+		}
+	}
+	return t, nil
+}
+
+// TLSConfig returns the server TLS configuration which verifies client
+// certificates against ClientCA, or nil if no ClientCA is configured. The
+// server's own certificates must be added by the caller.
+func (c *Config) TLSConfig() (*tls.Config, error) {
+	if c.ClientCA == "" {
+		if c.RequireClientCert {
+			return nil, fmt.Errorf("client certificates required " +
+				"but no client_ca given")
+		}
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(c.ClientCA)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("%s: no certificates found", c.ClientCA)
+	}
+	result := &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		MinVersion: tls.VersionTLS12,
+	}
+	if c.RequireClientCert {
+		result.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return result, nil
+}
@@ -0,0 +1,47 @@
+package access
+
+import (
+	"sync"
+	"time"
+)
+
+// Limit is a rate limit of Rate requests per second, with bursts of up to
+// Burst requests
+type Limit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// bucket is a token bucket enforcing a Limit
+type bucket struct {
+	mu     sync.Mutex
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(l Limit) *bucket {
+	if l.Burst < 1 {
+		l.Burst = 1
+	}
+	return &bucket{limit: l, tokens: float64(l.Burst)}
+}
+
+// allow reports whether a request may proceed at now, consuming a token if
+// so
+func (b *bucket) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+		if burst := float64(b.limit.Burst); b.tokens > burst {
+			b.tokens = burst
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,171 @@
+// audit keeps an append-only record of every command which changes a robot:
+// who or what issued it, with which parameters, the result and how long it
+// took. Robots are wrapped so that each integration records under its own
+// actor, e.g. "alexa" or "homeassistant", and the record can be queried over
+// HTTP to answer "who started the robot during the meeting?".
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Entry is a single audited command
+type Entry struct {
+	Time     time.Time         `json:"time"`
+	Actor    string            `json:"actor"`
+	Robot    string            `json:"robot"`
+	Command  string            `json:"command"`
+	Params   json.RawMessage   `json:"params,omitempty"`
+	Result   string            `json:"result,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Latency  time.Duration     `json:"latency"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Filter selects Entries. Empty fields match everything.
+type Filter struct {
+	Actor   string
+	Robot   string
+	Command string
+	From    time.Time
+	To      time.Time
+}
+
+// Match reports whether e is selected by the Filter
+func (f *Filter) Match(e *Entry) bool {
+	switch {
+	case f.Actor != "" && e.Actor != f.Actor:
+	case f.Robot != "" && e.Robot != f.Robot:
+	case f.Command != "" && e.Command != f.Command:
+	case !f.From.IsZero() && e.Time.Before(f.From):
+	case !f.To.IsZero() && !e.Time.Before(f.To):
+	default:
+		return true
+	}
+	return false
+}
+
+// Log is an append-only record of commands, held in memory and, optionally,
+// in a file
+type Log struct {
+	mu      sync.Mutex
+	entries []Entry
+	file    *os.File
+}
+
+// Open returns a Log persisted to the file at path, loading any entries
+// already recorded there. An empty path keeps the Log in memory only.
+func Open(path string) (*Log, error) {
+	l := &Log{}
+	if path == "" {
+		return l, nil
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		var e Entry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		l.entries = append(l.entries, e)
+	}
+	if err := s.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	l.file = f
+	return l, nil
+}
+
+// Close closes the Log's file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Record appends an Entry to the Log
+func (l *Log) Record(e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, e)
+	if l.file == nil {
+		return nil
+	}
+	b, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+	_, err = l.file.Write(append(b, '\n'))
+	return err
+}
+
+// Query returns the Entries selected by f, oldest first
+func (l *Log) Query(f Filter) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	result := []Entry{}
+	for i := range l.entries {
+		if f.Match(&l.entries[i]) {
+			result = append(result, l.entries[i])
+		}
+	}
+	return result
+}
+
+// ServeHTTP serves the Entries selected by the actor, robot, command, from
+// and to query parameters as JSON. Times are given in RFC 3339 format, and
+// the limit parameter keeps only the most recent Entries.
+func (l *Log) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	q := req.URL.Query()
+	f := Filter{Actor: q.Get("actor"), Robot: q.Get("robot"),
+		Command: q.Get("command")}
+	for _, t := range []struct {
+		name string
+		dest *time.Time
+	}{{"from", &f.From}, {"to", &f.To}} {
+		v := q.Get(t.name)
+		if v == "" {
+			continue
+		}
+		var err error
+		if *t.dest, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	result := l.Query(f)
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		if n < len(result) {
+			result = result[len(result)-n:]
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,163 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/richlj/neato/nucleo"
+)
+
+var (
+	_ nucleo.RobotService = (*Robot)(nil)
+)
+
+// Robot wraps a nucleo.RobotService, recording each command which changes
+// the robot in a Log under the Robot's actor. Read-only commands are passed
+// through unrecorded.
+type Robot struct {
+	nucleo.RobotService
+	log      *Log
+	actor    string
+	id       string
+	metadata map[string]string
+}
+
+// Wrap returns a Robot which records the commands issued to robot, identified
+// in the Log by id, as issued by actor
+func Wrap(robot nucleo.RobotService, log *Log, actor, id string) *Robot {
+	return &Robot{RobotService: robot, log: log, actor: actor, id: id}
+}
+
+// WithContext returns a copy of the Robot which records the request
+// metadata carried by ctx, as set by nucleo.WithRequestMetadata, with each
+// command
+func (r *Robot) WithContext(ctx context.Context) *Robot {
+	result := *r
+	result.metadata = nucleo.RequestMetadata(ctx)
+	return &result
+}
+
+// record calls f, recording it as cmd with the supplied parameters
+func (r *Robot) record(cmd string, params interface{},
+	f func() (*nucleo.Response, error)) (*nucleo.Response, error) {
+	start := time.Now()
+	resp, err := f()
+	e := Entry{
+		Time:     start,
+		Actor:    r.actor,
+		Robot:    r.id,
+		Command:  cmd,
+		Latency:  time.Since(start),
+		Metadata: r.metadata,
+	}
+	if params != nil {
+		e.Params, _ = json.Marshal(params)
+	}
+	if resp != nil {
+		e.Result = resp.Result.String()
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	if lerr := r.log.Record(e); lerr != nil && err == nil {
+		err = lerr
+	}
+	return resp, err
+}
+
+// call records a command taking Params
+func (r *Robot) call(cmd string, a *nucleo.Params,
+	f func(*nucleo.Params) (*nucleo.Response, error)) (*nucleo.Response,
+	error) {
+	var params interface{}
+	if a != nil {
+		params = a
+	}
+	return r.record(cmd, params, func() (*nucleo.Response, error) {
+		return f(a)
+	})
+}
+
+// StartCleaning records and issues a startCleaning command
+func (r *Robot) StartCleaning(a *nucleo.Params) (*nucleo.Response, error) {
+	return r.call("startCleaning", a, r.RobotService.StartCleaning)
+}
+
+// StopCleaning records and issues a stopCleaning command
+func (r *Robot) StopCleaning(a *nucleo.Params) (*nucleo.Response, error) {
+	return r.call("stopCleaning", a, r.RobotService.StopCleaning)
+}
+
+// PauseCleaning records and issues a pauseCleaning command
+func (r *Robot) PauseCleaning(a *nucleo.Params) (*nucleo.Response, error) {
+	return r.call("pauseCleaning", a, r.RobotService.PauseCleaning)
+}
+
+// ResumeCleaning records and issues a resumeCleaning command
+func (r *Robot) ResumeCleaning(a *nucleo.Params) (*nucleo.Response, error) {
+	return r.call("resumeCleaning", a, r.RobotService.ResumeCleaning)
+}
+
+// SendToBase records and issues a sendToBase command
+func (r *Robot) SendToBase(a *nucleo.Params) (*nucleo.Response, error) {
+	return r.call("sendToBase", a, r.RobotService.SendToBase)
+}
+
+// StartSpotCleaning records and issues a spot cleaning command
+func (r *Robot) StartSpotCleaning(width, height int,
+	repeat bool) (*nucleo.Response, error) {
+	params := map[string]interface{}{"spotWidth": width,
+		"spotHeight": height, "repeat": repeat}
+	return r.record("startCleaning", params,
+		func() (*nucleo.Response, error) {
+			return r.RobotService.StartSpotCleaning(width, height,
+				repeat)
+		})
+}
+
+// SetSchedule records and issues a setSchedule command
+func (r *Robot) SetSchedule(a *nucleo.Params) (*nucleo.Response, error) {
+	return r.call("setSchedule", a, r.RobotService.SetSchedule)
+}
+
+// EnableSchedule records and issues an enableSchedule command
+func (r *Robot) EnableSchedule(a *nucleo.Params) (*nucleo.Response, error) {
+	return r.call("enableSchedule", a, r.RobotService.EnableSchedule)
+}
+
+// DisableSchedule records and issues a disableSchedule command
+func (r *Robot) DisableSchedule(a *nucleo.Params) (*nucleo.Response, error) {
+	return r.call("disableSchedule", a, r.RobotService.DisableSchedule)
+}
+
+// SetMapBoundaries records and issues a setMapBoundaries command
+func (r *Robot) SetMapBoundaries(a *nucleo.Params) (*nucleo.Response,
+	error) {
+	return r.call("setMapBoundaries", a, r.RobotService.SetMapBoundaries)
+}
+
+// StartPersistentMapExploration records and issues a
+// startPersistentMapExploration command
+func (r *Robot) StartPersistentMapExploration(a *nucleo.Params) (
+	*nucleo.Response, error) {
+	return r.call("startPersistentMapExploration", a,
+		r.RobotService.StartPersistentMapExploration)
+}
+
+// SetPreferences records and issues a setPreferences command
+func (r *Robot) SetPreferences(a *nucleo.Params) (*nucleo.Response, error) {
+	return r.call("setPreferences", a, r.RobotService.SetPreferences)
+}
+
+// FindMe records and issues a findMe command
+func (r *Robot) FindMe(a *nucleo.Params) (*nucleo.Response, error) {
+	return r.call("findMe", a, r.RobotService.FindMe)
+}
+
+// DismissCurrentAlert records and issues a dismissCurrentAlert command
+func (r *Robot) DismissCurrentAlert(a *nucleo.Params) (*nucleo.Response,
+	error) {
+	return r.call("dismissCurrentAlert", a,
+		r.RobotService.DismissCurrentAlert)
+}
@@ -0,0 +1,288 @@
+// battery tracks the health of robots' batteries over weeks and months. A
+// Sampler records battery readings into a store.Store, Analyze estimates from
+// them how long a full charge now lasts compared with when tracking began,
+// and a Monitor raises an Event when the effective capacity falls below a
+// threshold, before the robot starts dying part way through its runs.
+//
+// The robots do not report their capacity directly. It is estimated from the
+// time to empty they report when running on battery, scaled to a full
+// charge.
+
+package battery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/richlj/neato/clock"
+	"github.com/richlj/neato/nucleo"
+	"github.com/richlj/neato/store"
+	"github.com/richlj/neato/watch"
+)
+
+const (
+	// minLevel is the lowest charge level from which the time to empty is
+	// scaled to a full charge, below which the estimate is too coarse
+	minLevel = 20
+
+	// minEstimates is the number of estimates needed in each window
+	minEstimates = 3
+
+	defaultWindow    = 7 * 24 * time.Hour
+	defaultThreshold = 0.7
+	daysPerMonth     = 30
+)
+
+// ErrInsufficientData is returned by Analyze when there are too few usable
+// samples to estimate a trend
+var ErrInsufficientData = errors.New("battery: insufficient data")
+
+// Robot is the subset of a nucleo.RobotService used to sample its battery
+type Robot interface {
+	GetGeneralInfo(a *nucleo.Params) (*nucleo.GeneralInfo, error)
+}
+
+// NewSample returns the BatterySample of the robot with the supplied serial
+// number described by info at the supplied time
+func NewSample(serial string, info *nucleo.GeneralInfo,
+	at time.Time) store.BatterySample {
+	return store.BatterySample{
+		Robot:        serial,
+		Time:         at,
+		Level:        info.Battery.Level,
+		Charging:     info.Battery.TimeToFullCharge > 0,
+		TimeToEmpty:  info.Battery.TimeToEmpty,
+		TimeToFull:   info.Battery.TimeToFullCharge,
+		TotalCharges: info.Battery.TotalCharges,
+	}
+}
+
+// Sampler records battery readings in a Store
+type Sampler struct {
+	Store store.Store
+
+	// Clock dates and paces the samples. It defaults to clock.Real.
+	Clock clock.Clock
+}
+
+// Sample reads and records the battery of the robot with the supplied serial
+// number
+func (s *Sampler) Sample(ctx context.Context, serial string,
+	r Robot) (store.BatterySample, error) {
+	info, err := r.GetGeneralInfo(nil)
+	if err != nil {
+		return store.BatterySample{}, err
+	}
+	b := NewSample(serial, info, clock.Or(s.Clock).Now())
+	return b, s.Store.AddBattery(ctx, b)
+}
+
+// Run samples each robot, keyed by serial number, every interval until ctx
+// is cancelled, passing failures to report, if not nil
+func (s *Sampler) Run(ctx context.Context, interval time.Duration,
+	robots map[string]Robot, report func(serial string, err error)) error {
+	t := clock.Or(s.Clock).NewTicker(interval)
+	defer t.Stop()
+	for {
+		for serial, r := range robots {
+			if _, err := s.Sample(ctx, serial, r); err != nil &&
+				report != nil {
+				report(serial, err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C():
+		}
+	}
+}
+
+// Trend is the estimated health of a battery
+type Trend struct {
+	// Baseline is the estimated runtime of a full charge in the first
+	// window of samples, and Current in the last
+	Baseline time.Duration
+	Current  time.Duration
+
+	// Capacity is Current as a fraction of Baseline
+	Capacity float64
+
+	// FadePerMonth is the fraction of the Baseline lost every 30 days,
+	// fitted to every estimate
+	FadePerMonth float64
+
+	// Cycles is the number of charges reported by the latest sample, and
+	// CyclesTracked the number since the first
+	Cycles        int
+	CyclesTracked int
+
+	// Since and Until are the times of the first and last samples
+	Since time.Time
+	Until time.Time
+}
+
+type estimate struct {
+	at      time.Time
+	runtime time.Duration
+}
+
+// Analyze estimates the Trend of a battery from its samples, comparing the
+// first and last windows of the period they cover. A zero window selects a
+// week.
+func Analyze(samples []store.BatterySample,
+	window time.Duration) (Trend, error) {
+	if window <= 0 {
+		window = defaultWindow
+	}
+	sorted := append([]store.BatterySample(nil), samples...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return sorted[a].Time.Before(sorted[b].Time)
+	})
+	var estimates []estimate
+	for _, b := range sorted {
+		if b.Charging || b.TimeToEmpty <= 0 || b.Level < minLevel {
+			continue
+		}
+		estimates = append(estimates, estimate{b.Time,
+			b.TimeToEmpty * 100 / time.Duration(b.Level)})
+	}
+	if len(estimates) < 2*minEstimates {
+		return Trend{}, ErrInsufficientData
+	}
+	first, last := estimates[0].at, estimates[len(estimates)-1].at
+	if last.Sub(first) < 2*window {
+		return Trend{}, ErrInsufficientData
+	}
+	var early, late []time.Duration
+	for _, e := range estimates {
+		switch {
+		case e.at.Before(first.Add(window)):
+			early = append(early, e.runtime)
+		case !e.at.Before(last.Add(-window)):
+			late = append(late, e.runtime)
+		}
+	}
+	if len(early) < minEstimates || len(late) < minEstimates {
+		return Trend{}, ErrInsufficientData
+	}
+	t := Trend{
+		Baseline: median(early),
+		Current:  median(late),
+		Since:    sorted[0].Time,
+		Until:    sorted[len(sorted)-1].Time,
+	}
+	// Some readings omit the charge count, so the first and last which
+	// report one are used
+	initial := -1
+	for _, b := range sorted {
+		if b.TotalCharges <= 0 {
+			continue
+		}
+		if initial < 0 {
+			initial = b.TotalCharges
+		}
+		t.Cycles = b.TotalCharges
+		t.CyclesTracked = b.TotalCharges - initial
+	}
+	t.Capacity = float64(t.Current) / float64(t.Baseline)
+	t.FadePerMonth = -slope(estimates, first) * daysPerMonth /
+		float64(t.Baseline)
+	return t, nil
+}
+
+// median returns the median of a, which it sorts
+func median(a []time.Duration) time.Duration {
+	sort.Slice(a, func(i, j int) bool { return a[i] < a[j] })
+	if len(a)%2 == 1 {
+		return a[len(a)/2]
+	}
+	return (a[len(a)/2-1] + a[len(a)/2]) / 2
+}
+
+// slope returns the least-squares slope of the estimated runtimes against
+// days since origin, in nanoseconds per day
+func slope(estimates []estimate, origin time.Time) float64 {
+	var sx, sy, sxx, sxy float64
+	n := float64(len(estimates))
+	for _, e := range estimates {
+		x := e.at.Sub(origin).Hours() / 24
+		y := float64(e.runtime)
+		sx, sy, sxx, sxy = sx+x, sy+y, sxx+x*x, sxy+x*y
+	}
+	d := n*sxx - sx*sx
+	if d == 0 {
+		return 0
+	}
+	return (n*sxy - sx*sy) / d
+}
+
+// Monitor raises an Event when a battery's effective capacity falls below a
+// threshold. Each robot is reported once until its capacity recovers, as
+// when its battery is replaced.
+type Monitor struct {
+	Store store.Store
+
+	// Threshold is the Capacity below which an Event is raised. It
+	// defaults to 0.7.
+	Threshold float64
+
+	// Window is passed to Analyze
+	Window time.Duration
+
+	// Notify receives the Events, e.g. to pass to a webhook.Dispatcher
+	Notify func(watch.Event)
+
+	// Clock dates the Events. It defaults to clock.Real.
+	Clock clock.Clock
+
+	mu      sync.Mutex
+	alerted map[string]bool
+}
+
+// Check analyses the samples of the robot with the supplied serial number
+// and name, notifying if its capacity has fallen below the threshold
+func (m *Monitor) Check(ctx context.Context, serial,
+	name string) (Trend, error) {
+	samples, err := m.Store.Battery(ctx, store.Query{Robot: serial})
+	if err != nil {
+		return Trend{}, err
+	}
+	t, err := Analyze(samples, m.Window)
+	if err != nil {
+		return Trend{}, err
+	}
+	threshold := m.Threshold
+	if threshold <= 0 {
+		threshold = defaultThreshold
+	}
+	m.mu.Lock()
+	if m.alerted == nil {
+		m.alerted = make(map[string]bool)
+	}
+	degraded := t.Capacity < threshold
+	notify := degraded && !m.alerted[serial]
+	m.alerted[serial] = degraded
+	m.mu.Unlock()
+	if notify && m.Notify != nil {
+		msg := fmt.Sprintf("battery capacity is %d%% of its baseline "+
+			"(%d min of %d min on a full charge) after %d charges",
+			int(math.Round(t.Capacity*100)),
+			int(t.Current.Round(time.Minute).Minutes()),
+			int(t.Baseline.Round(time.Minute).Minutes()), t.Cycles)
+		m.Notify(watch.Event{
+			Type:    watch.BatteryDegraded,
+			Robot:   serial,
+			Name:    name,
+			Time:    clock.Or(m.Clock).Now(),
+			Message: msg,
+			Summary: name + ": " + msg,
+		})
+	}
+	return t, nil
+}
@@ -0,0 +1,132 @@
+//go:build !neato_lite
+
+// ExportAccount copies everything Neato holds about an account into a local
+// directory tree:
+//
+//	user.json
+//	robots/<serial>/robot.json
+//	robots/<serial>/schedule.json
+//	robots/<serial>/maps/<id>.json and <id>.png
+//	robots/<serial>/persistent_maps/<id>.json and <id>.png
+//
+// The robot records include their secret keys, so files are created readable
+// by the owner only.
+
+package beehive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/richlj/neato/nucleo"
+)
+
+const (
+	exportDirMode  = 0700
+	exportFileMode = 0600
+)
+
+type scheduleExport struct {
+	Enabled bool           `json:"enabled"`
+	Events  []nucleo.Event `json:"events"`
+}
+
+// ExportAccount writes the account's user profile, robot records, maps,
+// persistent maps and schedules beneath dir. Map images which have expired
+// or cannot be fetched are skipped, as are the schedules of robots which
+// cannot be reached.
+func (s *Session) ExportAccount(ctx context.Context, dir string) error {
+	u, err := s.GetUser()
+	if err != nil {
+		return err
+	}
+	if err := writeJSON(filepath.Join(dir, "user.json"), u); err != nil {
+		return err
+	}
+	robots, err := s.ListRobots()
+	if err != nil {
+		return err
+	}
+	for i := range robots {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.exportRobot(ctx, dir, &robots[i]); err != nil {
+			return fmt.Errorf("robot %s: %v", robots[i].Serial, err)
+		}
+	}
+	return nil
+}
+
+func (s *Session) exportRobot(ctx context.Context, dir string,
+	r *Robot) error {
+	dir = filepath.Join(dir, "robots", r.Serial)
+	if err := writeJSON(filepath.Join(dir, "robot.json"), r); err != nil {
+		return err
+	}
+	if resp, err := r.GetSchedule(nil); err == nil {
+		if err := writeJSON(filepath.Join(dir, "schedule.json"),
+			&scheduleExport{Enabled: resp.Data.Enabled,
+				Events: resp.Data.Events}); err != nil {
+			return err
+		}
+	}
+	maps, err := s.ListRobotMaps(r.Serial)
+	if err != nil {
+		return err
+	}
+	if err := s.exportMaps(ctx, filepath.Join(dir, "maps"),
+		maps.Maps); err != nil {
+		return err
+	}
+	persistent, err := s.ListRobotPersistentMaps(r.Serial)
+	if err != nil {
+		return err
+	}
+	return s.exportMaps(ctx, filepath.Join(dir, "persistent_maps"),
+		persistent)
+}
+
+func (s *Session) exportMaps(ctx context.Context, dir string,
+	maps []Map) error {
+	for _, m := range maps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := writeJSON(filepath.Join(dir, m.ID+".json"), &m)
+		if err != nil {
+			return err
+		}
+		if m.URL == "" {
+			continue
+		}
+		b, err := s.download(m.URL)
+		if err != nil {
+			continue
+		}
+		err = writeFile(filepath.Join(dir, m.ID+imageExt(m.URL)), b)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeJSON(name string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(name, b)
+}
+
+func writeFile(name string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), exportDirMode); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, b, exportFileMode)
+}
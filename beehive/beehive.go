@@ -0,0 +1,428 @@
+// The Beehive API is used to interact with user and robot data. Amongst other
+// things, it supplies the Robot SecretKeys required to authenticate with the
+// Nucleo API.
+
+package beehive
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/richlj/neato/clock"
+	"github.com/richlj/neato/decode"
+	"github.com/richlj/neato/egress"
+	"github.com/richlj/neato/nucleo"
+)
+
+const (
+	beehiveAcceptHeader = "application/vnd.neato.beehive.v1+json"
+	beehiveHost         = "beehive.neatocloud.com"
+
+	scheme      = "https"
+	platform    = "ios"
+	tokenLength = 32
+	dateFormat  = "2006-01-02"
+
+	// Addr is the network address of the Beehive API
+	Addr = beehiveHost + ":443"
+)
+
+var (
+	scopes = []string{"maps", "public_profile", "control_robots"}
+)
+
+func (t *token) queryValues(c *credentials) *url.Values {
+	return &url.Values{
+		"platform": []string{platform},
+		"token":    []string{t.String()},
+		"email":    []string{c.Username},
+		"password": []string{c.Password},
+	}
+}
+
+// NewSession generates a new Session for use with the Neato Beehive API,
+// configured with the supplied Options
+func NewSession(opts ...Option) (*Session, error) {
+	s := &Session{client: http.Client{Transport: egress.Transport()}}
+	for _, o := range opts {
+		o(s)
+	}
+	if err := s.Refresh(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Refresh updates a *Session's authentication data
+func (s *Session) Refresh() error {
+	t, err := newToken()
+	if err != nil {
+		return err
+	}
+	c, err := s.getCredentials()
+	if err != nil {
+		return err
+	}
+	u := s.url("sessions")
+	u.RawQuery = t.queryValues(c).Encode()
+	req, err := http.NewRequest(http.MethodPost, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", s.Version.acceptHeader())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		if uerr, ok := err.(*url.Error); ok {
+			// the query carries the account's credentials
+			uerr.URL = s.url("sessions").String()
+		}
+		return s.wrap(http.MethodPost, "sessions", "", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		err := &nucleo.StatusError{Code: resp.StatusCode,
+			Status: resp.Status}
+		return s.wrap(http.MethodPost, "sessions", "", err)
+	}
+	return s.wrap(http.MethodPost, "sessions", "",
+		decode.Decode(resp.Body, s))
+}
+
+// Session contains HTTP session data for use with the Neato Beehive API
+type Session struct {
+	AccessToken string     `json:"access_token"`
+	CurrentTime time.Time  `json:"current_time"`
+	Version     APIVersion `json:"-"`
+
+	client       http.Client
+	robotOptions []nucleo.Option
+	robotCache   *robotCache
+	robotStore   RobotStore
+	credentials  *credentials
+	endpoint     *url.URL
+	clock        clock.Clock
+
+	snapshotWorkers int
+}
+
+// User is a user on the Neato systems with access to zero or more resources
+type User struct {
+	ID          string    `json:"id"`
+	FirstName   string    `json:"first_name"`
+	LastName    string    `json:"last_name"`
+	Company     string    `json:"company"`
+	Locale      string    `json:"locale"`
+	PhoneNumber string    `json:"phone_number"`
+	Street1     string    `json:"street_1"`
+	Street2     string    `json:"street_2"`
+	City        string    `json:"city"`
+	PostCode    string    `json:"post_code"`
+	Province    string    `json:"province"`
+	StateRegion string    `json:"state_region"`
+	CountryCode string    `json:"country_code"`
+	Developer   bool      `json:"developer"`
+	Email       string    `json:"email"`
+	Newsletter  bool      `json:"newsletter"`
+	CreatedAt   time.Time `json:"created_at"`
+	VerifiedAt  time.Time `json:"verified_at"`
+}
+
+func newToken() (*token, error) {
+	key := make([]byte, tokenLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	hexKey := make([]byte, hex.EncodedLen(len(key)))
+	_ = hex.Encode(hexKey, key)
+	return &token{hexKey}, nil
+}
+
+type token struct {
+	value []byte
+}
+
+func (t *token) String() string {
+	if t != nil {
+		return string(t.value)
+	}
+	return ""
+}
+
+// url returns the URL of the Beehive resource at p, relative to the
+// Session's endpoint if one is set
+func (s *Session) url(p string) *url.URL {
+	u := s.base()
+	u.Path = path.Join(u.Path, p)
+	return u
+}
+
+// base returns a copy of the base URL of the Session's requests
+func (s *Session) base() *url.URL {
+	if s.endpoint == nil {
+		return &url.URL{Scheme: scheme, Host: beehiveHost}
+	}
+	u := *s.endpoint
+	return &u
+}
+
+func (s *Session) bearer() string {
+	return fmt.Sprintf("Bearer %s", s.AccessToken)
+}
+
+// A Robot corresponds to the data and controls for a physical robot. The
+// embedded nucleo.Robot carries the identity and secret key used to issue it
+// commands.
+type Robot struct {
+	nucleo.Robot
+	Prefix      string    `json:"prefix"`
+	PurchasedAt time.Time `json:"purchased_at"`
+	LinkedAt    time.Time `json:"linked_at"`
+	Traits      []string  `json:"traits"`
+
+	// LatestFirmware is the newest firmware available to the robot
+	LatestFirmware string `json:"latest_firmware"`
+	MACAddress     string `json:"mac_address"`
+	Vendor         string `json:"vendor"`
+	BirthDate      Date   `json:"birth_date"`
+
+	// TimezoneName is the IANA name of the robot's timezone, e.g.
+	// "Europe/London", if it has been set. See Timezone.
+	TimezoneName string `json:"timezone"`
+
+	// session is the Session which listed the Robot, if any
+	session *Session
+}
+
+// Timezone returns the location in which the Robot reports its schedule and
+// history, or time.UTC if its timezone has never been set
+func (r *Robot) Timezone() (*time.Location, error) {
+	if r.TimezoneName == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(r.TimezoneName)
+	if err != nil {
+		return nil, fmt.Errorf("robot timezone: %v", err)
+	}
+	return loc, nil
+}
+
+// LatestFirmwareVersion parses LatestFirmware, reporting false if the Robot
+// did not report one or it is malformed
+func (r *Robot) LatestFirmwareVersion() (nucleo.FirmwareVersion, bool) {
+	v, err := nucleo.ParseFirmwareVersion(r.LatestFirmware)
+	return v, err == nil
+}
+
+// Date is a time which Beehive gives as either an RFC 3339 timestamp or a
+// bare date such as "2017-06-01"
+type Date struct {
+	time.Time
+}
+
+// UnmarshalJSON decodes a Date from either form
+func (d *Date) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		d.Time = time.Time{}
+		return nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, dateFormat} {
+		if t, err := time.Parse(layout, s); err == nil {
+			d.Time = t
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid date %q", s)
+}
+
+// MarshalJSON encodes a Date as an RFC 3339 timestamp
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte(`""`), nil
+	}
+	return json.Marshal(d.Time.Format(time.RFC3339))
+}
+
+func (s *Session) setHeaders(req *http.Request) {
+	req.Header.Set("Accept", s.Version.acceptHeader())
+	req.Header.Set("Authorization", s.bearer())
+}
+
+// MapsResult contains details about the maps available on a Robot
+type MapsResult struct {
+	Stats struct{} `json:"stats"`
+	Maps  []Map    `json:"maps"`
+}
+
+// Map is a single map, as stored on a Robot
+type Map struct {
+	Version                        int       `json:"version"`
+	ID                             string    `json:"id"`
+	URL                            string    `json:"url"`
+	URLValidForSeconds             int       `json:"url_valid_for_seconds"`
+	RunID                          string    `json:"run_id"`
+	Status                         string    `json:"status"`
+	LaunchedFrom                   string    `json:"launched_from"`
+	Error                          string    `json:"error"`
+	Category                       int       `json:"category"`
+	Mode                           int       `json:"mode"`
+	Modifier                       int       `json:"modifier"`
+	StartAt                        time.Time `json:"start_at"`
+	EndAt                          time.Time `json:"end_at"`
+	EndOrientationRelativeDegrees  int       `json:"end_orientation_relative_degrees"`
+	RunChargeAtStart               int       `json:"run_charge_at_start"`
+	RunChargeAtEnd                 int       `json:"run_charge_at_end"`
+	SuspendedCleaningChargingCount int       `json:"suspended_cleaning_charging_count"`
+	TimeInSuspendedCleaning        int       `json:"time_in_suspended_cleaning"`
+	TimeInError                    int       `json:"time_in_error"`
+	TimeInPause                    int       `json:"time_in_pause"`
+	CleanedArea                    float64   `json:"cleaned_area"`
+	BaseCount                      int       `json:"base_count"`
+	IsDocked                       bool      `json:"is_docked"`
+	Delocalized                    bool      `json:"delocalized"`
+}
+
+func (s *Session) exec(method, path string) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.url(path).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	s.setHeaders(req)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// get decodes the resource at p into v. robot is the serial number in p, if
+// any, which is masked in the *RequestError returned on failure.
+func (s *Session) get(p, robot string, v interface{}) error {
+	r, err := s.exec(http.MethodGet, p)
+	if err != nil {
+		return s.wrap(http.MethodGet, p, robot, err)
+	}
+	defer r.Body.Close()
+	if r.StatusCode/100 != 2 {
+		return s.wrap(http.MethodGet, p, robot, &nucleo.StatusError{
+			Code: r.StatusCode, Status: r.Status})
+	}
+	return s.wrap(http.MethodGet, p, robot, decode.Decode(r.Body, v))
+}
+
+// send sends in, as JSON, to the resource at p with method, decoding the
+// response into out if it is not nil. robot is the serial number in p, if
+// any.
+func (s *Session) send(method, p, robot string, in,
+	out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, s.url(p).String(),
+		bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	s.setHeaders(req)
+	req.Header.Set("Content-Type", "application/json")
+	r, err := s.client.Do(req)
+	if err != nil {
+		return s.wrap(method, p, robot, err)
+	}
+	defer r.Body.Close()
+	if r.StatusCode/100 != 2 {
+		return s.wrap(method, p, robot, &nucleo.StatusError{
+			Code: r.StatusCode, Status: r.Status})
+	}
+	if out == nil {
+		return nil
+	}
+	return s.wrap(method, p, robot, decode.Decode(r.Body, out))
+}
+
+// GetRobotMap retrieves a particular Map from a specific Robot
+func (s *Session) GetRobotMap(robot, id string) (*Map, error) {
+	var result Map
+	if err := s.get(path.Join("users/me/robots", robot, "maps", id), robot,
+		&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetUser returns the User for the account
+func (s *Session) GetUser() (*User, error) {
+	var result User
+	if err := s.get("users/me", "", &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListRobots returns the Robots for the account
+func (s *Session) ListRobots() ([]Robot, error) {
+	if s.robotCache != nil {
+		s.loadRobots()
+		if result, ok := s.robotCache.get(s.now()); ok {
+			return result, nil
+		}
+	}
+	return s.fetchRobots()
+}
+
+func (s *Session) fetchRobots() ([]Robot, error) {
+	var result []Robot
+	if err := s.get("users/me/robots", "", &result); err != nil {
+		return nil, err
+	}
+	for i := range result {
+		s.attach(&result[i])
+	}
+	if s.robotCache != nil {
+		now := s.now()
+		s.robotCache.put(result, now)
+		if s.robotStore != nil {
+			// if saving fails, they are fetched again on restart
+			_ = s.robotStore.SaveRobots(result, now)
+		}
+	}
+	return result, nil
+}
+
+// attach configures r, listed by s, to be issued commands
+func (s *Session) attach(r *Robot) {
+	r.Configure(nucleo.WithKeyRefresh(s.robotKey))
+	r.Configure(s.robotOptions...)
+	r.session = s
+}
+
+// ListRobotMaps returns the maps for the specified robot
+func (s *Session) ListRobotMaps(robot string) (*MapsResult, error) {
+	var result MapsResult
+	if err := s.get(path.Join("users/me/robots", robot, "maps"), robot,
+		&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListRobotPersistentMaps returns the persistent maps for the specified Robot
+func (s *Session) ListRobotPersistentMaps(robot string) ([]Map, error) {
+	var result []Map
+	if err := s.get(path.Join("users/me/robots", robot, "persistent_maps"),
+		robot, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,37 @@
+package beehive
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/richlj/neato/nucleo"
+)
+
+func TestRefreshStatus(t *testing.T) {
+	tests := []struct {
+		code      int32
+		auth      bool
+		retryable bool
+	}{
+		{http.StatusUnauthorized, true, false},
+		{http.StatusNotFound, false, false},
+		{http.StatusServiceUnavailable, false, true},
+	}
+	f := &fakeBeehive{}
+	s := newTestSession(t, f)
+	for _, tt := range tests {
+		atomic.StoreInt32(&f.sessionStatus, tt.code)
+		err := s.Refresh()
+		if err == nil {
+			t.Errorf("%d: Refresh() succeeded", tt.code)
+			continue
+		}
+		auth := nucleo.IsAuthError(err)
+		retryable := nucleo.IsRetryable(err)
+		if auth != tt.auth || retryable != tt.retryable {
+			t.Errorf("%d: Refresh() = %v, auth %t, retryable %t",
+				tt.code, err, auth, retryable)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+// Currently this SDK only integrates with `github.com/richlj/passlib`, which
+// is unavailable in the browser. There, credentials must be supplied with
+// WithCredentials.
+
+package beehive
+
+type credentials struct {
+	Username string
+	Password string
+}
+
+// getCredentials returns the credentials supplied to the Session, or failing
+// that, those in the credential store
+func (s *Session) getCredentials() (*credentials, error) {
+	if s.credentials != nil {
+		return s.credentials, nil
+	}
+	return getCredentials()
+}
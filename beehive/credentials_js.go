@@ -0,0 +1,12 @@
+//go:build js
+
+package beehive
+
+import (
+	"errors"
+)
+
+func getCredentials() (*credentials, error) {
+	return nil, errors.New("no credential store is available in the " +
+		"browser; supply credentials with WithCredentials")
+}
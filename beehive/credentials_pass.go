@@ -1,6 +1,6 @@
-// Currently this SDK only integrates with `github.com/richlj/passlib`
+//go:build !js
 
-package neato
+package beehive
 
 import (
 	"github.com/richlj/passlib"
@@ -10,11 +10,6 @@ var (
 	credentialsPassRE = ".*neatorobotics.*/.*"
 )
 
-type credentials struct {
-	Username string
-	Password string
-}
-
 func getCredentials() (*credentials, error) {
 	return getCredentialsPass()
 }
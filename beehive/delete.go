@@ -0,0 +1,79 @@
+// Deleting an account or unlinking a robot cannot be undone, so both require
+// a Confirmation naming what is to be removed. Nucleo offers third-party
+// clients no factory or wifi reset commands, so a robot being passed on must
+// still be reset from its own menu once unlinked.
+
+package beehive
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/richlj/neato/nucleo"
+)
+
+var (
+	// ErrNotConfirmed is returned when a destructive operation is requested
+	// without a matching Confirmation
+	ErrNotConfirmed = errors.New("operation not confirmed")
+)
+
+// Confirmation authorises a destructive operation
+type Confirmation struct {
+	email  string
+	serial string
+}
+
+// ConfirmAccount confirms the deletion of the account with the supplied email
+// address
+func ConfirmAccount(email string) Confirmation {
+	return Confirmation{email: email}
+}
+
+// ConfirmRobot confirms the unlinking of the Robot with the supplied serial
+func ConfirmRobot(serial string) Confirmation {
+	return Confirmation{serial: serial}
+}
+
+// DeleteAccount permanently deletes the account and its data. c must be a
+// ConfirmAccount naming the account's email address.
+func (s *Session) DeleteAccount(c Confirmation) error {
+	u, err := s.GetUser()
+	if err != nil {
+		return err
+	}
+	if c.email == "" || !strings.EqualFold(c.email, u.Email) {
+		return ErrNotConfirmed
+	}
+	return s.delete("users/me", "")
+}
+
+// UnlinkRobot removes the Robot with the supplied serial from the account. c
+// must be a ConfirmRobot naming the same serial.
+func (s *Session) UnlinkRobot(serial string, c Confirmation) error {
+	if serial == "" || !strings.EqualFold(c.serial, serial) {
+		return ErrNotConfirmed
+	}
+	if err := s.delete(path.Join("users/me/robots", serial),
+		serial); err != nil {
+		return err
+	}
+	s.clearRobots()
+	return nil
+}
+
+// delete removes the resource at p. robot is the serial number in p, if any.
+func (s *Session) delete(p, robot string) error {
+	resp, err := s.exec(http.MethodDelete, p)
+	if err != nil {
+		return s.wrap(http.MethodDelete, p, robot, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return s.wrap(http.MethodDelete, p, robot, &nucleo.StatusError{
+			Code: resp.StatusCode, Status: resp.Status})
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package beehive
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/richlj/neato/nucleo"
+)
+
+// RequestError records the method, resource and endpoint of a failed Beehive
+// request. The underlying error is available to errors.Is and errors.As.
+type RequestError struct {
+	Method string
+	// Path is the resource requested, with any robot serial number masked
+	// by nucleo.MaskSerial, so that the error may be logged
+	Path     string
+	Endpoint string
+	Err      error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("beehive %s %s at %s: %v", e.Method, e.Path,
+		e.Endpoint, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// wrap returns err annotated with the request it arose from, or nil if err
+// is nil. robot is the serial number in p, if any.
+func (s *Session) wrap(method, p, robot string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if robot != "" {
+		p = strings.Replace(p, robot, nucleo.MaskSerial(robot), -1)
+	}
+	return &RequestError{
+		Method:   method,
+		Path:     p,
+		Endpoint: s.url("").Host,
+		Err:      err,
+	}
+}
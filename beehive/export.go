@@ -0,0 +1,221 @@
+//go:build !neato_lite
+
+// A run can be exported as a zip archive holding its map image, metadata,
+// computed statistics and the boundaries of the robot's floor plans, for
+// sharing in support requests or archiving. The archive's manifest.json
+// lists its contents with their checksums.
+
+package beehive
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/richlj/neato/nucleo"
+)
+
+const (
+	manifestVersion = 1
+)
+
+// Manifest describes the contents of a run archive
+type Manifest struct {
+	Version int            `json:"version"`
+	Robot   string         `json:"robot"`
+	RunID   string         `json:"run_id"`
+	Created time.Time      `json:"created"`
+	Files   []ManifestFile `json:"files"`
+	Notes   []string       `json:"notes,omitempty"`
+}
+
+// ManifestFile is a single file in a run archive
+type ManifestFile struct {
+	Name   string `json:"name"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// RunStats are the statistics computed for an exported run
+type RunStats struct {
+	Summary            string  `json:"summary"`
+	AreaSquareMeters   float64 `json:"area_square_meters"`
+	DurationSeconds    float64 `json:"duration_seconds"`
+	SquareMetersPerMin float64 `json:"square_meters_per_minute"`
+	ChargeUsed         int     `json:"charge_used"`
+	Recharges          int     `json:"recharges"`
+	SuspendedSeconds   int     `json:"suspended_seconds"`
+	PausedSeconds      int     `json:"paused_seconds"`
+	ErrorSeconds       int     `json:"error_seconds"`
+}
+
+type archive struct {
+	zip      *zip.Writer
+	manifest *Manifest
+}
+
+// ExportRun writes a zip archive of the run with the supplied ID, performed
+// by the Robot with the supplied serial, to w. Parts of the archive which
+// cannot be fetched, such as the boundaries of a robot without persistent
+// maps, are recorded as notes in the manifest rather than failing the export.
+func (s *Session) ExportRun(robot, runID string, w io.Writer) error {
+	m, err := s.findRun(robot, runID)
+	if err != nil {
+		return err
+	}
+	a := &archive{zip: zip.NewWriter(w), manifest: &Manifest{
+		Version: manifestVersion,
+		Robot:   robot,
+		RunID:   runID,
+		Created: s.now().UTC(),
+	}}
+	if err := a.addJSON("run.json", m); err != nil {
+		return err
+	}
+	if err := a.addJSON("stats.json", newRunStats(robot, m)); err != nil {
+		return err
+	}
+	if err := s.addMapImage(a, m); err != nil {
+		return err
+	}
+	if err := s.addBoundaries(a, robot); err != nil {
+		return err
+	}
+	if err := a.addJSON("manifest.json", a.manifest); err != nil {
+		return err
+	}
+	return a.zip.Close()
+}
+
+// findRun returns the Map of the identified run
+func (s *Session) findRun(robot, runID string) (*Map, error) {
+	maps, err := s.ListRobotMaps(robot)
+	if err != nil {
+		return nil, err
+	}
+	for i := range maps.Maps {
+		if maps.Maps[i].RunID == runID || maps.Maps[i].ID == runID {
+			return &maps.Maps[i], nil
+		}
+	}
+	return nil, fmt.Errorf("run %s not found for robot %s", runID, robot)
+}
+
+func newRunStats(robot string, m *Map) *RunStats {
+	r := NewRunReport(robot, m)
+	s := &RunStats{
+		Summary:          r.Summary(),
+		AreaSquareMeters: r.Area.SquareMeters(),
+		DurationSeconds:  time.Duration(r.Duration).Seconds(),
+		ChargeUsed:       m.RunChargeAtStart - m.RunChargeAtEnd,
+		Recharges:        r.Recharges,
+		SuspendedSeconds: m.TimeInSuspendedCleaning,
+		PausedSeconds:    m.TimeInPause,
+		ErrorSeconds:     m.TimeInError,
+	}
+	if min := r.Duration.Minutes(); min > 0 {
+		s.SquareMetersPerMin = s.AreaSquareMeters / min
+	}
+	return s
+}
+
+func (s *Session) addMapImage(a *archive, m *Map) error {
+	if m.URL == "" {
+		a.note("the run has no map image")
+		return nil
+	}
+	b, err := s.download(m.URL)
+	if err != nil {
+		a.note(fmt.Sprintf("map image not fetched: %v", err))
+		return nil
+	}
+	return a.add("map"+imageExt(m.URL), b)
+}
+
+// download fetches a map image from the presigned URL given in a Map
+func (s *Session) download(u string) ([]byte, error) {
+	resp, err := s.client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (s *Session) addBoundaries(a *archive, robot string) error {
+	maps, err := s.ListRobotPersistentMaps(robot)
+	if err != nil || len(maps) == 0 {
+		a.note("the robot has no persistent maps")
+		return nil
+	}
+	r, err := s.findRobot(robot)
+	if err != nil {
+		a.note(fmt.Sprintf("boundaries not fetched: %v", err))
+		return nil
+	}
+	for _, m := range maps {
+		b, err := r.Boundaries(m.ID)
+		if err != nil {
+			a.note(fmt.Sprintf("boundaries of map %s not "+
+				"fetched: %v", m.ID, err))
+			continue
+		}
+		if b == nil {
+			b = []nucleo.Boundary{}
+		}
+		if err := a.addJSON(path.Join("boundaries", m.ID+".json"),
+			b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func imageExt(u string) string {
+	p, err := url.Parse(u)
+	if err == nil {
+		if ext := path.Ext(p.Path); ext != "" {
+			return ext
+		}
+	}
+	return ".png"
+}
+
+func (a *archive) addJSON(name string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return a.add(name, b)
+}
+
+func (a *archive) add(name string, b []byte) error {
+	f, err := a.zip.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(b)
+	a.manifest.Files = append(a.manifest.Files, ManifestFile{
+		Name:   name,
+		Size:   len(b),
+		SHA256: hex.EncodeToString(sum[:]),
+	})
+	return nil
+}
+
+func (a *archive) note(s string) {
+	a.manifest.Notes = append(a.manifest.Notes, s)
+}
@@ -0,0 +1,45 @@
+// Linking adds a new robot to the account, given the serial number and
+// secret printed under its dustbin or obtained during the setup flow, so
+// that units can be provisioned without the mobile app. The robot must
+// already be connected to the network for Nucleo to reach it afterwards.
+
+package beehive
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Link describes a robot to link to the account
+type Link struct {
+	Serial    string `json:"serial"`
+	SecretKey string `json:"secret_key"`
+
+	// Name and Model are optional. Beehive names unnamed robots after
+	// their model.
+	Name  string `json:"name,omitempty"`
+	Model string `json:"model,omitempty"`
+}
+
+// LinkRobot links the described robot to the account, returning it ready to
+// be issued commands
+func (s *Session) LinkRobot(l Link) (*Robot, error) {
+	if l.Serial == "" || l.SecretKey == "" {
+		return nil, errors.New("a serial and secret key are " +
+			"required to link a robot")
+	}
+	var result Robot
+	if err := s.send(http.MethodPost, "users/me/robots", "", &l,
+		&result); err != nil {
+		return nil, err
+	}
+	if result.Serial == "" {
+		result.Serial = l.Serial
+	}
+	if result.SecretKey == "" {
+		result.SecretKey = l.SecretKey
+	}
+	s.attach(&result)
+	s.clearRobots()
+	return &result, nil
+}
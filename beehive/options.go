@@ -0,0 +1,101 @@
+package beehive
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/richlj/neato/clock"
+	"github.com/richlj/neato/nucleo"
+)
+
+// The supported versions of the Beehive API payloads
+const (
+	V1 APIVersion = 1
+	V2 APIVersion = 2
+)
+
+// APIVersion selects the version of the payloads exchanged with the Beehive
+// API. The zero value selects V1.
+type APIVersion int
+
+// acceptHeader returns the Accept header value which requests v
+func (v APIVersion) acceptHeader() string {
+	if v <= V1 {
+		return beehiveAcceptHeader
+	}
+	return fmt.Sprintf("%s;version=%d", beehiveAcceptHeader, v)
+}
+
+// Option configures a Session
+type Option func(*Session)
+
+// WithAPIVersion selects the version of the Beehive API payloads used by the
+// Session
+func WithAPIVersion(v APIVersion) Option {
+	return func(s *Session) {
+		s.Version = v
+	}
+}
+
+// WithRobotOptions configures every Robot listed by the Session with the
+// supplied Options, e.g. nucleo.WithQuietHours
+func WithRobotOptions(opts ...nucleo.Option) Option {
+	return func(s *Session) {
+		s.robotOptions = append(s.robotOptions, opts...)
+	}
+}
+
+// WithCredentials supplies the account's username and password directly,
+// rather than fetching them from the credential store. It is required where
+// no store is available, such as in a browser.
+func WithCredentials(username, password string) Option {
+	return func(s *Session) {
+		s.credentials = &credentials{Username: username,
+			Password: password}
+	}
+}
+
+// WithEndpoint sends the Session's requests to the supplied base URL rather
+// than to the Beehive API directly, e.g. to a CORS proxy when the SDK runs in
+// a browser
+func WithEndpoint(u *url.URL) Option {
+	return func(s *Session) {
+		s.endpoint = u
+	}
+}
+
+// WithScheme sends the Session's requests with the supplied URL scheme, e.g.
+// "http" for a test server
+func WithScheme(scheme string) Option {
+	return func(s *Session) {
+		u := s.base()
+		u.Scheme = scheme
+		s.endpoint = u
+	}
+}
+
+// WithHost sends the Session's requests to the supplied host and port rather
+// than to the Beehive API, e.g. through a corporate egress proxy
+func WithHost(host string) Option {
+	return func(s *Session) {
+		u := s.base()
+		u.Host = host
+		s.endpoint = u
+	}
+}
+
+// WithClock supplies the Clock used to expire the robot cache and date
+// snapshots and exports, e.g. a clock.Fake in tests. Robots listed by the
+// Session are configured with the same Clock.
+func WithClock(c clock.Clock) Option {
+	return func(s *Session) {
+		s.clock = c
+		s.robotOptions = append(s.robotOptions, nucleo.WithClock(c))
+	}
+}
+
+// now returns the current time according to the Session's Clock
+func (s *Session) now() time.Time {
+	return clock.Or(s.clock).Now()
+}
@@ -0,0 +1,140 @@
+// The robot list, including each Robot's SecretKey, can be cached by the
+// Session and kept in a RobotStore across restarts. Robots listed by a
+// Session also ask it for their current key when Nucleo rejects a signature,
+// so that a rotated key is picked up without the caller relisting robots.
+
+package beehive
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RobotStore keeps the robot list cached by WithRobotCache, including each
+// Robot's SecretKey, so that it survives restarts. store.RobotTokens keeps it
+// among the tokens of a store.Store.
+type RobotStore interface {
+	// LoadRobots returns the stored robots and the time at which they
+	// were fetched, or no robots if none are stored
+	LoadRobots() ([]Robot, time.Time, error)
+
+	// SaveRobots stores robots, fetched at t, replacing any stored before
+	SaveRobots(robots []Robot, t time.Time) error
+}
+
+type robotCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	robots  []Robot
+	fetched time.Time
+	// loaded is set once the cache no longer needs filling from the
+	// RobotStore
+	loaded bool
+}
+
+// WithRobotCache serves ListRobots from memory for ttl after each fetch
+func WithRobotCache(ttl time.Duration) Option {
+	return func(s *Session) {
+		s.robotCache = &robotCache{ttl: ttl}
+	}
+}
+
+// WithRobotStore keeps the robot cache in rs: it is filled from rs when
+// first used, and saved to rs after each fetch. It has no effect unless
+// WithRobotCache is also supplied.
+func WithRobotStore(rs RobotStore) Option {
+	return func(s *Session) {
+		s.robotStore = rs
+	}
+}
+
+// loadRobots fills the robot cache from the RobotStore, once. The robots are
+// fetched from Beehive instead if none can be loaded.
+func (s *Session) loadRobots() {
+	c := s.robotCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.loaded || s.robotStore == nil {
+		return
+	}
+	c.loaded = true
+	robots, fetched, err := s.robotStore.LoadRobots()
+	if err != nil || len(robots) == 0 {
+		return
+	}
+	for i := range robots {
+		s.attach(&robots[i])
+	}
+	c.robots, c.fetched = robots, fetched
+}
+
+// clearRobots empties the robot cache and the RobotStore, once the robots
+// linked to the account have changed
+func (s *Session) clearRobots() {
+	if s.robotCache == nil {
+		return
+	}
+	s.robotCache.clear()
+	if s.robotStore != nil {
+		_ = s.robotStore.SaveRobots(nil, time.Time{})
+	}
+}
+
+func (c *robotCache) get(now time.Time) ([]Robot, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.robots == nil || now.Sub(c.fetched) > c.ttl {
+		return nil, false
+	}
+	result := make([]Robot, len(c.robots))
+	copy(result, c.robots)
+	return result, true
+}
+
+func (c *robotCache) put(robots []Robot, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.robots = make([]Robot, len(robots))
+	copy(c.robots, robots)
+	c.fetched = now
+	c.loaded = true
+}
+
+// findRobot returns the account's Robot with the supplied serial
+func (s *Session) findRobot(serial string) (*Robot, error) {
+	robots, err := s.ListRobots()
+	if err != nil {
+		return nil, err
+	}
+	for i := range robots {
+		if strings.EqualFold(robots[i].Serial, serial) {
+			return &robots[i], nil
+		}
+	}
+	return nil, fmt.Errorf("robot %s not found", serial)
+}
+
+// robotKey fetches the current SecretKey of the Robot with the supplied
+// serial, bypassing and refreshing the robot cache
+func (s *Session) robotKey(serial string) (string, error) {
+	robots, err := s.fetchRobots()
+	if err != nil {
+		return "", err
+	}
+	for _, r := range robots {
+		if strings.EqualFold(r.Serial, serial) {
+			return r.SecretKey, nil
+		}
+	}
+	return "", fmt.Errorf("robot %s is no longer linked to the account",
+		serial)
+}
+
+func (c *robotCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.robots = nil
+	c.loaded = true
+}
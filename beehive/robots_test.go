@@ -0,0 +1,106 @@
+package beehive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeBeehive serves sessions and the robot list, counting the lists.
+// Sessions fail with sessionStatus if it is set.
+type fakeBeehive struct {
+	lists         int32
+	sessionStatus int32
+}
+
+func (f *fakeBeehive) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch req.URL.Path {
+	case "/sessions":
+		if code := atomic.LoadInt32(&f.sessionStatus); code != 0 {
+			w.WriteHeader(int(code))
+			w.Write([]byte(`{"message":"refused"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"token"}`))
+	case "/users/me/robots":
+		atomic.AddInt32(&f.lists, 1)
+		w.Write([]byte(`[{"serial":"OPS01234-0123456789AB",` +
+			`"name":"Kitchen","secret_key":"key"}]`))
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func newTestSession(t *testing.T, h http.Handler,
+	opts ...Option) *Session {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = append([]Option{WithEndpoint(u), WithCredentials("a", "b")},
+		opts...)
+	s, err := NewSession(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// memoryRobots is a RobotStore in memory
+type memoryRobots struct {
+	robots  []Robot
+	fetched time.Time
+}
+
+func (m *memoryRobots) LoadRobots() ([]Robot, time.Time, error) {
+	return m.robots, m.fetched, nil
+}
+
+func (m *memoryRobots) SaveRobots(robots []Robot, t time.Time) error {
+	m.robots, m.fetched = robots, t
+	return nil
+}
+
+func TestRobotStore(t *testing.T) {
+	f := &fakeBeehive{}
+	rs := &memoryRobots{}
+	tests := []struct {
+		name  string
+		opts  []Option
+		lists int32
+	}{
+		{"first", []Option{WithRobotCache(time.Hour),
+			WithRobotStore(rs)}, 1},
+		{"restart", []Option{WithRobotCache(time.Hour),
+			WithRobotStore(rs)}, 1},
+		{"expired", []Option{WithRobotCache(time.Nanosecond),
+			WithRobotStore(rs)}, 2},
+		{"no cache", []Option{WithRobotStore(rs)}, 3},
+	}
+	for _, tt := range tests {
+		s := newTestSession(t, f, tt.opts...)
+		robots, err := s.ListRobots()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(robots) != 1 || robots[0].SecretKey != "key" ||
+			robots[0].session != s {
+			t.Errorf("%s: got %+v", tt.name, robots)
+		}
+		if n := atomic.LoadInt32(&f.lists); n != tt.lists {
+			t.Errorf("%s: %d lists, want %d", tt.name, n, tt.lists)
+		}
+	}
+	s := newTestSession(t, f, WithRobotCache(time.Hour), WithRobotStore(rs))
+	s.clearRobots()
+	if len(rs.robots) != 0 {
+		t.Errorf("clearRobots left %d robots stored", len(rs.robots))
+	}
+}
@@ -0,0 +1,19 @@
+// SessionService covers the public operations of Sessions, so that code
+// built on the SDK can depend on it and inject fakes in place of the concrete
+// type, such as those in the mocks package.
+
+package beehive
+
+var (
+	_ SessionService = (*Session)(nil)
+)
+
+// SessionService covers the operations available on a Session
+type SessionService interface {
+	Refresh() error
+	GetUser() (*User, error)
+	ListRobots() ([]Robot, error)
+	ListRobotMaps(robot string) (*MapsResult, error)
+	GetRobotMap(robot, id string) (*Map, error)
+	ListRobotPersistentMaps(robot string) ([]Map, error)
+}
@@ -0,0 +1,91 @@
+// A FleetSnapshot captures the state of every robot on the account at once,
+// which is what a dashboard needs to render. States are fetched concurrently
+// by a bounded number of workers.
+
+package beehive
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/richlj/neato/nucleo"
+)
+
+const (
+	defaultSnapshotWorkers = 4
+)
+
+// RobotSnapshot holds a Robot and its state, or the error which prevented the
+// state from being fetched
+type RobotSnapshot struct {
+	Robot Robot
+	State *nucleo.RobotState
+	Err   error
+}
+
+// FleetSnapshot holds the state of every Robot on the account
+type FleetSnapshot struct {
+	Time   time.Time
+	Robots []RobotSnapshot
+}
+
+// WithSnapshotWorkers sets the number of robots SnapshotAll queries at once
+func WithSnapshotWorkers(n int) Option {
+	return func(s *Session) {
+		s.snapshotWorkers = n
+	}
+}
+
+// SnapshotAll lists the account's Robots and fetches the state of each. A
+// Robot whose state cannot be fetched is included with its error; robots not
+// reached before ctx is cancelled report ctx.Err().
+func (s *Session) SnapshotAll(ctx context.Context) (*FleetSnapshot, error) {
+	robots, err := s.ListRobots()
+	if err != nil {
+		return nil, err
+	}
+	result := &FleetSnapshot{
+		Time:   s.now(),
+		Robots: make([]RobotSnapshot, len(robots)),
+	}
+	workers := s.snapshotWorkers
+	if workers <= 0 {
+		workers = defaultSnapshotWorkers
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				snap := &result.Robots[i]
+				if err := ctx.Err(); err != nil {
+					snap.Err = err
+					continue
+				}
+				rc := snap.Robot.WithContext(ctx)
+				snap.State, snap.Err = rc.State()
+			}
+		}()
+	}
+	for i, r := range robots {
+		result.Robots[i].Robot = r
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return result, nil
+}
+
+// Failed returns the RobotSnapshots whose state could not be fetched
+func (f *FleetSnapshot) Failed() []RobotSnapshot {
+	var result []RobotSnapshot
+	for _, r := range f.Robots {
+		if r.Err != nil {
+			result = append(result, r)
+		}
+	}
+	return result
+}
@@ -0,0 +1,109 @@
+// Summaries turn cleaning runs into short human descriptions, e.g.
+// "Dusty cleaned 34.0 m² in 52 min (eco), interrupted once to recharge", for
+// display and notifications.
+
+package beehive
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/richlj/neato/i18n"
+	"github.com/richlj/neato/units"
+)
+
+const (
+	modeEco   = 1
+	modeTurbo = 2
+)
+
+var (
+	modeMessages = map[int]string{
+		modeEco:   "mode.eco",
+		modeTurbo: "mode.turbo",
+	}
+)
+
+// RunReport describes a single completed or abandoned cleaning run
+type RunReport struct {
+	Robot     string
+	Start     time.Time
+	End       time.Time
+	Area      units.Area
+	Duration  units.Duration
+	Mode      int
+	Recharges int
+	Status    string
+	Error     string
+}
+
+// NewRunReport builds a RunReport from the Map of a cleaning run performed by
+// the named Robot
+func NewRunReport(robot string, m *Map) *RunReport {
+	d := m.EndAt.Sub(m.StartAt) -
+		time.Duration(m.TimeInSuspendedCleaning)*time.Second
+	if d < 0 {
+		d = 0
+	}
+	return &RunReport{
+		Robot:     robot,
+		Start:     m.StartAt,
+		End:       m.EndAt,
+		Area:      units.Area(m.CleanedArea),
+		Duration:  units.Duration(d),
+		Mode:      m.Mode,
+		Recharges: m.SuspendedCleaningChargingCount,
+		Status:    m.Status,
+		Error:     m.Error,
+	}
+}
+
+// Summary returns a one-line description of the RunReport
+func (r *RunReport) Summary() string {
+	return r.SummaryWith(i18n.DefaultFormatter)
+}
+
+// Details returns a multi-line description of the RunReport
+func (r *RunReport) Details() string {
+	return r.DetailsWith(i18n.DefaultFormatter)
+}
+
+// SummaryWith returns a one-line description of the RunReport in the locale
+// of the supplied Formatter
+func (r *RunReport) SummaryWith(f *i18n.Formatter) string {
+	s := f.Message("run.summary", r.Robot, f.AreaDuration(r.Area,
+		r.Duration))
+	if m, ok := modeMessages[r.Mode]; ok {
+		s += f.Message("run.mode", f.Message(m))
+	}
+	switch {
+	case r.Recharges == 1:
+		s += f.Message("run.recharged_once")
+	case r.Recharges > 1:
+		s += f.Message("run.recharged_n", r.Recharges)
+	}
+	if r.Error != "" {
+		s += f.Message("run.error", r.Error)
+	}
+	return s
+}
+
+// DetailsWith returns a multi-line description of the RunReport in the locale
+// of the supplied Formatter
+func (r *RunReport) DetailsWith(f *i18n.Formatter) string {
+	var b strings.Builder
+	fmt.Fprintln(&b, r.SummaryWith(f))
+	b.WriteString(f.Line("label.started", r.Start.Format(time.RFC1123)))
+	b.WriteString(f.Line("label.ended", r.End.Format(time.RFC1123)))
+	b.WriteString(f.Line("label.area", f.Area(r.Area)))
+	b.WriteString(f.Line("label.duration", f.Duration(r.Duration)))
+	b.WriteString(f.Line("label.recharges", r.Recharges))
+	if r.Status != "" {
+		b.WriteString(f.Line("label.status", r.Status))
+	}
+	if r.Error != "" {
+		b.WriteString(f.Line("label.error", f.Alert(r.Error)))
+	}
+	return b.String()
+}
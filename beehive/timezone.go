@@ -0,0 +1,61 @@
+// A robot reports its schedule and history in the timezone recorded against
+// it in Beehive. Robots which have moved house, or whose timezone was never
+// set during setup, report them in the wrong zone until it is corrected.
+// Nucleo offers third-party clients no timezone command, so the timezone is
+// changed on the robot's Beehive record.
+
+package beehive
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+)
+
+var (
+	// ErrNoSession is returned for operations on a Robot which was not
+	// listed by a Session
+	ErrNoSession = errors.New("robot not listed by a session")
+)
+
+type robotUpdate struct {
+	Timezone string `json:"timezone"`
+}
+
+// RobotTimezone returns the timezone of the Robot with the supplied serial
+func (s *Session) RobotTimezone(serial string) (*time.Location, error) {
+	r, err := s.findRobot(serial)
+	if err != nil {
+		return nil, err
+	}
+	return r.Timezone()
+}
+
+// SetRobotTimezone sets the timezone of the Robot with the supplied serial to
+// the named IANA timezone, e.g. "Europe/London"
+func (s *Session) SetRobotTimezone(serial, name string) error {
+	if _, err := time.LoadLocation(name); err != nil || name == "" {
+		return fmt.Errorf("invalid timezone %q", name)
+	}
+	if err := s.send(http.MethodPut, path.Join("users/me/robots", serial),
+		serial, &robotUpdate{Timezone: name}, nil); err != nil {
+		return err
+	}
+	s.clearRobots()
+	return nil
+}
+
+// SetTimezone sets the Robot's timezone to the named IANA timezone, e.g.
+// "Europe/London", through the Session which listed it
+func (r *Robot) SetTimezone(name string) error {
+	if r.session == nil {
+		return ErrNoSession
+	}
+	if err := r.session.SetRobotTimezone(r.Serial, name); err != nil {
+		return err
+	}
+	r.TimezoneName = name
+	return nil
+}
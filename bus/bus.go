@@ -0,0 +1,57 @@
+// bus publishes the Events of a watch.Watcher to existing event buses, so
+// that other services can react to robots without polling them. Events are
+// published as CloudEvents in the structured JSON mode, to NATS subjects
+// named after the robot and Event type, or to a Kafka topic keyed by robot.
+
+package bus
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/richlj/neato/watch"
+)
+
+// Publisher is implemented by the event bus clients
+type Publisher interface {
+	// Publish sends a single Event
+	Publish(ctx context.Context, e watch.Event) error
+
+	// Close releases the Publisher's connection
+	Close() error
+}
+
+// Follow publishes the Events of w to p until ctx is cancelled or w is shut
+// down. Failed polls are not published. Events which cannot be published are
+// passed to onError, if not nil, and dropped.
+func Follow(ctx context.Context, w *watch.Watcher, p Publisher,
+	onError func(watch.Event, error)) error {
+	events, cancel := w.Subscribe()
+	defer cancel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if e.Type == watch.PollFailed {
+				continue
+			}
+			err := p.Publish(ctx, e)
+			if err != nil && onError != nil {
+				onError(e, err)
+			}
+		}
+	}
+}
+
+// payload returns the published form of an Event
+func payload(e watch.Event) ([]byte, error) {
+	ce, err := e.CloudEvent()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&ce)
+}
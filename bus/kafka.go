@@ -0,0 +1,112 @@
+package bus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/richlj/neato/notify"
+	"github.com/richlj/neato/watch"
+)
+
+const kafkaContentType = "application/vnd.kafka.json.v2+json"
+
+// Kafka publishes Events to a Kafka topic through a Confluent-compatible
+// REST Proxy, keyed by robot so that each robot's Events keep their order
+// within a partition. Speaking the REST Proxy rather than the Kafka wire
+// protocol keeps the SDK free of a Kafka client dependency.
+type Kafka struct {
+	// URL is the base URL of the REST Proxy, e.g. "http://proxy:8082"
+	URL string
+
+	// Topic receives the Events
+	Topic string
+
+	// Username and Password, if set, authenticate with the proxy
+	Username string
+	Password string
+
+	Client *http.Client
+
+	// Templates, if set, word the Summary of the Events published
+	Templates *notify.Templates
+}
+
+type kafkaRecords struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+type kafkaRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+type kafkaResponse struct {
+	Offsets []struct {
+		Partition *int   `json:"partition"`
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+	Message string `json:"message"`
+}
+
+// Publish sends an Event
+func (k *Kafka) Publish(ctx context.Context, e watch.Event) error {
+	value, err := payload(k.Templates.Apply(e))
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(&kafkaRecords{
+		Records: []kafkaRecord{{Key: e.Robot, Value: value}},
+	})
+	if err != nil {
+		return err
+	}
+	u := strings.TrimSuffix(k.URL, "/") + "/topics/" +
+		url.PathEscape(k.Topic)
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", kafkaContentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if k.Username != "" {
+		req.SetBasicAuth(k.Username, k.Password)
+	}
+	client := k.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("kafka: %v", err)
+	}
+	defer resp.Body.Close()
+	var result kafkaResponse
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	json.Unmarshal(b, &result)
+	if resp.StatusCode/100 != 2 {
+		if result.Message != "" {
+			return fmt.Errorf("kafka: proxy responded %s: %s",
+				resp.Status, result.Message)
+		}
+		return fmt.Errorf("kafka: proxy responded %s", resp.Status)
+	}
+	for _, o := range result.Offsets {
+		if o.ErrorCode != nil {
+			return fmt.Errorf("kafka: %s", o.Error)
+		}
+	}
+	return nil
+}
+
+// Close does nothing, as the proxy is reached over HTTP
+func (k *Kafka) Close() error {
+	return nil
+}
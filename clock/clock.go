@@ -0,0 +1,212 @@
+// clock abstracts the passage of time, so that request signing, caches,
+// watchers and runners can be driven by a Fake in tests and simulations
+// instead of sleeping.
+
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is a source of the current time and of timers
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer delivers the time on its channel once, when it expires
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Ticker delivers the time on its channel at regular intervals
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+var (
+	// Real is the system clock
+	Real Clock = realClock{}
+)
+
+// Or returns c, or Real if c is nil
+func Or(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTicker) Stop() {
+	t.t.Stop()
+}
+
+// Fake is a Clock which only moves when it is advanced. Timers and Tickers
+// fire, in order, as Advance or Set passes their deadlines.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeTimer
+}
+
+// NewFake returns a Fake reading t
+func NewFake(t time.Time) *Fake {
+	return &Fake{now: t}
+}
+
+// Now returns the Fake's current time
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// NewTimer returns a Timer which fires once the Fake reaches d from now
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.add(d, 0)
+}
+
+// NewTicker returns a Ticker which fires each time the Fake passes a
+// multiple of d from now
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	return fakeTicker{f.add(d, d)}
+}
+
+// Advance moves the Fake forward by d
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Set moves the Fake to t, firing any Timers and Tickers due by then. The
+// Fake never moves backwards.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for {
+		sort.Slice(f.waiters, func(i, j int) bool {
+			return f.waiters[i].at.Before(f.waiters[j].at)
+		})
+		if len(f.waiters) == 0 || f.waiters[0].at.After(t) {
+			break
+		}
+		w := f.waiters[0]
+		if w.at.After(f.now) {
+			f.now = w.at
+		}
+		w.fire(f.now)
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+		} else {
+			f.waiters = f.waiters[1:]
+		}
+	}
+	if t.After(f.now) {
+		f.now = t
+	}
+}
+
+// Waiters returns the number of Timers and Tickers yet to fire, so that a
+// test may wait for the code under test to start waiting before advancing
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+func (f *Fake) add(d, period time.Duration) *fakeTimer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := &fakeTimer{f: f, c: make(chan time.Time, 1), at: f.now.Add(d),
+		period: period}
+	if period == 0 && d <= 0 {
+		w.fire(f.now)
+		return w
+	}
+	f.waiters = append(f.waiters, w)
+	return w
+}
+
+func (f *Fake) remove(w *fakeTimer) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, o := range f.waiters {
+		if o == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	f      *Fake
+	c      chan time.Time
+	at     time.Time
+	period time.Duration
+}
+
+// fire delivers t without blocking, dropping ticks as a time.Ticker does
+func (w *fakeTimer) fire(t time.Time) {
+	select {
+	case w.c <- t:
+	default:
+	}
+}
+
+func (w *fakeTimer) C() <-chan time.Time {
+	return w.c
+}
+
+func (w *fakeTimer) Stop() bool {
+	return w.f.remove(w)
+}
+
+type fakeTicker struct {
+	*fakeTimer
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeTimer.Stop()
+}
@@ -0,0 +1,177 @@
+// config describes a long-running service built on the SDK in a JSON file:
+// the robots it watches, how often they are polled, where their Events are
+// delivered, their cleaning schedules and how the cloud is reached. A
+// Reloader applies changes to the file whilst the service runs, without
+// dropping the Watcher's state or the sessions of robots which are
+// unchanged.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/richlj/neato/egress"
+	"github.com/richlj/neato/nucleo"
+	"github.com/richlj/neato/watch"
+)
+
+// Config is the configuration of a service
+type Config struct {
+	Robots []Robot `json:"robots"`
+
+	// Interval, ActiveInterval, IdleInterval and Spacing are the polling
+	// intervals of the Watcher, as described there
+	Interval       Duration `json:"interval"`
+	ActiveInterval Duration `json:"active_interval"`
+	IdleInterval   Duration `json:"idle_interval"`
+	Spacing        Duration `json:"spacing"`
+
+	Webhooks []Webhook `json:"webhooks"`
+
+	Network Network `json:"network"`
+}
+
+// Network configures how the Neato cloud is reached, as for egress.Config
+type Network struct {
+	egress.Config
+
+	// FallbackDelay is egress.Config.FallbackDelay, written as a Duration
+	FallbackDelay Duration `json:"fallback_delay"`
+}
+
+// egress returns the egress.Config which n describes
+func (n Network) egress() egress.Config {
+	c := n.Config
+	c.FallbackDelay = time.Duration(n.FallbackDelay)
+	return c
+}
+
+// Robot is a robot to watch. Its secret is given directly, or for
+// preference, as the name of an environment variable holding it.
+type Robot struct {
+	Serial    string `json:"serial"`
+	Name      string `json:"name"`
+	Secret    string `json:"secret"`
+	SecretEnv string `json:"secret_env"`
+
+	// Schedule, if not nil, is set on the robot, replacing its schedule
+	Schedule []nucleo.Event `json:"schedule"`
+}
+
+// Webhook is a webhook.Endpoint. Its secret is given directly or as the name
+// of an environment variable holding it.
+type Webhook struct {
+	URL       string            `json:"url"`
+	Secret    string            `json:"secret"`
+	SecretEnv string            `json:"secret_env"`
+	Events    []watch.EventType `json:"events"`
+}
+
+// Duration is a time.Duration written in JSON as a string such as "30s"
+type Duration time.Duration
+
+// UnmarshalJSON parses a Duration from a string such as "30s"
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("invalid duration %s", b)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON writes a Duration as a string such as "30s"
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Load reads a Config from the JSON file at path and validates it
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var result Config
+	d := json.NewDecoder(f)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&result); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := result.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &result, nil
+}
+
+// Validate checks that the Config is complete and consistent
+func (c *Config) Validate() error {
+	if c.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	seen := make(map[string]bool)
+	for _, r := range c.Robots {
+		switch {
+		case r.Serial == "":
+			return fmt.Errorf("robot without a serial")
+		case seen[r.Serial]:
+			return fmt.Errorf("robot %s configured twice", r.Serial)
+		case r.secret() == "":
+			return fmt.Errorf("robot %s has no secret", r.Serial)
+		}
+		seen[r.Serial] = true
+		if r.Schedule != nil {
+			err := nucleo.ValidateSchedule(r.Schedule, nil, nil)
+			if err != nil {
+				return fmt.Errorf("robot %s: %v", r.Serial, err)
+			}
+		}
+	}
+	urls := make(map[string]bool)
+	for _, w := range c.Webhooks {
+		switch {
+		case w.URL == "":
+			return fmt.Errorf("webhook without a URL")
+		case urls[w.URL]:
+			return fmt.Errorf("webhook %s configured twice", w.URL)
+		}
+		urls[w.URL] = true
+	}
+	if err := c.Network.Validate(); err != nil {
+		return fmt.Errorf("network: %v", err)
+	}
+	return nil
+}
+
+// Intervals returns the polling intervals of the Config
+func (c *Config) Intervals() watch.Intervals {
+	return watch.Intervals{
+		Interval:       time.Duration(c.Interval),
+		ActiveInterval: time.Duration(c.ActiveInterval),
+		IdleInterval:   time.Duration(c.IdleInterval),
+		Spacing:        time.Duration(c.Spacing),
+	}
+}
+
+// secret returns the Robot's secret, from the environment if so configured
+func (r Robot) secret() string {
+	if r.SecretEnv != "" {
+		return os.Getenv(r.SecretEnv)
+	}
+	return r.Secret
+}
+
+// secret returns the Webhook's secret, from the environment if so configured
+func (w Webhook) secret() string {
+	if w.SecretEnv != "" {
+		return os.Getenv(w.SecretEnv)
+	}
+	return w.Secret
+}
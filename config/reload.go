@@ -0,0 +1,187 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/richlj/neato/clock"
+	"github.com/richlj/neato/egress"
+	"github.com/richlj/neato/nucleo"
+	"github.com/richlj/neato/watch"
+	"github.com/richlj/neato/webhook"
+)
+
+// Reloader applies the Config in a file to a Watcher and Dispatcher, and
+// reapplies it when the file changes. Only the differences are applied:
+// robots whose serial and secret are unchanged keep their place in the
+// Watcher, their last state and their Nucleo sessions.
+type Reloader struct {
+	Path    string
+	Watcher *watch.Watcher
+
+	// Dispatcher, if not nil, delivers to the configured webhooks
+	Dispatcher *webhook.Dispatcher
+
+	// NewRobot returns the Robot to watch for a configured robot. It
+	// defaults to a nucleo.Robot.
+	NewRobot func(r Robot) (watch.Robot, error)
+
+	// Clock paces Watch. It defaults to clock.Real.
+	Clock clock.Clock
+
+	mu      sync.Mutex
+	current *Config
+	robots  map[string]watch.Robot
+	modTime time.Time
+	size    int64
+}
+
+// Config returns the Config last applied, or nil if none has been
+func (rl *Reloader) Config() *Config {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.current
+}
+
+// Reload reads the file and applies any changes. If the file cannot be read
+// or is invalid, the Config last applied remains in force.
+func (rl *Reloader) Reload() error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	fi, err := os.Stat(rl.Path)
+	if err != nil {
+		return err
+	}
+	rl.modTime, rl.size = fi.ModTime(), fi.Size()
+	c, err := Load(rl.Path)
+	if err != nil {
+		return err
+	}
+	return rl.apply(c)
+}
+
+// Watch reloads the file whenever its modification time or size changes,
+// checking every interval until ctx is cancelled. Failed reloads are passed
+// to report, if not nil. It is typically run alongside
+// systemd.HandleReload(ctx, rl.Reload, report) to reload on SIGHUP too.
+func (rl *Reloader) Watch(ctx context.Context, interval time.Duration,
+	report func(error)) error {
+	t := clock.Or(rl.Clock).NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C():
+		}
+		fi, err := os.Stat(rl.Path)
+		if err == nil {
+			rl.mu.Lock()
+			changed := !fi.ModTime().Equal(rl.modTime) ||
+				fi.Size() != rl.size
+			rl.mu.Unlock()
+			if !changed {
+				continue
+			}
+			err = rl.Reload()
+		}
+		if err != nil && report != nil {
+			report(err)
+		}
+	}
+}
+
+// apply brings the Watcher and Dispatcher into line with c. rl.mu must be
+// held.
+func (rl *Reloader) apply(c *Config) error {
+	if err := egress.Configure(c.Network.egress()); err != nil {
+		return fmt.Errorf("network: %v", err)
+	}
+	previous := make(map[string]Robot)
+	if rl.current != nil {
+		for _, r := range rl.current.Robots {
+			previous[r.Serial] = r
+		}
+	}
+	robots := make(map[string]watch.Robot)
+	var added []Robot
+	for _, r := range c.Robots {
+		if p, ok := previous[r.Serial]; ok && p.secret() == r.secret() {
+			robots[r.Serial] = rl.robots[r.Serial]
+			continue
+		}
+		wr, err := rl.newRobot(r)
+		if err != nil {
+			return fmt.Errorf("robot %s: %v", r.Serial, err)
+		}
+		robots[r.Serial] = wr
+		added = append(added, r)
+	}
+	for serial := range rl.robots {
+		if _, ok := robots[serial]; !ok {
+			rl.Watcher.Remove(serial)
+		}
+	}
+	for _, r := range added {
+		rl.Watcher.Remove(r.Serial)
+		rl.Watcher.Add(r.Serial, robots[r.Serial])
+	}
+	rl.Watcher.SetIntervals(c.Intervals())
+	if rl.Dispatcher != nil {
+		endpoints := make([]webhook.Endpoint, len(c.Webhooks))
+		for i, w := range c.Webhooks {
+			endpoints[i] = webhook.Endpoint{
+				URL:    w.URL,
+				Secret: w.secret(),
+				Events: w.Events,
+			}
+		}
+		rl.Dispatcher.SetEndpoints(endpoints...)
+	}
+	rl.robots, rl.current = robots, c
+	var result error
+	for _, r := range c.Robots {
+		p := previous[r.Serial]
+		if r.Schedule == nil || !isAdded(added, r.Serial) &&
+			reflect.DeepEqual(p.Schedule, r.Schedule) {
+			continue
+		}
+		err := setSchedule(robots[r.Serial], r.Schedule)
+		if err != nil && result == nil {
+			result = fmt.Errorf("robot %s: %v", r.Serial, err)
+		}
+	}
+	return result
+}
+
+// newRobot returns the Robot to watch for r
+func (rl *Reloader) newRobot(r Robot) (watch.Robot, error) {
+	if rl.NewRobot != nil {
+		return rl.NewRobot(r)
+	}
+	return nucleo.NewRobot(r.Serial, r.secret()), nil
+}
+
+// isAdded reports whether the robot with serial is among added
+func isAdded(added []Robot, serial string) bool {
+	for _, r := range added {
+		if r.Serial == serial {
+			return true
+		}
+	}
+	return false
+}
+
+// setSchedule sets the schedule of r, if it supports schedules
+func setSchedule(r watch.Robot, events []nucleo.Event) error {
+	s, ok := r.(nucleo.ScheduleService)
+	if !ok {
+		return fmt.Errorf("schedules not supported")
+	}
+	_, err := s.SetSchedule(&nucleo.Params{Events: events})
+	return err
+}
@@ -0,0 +1,207 @@
+// decode turns API responses into Go values without trusting them to be well
+// formed. Malformed and truncated payloads are reported as an *Error giving
+// the offset and surrounding text rather than a bare syntax error, and a
+// panic in a decoder is recovered and reported the same way.
+//
+// Some firmware sends the strings "" and "null" in place of a missing value,
+// which encoding/json cannot decode into a time.Time or a number. A payload
+// which fails to decode is retried with both treated as null, so that they
+// decode as the zero value of any type. Payloads which decode as they are,
+// such as those decoded into maps to be written back, are left untouched.
+
+package decode
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+const (
+	contextBytes = 24
+)
+
+var (
+	// ErrEmpty is returned for a response with no body
+	ErrEmpty = errors.New("empty response")
+
+	bufferPool = sync.Pool{
+		New: func() interface{} { return new(bytes.Buffer) },
+	}
+)
+
+// Error reports where a response could not be decoded
+type Error struct {
+	// Offset is the byte offset in the response at which decoding failed
+	Offset int64
+	// Context is the text surrounding Offset
+	Context string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Context == "" {
+		return fmt.Sprintf("malformed response at byte %d: %v",
+			e.Offset, e.Err)
+	}
+	return fmt.Sprintf("malformed response at byte %d near %q: %v",
+		e.Offset, e.Context, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Decode reads the whole of rd and decodes it into v. Errors from rd are
+// returned as they are.
+func Decode(rd io.Reader, v interface{}) error {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	defer bufferPool.Put(buf)
+	buf.Reset()
+	if _, err := buf.ReadFrom(rd); err != nil {
+		return err
+	}
+	return Unmarshal(buf.Bytes(), v)
+}
+
+// Unmarshal decodes b into v, as json.Unmarshal but with the treatment of
+// missing values and errors described above. b is not modified.
+func Unmarshal(b []byte, v interface{}) (err error) {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return ErrEmpty
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = &Error{Err: fmt.Errorf("decoder panicked: %v", p)}
+		}
+	}()
+	err = json.Unmarshal(b, v)
+	var syntax *json.SyntaxError
+	if err == nil || errors.As(err, &syntax) {
+		return wrap(b, err)
+	}
+	n, changed := normalize(b)
+	if !changed {
+		return newError(b, err)
+	}
+	// discard whatever the first attempt decoded before retrying
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv.Elem().Set(reflect.Zero(rv.Elem().Type()))
+	}
+	return wrap(n, json.Unmarshal(n, v))
+}
+
+// wrap returns err located within b, or nil if err is nil
+func wrap(b []byte, err error) error {
+	if err == nil {
+		return nil
+	}
+	return newError(b, err)
+}
+
+// Normalize returns b with each string value "" or "null" replaced by null.
+// Object keys are left alone, and b itself is returned if nothing needs
+// replacing, or if it is not well enough formed to tell keys from values.
+func Normalize(b []byte) []byte {
+	result, _ := normalize(b)
+	return result
+}
+
+// normalize implements Normalize, also reporting whether anything changed
+func normalize(b []byte) ([]byte, bool) {
+	var (
+		out    []byte
+		last   int
+		stack  []byte
+		expect bool // whether the next string is an object key
+	)
+	for i := 0; i < len(b); i++ {
+		switch b[i] {
+		case '{':
+			stack = append(stack, '{')
+			expect = true
+		case '[':
+			stack = append(stack, '[')
+			expect = false
+		case '}', ']':
+			if len(stack) == 0 {
+				return b, false
+			}
+			stack = stack[:len(stack)-1]
+			expect = false
+		case ',':
+			expect = len(stack) > 0 && stack[len(stack)-1] == '{'
+		case ':':
+			expect = false
+		case '"':
+			end := stringEnd(b, i)
+			if end < 0 {
+				return b, false
+			}
+			if !expect && missing(b[i:end]) {
+				out = append(out, b[last:i]...)
+				out = append(out, "null"...)
+				last = end
+			}
+			i = end - 1
+		}
+	}
+	if out == nil {
+		return b, false
+	}
+	return append(out, b[last:]...), true
+}
+
+// stringEnd returns the offset just beyond the string starting at b[i], or
+// -1 if it is unterminated
+func stringEnd(b []byte, i int) int {
+	for j := i + 1; j < len(b); j++ {
+		switch b[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return -1
+}
+
+func missing(s []byte) bool {
+	return string(s) == `""` || string(s) == `"null"`
+}
+
+// newError locates err, returned by json.Unmarshal, within b
+func newError(b []byte, err error) *Error {
+	var (
+		syntax *json.SyntaxError
+		typ    *json.UnmarshalTypeError
+		offset int64
+	)
+	switch {
+	case errors.As(err, &syntax):
+		offset = syntax.Offset
+	case errors.As(err, &typ):
+		offset = typ.Offset
+		if typ.Field != "" {
+			err = fmt.Errorf("field %s: cannot decode %s into %s",
+				typ.Field, typ.Value, typ.Type)
+		}
+	default:
+		return &Error{Err: err}
+	}
+	if offset > int64(len(b)) {
+		offset = int64(len(b))
+	}
+	from, to := offset-contextBytes, offset+contextBytes
+	if from < 0 {
+		from = 0
+	}
+	if to > int64(len(b)) {
+		to = int64(len(b))
+	}
+	return &Error{Offset: offset, Context: string(b[from:to]), Err: err}
+}
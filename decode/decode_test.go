@@ -0,0 +1,104 @@
+package decode
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type run struct {
+	Start  time.Time         `json:"start"`
+	Charge int               `json:"charge"`
+	Name   string            `json:"name"`
+	Extra  map[string]string `json:"extra"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	start := time.Date(2019, 6, 3, 7, 0, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		in     string
+		want   run
+		err    error
+		offset int64
+	}{
+		{name: "valid",
+			in:   `{"start":"2019-06-03T07:00:05Z","charge":64}`,
+			want: run{Start: start, Charge: 64}},
+		{name: "empty", in: " \n", err: ErrEmpty},
+		{name: "empty timestamp", in: `{"start":"","charge":64}`,
+			want: run{Charge: 64}},
+		{name: "null string timestamp",
+			in:   `{"start":"null","name":"null"}`,
+			want: run{}},
+		{name: "null string number", in: `{"charge":"null","name":"a"}`,
+			want: run{Name: "a"}},
+		{name: "strings kept when valid",
+			in:   `{"name":"","extra":{"a":"null"}}`,
+			want: run{Extra: map[string]string{"a": "null"}}},
+		{name: "truncated", in: `{"start":"2019-06-03T07:00:05Z","cha`,
+			offset: 36},
+		{name: "wrong type", in: `{"charge":"full"}`, offset: 16},
+	}
+	for _, tt := range tests {
+		var got run
+		err := Unmarshal([]byte(tt.in), &got)
+		var de *Error
+		switch {
+		case tt.err != nil:
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: error %v, want %v", tt.name, err,
+					tt.err)
+			}
+		case tt.offset > 0:
+			if !errors.As(err, &de) || de.Offset != tt.offset {
+				t.Errorf("%s: error %v, want one at byte %d",
+					tt.name, err, tt.offset)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case !reflect.DeepEqual(got, tt.want):
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`{"a":"","b":"null","c":"x"}`, `{"a":null,"b":null,"c":"x"}`},
+		{`{"":"","null":1}`, `{"":null,"null":1}`},
+		{`["", "null", "\"null\""]`, `[null, null, "\"null\""]`},
+		{`{"a":{"b":["",{"":""}]}}`, `{"a":{"b":[null,{"":null}]}}`},
+		{`{"a":"x"}`, `{"a":"x"}`},
+		{`{"a":"`, `{"a":"`},
+		{`]"null"`, `]"null"`},
+	}
+	for _, tt := range tests {
+		if got := string(Normalize([]byte(tt.in))); got != tt.want {
+			t.Errorf("Normalize(%s) = %s, want %s", tt.in, got,
+				tt.want)
+		}
+	}
+}
+
+// FuzzNormalize checks that Normalize never breaks well formed JSON, and
+// that Unmarshal returns an error rather than panicking on any input
+func FuzzNormalize(f *testing.F) {
+	f.Add([]byte(`{"start":"","end":"null","runs":[{"":"null"}]}`))
+	f.Add([]byte(`{"a":"\"","b":"\\"}`))
+	f.Add([]byte(`[`))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		if json.Valid(b) && !json.Valid(Normalize(b)) {
+			t.Errorf("Normalize(%q) = %q, which is not valid", b,
+				Normalize(b))
+		}
+		var v run
+		_ = Unmarshal(b, &v)
+		var m map[string]interface{}
+		_ = Unmarshal(b, &m)
+	})
+}
@@ -0,0 +1,272 @@
+// discover finds robots on the local network, for the local transport and
+// for answering "which IP is my robot?". Robots are recognised by the MAC
+// addresses in the account's Beehive records, looked up in the host's
+// neighbour (ARP) table, which an SSDP search first fills with the addresses
+// of the devices on the network. SSDP responders which identify themselves
+// as Neato devices are reported even if they match no robot on the account.
+//
+// Neato robots are not known to advertise a DNS-SD service, so no mDNS
+// browse is made.
+
+package discover
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/richlj/neato/beehive"
+)
+
+const (
+	defaultTimeout  = 3 * time.Second
+	defaultARPTable = "/proc/net/arp"
+	ssdpAddr        = "239.255.255.250:1900"
+	ssdpSearch      = "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n" +
+		"ST: ssdp:all\r\n\r\n"
+	vendor = "neato"
+
+	// The Sources of a Found robot
+	SourceARP  = "arp"
+	SourceSSDP = "ssdp"
+)
+
+// Found is a robot found on the local network. Serial, Name and Model are
+// empty for a Neato device which matches no robot on the account.
+type Found struct {
+	Serial  string
+	Name    string
+	Model   string
+	IP      net.IP
+	MAC     string
+	Sources []string
+
+	// Server is the SERVER header of the robot's SSDP response, if any
+	Server string
+}
+
+// Discoverer finds robots on the local network
+type Discoverer struct {
+	// Timeout limits the SSDP search. It defaults to three seconds.
+	Timeout time.Duration
+
+	// ARPTable is the path of the host's neighbour table, in the format
+	// of Linux's /proc/net/arp, which is the default
+	ARPTable string
+
+	// NoSSDP skips the SSDP search, relying on the neighbour table alone
+	NoSSDP bool
+}
+
+// Discover finds the robots on the local network with a default Discoverer
+func Discover(ctx context.Context, robots []beehive.Robot) ([]Found,
+	error) {
+	return (&Discoverer{}).Discover(ctx, robots)
+}
+
+// Discover finds robots on the local network, matching them against robots,
+// typically the account's from beehive.Session.ListRobots. The neighbour
+// table is read even if the SSDP search fails.
+func (d *Discoverer) Discover(ctx context.Context,
+	robots []beehive.Robot) ([]Found, error) {
+	var responses []ssdpResponse
+	var serr error
+	if !d.NoSSDP {
+		responses, serr = d.search(ctx)
+	}
+	neighbours, err := readARP(d.arpTable())
+	if err != nil {
+		if serr != nil {
+			return nil, serr
+		}
+		return nil, err
+	}
+	byMAC := make(map[string]*beehive.Robot)
+	for i := range robots {
+		if mac := normalizeMAC(robots[i].MACAddress); mac != "" {
+			byMAC[mac] = &robots[i]
+		}
+	}
+	found := make(map[string]*Found)
+	for ip, mac := range neighbours {
+		if r, ok := byMAC[mac]; ok {
+			found[ip] = &Found{Serial: r.Serial, Name: r.Name,
+				Model: r.Model, IP: net.ParseIP(ip), MAC: mac,
+				Sources: []string{SourceARP}}
+		}
+	}
+	for _, resp := range responses {
+		ip := resp.ip.String()
+		f, ok := found[ip]
+		if !ok {
+			if !resp.neato() {
+				continue
+			}
+			f = &Found{IP: resp.ip, MAC: neighbours[ip]}
+			found[ip] = f
+		}
+		if !contains(f.Sources, SourceSSDP) {
+			f.Sources = append(f.Sources, SourceSSDP)
+		}
+		f.Server = resp.server
+	}
+	result := make([]Found, 0, len(found))
+	for _, f := range found {
+		result = append(result, *f)
+	}
+	sort.Slice(result, func(a, b int) bool {
+		ia, ib := result[a].IP.To16(), result[b].IP.To16()
+		return bytes.Compare(ia, ib) < 0
+	})
+	return result, nil
+}
+
+func (d *Discoverer) arpTable() string {
+	if d.ARPTable != "" {
+		return d.ARPTable
+	}
+	return defaultARPTable
+}
+
+// ssdpResponse is a reply to an SSDP search
+type ssdpResponse struct {
+	ip     net.IP
+	server string
+	usn    string
+	st     string
+}
+
+// neato reports whether the response identifies a Neato device
+func (r *ssdpResponse) neato() bool {
+	for _, s := range []string{r.server, r.usn, r.st} {
+		if strings.Contains(strings.ToLower(s), vendor) {
+			return true
+		}
+	}
+	return false
+}
+
+// search makes an SSDP search and collects the responses until the timeout
+// or ctx is done
+func (d *Discoverer) search(ctx context.Context) ([]ssdpResponse, error) {
+	timeout := d.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	if t, ok := ctx.Deadline(); ok && t.Before(deadline) {
+		deadline = t
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+	addr, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.WriteTo([]byte(ssdpSearch), addr); err != nil {
+		return nil, err
+	}
+	conn.SetReadDeadline(deadline)
+	var result []ssdpResponse
+	buf := make([]byte, 2048)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return result, ctx.Err()
+			}
+			return result, err
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(
+			bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		result = append(result, ssdpResponse{
+			ip:     from.IP,
+			server: resp.Header.Get("Server"),
+			usn:    resp.Header.Get("Usn"),
+			st:     resp.Header.Get("St"),
+		})
+	}
+}
+
+// readARP returns the complete entries of the neighbour table at path, by
+// IP address
+func readARP(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	result := make(map[string]string)
+	sc := bufio.NewScanner(f)
+	for first := true; sc.Scan(); first = false {
+		fields := strings.Fields(sc.Text())
+		// IP address, HW type, Flags, HW address, Mask, Device
+		if first || len(fields) < 4 || fields[2] == "0x0" {
+			continue
+		}
+		if mac := normalizeMAC(fields[3]); mac != "" {
+			result[fields[0]] = mac
+		}
+	}
+	return result, sc.Err()
+}
+
+// normalizeMAC returns mac in lower case with colons, or "" if it is not a
+// valid, non-zero hardware address. Beehive may give addresses without
+// separators.
+func normalizeMAC(mac string) string {
+	if len(mac) == 12 && !strings.ContainsAny(mac, ":-.") {
+		var b strings.Builder
+		for i := 0; i < len(mac); i += 2 {
+			if i > 0 {
+				b.WriteByte(':')
+			}
+			b.WriteString(mac[i : i+2])
+		}
+		mac = b.String()
+	}
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) == 0 {
+		return ""
+	}
+	for _, b := range hw {
+		if b != 0 {
+			return hw.String()
+		}
+	}
+	return ""
+}
+
+func contains(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
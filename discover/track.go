@@ -0,0 +1,202 @@
+package discover
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/richlj/neato/beehive"
+	"github.com/richlj/neato/clock"
+)
+
+const (
+	defaultMaxAge = time.Hour
+	historyLength = 16
+
+	// SourceHook is the Source of addresses passed to Tracker.Observe by
+	// router integrations, such as DHCP lease notifications
+	SourceHook = "hook"
+)
+
+var (
+	// ErrNotFound is returned by Resolve when a robot cannot be found on
+	// the local network
+	ErrNotFound = errors.New("robot not found on the local network")
+)
+
+// Sighting is a period during which a robot was seen at an address
+type Sighting struct {
+	IP     net.IP
+	First  time.Time
+	Last   time.Time
+	Source string
+}
+
+// Tracker follows the LAN addresses of robots as DHCP moves them, from
+// periodic discovery and from router integrations calling Observe, so that
+// the local transport can find a robot without discovering it on every
+// request
+type Tracker struct {
+	Discoverer *Discoverer
+
+	// Robots returns the robots to track, typically
+	// beehive.Session.ListRobots
+	Robots func() ([]beehive.Robot, error)
+
+	// MaxAge is how long an address is trusted after the robot was last
+	// seen there, before Resolve discovers it afresh. It defaults to an
+	// hour.
+	MaxAge time.Duration
+
+	// OnChange, if set, is called when a robot is seen at a new address,
+	// with the previous address, if any
+	OnChange func(serial string, old, new net.IP)
+
+	// Clock dates the Sightings. It defaults to clock.Real.
+	Clock clock.Clock
+
+	mu      sync.Mutex
+	history map[string][]Sighting
+	stale   map[string]bool
+	refresh sync.Mutex
+}
+
+// Observe records that the robot with serial was seen at ip
+func (t *Tracker) Observe(serial string, ip net.IP, source string) {
+	now := clock.Or(t.Clock).Now()
+	t.mu.Lock()
+	if t.history == nil {
+		t.history = make(map[string][]Sighting)
+	}
+	delete(t.stale, serial)
+	h := t.history[serial]
+	var old net.IP
+	if n := len(h); n > 0 {
+		if h[n-1].IP.Equal(ip) {
+			h[n-1].Last, h[n-1].Source = now, source
+			t.mu.Unlock()
+			return
+		}
+		old = h[n-1].IP
+	}
+	h = append(h, Sighting{IP: ip, First: now, Last: now, Source: source})
+	if len(h) > historyLength {
+		h = h[len(h)-historyLength:]
+	}
+	t.history[serial] = h
+	t.mu.Unlock()
+	if t.OnChange != nil {
+		t.OnChange(serial, old, ip)
+	}
+}
+
+// Lookup returns the last address at which the robot with serial was seen,
+// and when
+func (t *Tracker) Lookup(serial string) (net.IP, time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := t.history[serial]
+	if len(h) == 0 {
+		return nil, time.Time{}, false
+	}
+	return h[len(h)-1].IP, h[len(h)-1].Last, true
+}
+
+// History returns the addresses at which the robot with serial has been
+// seen, oldest first
+func (t *Tracker) History(serial string) []Sighting {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Sighting(nil), t.history[serial]...)
+}
+
+// Invalidate marks the address of the robot with serial as stale, e.g. when
+// the local transport could not reach it there, so that the next Resolve
+// discovers it afresh
+func (t *Tracker) Invalidate(serial string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stale == nil {
+		t.stale = make(map[string]bool)
+	}
+	t.stale[serial] = true
+}
+
+// Refresh discovers the robots and records their addresses
+func (t *Tracker) Refresh(ctx context.Context) error {
+	t.refresh.Lock()
+	defer t.refresh.Unlock()
+	robots, err := t.Robots()
+	if err != nil {
+		return err
+	}
+	d := t.Discoverer
+	if d == nil {
+		d = &Discoverer{}
+	}
+	found, err := d.Discover(ctx, robots)
+	if err != nil {
+		return err
+	}
+	for _, f := range found {
+		if f.Serial != "" {
+			t.Observe(f.Serial, f.IP, f.Sources[0])
+		}
+	}
+	return nil
+}
+
+// Resolve returns the address of the robot with serial: the last address at
+// which it was seen if that is recent, or else its address from a fresh
+// discovery
+func (t *Tracker) Resolve(ctx context.Context, serial string) (net.IP,
+	error) {
+	if ip, ok := t.fresh(serial); ok {
+		return ip, nil
+	}
+	if err := t.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	if ip, ok := t.fresh(serial); ok {
+		return ip, nil
+	}
+	return nil, ErrNotFound
+}
+
+// fresh returns the address of the robot with serial if it was seen there
+// within MaxAge and has not been invalidated since
+func (t *Tracker) fresh(serial string) (net.IP, bool) {
+	ip, last, ok := t.Lookup(serial)
+	maxAge := t.MaxAge
+	if maxAge <= 0 {
+		maxAge = defaultMaxAge
+	}
+	t.mu.Lock()
+	stale := t.stale[serial]
+	t.mu.Unlock()
+	if !ok || stale || clock.Or(t.Clock).Now().Sub(last) > maxAge {
+		return nil, false
+	}
+	return ip, true
+}
+
+// Run refreshes the addresses every interval until ctx is cancelled,
+// passing failures to report, if not nil
+func (t *Tracker) Run(ctx context.Context, interval time.Duration,
+	report func(error)) error {
+	tk := clock.Or(t.Clock).NewTicker(interval)
+	defer tk.Stop()
+	for {
+		if err := t.Refresh(ctx); err != nil && report != nil &&
+			ctx.Err() == nil {
+			report(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tk.C():
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// egress configures how the SDK's clients reach the Neato cloud. The Nucleo
+// and Beehive clients share one HTTP transport, which dials through the
+// Config set here: a DNS server to use in place of the system's, static
+// addresses pinned for hostnames, for IoT VLANs whose external DNS is
+// blocked, and the IP versions dialled, for ISPs whose IPv6 is broken.
+// Changes apply to new connections; idle ones are closed.
+
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	dialTimeout = 30 * time.Second
+	keepAlive   = 30 * time.Second
+)
+
+// IPVersion restricts the addresses which are dialled
+type IPVersion string
+
+// IP versions
+const (
+	AnyIP IPVersion = ""
+	IPv4  IPVersion = "4"
+	IPv6  IPVersion = "6"
+)
+
+// network returns the network to dial in place of "tcp"
+func (v IPVersion) network() string {
+	return "tcp" + string(v)
+}
+
+// Config is the network configuration of the shared transport. The zero
+// value uses the system's resolver.
+type Config struct {
+	// Resolver is the address of a DNS server, e.g. "192.168.1.1:53",
+	// queried in place of the system's resolver
+	Resolver string `json:"resolver,omitempty"`
+
+	// Hosts pins hostnames, e.g. "nucleo.neatocloud.com", to static IP
+	// addresses, tried in order without a DNS lookup
+	Hosts map[string][]string `json:"hosts,omitempty"`
+
+	// IP dials only IPv4 or IPv6 addresses, including those pinned in
+	// Hosts. AnyIP dials both.
+	IP IPVersion `json:"ip,omitempty"`
+
+	// FallbackDelay is how long a dial to a host with both IPv6 and IPv4
+	// addresses waits for IPv6 before trying IPv4 as well ("Happy
+	// Eyeballs"). Zero waits 300ms, and a negative value disables the
+	// fallback. JSON has no duration type, so it is set from files by
+	// config.Network.
+	FallbackDelay time.Duration `json:"-"`
+}
+
+// Validate checks that the Resolver, pinned addresses and IP version are
+// well formed
+func (c Config) Validate() error {
+	switch c.IP {
+	case AnyIP, IPv4, IPv6:
+	default:
+		return fmt.Errorf("ip %q: must be \"4\" or \"6\"", c.IP)
+	}
+	if c.Resolver != "" {
+		if _, _, err := net.SplitHostPort(c.Resolver); err != nil {
+			return fmt.Errorf("resolver %q: %v", c.Resolver, err)
+		}
+	}
+	for host, ips := range c.Hosts {
+		if len(ips) == 0 {
+			return fmt.Errorf("host %q: no addresses", host)
+		}
+		for _, ip := range ips {
+			if net.ParseIP(ip) == nil {
+				return fmt.Errorf("host %q: invalid address %q",
+					host, ip)
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	mu      sync.RWMutex
+	current Config
+
+	shared = newTransport()
+)
+
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = DialContext
+	return t
+}
+
+// Configure sets the Config of the shared transport, closing its idle
+// connections so that new requests dial afresh
+func Configure(c Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	hosts := make(map[string][]string, len(c.Hosts))
+	for h, ips := range c.Hosts {
+		hosts[h] = append([]string(nil), ips...)
+	}
+	c.Hosts = hosts
+	mu.Lock()
+	current = c
+	mu.Unlock()
+	shared.CloseIdleConnections()
+	return nil
+}
+
+// Current returns the Config of the shared transport
+func Current() Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Transport returns the transport shared by the SDK's clients
+func Transport() http.RoundTripper {
+	return shared
+}
+
+// DialContext connects to addr as configured, for clients which dial
+// directly, such as TLS probes
+func DialContext(ctx context.Context, network, addr string) (net.Conn,
+	error) {
+	c := Current()
+	d := &net.Dialer{Timeout: dialTimeout, KeepAlive: keepAlive,
+		FallbackDelay: c.FallbackDelay}
+	if c.Resolver != "" {
+		d.Resolver = resolver(c.Resolver)
+	}
+	if network == "tcp" {
+		network = c.IP.network()
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, ok := c.Hosts[host]
+	if !ok {
+		return d.DialContext(ctx, network, addr)
+	}
+	err = errors.New("no addresses")
+	for _, ip := range ips {
+		var conn net.Conn
+		conn, err = d.DialContext(ctx, network,
+			net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, fmt.Errorf("dial %s pinned to %v: %v", host, ips, err)
+}
+
+// resolver returns a Resolver which queries the DNS server at addr
+func resolver(addr string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network,
+			_ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
@@ -0,0 +1,231 @@
+// Boundaries can be drawn onto a map image in any image editor, in pure red,
+// and imported from the edited image. Thin strokes become no-go lines and
+// filled shapes become zones, traced around their outline.
+
+package floorplan
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"github.com/richlj/neato/nucleo"
+)
+
+const (
+	// minAnnotationPixels is the size below which red marks are ignored as
+	// noise
+	minAnnotationPixels = 10
+	// maxLineWidth is the average width in pixels above which a mark is
+	// treated as a filled shape rather than a stroke
+	maxLineWidth = 8
+	// simplifyTolerance is the distance in pixels within which traced
+	// points are merged into straight edges
+	simplifyTolerance = 1.5
+)
+
+var (
+	// neighbours lists the eight surrounding pixels clockwise from the west
+	neighbours = []image.Point{{-1, 0}, {-1, -1}, {0, -1}, {1, -1},
+		{1, 0}, {1, 1}, {0, 1}, {-1, 1}}
+)
+
+type pixelSet map[image.Point]bool
+
+// ImportAnnotations finds the red marks drawn on a map image and converts
+// them into Boundaries. The result should be checked with
+// nucleo.ValidateBoundaries, as Robot.SetBoundaries does, since hand-drawn
+// shapes may overlap.
+func ImportAnnotations(img image.Image) []nucleo.Boundary {
+	t := NewTransform(img, 0)
+	origin := img.Bounds().Min
+	var result []nucleo.Boundary
+	for _, c := range components(redPixels(img)) {
+		if len(c) < minAnnotationPixels {
+			continue
+		}
+		path := longestPath(c)
+		if float64(len(c))/float64(len(path)) <= maxLineWidth {
+			points := toPoints(simplify(path), origin)
+			result = append(result, t.LineFromPixels(points...))
+			continue
+		}
+		o := simplify(outline(c))
+		if len(o) > 1 && o[0] == o[len(o)-1] {
+			o = o[:len(o)-1]
+		}
+		if len(o) >= 3 {
+			result = append(result,
+				t.PolygonFromPixels(toPoints(o, origin)...))
+		}
+	}
+	return result
+}
+
+func redPixels(img image.Image) pixelSet {
+	result := make(pixelSet)
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			if a >= opaque && r >= 0x9999 && g <= 0x4ccc &&
+				bl <= 0x4ccc {
+				result[image.Point{X: x, Y: y}] = true
+			}
+		}
+	}
+	return result
+}
+
+// components splits a set of pixels into its 8-connected parts
+func components(s pixelSet) []pixelSet {
+	seen := make(pixelSet)
+	var result []pixelSet
+	for _, start := range scanOrder(s) {
+		if seen[start] {
+			continue
+		}
+		c := pixelSet{start: true}
+		seen[start] = true
+		queue := []image.Point{start}
+		for len(queue) > 0 {
+			p := queue[0]
+			queue = queue[1:]
+			for _, d := range neighbours {
+				q := p.Add(d)
+				if s[q] && !seen[q] {
+					seen[q] = true
+					c[q] = true
+					queue = append(queue, q)
+				}
+			}
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+// scanOrder returns the pixels of s ordered by row, then column
+func scanOrder(s pixelSet) []image.Point {
+	result := make([]image.Point, 0, len(s))
+	for p := range s {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		return a.Y < b.Y || (a.Y == b.Y && a.X < b.X)
+	})
+	return result
+}
+
+// longestPath approximates the longest path through a component, from the
+// pixel farthest from an arbitrary start to the pixel farthest from that
+func longestPath(c pixelSet) []image.Point {
+	start := scanOrder(c)[0]
+	a, _ := bfs(c, start)
+	b, parents := bfs(c, a)
+	var result []image.Point
+	for p := b; p != a; p = parents[p] {
+		result = append(result, p)
+	}
+	return append(result, a)
+}
+
+// bfs returns the pixel of c farthest by path from start, and the parent of
+// each pixel on the paths explored
+func bfs(c pixelSet, start image.Point) (image.Point,
+	map[image.Point]image.Point) {
+	parents := map[image.Point]image.Point{start: start}
+	queue := []image.Point{start}
+	last := start
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		last = p
+		for _, d := range neighbours {
+			q := p.Add(d)
+			if _, ok := parents[q]; c[q] && !ok {
+				parents[q] = p
+				queue = append(queue, q)
+			}
+		}
+	}
+	return last, parents
+}
+
+// outline traces the outer edge of a component clockwise, by Moore
+// neighbour tracing from its first pixel in scan order
+func outline(c pixelSet) []image.Point {
+	start := scanOrder(c)[0]
+	result := []image.Point{start}
+	cur, back := start, start.Add(neighbours[0])
+	n := len(neighbours)
+	for steps := 0; steps < 4*len(c); steps++ {
+		k := direction(back.Sub(cur))
+		found := false
+		for i := 1; i <= n; i++ {
+			p := cur.Add(neighbours[(k+i)%n])
+			if c[p] {
+				back = cur.Add(neighbours[(k+i-1)%n])
+				cur = p
+				found = true
+				break
+			}
+		}
+		if !found || cur == start {
+			break
+		}
+		result = append(result, cur)
+	}
+	return append(result, start)
+}
+
+func direction(d image.Point) int {
+	for i, n := range neighbours {
+		if n == d {
+			return i
+		}
+	}
+	return 0
+}
+
+// simplify reduces a path to the points needed to follow it within
+// simplifyTolerance, by the Ramer–Douglas–Peucker algorithm
+func simplify(path []image.Point) []image.Point {
+	if len(path) < 3 {
+		return path
+	}
+	first, last := path[0], path[len(path)-1]
+	index, max := 0, 0.0
+	for i := 1; i < len(path)-1; i++ {
+		if d := distance(path[i], first, last); d > max {
+			index, max = i, d
+		}
+	}
+	if max <= simplifyTolerance {
+		return []image.Point{first, last}
+	}
+	left := simplify(path[:index+1])
+	return append(left[:len(left)-1], simplify(path[index:])...)
+}
+
+// distance returns the distance from p to the line through a and b
+func distance(p, a, b image.Point) float64 {
+	dx, dy := float64(b.X-a.X), float64(b.Y-a.Y)
+	l := math.Hypot(dx, dy)
+	if l == 0 {
+		return math.Hypot(float64(p.X-a.X), float64(p.Y-a.Y))
+	}
+	return math.Abs(dy*float64(p.X-a.X)-dx*float64(p.Y-a.Y)) / l
+}
+
+// toPoints converts pixels to Points relative to the image origin, at the
+// centre of each pixel
+func toPoints(pixels []image.Point, origin image.Point) []Point {
+	result := make([]Point, len(pixels))
+	for i, p := range pixels {
+		result[i] = Point{X: float64(p.X-origin.X) + 0.5,
+			Y: float64(p.Y-origin.Y) + 0.5}
+	}
+	return result
+}
@@ -0,0 +1,196 @@
+// floorplan renders persistent maps as SVG. The raw map image is traced into
+// rectangles of wall and explored floor, over which the map's zones are drawn
+// and labelled with their names and areas, and the dock is marked. Vector
+// output scales cleanly in web dashboards, unlike the PNG supplied by the
+// Beehive API.
+
+package floorplan
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"image"
+	"io"
+	"math"
+
+	"github.com/richlj/neato/nucleo"
+	"github.com/richlj/neato/units"
+)
+
+const (
+	// DefaultMetersPerPixel is the resolution of Neato map images
+	DefaultMetersPerPixel = 0.02
+
+	wallLuminance = 0.35
+	opaque        = 0x8000
+
+	floorColor    = "#e8eef4"
+	wallColor     = "#37474f"
+	zoneColor     = "#4fc3f7"
+	noGoColor     = "#e53935"
+	dockColor     = "#43a047"
+	labelColor    = "#263238"
+	labelFontSize = 12
+)
+
+// Plan is a persistent map to be rendered
+type Plan struct {
+	Image          image.Image
+	Boundaries     []nucleo.Boundary
+	MetersPerPixel float64
+	// Dock is the position of the base in image pixels, if known
+	Dock *image.Point
+}
+
+type cell int
+
+const (
+	unexplored cell = iota
+	floor
+	wall
+)
+
+type rect struct {
+	x, y, w, h int
+	c          cell
+}
+
+// SVG writes the Plan to w as an SVG document with the same dimensions as its
+// image
+func SVG(w io.Writer, p *Plan) error {
+	b := p.Image.Bounds()
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, `<svg xmlns="http://www.w3.org/2000/svg" `+
+		`viewBox="0 0 %d %d" width="%d" height="%d">`+"\n",
+		b.Dx(), b.Dy(), b.Dx(), b.Dy())
+	for _, r := range trace(p.Image) {
+		color := floorColor
+		if r.c == wall {
+			color = wallColor
+		}
+		fmt.Fprintf(out, `<rect x="%d" y="%d" width="%d" height="%d" `+
+			`fill="%s"/>`+"\n", r.x, r.y, r.w, r.h, color)
+	}
+	for _, bd := range p.Boundaries {
+		writeBoundary(out, p, &bd)
+	}
+	if p.Dock != nil {
+		fmt.Fprintf(out, `<circle cx="%d" cy="%d" r="6" fill="%s"/>`+
+			"\n", p.Dock.X-b.Min.X, p.Dock.Y-b.Min.Y, dockColor)
+		writeLabel(out, float64(p.Dock.X-b.Min.X),
+			float64(p.Dock.Y-b.Min.Y-10), "Dock")
+	}
+	fmt.Fprintln(out, "</svg>")
+	return out.Flush()
+}
+
+// Area returns the floor area enclosed by a polygon Boundary of the Plan
+func (p *Plan) Area(bd *nucleo.Boundary) units.Area {
+	if bd.Type != nucleo.BoundaryPolygon {
+		return 0
+	}
+	pts := p.Transform().Meters(bd)
+	var sum float64
+	for i := range pts {
+		j := (i + 1) % len(pts)
+		sum += pts[i].X*pts[j].Y - pts[j].X*pts[i].Y
+	}
+	return units.Area(math.Abs(sum) / 2)
+}
+
+// Transform returns the Transform between the Plan's coordinate systems
+func (p *Plan) Transform() Transform {
+	return NewTransform(p.Image, p.MetersPerPixel)
+}
+
+func writeBoundary(w io.Writer, p *Plan, bd *nucleo.Boundary) {
+	pts := p.Transform().Pixels(bd)
+	if len(pts) == 0 {
+		return
+	}
+	var coords string
+	for i, pt := range pts {
+		if i > 0 {
+			coords += " "
+		}
+		coords += fmt.Sprintf("%.1f,%.1f", pt.X, pt.Y)
+	}
+	if bd.Type != nucleo.BoundaryPolygon {
+		fmt.Fprintf(w, `<polyline points="%s" fill="none" stroke="%s" `+
+			`stroke-width="3"/>`+"\n", coords, noGoColor)
+		return
+	}
+	color := zoneColor
+	if bd.Color != "" {
+		color = bd.Color
+	}
+	fmt.Fprintf(w, `<polygon points="%s" fill="%s" fill-opacity="0.3" `+
+		`stroke="%s" stroke-width="2"/>`+"\n", coords,
+		html.EscapeString(color), html.EscapeString(color))
+	var cx, cy float64
+	for _, pt := range pts {
+		cx += pt.X
+		cy += pt.Y
+	}
+	cx /= float64(len(pts))
+	cy /= float64(len(pts))
+	label := p.Area(bd).String()
+	if bd.Name != "" {
+		label = bd.Name + " · " + label
+	}
+	writeLabel(w, cx, cy, label)
+}
+
+func writeLabel(w io.Writer, x, y float64, s string) {
+	fmt.Fprintf(w, `<text x="%.1f" y="%.1f" font-family="sans-serif" `+
+		`font-size="%d" text-anchor="middle" fill="%s">%s</text>`+"\n",
+		x, y, labelFontSize, labelColor, html.EscapeString(s))
+}
+
+// trace divides the image into rectangles of floor and wall, merging runs of
+// identical cells across rows to keep the output small
+func trace(img image.Image) []rect {
+	b := img.Bounds()
+	var (
+		result []rect
+		open   = make(map[[3]int]int)
+	)
+	for y := 0; y < b.Dy(); y++ {
+		next := make(map[[3]int]int)
+		py := b.Min.Y + y
+		for x := 0; x < b.Dx(); {
+			c := classify(img, b.Min.X+x, py)
+			start := x
+			for x < b.Dx() && classify(img, b.Min.X+x, py) == c {
+				x++
+			}
+			if c == unexplored {
+				continue
+			}
+			key := [3]int{start, x - start, int(c)}
+			if i, ok := open[key]; ok {
+				result[i].h++
+				next[key] = i
+				continue
+			}
+			result = append(result,
+				rect{x: start, y: y, w: x - start, h: 1, c: c})
+			next[key] = len(result) - 1
+		}
+		open = next
+	}
+	return result
+}
+
+func classify(img image.Image, x, y int) cell {
+	r, g, b, a := img.At(x, y).RGBA()
+	if a < opaque {
+		return unexplored
+	}
+	l := (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 0xffff
+	if l < wallLuminance {
+		return wall
+	}
+	return floor
+}
@@ -0,0 +1,123 @@
+// A Transform converts between the three coordinate systems used with
+// persistent maps. The map image is addressed in pixels from its top left
+// corner. Boundaries sent with setMapBoundaries give their vertices as
+// fractions of the image's width and height. Real-world positions are in
+// metres from the image's top left corner, with y increasing downwards.
+
+package floorplan
+
+import (
+	"image"
+
+	"github.com/richlj/neato/nucleo"
+)
+
+// Point is a position in pixels or metres
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Transform converts positions on a map image of the given size and
+// resolution
+type Transform struct {
+	Width          int
+	Height         int
+	MetersPerPixel float64
+}
+
+// NewTransform returns the Transform for a map image. A metersPerPixel of
+// zero selects DefaultMetersPerPixel.
+func NewTransform(img image.Image, metersPerPixel float64) Transform {
+	if metersPerPixel <= 0 {
+		metersPerPixel = DefaultMetersPerPixel
+	}
+	b := img.Bounds()
+	return Transform{Width: b.Dx(), Height: b.Dy(),
+		MetersPerPixel: metersPerPixel}
+}
+
+// PixelToBoundary converts a pixel position to boundary coordinates
+func (t Transform) PixelToBoundary(p Point) [2]float64 {
+	return [2]float64{p.X / float64(t.Width), p.Y / float64(t.Height)}
+}
+
+// BoundaryToPixel converts boundary coordinates to a pixel position
+func (t Transform) BoundaryToPixel(v [2]float64) Point {
+	return Point{X: v[0] * float64(t.Width), Y: v[1] * float64(t.Height)}
+}
+
+// PixelToMeters converts a pixel position to metres
+func (t Transform) PixelToMeters(p Point) Point {
+	return Point{X: p.X * t.MetersPerPixel, Y: p.Y * t.MetersPerPixel}
+}
+
+// MetersToPixel converts a position in metres to pixels
+func (t Transform) MetersToPixel(p Point) Point {
+	return Point{X: p.X / t.MetersPerPixel, Y: p.Y / t.MetersPerPixel}
+}
+
+// BoundaryToMeters converts boundary coordinates to metres
+func (t Transform) BoundaryToMeters(v [2]float64) Point {
+	return t.PixelToMeters(t.BoundaryToPixel(v))
+}
+
+// MetersToBoundary converts a position in metres to boundary coordinates
+func (t Transform) MetersToBoundary(p Point) [2]float64 {
+	return t.PixelToBoundary(t.MetersToPixel(p))
+}
+
+// Pixels returns the vertices of a Boundary in pixels
+func (t Transform) Pixels(b *nucleo.Boundary) []Point {
+	result := make([]Point, len(b.Vertices))
+	for i, v := range b.Vertices {
+		result[i] = t.BoundaryToPixel(v)
+	}
+	return result
+}
+
+// Meters returns the vertices of a Boundary in metres
+func (t Transform) Meters(b *nucleo.Boundary) []Point {
+	result := make([]Point, len(b.Vertices))
+	for i, v := range b.Vertices {
+		result[i] = t.BoundaryToMeters(v)
+	}
+	return result
+}
+
+// LineFromPixels returns a polyline Boundary through points given in pixels
+func (t Transform) LineFromPixels(points ...Point) nucleo.Boundary {
+	return t.fromPixels(nucleo.BoundaryPolyline, points)
+}
+
+// PolygonFromPixels returns a polygon Boundary with vertices given in pixels
+func (t Transform) PolygonFromPixels(points ...Point) nucleo.Boundary {
+	return t.fromPixels(nucleo.BoundaryPolygon, points)
+}
+
+// LineFromMeters returns a polyline Boundary through points given in metres
+func (t Transform) LineFromMeters(points ...Point) nucleo.Boundary {
+	return t.fromPixels(nucleo.BoundaryPolyline, t.toPixels(points))
+}
+
+// PolygonFromMeters returns a polygon Boundary with vertices given in metres
+func (t Transform) PolygonFromMeters(points ...Point) nucleo.Boundary {
+	return t.fromPixels(nucleo.BoundaryPolygon, t.toPixels(points))
+}
+
+func (t Transform) toPixels(points []Point) []Point {
+	result := make([]Point, len(points))
+	for i, p := range points {
+		result[i] = t.MetersToPixel(p)
+	}
+	return result
+}
+
+func (t Transform) fromPixels(kind string, points []Point) nucleo.Boundary {
+	b := nucleo.Boundary{Type: kind, Enabled: true,
+		Vertices: make([][2]float64, len(points))}
+	for i, p := range points {
+		b.Vertices[i] = t.PixelToBoundary(p)
+	}
+	return b
+}
@@ -0,0 +1,61 @@
+package grafana
+
+import (
+	"encoding/json"
+	"io"
+)
+
+type panel struct {
+	ID         int                 `json:"id"`
+	Type       string              `json:"type"`
+	Title      string              `json:"title"`
+	Datasource string              `json:"datasource"`
+	GridPos    map[string]int      `json:"gridPos"`
+	Targets    []map[string]string `json:"targets"`
+}
+
+// WriteDashboard writes the JSON definition of a Grafana dashboard which
+// graphs the charge, state and docking of every robot from the named
+// Datasource, with runs and errors shown as annotations. The result can be
+// imported through Grafana's dashboard import.
+func WriteDashboard(w io.Writer, title, datasource string) error {
+	var panels []panel
+	for i, m := range []struct{ metric, title, kind string }{
+		{MetricCharge, "Battery charge (%)", "timeseries"},
+		{MetricState, "State", "state-timeline"},
+		{MetricDocked, "Docked", "state-timeline"},
+	} {
+		panels = append(panels, panel{
+			ID:         i + 1,
+			Type:       m.kind,
+			Title:      m.title,
+			Datasource: datasource,
+			GridPos: map[string]int{
+				"x": 0, "y": i * 8, "w": 24, "h": 8,
+			},
+			Targets: []map[string]string{{
+				"refId": "A", "target": m.metric,
+				"type": "timeserie",
+			}},
+		})
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(map[string]interface{}{
+		"title":         title,
+		"schemaVersion": 36,
+		"time": map[string]string{
+			"from": "now-7d", "to": "now",
+		},
+		"refresh": "1m",
+		"panels":  panels,
+		"annotations": map[string]interface{}{
+			"list": []map[string]interface{}{{
+				"name":       "Runs and errors",
+				"datasource": datasource,
+				"enable":     true,
+				"iconColor":  "rgba(0, 150, 255, 1)",
+			}},
+		},
+	})
+}
@@ -0,0 +1,284 @@
+// grafana serves the history recorded in a store.Store to Grafana, using
+// the protocol of the SimpleJSON and JSON datasource plugins, and generates a
+// ready-made dashboard for it. The same data is available as plain JSON from
+// /runs for the Infinity plugin.
+
+package grafana
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/richlj/neato/store"
+)
+
+// The metrics served by a Datasource
+const (
+	MetricCharge = "charge"
+	MetricState  = "state"
+	MetricDocked = "docked"
+)
+
+var (
+	metrics = []string{MetricCharge, MetricState, MetricDocked}
+)
+
+// Datasource is an http.Handler serving the runs and states recorded in a
+// Store
+type Datasource struct {
+	Store store.Store
+
+	// Names maps serial numbers to the names under which robots are
+	// graphed. Robots missing from it are graphed by serial number.
+	Names map[string]string
+}
+
+type timeRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type queryRequest struct {
+	Range   timeRange `json:"range"`
+	Targets []struct {
+		Target string `json:"target"`
+	} `json:"targets"`
+}
+
+// Series is a single time series in a query response. Each datapoint is a
+// value and a time in milliseconds since the epoch.
+type Series struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// Annotation marks an event, such as the start of a run, on a graph
+type Annotation struct {
+	Annotation interface{} `json:"annotation"`
+	Time       int64       `json:"time"`
+	TimeEnd    int64       `json:"timeEnd,omitempty"`
+	Title      string      `json:"title"`
+	Text       string      `json:"text"`
+	Tags       []string    `json:"tags"`
+}
+
+// Run is a recorded cleaning run, with the last error the robot reported
+// during it
+type Run struct {
+	Robot     string    `json:"robot"`
+	Name      string    `json:"name"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end,omitempty"`
+	Area      float64   `json:"area"`
+	Completed bool      `json:"completed"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// ServeHTTP answers the SimpleJSON endpoints /, /search, /query and
+// /annotations, and serves the runs between the from and to query
+// parameters, as RFC 3339 times, from /runs
+func (d *Datasource) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch strings.TrimSuffix(req.URL.Path, "/") {
+	case "":
+		w.WriteHeader(http.StatusOK)
+	case "/search":
+		writeJSON(w, metrics)
+	case "/query":
+		var q queryRequest
+		if err := json.NewDecoder(req.Body).Decode(&q); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		series, err := d.query(req.Context(), &q)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, series)
+	case "/annotations":
+		var q struct {
+			Range      timeRange   `json:"range"`
+			Annotation interface{} `json:"annotation"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&q); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a, err := d.annotations(req.Context(), q.Range, q.Annotation)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, a)
+	case "/runs":
+		r, err := parseRange(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		runs, err := d.Runs(req.Context(), r.From, r.To)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, runs)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func parseRange(req *http.Request) (timeRange, error) {
+	r := timeRange{To: time.Now()}
+	var err error
+	if v := req.URL.Query().Get("from"); v != "" {
+		if r.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return r, err
+		}
+	}
+	if v := req.URL.Query().Get("to"); v != "" {
+		r.To, err = time.Parse(time.RFC3339, v)
+	}
+	return r, err
+}
+
+// query returns a Series per robot for each target
+func (d *Datasource) query(ctx context.Context, q *queryRequest) ([]Series,
+	error) {
+	states, err := d.Store.States(ctx, store.Query{Since: q.Range.From,
+		Until: q.Range.To})
+	if err != nil {
+		return nil, err
+	}
+	result := []Series{}
+	for _, t := range q.Targets {
+		byRobot := make(map[string]*Series)
+		var names []string
+		for _, st := range states {
+			v, ok := value(t.Target, &st)
+			if !ok {
+				continue
+			}
+			name := d.name(st.Robot)
+			s, ok := byRobot[name]
+			if !ok {
+				s = &Series{Target: name + " " + t.Target,
+					Datapoints: [][2]float64{}}
+				byRobot[name] = s
+				names = append(names, name)
+			}
+			s.Datapoints = append(s.Datapoints, [2]float64{v,
+				float64(millis(st.Time))})
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			result = append(result, *byRobot[n])
+		}
+	}
+	return result, nil
+}
+
+func value(metric string, st *store.State) (float64, bool) {
+	switch metric {
+	case MetricCharge:
+		return float64(st.Charge), true
+	case MetricState:
+		return float64(st.State), true
+	case MetricDocked:
+		if st.Docked {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// annotations marks each run, and each error, within r
+func (d *Datasource) annotations(ctx context.Context, r timeRange,
+	a interface{}) ([]Annotation, error) {
+	runs, err := d.Runs(ctx, r.From, r.To)
+	if err != nil {
+		return nil, err
+	}
+	states, err := d.Store.States(ctx, store.Query{Since: r.From,
+		Until: r.To})
+	if err != nil {
+		return nil, err
+	}
+	result := []Annotation{}
+	for _, run := range runs {
+		an := Annotation{Annotation: a, Time: millis(run.Start),
+			Title: run.Name + " cleaning",
+			Tags:  []string{"run", run.Name}}
+		if !run.End.IsZero() {
+			an.TimeEnd = millis(run.End)
+		}
+		result = append(result, an)
+	}
+	for _, st := range states {
+		if st.Error == "" {
+			continue
+		}
+		name := d.name(st.Robot)
+		result = append(result, Annotation{Annotation: a,
+			Time: millis(st.Time), Title: name + " error",
+			Text: st.Error, Tags: []string{"error", name}})
+	}
+	return result, nil
+}
+
+// Runs returns the runs which started from from up to but excluding to,
+// oldest first. A run still in progress has no End.
+func (d *Datasource) Runs(ctx context.Context, from, to time.Time) ([]Run,
+	error) {
+	runs, err := d.Store.Runs(ctx, store.Query{Since: from, Until: to})
+	if err != nil {
+		return nil, err
+	}
+	result := []Run{}
+	for _, r := range runs {
+		run := Run{Robot: r.Robot, Name: d.name(r.Robot),
+			Start: r.Start, End: r.End, Area: r.Area,
+			Completed: r.Completed}
+		end := r.End
+		if end.IsZero() {
+			end = to
+		}
+		states, err := d.Store.States(ctx, store.Query{Robot: r.Robot,
+			Since: r.Start, Until: end})
+		if err != nil {
+			return nil, err
+		}
+		for _, st := range states {
+			if st.Error != "" {
+				run.Error = st.Error
+			}
+		}
+		result = append(result, run)
+	}
+	return result, nil
+}
+
+// name returns the name under which the robot with serial is graphed
+func (d *Datasource) name(serial string) string {
+	if n, ok := d.Names[serial]; ok {
+		return n
+	}
+	return serial
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
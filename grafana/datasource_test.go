@@ -0,0 +1,85 @@
+package grafana
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/richlj/neato/store"
+)
+
+func TestDatasource(t *testing.T) {
+	s, err := store.OpenFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	start := time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)
+	runs := []store.Run{
+		{Robot: "OPS1", Start: start, End: start.Add(time.Hour),
+			Area: 42, Completed: true},
+		{Robot: "OPS2", Start: start.Add(2 * time.Hour)},
+	}
+	for _, r := range runs {
+		if err := s.PutRun(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, st := range []store.State{
+		{Robot: "OPS1", Charge: 90},
+		{Robot: "OPS1", Charge: 80, Error: "ui_error_brush_stuck"},
+		{Robot: "OPS1", Charge: 70, Docked: true},
+		{Robot: "OPS2", Charge: 50},
+	} {
+		st.Time = start.Add(time.Duration(i) * 20 * time.Minute)
+		if err := s.AddState(ctx, st); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := &Datasource{Store: s, Names: map[string]string{"OPS1": "Kitchen"}}
+
+	rec := httptest.NewRecorder()
+	d.ServeHTTP(rec, httptest.NewRequest("GET",
+		"/runs?from=2026-10-12T00:00:00Z&to=2026-10-13T00:00:00Z", nil))
+	var got []Run
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("/runs: %v: %s", err, rec.Body)
+	}
+	if len(got) != 2 || got[0].Name != "Kitchen" || got[0].Area != 42 ||
+		got[0].Error != "ui_error_brush_stuck" ||
+		got[1].Name != "OPS2" || got[1].Error != "" {
+		t.Errorf("/runs = %+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	d.ServeHTTP(rec, httptest.NewRequest("POST", "/query",
+		strings.NewReader(`{"range": {"from": "2026-10-12T00:00:00Z",
+		"to": "2026-10-13T00:00:00Z"},
+		"targets": [{"target": "charge"}]}`)))
+	var series []Series
+	if err := json.Unmarshal(rec.Body.Bytes(), &series); err != nil {
+		t.Fatalf("/query: %v: %s", err, rec.Body)
+	}
+	if len(series) != 2 || series[0].Target != "Kitchen charge" ||
+		len(series[0].Datapoints) != 3 ||
+		series[0].Datapoints[2][0] != 70 ||
+		series[1].Target != "OPS2 charge" {
+		t.Errorf("/query = %+v", series)
+	}
+
+	rec = httptest.NewRecorder()
+	d.ServeHTTP(rec, httptest.NewRequest("POST", "/annotations",
+		strings.NewReader(`{"range": {"from": "2026-10-12T00:00:00Z",
+		"to": "2026-10-13T00:00:00Z"}}`)))
+	var annotations []Annotation
+	if err := json.Unmarshal(rec.Body.Bytes(), &annotations); err != nil {
+		t.Fatalf("/annotations: %v: %s", err, rec.Body)
+	}
+	if len(annotations) != 3 || annotations[2].Title != "Kitchen error" {
+		t.Errorf("/annotations = %+v", annotations)
+	}
+}
@@ -0,0 +1,271 @@
+// homeassistant models robots as Home Assistant vacuum entities. Supported
+// features are derived from each robot's capabilities, persistent map zones
+// are exposed as segments, and the services Home Assistant calls on a vacuum,
+// including vacuum.send_command, are translated into robot commands.
+
+package homeassistant
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/richlj/neato/nucleo"
+)
+
+// Feature flags of Home Assistant's VacuumEntityFeature
+const (
+	FeaturePause       = 4
+	FeatureStop        = 8
+	FeatureReturnHome  = 16
+	FeatureFanSpeed    = 32
+	FeatureBattery     = 64
+	FeatureSendCommand = 256
+	FeatureLocate      = 512
+	FeatureCleanSpot   = 1024
+	FeatureState       = 4096
+	FeatureStart       = 8192
+)
+
+// Home Assistant vacuum states
+const (
+	StateCleaning  = "cleaning"
+	StateDocked    = "docked"
+	StatePaused    = "paused"
+	StateIdle      = "idle"
+	StateReturning = "returning"
+	StateError     = "error"
+)
+
+// Fan speeds, corresponding to the eco and turbo cleaning modes
+const (
+	FanSpeedEco   = "eco"
+	FanSpeedTurbo = "turbo"
+)
+
+const (
+	categoryHouse = 2
+	categoryMap   = 4
+
+	modeEco   = 1
+	modeTurbo = 2
+
+	modifierNormal   = 1
+	navigationNormal = 1
+
+	spotSize = 200
+)
+
+// Segment is a zone of a persistent map which can be cleaned on its own
+type Segment struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Entity is a robot modelled as a Home Assistant vacuum entity
+type Entity struct {
+	UniqueID          string    `json:"unique_id"`
+	Name              string    `json:"name"`
+	SupportedFeatures int       `json:"supported_features"`
+	FanSpeedList      []string  `json:"fan_speed_list,omitempty"`
+	Segments          []Segment `json:"segments,omitempty"`
+
+	robot        nucleo.RobotService
+	capabilities nucleo.Capabilities
+
+	mu       sync.Mutex
+	fanSpeed string
+}
+
+// Attributes are the state and attributes of an Entity, as reported to Home
+// Assistant
+type Attributes struct {
+	State        string `json:"state"`
+	BatteryLevel int    `json:"battery_level"`
+	FanSpeed     string `json:"fan_speed,omitempty"`
+	Status       string `json:"status"`
+}
+
+// NewEntity models a robot as a vacuum entity, identified by its serial
+// number and supporting the features allowed by its Capabilities. The
+// supplied segments are exposed for zone cleaning if the robot supports it.
+func NewEntity(serial, name string, r nucleo.RobotService,
+	c nucleo.Capabilities, segments []Segment) *Entity {
+	e := &Entity{
+		UniqueID: serial,
+		Name:     name,
+		SupportedFeatures: FeatureStart | FeaturePause | FeatureStop |
+			FeatureReturnHome | FeatureBattery | FeatureState |
+			FeatureSendCommand,
+		robot:        r,
+		capabilities: c,
+	}
+	if c.EcoMode() {
+		e.SupportedFeatures |= FeatureFanSpeed
+		e.FanSpeedList = []string{FanSpeedEco, FanSpeedTurbo}
+		e.fanSpeed = FanSpeedEco
+	}
+	if c.Supports("findMe") {
+		e.SupportedFeatures |= FeatureLocate
+	}
+	if c.SpotCleaning() {
+		e.SupportedFeatures |= FeatureCleanSpot
+	}
+	if c.Zones() {
+		e.Segments = segments
+	}
+	return e
+}
+
+// State maps a RobotState to the Home Assistant vacuum state
+func State(s *nucleo.RobotState) string {
+	switch {
+	case s.State == nucleo.StateError || s.Error != "":
+		return StateError
+	case s.State == nucleo.StatePaused:
+		return StatePaused
+	case s.State == nucleo.StateBusy && s.Action == nucleo.ActionDocking:
+		return StateReturning
+	case s.State == nucleo.StateBusy:
+		return StateCleaning
+	case s.Docked:
+		return StateDocked
+	}
+	return StateIdle
+}
+
+// Attributes retrieves the current state and attributes of the Entity
+func (e *Entity) Attributes() (*Attributes, error) {
+	s, err := e.robot.State()
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return &Attributes{
+		State:        State(s),
+		BatteryLevel: s.Charge,
+		FanSpeed:     e.fanSpeed,
+		Status:       s.Summary(),
+	}, nil
+}
+
+// Call handles a Home Assistant vacuum service call, e.g. "start" or
+// "return_to_base", with the supplied service data
+func (e *Entity) Call(service string, data map[string]interface{}) error {
+	var err error
+	switch service {
+	case "start":
+		err = e.start("")
+	case "pause":
+		_, err = e.robot.PauseCleaning(nil)
+	case "stop":
+		_, err = e.robot.StopCleaning(nil)
+	case "return_to_base":
+		_, err = e.robot.SendToBase(nil)
+	case "locate":
+		_, err = e.robot.FindMe(nil)
+	case "clean_spot":
+		_, err = e.robot.StartSpotCleaning(spotSize, spotSize, false)
+	case "set_fan_speed":
+		err = e.setFanSpeed(data["fan_speed"])
+	case "send_command":
+		cmd, _ := data["command"].(string)
+		params, _ := data["params"].(map[string]interface{})
+		err = e.SendCommand(cmd, params)
+	default:
+		err = fmt.Errorf("unsupported service %q", service)
+	}
+	return err
+}
+
+// SendCommand translates a vacuum.send_command call. The commands supported
+// are "clean_segment" with a "segment" parameter naming a Segment by ID or
+// name, and the names of the robot's Nucleo commands which take no
+// parameters, e.g. "dismissCurrentAlert".
+func (e *Entity) SendCommand(cmd string, params map[string]interface{}) error {
+	switch cmd {
+	case "clean_segment", "app_segment_clean":
+		id, err := e.segment(params["segment"])
+		if err != nil {
+			return err
+		}
+		return e.start(id)
+	case "findMe":
+		_, err := e.robot.FindMe(nil)
+		return err
+	case "startCleaning":
+		return e.start("")
+	case "pauseCleaning":
+		_, err := e.robot.PauseCleaning(nil)
+		return err
+	case "resumeCleaning":
+		_, err := e.robot.ResumeCleaning(nil)
+		return err
+	case "stopCleaning":
+		_, err := e.robot.StopCleaning(nil)
+		return err
+	case "sendToBase":
+		_, err := e.robot.SendToBase(nil)
+		return err
+	}
+	return fmt.Errorf("unsupported command %q", cmd)
+}
+
+// start begins cleaning the whole house, or the zone with the supplied
+// boundary ID, resuming instead if the robot is paused
+func (e *Entity) start(boundaryID string) error {
+	s, err := e.robot.State()
+	if err != nil {
+		return err
+	}
+	if s.State == nucleo.StatePaused && boundaryID == "" {
+		_, err = e.robot.ResumeCleaning(nil)
+		return err
+	}
+	p := &nucleo.Params{
+		Category:       categoryHouse,
+		Mode:           e.mode(),
+		Modifier:       modifierNormal,
+		NavigationMode: navigationNormal,
+		BoundaryID:     boundaryID,
+	}
+	if e.capabilities.PersistentMaps() {
+		p.Category = categoryMap
+	}
+	_, err = e.robot.StartCleaning(p)
+	return err
+}
+
+func (e *Entity) mode() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.fanSpeed == FanSpeedTurbo {
+		return modeTurbo
+	}
+	return modeEco
+}
+
+func (e *Entity) setFanSpeed(v interface{}) error {
+	s, _ := v.(string)
+	if s != FanSpeedEco && s != FanSpeedTurbo {
+		return fmt.Errorf("unsupported fan speed %v", v)
+	}
+	if e.SupportedFeatures&FeatureFanSpeed == 0 {
+		return fmt.Errorf("fan speed is not supported")
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.fanSpeed = s
+	return nil
+}
+
+// segment returns the boundary ID of the Segment identified by v
+func (e *Entity) segment(v interface{}) (string, error) {
+	s, _ := v.(string)
+	for _, seg := range e.Segments {
+		if seg.ID == s || seg.Name == s {
+			return seg.ID, nil
+		}
+	}
+	return "", fmt.Errorf("unknown segment %v", v)
+}
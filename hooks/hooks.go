@@ -0,0 +1,159 @@
+// hooks exposes robot actions as authenticated inbound webhooks, so that
+// IFTTT, Shortcuts, doorbells, alarm systems and the like can trigger them
+// without knowing anything of the Neato APIs:
+//
+//	POST /hooks/start
+//	POST /hooks/dock
+//
+// An OpenAPI document describing the routes is served at /openapi.json, so
+// that typed clients can be generated for front-ends.
+//
+// Each route accepts only the tokens registered for it, supplied as a bearer
+// token or as the token query parameter for callers that cannot set headers.
+// Where more than one robot is registered, the robot query parameter selects
+// one by name.
+
+package hooks
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/richlj/neato/nucleo"
+)
+
+const (
+	prefix       = "/hooks/"
+	bearerPrefix = "Bearer "
+)
+
+var (
+	// actions maps route names to the commands they issue
+	actions = map[string]func(nucleo.CleaningService,
+		*nucleo.Params) (*nucleo.Response, error){
+		"start":  nucleo.CleaningService.StartCleaning,
+		"stop":   nucleo.CleaningService.StopCleaning,
+		"pause":  nucleo.CleaningService.PauseCleaning,
+		"resume": nucleo.CleaningService.ResumeCleaning,
+		"dock":   nucleo.CleaningService.SendToBase,
+	}
+)
+
+// Handler serves the webhook routes
+type Handler struct {
+	mu     sync.RWMutex
+	robots map[string]nucleo.CleaningService
+	tokens map[string][]string
+}
+
+// NewHandler returns a Handler with no robots or tokens registered
+func NewHandler() *Handler {
+	return &Handler{
+		robots: make(map[string]nucleo.CleaningService),
+		tokens: make(map[string][]string),
+	}
+}
+
+// AddRobot makes a robot available to the webhooks under the supplied name
+func (h *Handler) AddRobot(name string, r nucleo.CleaningService) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.robots[name] = r
+}
+
+// Allow permits token to trigger the named route, e.g. "start" or "dock"
+func (h *Handler) Allow(route, token string) error {
+	if _, ok := actions[route]; !ok {
+		return fmt.Errorf("unknown route %q", route)
+	}
+	if token == "" {
+		return fmt.Errorf("empty token for route %q", route)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.tokens[route] = append(h.tokens[route], token)
+	return nil
+}
+
+// ServeHTTP handles POST /hooks/{route}, and serves the OpenAPI document for
+// the routes at GET /openapi.json
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == SpecPath {
+		serveSpec(w, req)
+		return
+	}
+	if !strings.HasPrefix(req.URL.Path, prefix) {
+		http.NotFound(w, req)
+		return
+	}
+	route := strings.TrimPrefix(req.URL.Path, prefix)
+	action, ok := actions[route]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorized(route, req) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	r, err := h.robot(req.URL.Query().Get("robot"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	resp, err := action(r, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"result": resp.Result.String(),
+	})
+}
+
+func (h *Handler) authorized(route string, req *http.Request) bool {
+	got := req.URL.Query().Get("token")
+	if a := req.Header.Get("Authorization"); strings.HasPrefix(a,
+		bearerPrefix) {
+		got = strings.TrimPrefix(a, bearerPrefix)
+	}
+	if got == "" {
+		return false
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, t := range h.tokens[route] {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(got)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// robot returns the named robot, or the only robot if name is empty
+func (h *Handler) robot(name string) (nucleo.CleaningService, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if name == "" {
+		if len(h.robots) != 1 {
+			return nil, fmt.Errorf("robot must be specified")
+		}
+		for _, r := range h.robots {
+			return r, nil
+		}
+	}
+	r, ok := h.robots[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown robot %q", name)
+	}
+	return r, nil
+}
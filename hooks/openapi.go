@@ -0,0 +1,121 @@
+package hooks
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+const (
+	// SpecPath is where a Handler serves its OpenAPI document
+	SpecPath = "/openapi.json"
+
+	openAPIVersion = "3.0.3"
+)
+
+var (
+	summaries = map[string]string{
+		"start":  "Start cleaning",
+		"stop":   "Stop cleaning",
+		"pause":  "Pause cleaning",
+		"resume": "Resume cleaning",
+		"dock":   "Send the robot to its base",
+	}
+)
+
+// OpenAPI returns an OpenAPI 3 document describing the webhook routes, from
+// which typed clients can be generated
+func OpenAPI() map[string]interface{} {
+	var routes []string
+	for r := range actions {
+		routes = append(routes, r)
+	}
+	sort.Strings(routes)
+	robot := map[string]interface{}{
+		"name":        "robot",
+		"in":          "query",
+		"description": "The robot to command, if more than one",
+		"schema":      map[string]string{"type": "string"},
+	}
+	result := map[string]interface{}{
+		"schema": map[string]string{
+			"$ref": "#/components/schemas/Result",
+		},
+	}
+	responses := map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": "The command was issued",
+			"content": map[string]interface{}{
+				"application/json": result,
+			},
+		},
+		"401": map[string]string{
+			"description": "The token is missing or not " +
+				"permitted for the route",
+		},
+		"404": map[string]string{
+			"description": "The robot is unknown",
+		},
+		"502": map[string]string{
+			"description": "The robot could not be reached or " +
+				"rejected the command",
+		},
+	}
+	paths := make(map[string]interface{})
+	for _, r := range routes {
+		paths[prefix+r] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"operationId": r,
+				"summary":     summaries[r],
+				"parameters":  []interface{}{robot},
+				"responses":   responses,
+			},
+		}
+	}
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]string{
+			"title":   "neato webhooks",
+			"version": "1",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"Result": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"result": map[string]string{
+							"type": "string",
+						},
+					},
+				},
+			},
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]string{
+					"type":   "http",
+					"scheme": "bearer",
+				},
+				"query": map[string]string{
+					"type": "apiKey",
+					"in":   "query",
+					"name": "token",
+				},
+			},
+		},
+		"security": []interface{}{
+			map[string][]string{"bearer": {}},
+			map[string][]string{"query": {}},
+		},
+	}
+}
+
+func serveSpec(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	_ = e.Encode(OpenAPI())
+}
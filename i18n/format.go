@@ -0,0 +1,126 @@
+package i18n
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/richlj/neato/units"
+)
+
+var (
+	// imperialRegions lists the regions in which areas are given in square
+	// feet
+	imperialRegions = []string{"us", "lr", "mm"}
+
+	// decimalCommaLanguages lists the languages which use a comma as the
+	// decimal separator
+	decimalCommaLanguages = []string{"cs", "da", "de", "es", "fi", "fr",
+		"it", "nb", "nl", "pl", "pt", "ru", "sv"}
+
+	// DefaultFormatter is used when formatting values without an explicit
+	// locale
+	DefaultFormatter = &Formatter{
+		Language:         defaultLanguage,
+		Units:            units.Metric,
+		DecimalSeparator: ".",
+	}
+)
+
+// Formatter presents Areas, Durations and messages in human-readable form for
+// a particular locale
+type Formatter struct {
+	Language         string
+	Units            units.UnitSystem
+	DecimalSeparator string
+}
+
+// NewFormatter returns a Formatter for the supplied locale, which may be
+// given in any of the forms used by the Neato APIs, e.g. "en", "en_US" or
+// "de-DE", such as the locale set in a Robot's preferences
+func NewFormatter(locale string) *Formatter {
+	lang, region := splitLocale(locale)
+	f := &Formatter{
+		Language:         lang,
+		Units:            units.Metric,
+		DecimalSeparator: ".",
+	}
+	if contains(imperialRegions, region) {
+		f.Units = units.Imperial
+	}
+	if contains(decimalCommaLanguages, lang) {
+		f.DecimalSeparator = ","
+	}
+	return f
+}
+
+// Area formats an Area, e.g. "23.4 m²" or "251.9 sq ft"
+func (f *Formatter) Area(a units.Area) string {
+	if f.Units == units.Imperial {
+		return f.Message("area.imperial", f.decimal(a.SquareFeet()))
+	}
+	return f.Message("area.metric", f.decimal(a.SquareMeters()))
+}
+
+// Duration formats a Duration to the nearest minute, e.g. "41 min" or
+// "1 h 12 min"
+func (f *Formatter) Duration(d units.Duration) string {
+	m := d.RoundedMinutes()
+	if m < 60 {
+		return f.Message("duration.minutes", m)
+	}
+	if m%60 == 0 {
+		return f.Message("duration.hours", m/60)
+	}
+	return f.Message("duration.hours_minutes", m/60, m%60)
+}
+
+// AreaDuration formats an Area cleaned over a Duration, e.g.
+// "23.4 m² in 41 min"
+func (f *Formatter) AreaDuration(a units.Area, d units.Duration) string {
+	return f.Message("area_duration", f.Area(a), f.Duration(d))
+}
+
+// Line formats a labelled line of a multi-line description, where label is
+// the key of the label's message, e.g. "Battery: 54%"
+func (f *Formatter) Line(label string, v interface{}) string {
+	return fmt.Sprintf("%s: %v\n", f.Message(label), v)
+}
+
+// Describe returns the message which keys maps v to, e.g. the description
+// of a numeric state, or a placeholder if v is unknown
+func (f *Formatter) Describe(keys map[int]string, v int) string {
+	if key, ok := keys[v]; ok {
+		return f.Message(key)
+	}
+	return f.Message("unknown", v)
+}
+
+func (f *Formatter) decimal(v float64) string {
+	s := fmt.Sprintf("%.1f", v)
+	if f.DecimalSeparator != "" && f.DecimalSeparator != "." {
+		s = strings.Replace(s, ".", f.DecimalSeparator, 1)
+	}
+	return s
+}
+
+func splitLocale(locale string) (lang, region string) {
+	a := strings.FieldsFunc(strings.ToLower(locale), func(r rune) bool {
+		return r == '_' || r == '-'
+	})
+	if len(a) > 0 {
+		lang = a[0]
+	}
+	if len(a) > 1 {
+		region = a[1]
+	}
+	return lang, region
+}
+
+func contains(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package i18n
+
+import (
+	"testing"
+
+	"github.com/richlj/neato/units"
+)
+
+func TestNewFormatter(t *testing.T) {
+	tests := []struct {
+		locale    string
+		language  string
+		units     units.UnitSystem
+		separator string
+	}{
+		{"", "", units.Metric, "."},
+		{"en", "en", units.Metric, "."},
+		{"en_US", "en", units.Imperial, "."},
+		{"en-GB", "en", units.Metric, "."},
+		{"de-DE", "de", units.Metric, ","},
+		{"DE_de", "de", units.Metric, ","},
+	}
+	for _, tt := range tests {
+		f := NewFormatter(tt.locale)
+		if f.Language != tt.language || f.Units != tt.units ||
+			f.DecimalSeparator != tt.separator {
+			t.Errorf("NewFormatter(%q) = %+v", tt.locale, *f)
+		}
+	}
+}
+
+func TestAreaDuration(t *testing.T) {
+	tests := []struct {
+		locale  string
+		area    units.Area
+		seconds int
+		want    string
+	}{
+		{"en", 23.4, 41 * 60, "23.4 m² in 41 min"},
+		{"en_US", 23.4, 41 * 60, "251.9 sq ft in 41 min"},
+		{"en", 50, 60 * 60, "50.0 m² in 1 h"},
+		{"en", 50, 72 * 60, "50.0 m² in 1 h 12 min"},
+		{"de_DE", 23.4, 72 * 60, "23,4 m² in 1 Std. 12 Min."},
+	}
+	for _, tt := range tests {
+		got := NewFormatter(tt.locale).AreaDuration(tt.area,
+			units.Seconds(tt.seconds))
+		if got != tt.want {
+			t.Errorf("%s: AreaDuration(%v, %ds) = %q, want %q",
+				tt.locale, tt.area, tt.seconds, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+// i18n presents user-facing strings in the language configured on a robot or
+// chosen by the user. Messages are looked up in a catalog keyed by language,
+// and quantities are formatted in the units and number format of the locale.
+
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultLanguage = "en"
+)
+
+var (
+	messages = map[string]map[string]string{
+		"en": {
+			"area.metric":              "%s m²",
+			"area.imperial":            "%s sq ft",
+			"duration.minutes":         "%d min",
+			"duration.hours":           "%d h",
+			"duration.hours_minutes":   "%d h %d min",
+			"area_duration":            "%s in %s",
+			"run.summary":              "%s cleaned %s",
+			"run.mode":                 " (%s)",
+			"run.recharged_once":       ", interrupted once to recharge",
+			"run.recharged_n":          ", interrupted %d times to recharge",
+			"run.error":                ", ended with error %q",
+			"status.summary":           "%s %s, battery at %d%%",
+			"status.error":             "has an error (%s)",
+			"status.docked_charging":   "is docked and charging",
+			"status.docked":            "is docked",
+			"status.active":            "is %s",
+			"label.started":            "Started",
+			"label.ended":              "Ended",
+			"label.area":               "Area",
+			"label.duration":           "Duration",
+			"label.recharges":          "Recharges",
+			"label.status":             "Status",
+			"label.error":              "Error",
+			"label.state":              "State",
+			"label.action":             "Action",
+			"label.battery":            "Battery",
+			"label.docked":             "Docked",
+			"label.alert":              "Alert",
+			"bool.true":                "yes",
+			"bool.false":               "no",
+			"unknown":                  "unknown (%d)",
+			"mode.eco":                 "eco",
+			"mode.turbo":               "turbo",
+			"state.idle":               "idle",
+			"state.busy":               "busy",
+			"state.paused":             "paused",
+			"state.error":              "in error",
+			"action.house_cleaning":    "house cleaning",
+			"action.spot_cleaning":     "spot cleaning",
+			"action.manual_cleaning":   "manual cleaning",
+			"action.docking":           "docking",
+			"action.user_menu":         "in the user menu",
+			"action.suspended":         "suspended cleaning",
+			"action.updating":          "updating",
+			"action.copying_logs":      "copying logs",
+			"action.recovering":        "recovering location",
+			"action.iec_test":          "running the IEC test",
+			"action.map_cleaning":      "map cleaning",
+			"action.exploring":         "exploring the map",
+			"action.acquiring_ids":     "acquiring persistent map IDs",
+			"action.uploading_map":     "creating and uploading a map",
+			"action.suspended_explore": "suspended exploration",
+
+			"alert.ui_alert_dust_bin_full":            "The dust bin is full",
+			"alert.ui_alert_recovering_location":      "Recovering its location",
+			"alert.ui_alert_return_to_base":           "Returning to base",
+			"alert.ui_alert_return_to_charge":         "Returning to base to charge",
+			"alert.ui_alert_busy_charging":            "Busy charging",
+			"alert.ui_alert_timed_charging":           "Charging before the next run",
+			"alert.ui_alert_disconnected":             "Disconnected from the cloud",
+			"alert.ui_alert_brush_change":             "The brush needs changing",
+			"alert.ui_alert_filter_change":            "The filter needs changing",
+			"alert.ui_alert_swupdate_fail":            "The software update failed",
+			"alert.dustbin_full":                      "The dust bin is full",
+			"alert.maint_brush_change":                "The brush needs changing",
+			"alert.maint_filter_change":               "The filter needs changing",
+			"alert.ui_error_dust_bin_full":            "The dust bin is full",
+			"alert.ui_error_dust_bin_missing":         "The dust bin is missing",
+			"alert.ui_error_dust_bin_emptied":         "The dust bin has been emptied",
+			"alert.ui_error_brush_stuck":              "The brush is stuck",
+			"alert.ui_error_brush_overloaded":         "The brush is overloaded",
+			"alert.ui_error_bumper_stuck":             "The bumper is stuck",
+			"alert.ui_error_lwheel_stuck":             "The left wheel is stuck",
+			"alert.ui_error_rwheel_stuck":             "The right wheel is stuck",
+			"alert.ui_error_lds_jammed":               "The laser distance sensor is jammed",
+			"alert.ui_error_lds_disconnected":         "The laser distance sensor is disconnected",
+			"alert.ui_error_lds_bad_packets":          "The laser distance sensor is sending bad data",
+			"alert.ui_error_lds_missed_packets":       "The laser distance sensor is missing data",
+			"alert.ui_error_rdrop_stuck":              "The right drop sensor is stuck",
+			"alert.ui_error_ldrop_stuck":              "The left drop sensor is stuck",
+			"alert.ui_error_picked_up":                "The robot was picked up",
+			"alert.ui_error_navigation_falling":       "The robot cannot navigate safely",
+			"alert.ui_error_navigation_noprogress":    "The robot is unable to make progress",
+			"alert.ui_error_unable_to_return_to_base": "The robot cannot return to base",
+			"alert.ui_error_battery_overtemp":         "The battery is too hot",
+			"alert.ui_error_hardware_failure":         "A hardware failure has occurred",
+		},
+		"de": {
+			"area.metric":              "%s m²",
+			"area.imperial":            "%s sq ft",
+			"duration.minutes":         "%d Min.",
+			"duration.hours":           "%d Std.",
+			"duration.hours_minutes":   "%d Std. %d Min.",
+			"area_duration":            "%s in %s",
+			"run.summary":              "%s hat %s gereinigt",
+			"run.mode":                 " (%s)",
+			"run.recharged_once":       ", einmal zum Aufladen unterbrochen",
+			"run.recharged_n":          ", %d-mal zum Aufladen unterbrochen",
+			"run.error":                ", mit Fehler %q beendet",
+			"status.summary":           "%s %s, Akku bei %d %%",
+			"status.error":             "hat einen Fehler (%s)",
+			"status.docked_charging":   "lädt in der Ladestation",
+			"status.docked":            "ist angedockt",
+			"status.active":            "ist im Modus „%s“",
+			"label.started":            "Gestartet",
+			"label.ended":              "Beendet",
+			"label.area":               "Fläche",
+			"label.duration":           "Dauer",
+			"label.recharges":          "Ladevorgänge",
+			"label.status":             "Status",
+			"label.error":              "Fehler",
+			"label.state":              "Zustand",
+			"label.action":             "Aktion",
+			"label.battery":            "Akku",
+			"label.docked":             "Angedockt",
+			"label.alert":              "Hinweis",
+			"bool.true":                "ja",
+			"bool.false":               "nein",
+			"unknown":                  "unbekannt (%d)",
+			"mode.eco":                 "Eco",
+			"mode.turbo":               "Turbo",
+			"state.idle":               "Leerlauf",
+			"state.busy":               "beschäftigt",
+			"state.paused":             "pausiert",
+			"state.error":              "Fehler",
+			"action.house_cleaning":    "Hausreinigung",
+			"action.spot_cleaning":     "Punktreinigung",
+			"action.manual_cleaning":   "manuelle Reinigung",
+			"action.docking":           "Andocken",
+			"action.user_menu":         "Benutzermenü",
+			"action.suspended":         "Reinigung unterbrochen",
+			"action.updating":          "Aktualisierung",
+			"action.copying_logs":      "Protokolle kopieren",
+			"action.recovering":        "Standortbestimmung",
+			"action.iec_test":          "IEC-Test",
+			"action.map_cleaning":      "Kartenreinigung",
+			"action.exploring":         "Kartenerkundung",
+			"action.acquiring_ids":     "Karten-IDs abrufen",
+			"action.uploading_map":     "Karte erstellen und hochladen",
+			"action.suspended_explore": "Erkundung unterbrochen",
+
+			"alert.ui_alert_dust_bin_full":            "Der Staubbehälter ist voll",
+			"alert.ui_alert_recovering_location":      "Standort wird ermittelt",
+			"alert.ui_alert_return_to_base":           "Kehrt zur Ladestation zurück",
+			"alert.ui_alert_return_to_charge":         "Kehrt zum Aufladen zur Ladestation zurück",
+			"alert.ui_alert_busy_charging":            "Lädt gerade",
+			"alert.ui_alert_timed_charging":           "Lädt vor der nächsten Reinigung",
+			"alert.ui_alert_disconnected":             "Keine Verbindung zur Cloud",
+			"alert.ui_alert_brush_change":             "Die Bürste muss gewechselt werden",
+			"alert.ui_alert_filter_change":            "Der Filter muss gewechselt werden",
+			"alert.ui_alert_swupdate_fail":            "Das Software-Update ist fehlgeschlagen",
+			"alert.dustbin_full":                      "Der Staubbehälter ist voll",
+			"alert.maint_brush_change":                "Die Bürste muss gewechselt werden",
+			"alert.maint_filter_change":               "Der Filter muss gewechselt werden",
+			"alert.ui_error_dust_bin_full":            "Der Staubbehälter ist voll",
+			"alert.ui_error_dust_bin_missing":         "Der Staubbehälter fehlt",
+			"alert.ui_error_dust_bin_emptied":         "Der Staubbehälter wurde geleert",
+			"alert.ui_error_brush_stuck":              "Die Bürste klemmt",
+			"alert.ui_error_brush_overloaded":         "Die Bürste ist überlastet",
+			"alert.ui_error_bumper_stuck":             "Der Stoßfänger klemmt",
+			"alert.ui_error_lwheel_stuck":             "Das linke Rad klemmt",
+			"alert.ui_error_rwheel_stuck":             "Das rechte Rad klemmt",
+			"alert.ui_error_lds_jammed":               "Der Laser-Abstandssensor klemmt",
+			"alert.ui_error_lds_disconnected":         "Der Laser-Abstandssensor ist nicht verbunden",
+			"alert.ui_error_lds_bad_packets":          "Der Laser-Abstandssensor sendet fehlerhafte Daten",
+			"alert.ui_error_lds_missed_packets":       "Der Laser-Abstandssensor sendet unvollständige Daten",
+			"alert.ui_error_rdrop_stuck":              "Der rechte Absturzsensor klemmt",
+			"alert.ui_error_ldrop_stuck":              "Der linke Absturzsensor klemmt",
+			"alert.ui_error_picked_up":                "Der Roboter wurde hochgehoben",
+			"alert.ui_error_navigation_falling":       "Der Roboter kann nicht sicher navigieren",
+			"alert.ui_error_navigation_noprogress":    "Der Roboter kommt nicht weiter",
+			"alert.ui_error_unable_to_return_to_base": "Der Roboter findet nicht zur Ladestation zurück",
+			"alert.ui_error_battery_overtemp":         "Der Akku ist zu heiß",
+			"alert.ui_error_hardware_failure":         "Ein Hardwarefehler ist aufgetreten",
+		},
+	}
+)
+
+// languageNames maps the languages offered by robots to their names in
+// their own language
+var languageNames = map[string]string{
+	"cs": "Čeština",
+	"da": "Dansk",
+	"de": "Deutsch",
+	"en": "English",
+	"es": "Español",
+	"fi": "Suomi",
+	"fr": "Français",
+	"it": "Italiano",
+	"ja": "日本語",
+	"ko": "한국어",
+	"nb": "Norsk bokmål",
+	"nl": "Nederlands",
+	"pl": "Polski",
+	"pt": "Português",
+	"ru": "Русский",
+	"sv": "Svenska",
+	"zh": "中文",
+}
+
+// LanguageName returns the name of the language of locale, e.g. "Deutsch"
+// for "de_DE", in that language, or locale itself if it is not known
+func LanguageName(locale string) string {
+	lang, _ := splitLocale(locale)
+	if name, ok := languageNames[lang]; ok {
+		return name
+	}
+	return locale
+}
+
+// Languages returns the languages for which messages are available
+func Languages() []string {
+	var result []string
+	for k := range messages {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Message returns the message identified by key in the Formatter's language,
+// formatted with the supplied arguments. Messages missing from a language
+// fall back to English, and unknown keys are returned as they are.
+func (f *Formatter) Message(key string, args ...interface{}) string {
+	format, ok := messages[f.language()][key]
+	if !ok {
+		if format, ok = messages[defaultLanguage][key]; !ok {
+			return key
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Alert returns a human description of an alert or error code reported by a
+// Robot, e.g. "ui_alert_dust_bin_full"
+func (f *Formatter) Alert(code string) string {
+	if s := f.Message("alert." + code); s != "alert."+code {
+		return s
+	}
+	s := strings.TrimPrefix(strings.TrimPrefix(code, "ui_alert_"),
+		"ui_error_")
+	return strings.Replace(s, "_", " ", -1)
+}
+
+func (f *Formatter) language() string {
+	if _, ok := messages[f.Language]; ok {
+		return f.Language
+	}
+	return defaultLanguage
+}
@@ -0,0 +1,148 @@
+// journal records the state transitions observed by a watch.Watcher in a
+// store.Store, together with the command which triggered each where it is
+// known, so that questions such as "what did the robot do last night?" can be
+// answered after the fact. The runs the Watcher sees are recorded in the Store
+// too. How much history is kept is left to the Store's retention Policy.
+
+package journal
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/richlj/neato/clock"
+	"github.com/richlj/neato/store"
+	"github.com/richlj/neato/watch"
+)
+
+const (
+	// commandWindow is how long after a command is issued a transition may
+	// be attributed to it
+	commandWindow = 2 * time.Minute
+)
+
+type pendingCommand struct {
+	cmd string
+	at  time.Time
+}
+
+// Journal records state transitions and runs in a Store
+type Journal struct {
+	Store store.Store
+
+	// Clock times the commands noted. It defaults to clock.Real.
+	Clock clock.Clock
+
+	mu       sync.Mutex
+	commands map[string]pendingCommand
+	// recorded is when each robot's state was last recorded, as a poll
+	// may yield several Events for the same state
+	recorded map[string]time.Time
+	// started is when each robot's run in progress started
+	started map[string]time.Time
+}
+
+// New returns a Journal recording in s
+func New(s store.Store) *Journal {
+	return &Journal{
+		Store:    s,
+		commands: make(map[string]pendingCommand),
+		recorded: make(map[string]time.Time),
+		started:  make(map[string]time.Time),
+	}
+}
+
+// NoteCommand records that cmd was issued to the identified robot, so that
+// the transition which follows can be attributed to it
+func (j *Journal) NoteCommand(robot, cmd string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.commands[robot] = pendingCommand{cmd: cmd,
+		at: clock.Or(j.Clock).Now()}
+}
+
+// Record adds the state observed in an Event to the Store, and the run it
+// starts or completes. A run is completed if the robot ends it on its base.
+// Failed polls are ignored.
+func (j *Journal) Record(ctx context.Context, e watch.Event) error {
+	if e.Type == watch.PollFailed {
+		return nil
+	}
+	j.mu.Lock()
+	var st *store.State
+	if !j.recorded[e.Robot].Equal(e.Time) {
+		j.recorded[e.Robot] = e.Time
+		s := store.StateFromEvent(e)
+		if c, ok := j.commands[e.Robot]; ok {
+			if e.Time.Sub(c.at) <= commandWindow {
+				s.Command = c.cmd
+			}
+			delete(j.commands, e.Robot)
+		}
+		st = &s
+	}
+	var run *store.Run
+	switch e.Type {
+	case watch.RunStarted:
+		j.started[e.Robot] = e.Time
+		run = &store.Run{Robot: e.Robot, Start: e.Time}
+	case watch.RunCompleted:
+		if start, ok := j.started[e.Robot]; ok {
+			run = &store.Run{Robot: e.Robot, Start: start,
+				End: e.Time, Completed: e.Docked}
+			delete(j.started, e.Robot)
+		}
+	}
+	j.mu.Unlock()
+	if st != nil {
+		if err := j.Store.AddState(ctx, *st); err != nil {
+			return err
+		}
+	}
+	if run != nil {
+		return j.Store.PutRun(ctx, *run)
+	}
+	return nil
+}
+
+// Follow records the Events of w until ctx is cancelled or w is shut down
+func (j *Journal) Follow(ctx context.Context, w *watch.Watcher) error {
+	events, cancel := w.Subscribe()
+	defer cancel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := j.Record(ctx, e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Between returns the states recorded from from up to but excluding to, in
+// chronological order
+func (j *Journal) Between(ctx context.Context,
+	from, to time.Time) ([]store.State, error) {
+	return j.Store.States(ctx, store.Query{Since: from, Until: to})
+}
+
+// LastError returns the most recent state in which a robot reported an
+// error, and whether there is one
+func (j *Journal) LastError(ctx context.Context) (store.State, bool, error) {
+	states, err := j.Store.States(ctx, store.Query{})
+	if err != nil {
+		return store.State{}, false, err
+	}
+	for i := len(states) - 1; i >= 0; i-- {
+		if states[i].Error != "" {
+			return states[i], true, nil
+		}
+	}
+	return store.State{}, false, nil
+}
@@ -0,0 +1,87 @@
+package journal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/richlj/neato/clock"
+	"github.com/richlj/neato/nucleo"
+	"github.com/richlj/neato/store"
+	"github.com/richlj/neato/watch"
+)
+
+func TestRecord(t *testing.T) {
+	s, err := store.OpenFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	c := clock.NewFake(time.Date(2026, 10, 12, 22, 0, 0, 0, time.UTC))
+	j := New(s)
+	j.Clock = c
+	record := func(types ...watch.EventType) {
+		t.Helper()
+		e := watch.Event{Robot: "OPS1", Time: c.Now(),
+			State:  int(nucleo.StateBusy),
+			Action: int(nucleo.ActionHouseCleaning)}
+		for _, typ := range types {
+			e.Type = typ
+			if typ == watch.RunCompleted {
+				e.State = int(nucleo.StateIdle)
+				e.Action, e.Docked = 0, true
+			}
+			if err := j.Record(ctx, e); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	start := c.Now()
+	j.NoteCommand("OPS1", "startCleaning")
+	c.Advance(time.Minute)
+	record(watch.StateChanged, watch.RunStarted)
+	j.NoteCommand("OPS1", "pauseCleaning")
+	c.Advance(commandWindow + time.Second)
+	record(watch.PollFailed)
+	record(watch.StateChanged)
+	c.Advance(time.Hour)
+	record(watch.StateChanged, watch.RunCompleted)
+
+	states, err := j.Between(ctx, start, c.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 3 || states[0].Command != "startCleaning" ||
+		states[1].Command != "" || states[2].Command != "" {
+		t.Errorf("Between = %+v", states)
+	}
+	runs, err := s.Runs(ctx, store.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || !runs[0].Start.Equal(start.Add(time.Minute)) ||
+		!runs[0].End.Equal(c.Now()) || !runs[0].Completed {
+		t.Errorf("runs = %+v", runs)
+	}
+
+	if _, ok, err := j.LastError(ctx); ok || err != nil {
+		t.Errorf("LastError = %v, %v", ok, err)
+	}
+	errs := []string{"ui_error_brush_stuck", "ui_error_dust_bin"}
+	for _, e := range errs {
+		c.Advance(time.Minute)
+		err := j.Record(ctx, watch.Event{Type: watch.AlertRaised,
+			Robot: "OPS1", Time: c.Now(), Error: e})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Advance(time.Minute)
+	record(watch.StateChanged)
+	st, ok, err := j.LastError(ctx)
+	if err != nil || !ok || st.Error != "ui_error_dust_bin" {
+		t.Errorf("LastError = %+v, %v, %v", st, ok, err)
+	}
+}
@@ -0,0 +1,120 @@
+//go:build live
+
+package live
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// readCommands are the Nucleo commands which the checks may send
+var readCommands = map[string]bool{
+	"getRobotState":  true,
+	"getGeneralInfo": true,
+	"getRobotInfo":   true,
+	"getSchedule":    true,
+	"getPreferences": true,
+	"getLocalStats":  true,
+}
+
+// errMutation is returned for a request which could change the account or a
+// robot
+var errMutation = errors.New("live: refused request which could mutate")
+
+// guard is an http.RoundTripper which refuses requests that could mutate
+type guard struct {
+	next http.RoundTripper
+}
+
+func (g *guard) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := screen(req); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return g.next.RoundTrip(req)
+}
+
+// screen returns errMutation unless req is a GET, a Beehive login or a
+// Nucleo read command
+func screen(req *http.Request) error {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return nil
+	}
+	if req.Method != http.MethodPost {
+		return errMutation
+	}
+	if strings.HasSuffix(req.URL.Path, "/sessions") {
+		return nil
+	}
+	if !strings.HasSuffix(req.URL.Path, "/messages") || req.Body == nil {
+		return errMutation
+	}
+	b, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	var msg struct {
+		Cmd string `json:"cmd"`
+	}
+	if json.Unmarshal(b, &msg) != nil || !readCommands[msg.Cmd] {
+		return fmt.Errorf("%w: %q", errMutation, msg.Cmd)
+	}
+	return nil
+}
+
+func TestScreen(t *testing.T) {
+	const (
+		beehive = "https://beehive.invalid"
+		nucleo  = "https://nucleo.invalid/vendors/neato/robots/x/" +
+			"messages"
+	)
+	tests := []struct {
+		method, url, body string
+		refused           bool
+	}{
+		{http.MethodGet, beehive + "/users/me/robots", "", false},
+		{http.MethodPost, beehive + "/sessions", "{}", false},
+		{http.MethodDelete, beehive + "/sessions", "", true},
+		{http.MethodPut, beehive + "/users/me", "{}", true},
+		{http.MethodPost, nucleo, `{"reqId":"1","cmd":"getRobotState"}`,
+			false},
+		{http.MethodPost, nucleo, `{"reqId":"1","cmd":"startCleaning"}`,
+			true},
+		{http.MethodPost, nucleo, `{"reqId":"1","cmd":"setSchedule"}`,
+			true},
+		{http.MethodPost, nucleo, `not json`, true},
+		{http.MethodPost, beehive + "/robots/x/maps", "{}", true},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, tt.url,
+			strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = screen(req)
+		refused := errors.Is(err, errMutation)
+		if refused != tt.refused {
+			t.Errorf("%s %s %s: %v, refused %v", tt.method, tt.url,
+				tt.body, err, tt.refused)
+		}
+		if err != nil {
+			continue
+		}
+		// the body must still be readable by the transport
+		if b, err := ioutil.ReadAll(req.Body); err != nil ||
+			string(b) != tt.body {
+			t.Errorf("%s %s: body %q, %v", tt.method, tt.url, b,
+				err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+//go:build live
+
+// Package live runs read-only checks against a real Neato account, to catch
+// changes in the Beehive and Nucleo APIs before users do. Its tests are built
+// only with the live tag:
+//
+//	NEATO_EMAIL=... NEATO_PASSWORD=... go test -tags=live ./live
+//
+// Without credentials the account checks are skipped. The checks only read,
+// and every request is screened before it is sent: Beehive requests other
+// than logging in must be GETs, and Nucleo messages must be read commands, so
+// that a mistaken check cannot move or reconfigure a robot. NEATO_SERIAL
+// limits the robot checks to one robot.
+package live
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/richlj/neato/beehive"
+	"github.com/richlj/neato/nucleo"
+)
+
+func TestMain(m *testing.M) {
+	http.DefaultTransport = &guard{next: http.DefaultTransport}
+	os.Exit(m.Run())
+}
+
+// login returns a Session for the account in the environment, skipping the
+// test if there is none
+func login(t *testing.T) *beehive.Session {
+	t.Helper()
+	email, password := os.Getenv("NEATO_EMAIL"), os.Getenv("NEATO_PASSWORD")
+	if email == "" || password == "" {
+		t.Skip("NEATO_EMAIL and NEATO_PASSWORD are not set")
+	}
+	s, err := beehive.NewSession(beehive.WithCredentials(email, password))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestAccount(t *testing.T) {
+	session := login(t)
+	robots, err := session.ListRobots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range robots {
+		if r.Serial == "" || r.SecretKey == "" || r.Model == "" {
+			t.Fatalf("robot %s lacks a serial, secret key or model",
+				nucleo.MaskSerial(r.Serial))
+		}
+	}
+	serial := os.Getenv("NEATO_SERIAL")
+	for i := range robots {
+		r := &robots[i]
+		if serial != "" && r.Serial != serial {
+			continue
+		}
+		t.Run(nucleo.MaskSerial(r.Serial), func(t *testing.T) {
+			checkRobot(t, session, r)
+		})
+	}
+}
+
+// checkRobot runs the read checks against r
+func checkRobot(t *testing.T, session *beehive.Session, r *beehive.Robot) {
+	st, err := r.State()
+	if nucleo.IsRobotOffline(err) {
+		t.Skip("robot offline")
+	}
+	if err != nil {
+		t.Fatalf("state: %v", err)
+	}
+	if st.State == 0 {
+		t.Error("state: no state reported")
+	}
+	tests := []struct {
+		name  string
+		check func() error
+	}{
+		{"capabilities", func() error {
+			c, err := r.Capabilities()
+			if err == nil && len(c) == 0 {
+				return errors.New("no services reported")
+			}
+			return err
+		}},
+		{"general info", func() error {
+			_, err := r.GetGeneralInfo(nil)
+			return err
+		}},
+		{"schedule", func() error {
+			_, err := r.GetSchedule(nil)
+			return err
+		}},
+		{"maps", func() error {
+			_, err := session.ListRobotMaps(r.Serial)
+			return err
+		}},
+		{"timezone", func() error {
+			_, err := r.Timezone()
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		if err := tt.check(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
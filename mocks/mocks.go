@@ -0,0 +1,365 @@
+// mocks provides fakes of the neato service interfaces. Each method calls the
+// corresponding function field when it is set, and otherwise returns a
+// successful empty result. Calls are recorded in order so that tests can
+// assert on what was issued.
+
+package mocks
+
+import (
+	"sync"
+
+	"github.com/richlj/neato/beehive"
+	"github.com/richlj/neato/nucleo"
+)
+
+var (
+	_ nucleo.RobotService    = (*Robot)(nil)
+	_ beehive.SessionService = (*Session)(nil)
+)
+
+// Call records a single method invocation on a mock
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+type recorder struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+func (r *recorder) record(method string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, Call{Method: method, Args: args})
+}
+
+// Calls returns the invocations made so far
+func (r *recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := make([]Call, len(r.calls))
+	copy(result, r.calls)
+	return result
+}
+
+func okResponse() *nucleo.Response {
+	return &nucleo.Response{Version: 1, Result: "ok"}
+}
+
+// Robot is a fake nucleo.RobotService
+type Robot struct {
+	recorder
+	StartCleaningFunc                 func(a *nucleo.Params) (*nucleo.Response, error)
+	StopCleaningFunc                  func(a *nucleo.Params) (*nucleo.Response, error)
+	PauseCleaningFunc                 func(a *nucleo.Params) (*nucleo.Response, error)
+	ResumeCleaningFunc                func(a *nucleo.Params) (*nucleo.Response, error)
+	SendToBaseFunc                    func(a *nucleo.Params) (*nucleo.Response, error)
+	FindMeFunc                        func(a *nucleo.Params) (*nucleo.Response, error)
+	DismissCurrentAlertFunc           func(a *nucleo.Params) (*nucleo.Response, error)
+	GetRobotStateFunc                 func(a *nucleo.Params) (*nucleo.Response, error)
+	GetGeneralInfoFunc                func(a *nucleo.Params) (*nucleo.GeneralInfo, error)
+	GetLocalStatsFunc                 func(a *nucleo.Params) (*nucleo.Response, error)
+	GetRobotInfoFunc                  func(a *nucleo.Params) (*nucleo.HardwareInfo, error)
+	GetRobotManualCleaningInfoFunc    func(a *nucleo.Params) (*nucleo.Response, error)
+	GetScheduleFunc                   func(a *nucleo.Params) (*nucleo.Response, error)
+	SetScheduleFunc                   func(a *nucleo.Params) (*nucleo.Response, error)
+	EnableScheduleFunc                func(a *nucleo.Params) (*nucleo.Response, error)
+	DisableScheduleFunc               func(a *nucleo.Params) (*nucleo.Response, error)
+	GetMapBoundariesFunc              func(a *nucleo.Params) (*nucleo.Response, error)
+	SetMapBoundariesFunc              func(a *nucleo.Params) (*nucleo.Response, error)
+	StartPersistentMapExplorationFunc func(a *nucleo.Params) (*nucleo.Response, error)
+	GetPreferencesFunc                func(a *nucleo.Params) (*nucleo.Response, error)
+	SetPreferencesFunc                func(a *nucleo.Params) (*nucleo.Response, error)
+	StartSpotCleaningFunc             func(width, height int, repeat bool) (*nucleo.Response, error)
+	StateFunc                         func() (*nucleo.RobotState, error)
+	CapabilitiesFunc                  func() (nucleo.Capabilities, error)
+}
+
+// StartCleaning records the call and invokes StartCleaningFunc
+func (r *Robot) StartCleaning(a *nucleo.Params) (*nucleo.Response, error) {
+	r.record("StartCleaning", a)
+	if r.StartCleaningFunc != nil {
+		return r.StartCleaningFunc(a)
+	}
+	return okResponse(), nil
+}
+
+// StopCleaning records the call and invokes StopCleaningFunc
+func (r *Robot) StopCleaning(a *nucleo.Params) (*nucleo.Response, error) {
+	r.record("StopCleaning", a)
+	if r.StopCleaningFunc != nil {
+		return r.StopCleaningFunc(a)
+	}
+	return okResponse(), nil
+}
+
+// PauseCleaning records the call and invokes PauseCleaningFunc
+func (r *Robot) PauseCleaning(a *nucleo.Params) (*nucleo.Response, error) {
+	r.record("PauseCleaning", a)
+	if r.PauseCleaningFunc != nil {
+		return r.PauseCleaningFunc(a)
+	}
+	return okResponse(), nil
+}
+
+// ResumeCleaning records the call and invokes ResumeCleaningFunc
+func (r *Robot) ResumeCleaning(a *nucleo.Params) (*nucleo.Response, error) {
+	r.record("ResumeCleaning", a)
+	if r.ResumeCleaningFunc != nil {
+		return r.ResumeCleaningFunc(a)
+	}
+	return okResponse(), nil
+}
+
+// SendToBase records the call and invokes SendToBaseFunc
+func (r *Robot) SendToBase(a *nucleo.Params) (*nucleo.Response, error) {
+	r.record("SendToBase", a)
+	if r.SendToBaseFunc != nil {
+		return r.SendToBaseFunc(a)
+	}
+	return okResponse(), nil
+}
+
+// FindMe records the call and invokes FindMeFunc
+func (r *Robot) FindMe(a *nucleo.Params) (*nucleo.Response, error) {
+	r.record("FindMe", a)
+	if r.FindMeFunc != nil {
+		return r.FindMeFunc(a)
+	}
+	return okResponse(), nil
+}
+
+// DismissCurrentAlert records the call and invokes DismissCurrentAlertFunc
+func (r *Robot) DismissCurrentAlert(a *nucleo.Params) (*nucleo.Response,
+	error) {
+	r.record("DismissCurrentAlert", a)
+	if r.DismissCurrentAlertFunc != nil {
+		return r.DismissCurrentAlertFunc(a)
+	}
+	return okResponse(), nil
+}
+
+// GetRobotState records the call and invokes GetRobotStateFunc
+func (r *Robot) GetRobotState(a *nucleo.Params) (*nucleo.Response, error) {
+	r.record("GetRobotState", a)
+	if r.GetRobotStateFunc != nil {
+		return r.GetRobotStateFunc(a)
+	}
+	return okResponse(), nil
+}
+
+// GetGeneralInfo records the call and invokes GetGeneralInfoFunc
+func (r *Robot) GetGeneralInfo(a *nucleo.Params) (*nucleo.GeneralInfo, error) {
+	r.record("GetGeneralInfo", a)
+	if r.GetGeneralInfoFunc != nil {
+		return r.GetGeneralInfoFunc(a)
+	}
+	return &nucleo.GeneralInfo{}, nil
+}
+
+// GetLocalStats records the call and invokes GetLocalStatsFunc
+func (r *Robot) GetLocalStats(a *nucleo.Params) (*nucleo.Response, error) {
+	r.record("GetLocalStats", a)
+	if r.GetLocalStatsFunc != nil {
+		return r.GetLocalStatsFunc(a)
+	}
+	return okResponse(), nil
+}
+
+// GetRobotInfo records the call and invokes GetRobotInfoFunc
+func (r *Robot) GetRobotInfo(a *nucleo.Params) (*nucleo.HardwareInfo, error) {
+	r.record("GetRobotInfo", a)
+	if r.GetRobotInfoFunc != nil {
+		return r.GetRobotInfoFunc(a)
+	}
+	return &nucleo.HardwareInfo{}, nil
+}
+
+// GetRobotManualCleaningInfo records the call and invokes
+// GetRobotManualCleaningInfoFunc
+func (r *Robot) GetRobotManualCleaningInfo(a *nucleo.Params) (*nucleo.Response,
+	error) {
+	r.record("GetRobotManualCleaningInfo", a)
+	if r.GetRobotManualCleaningInfoFunc != nil {
+		return r.GetRobotManualCleaningInfoFunc(a)
+	}
+	return okResponse(), nil
+}
+
+// GetSchedule records the call and invokes GetScheduleFunc
+func (r *Robot) GetSchedule(a *nucleo.Params) (*nucleo.Response, error) {
+	r.record("GetSchedule", a)
+	if r.GetScheduleFunc != nil {
+		return r.GetScheduleFunc(a)
+	}
+	return okResponse(), nil
+}
+
+// SetSchedule records the call and invokes SetScheduleFunc
+func (r *Robot) SetSchedule(a *nucleo.Params) (*nucleo.Response, error) {
+	r.record("SetSchedule", a)
+	if r.SetScheduleFunc != nil {
+		return r.SetScheduleFunc(a)
+	}
+	return okResponse(), nil
+}
+
+// EnableSchedule records the call and invokes EnableScheduleFunc
+func (r *Robot) EnableSchedule(a *nucleo.Params) (*nucleo.Response, error) {
+	r.record("EnableSchedule", a)
+	if r.EnableScheduleFunc != nil {
+		return r.EnableScheduleFunc(a)
+	}
+	return okResponse(), nil
+}
+
+// DisableSchedule records the call and invokes DisableScheduleFunc
+func (r *Robot) DisableSchedule(a *nucleo.Params) (*nucleo.Response, error) {
+	r.record("DisableSchedule", a)
+	if r.DisableScheduleFunc != nil {
+		return r.DisableScheduleFunc(a)
+	}
+	return okResponse(), nil
+}
+
+// GetMapBoundaries records the call and invokes GetMapBoundariesFunc
+func (r *Robot) GetMapBoundaries(a *nucleo.Params) (*nucleo.Response, error) {
+	r.record("GetMapBoundaries", a)
+	if r.GetMapBoundariesFunc != nil {
+		return r.GetMapBoundariesFunc(a)
+	}
+	return okResponse(), nil
+}
+
+// SetMapBoundaries records the call and invokes SetMapBoundariesFunc
+func (r *Robot) SetMapBoundaries(a *nucleo.Params) (*nucleo.Response, error) {
+	r.record("SetMapBoundaries", a)
+	if r.SetMapBoundariesFunc != nil {
+		return r.SetMapBoundariesFunc(a)
+	}
+	return okResponse(), nil
+}
+
+// StartPersistentMapExploration records the call and invokes
+// StartPersistentMapExplorationFunc
+func (r *Robot) StartPersistentMapExploration(a *nucleo.Params) (
+	*nucleo.Response, error) {
+	r.record("StartPersistentMapExploration", a)
+	if r.StartPersistentMapExplorationFunc != nil {
+		return r.StartPersistentMapExplorationFunc(a)
+	}
+	return okResponse(), nil
+}
+
+// GetPreferences records the call and invokes GetPreferencesFunc
+func (r *Robot) GetPreferences(a *nucleo.Params) (*nucleo.Response, error) {
+	r.record("GetPreferences", a)
+	if r.GetPreferencesFunc != nil {
+		return r.GetPreferencesFunc(a)
+	}
+	return okResponse(), nil
+}
+
+// SetPreferences records the call and invokes SetPreferencesFunc
+func (r *Robot) SetPreferences(a *nucleo.Params) (*nucleo.Response, error) {
+	r.record("SetPreferences", a)
+	if r.SetPreferencesFunc != nil {
+		return r.SetPreferencesFunc(a)
+	}
+	return okResponse(), nil
+}
+
+// StartSpotCleaning records the call and invokes StartSpotCleaningFunc
+func (r *Robot) StartSpotCleaning(width, height int, repeat bool) (
+	*nucleo.Response, error) {
+	r.record("StartSpotCleaning", width, height, repeat)
+	if r.StartSpotCleaningFunc != nil {
+		return r.StartSpotCleaningFunc(width, height, repeat)
+	}
+	return okResponse(), nil
+}
+
+// State records the call and invokes StateFunc
+func (r *Robot) State() (*nucleo.RobotState, error) {
+	r.record("State")
+	if r.StateFunc != nil {
+		return r.StateFunc()
+	}
+	return &nucleo.RobotState{}, nil
+}
+
+// Capabilities records the call and invokes CapabilitiesFunc
+func (r *Robot) Capabilities() (nucleo.Capabilities, error) {
+	r.record("Capabilities")
+	if r.CapabilitiesFunc != nil {
+		return r.CapabilitiesFunc()
+	}
+	return nucleo.Capabilities{}, nil
+}
+
+// Session is a fake beehive.SessionService
+type Session struct {
+	recorder
+	RefreshFunc                 func() error
+	GetUserFunc                 func() (*beehive.User, error)
+	ListRobotsFunc              func() ([]beehive.Robot, error)
+	ListRobotMapsFunc           func(robot string) (*beehive.MapsResult, error)
+	GetRobotMapFunc             func(robot, id string) (*beehive.Map, error)
+	ListRobotPersistentMapsFunc func(robot string) ([]beehive.Map, error)
+}
+
+// Refresh records the call and invokes RefreshFunc
+func (s *Session) Refresh() error {
+	s.record("Refresh")
+	if s.RefreshFunc != nil {
+		return s.RefreshFunc()
+	}
+	return nil
+}
+
+// GetUser records the call and invokes GetUserFunc
+func (s *Session) GetUser() (*beehive.User, error) {
+	s.record("GetUser")
+	if s.GetUserFunc != nil {
+		return s.GetUserFunc()
+	}
+	return &beehive.User{}, nil
+}
+
+// ListRobots records the call and invokes ListRobotsFunc
+func (s *Session) ListRobots() ([]beehive.Robot, error) {
+	s.record("ListRobots")
+	if s.ListRobotsFunc != nil {
+		return s.ListRobotsFunc()
+	}
+	return nil, nil
+}
+
+// ListRobotMaps records the call and invokes ListRobotMapsFunc
+func (s *Session) ListRobotMaps(robot string) (*beehive.MapsResult, error) {
+	s.record("ListRobotMaps", robot)
+	if s.ListRobotMapsFunc != nil {
+		return s.ListRobotMapsFunc(robot)
+	}
+	return &beehive.MapsResult{}, nil
+}
+
+// GetRobotMap records the call and invokes GetRobotMapFunc
+func (s *Session) GetRobotMap(robot, id string) (*beehive.Map, error) {
+	s.record("GetRobotMap", robot, id)
+	if s.GetRobotMapFunc != nil {
+		return s.GetRobotMapFunc(robot, id)
+	}
+	return &beehive.Map{}, nil
+}
+
+// ListRobotPersistentMaps records the call and invokes
+// ListRobotPersistentMapsFunc
+func (s *Session) ListRobotPersistentMaps(robot string) ([]beehive.Map, error) {
+	s.record("ListRobotPersistentMaps", robot)
+	if s.ListRobotPersistentMapsFunc != nil {
+		return s.ListRobotPersistentMapsFunc(robot)
+	}
+	return nil, nil
+}
@@ -4,9 +4,118 @@
 // The Beehive API provides access to the records of users and robots on
 // Neato's servers, and the Nucleo API uses the aforementioned identity and
 // authentication data to issue commands to devices.
+//
+// Each API is implemented in its own package, beehive and nucleo, with shared
+// types in units and i18n. This package is a thin facade over them for
+// callers that use both APIs together.
 
 package neato
 
-const (
-	scheme = "https"
+import (
+	"context"
+
+	"github.com/richlj/neato/beehive"
+	"github.com/richlj/neato/discover"
+	"github.com/richlj/neato/i18n"
+	"github.com/richlj/neato/nucleo"
+	"github.com/richlj/neato/units"
+)
+
+// Types of the Beehive API
+type (
+	Session        = beehive.Session
+	SessionOption  = beehive.Option
+	SessionService = beehive.SessionService
+	User           = beehive.User
+	Robot          = beehive.Robot
+	Map            = beehive.Map
+	MapsResult     = beehive.MapsResult
+	RunReport      = beehive.RunReport
+	FleetSnapshot  = beehive.FleetSnapshot
+)
+
+// Types of the Nucleo API
+type (
+	RobotOption  = nucleo.Option
+	Params       = nucleo.Params
+	Response     = nucleo.Response
+	RobotState   = nucleo.RobotState
+	RobotService = nucleo.RobotService
+	Capabilities = nucleo.Capabilities
+	GeneralInfo  = nucleo.GeneralInfo
+	HardwareInfo = nucleo.HardwareInfo
+	FloorPlan    = nucleo.FloorPlan
+	WifiNetwork  = nucleo.WifiNetwork
+	Preferences  = nucleo.Preferences
+	Locale       = nucleo.Locale
+	RunOption    = nucleo.RunOption
+	RunDefaults  = nucleo.RunDefaults
+)
+
+// Types of local discovery
+type (
+	Discovered = discover.Found
+)
+
+// Shared types
+type (
+	Area       = units.Area
+	Duration   = units.Duration
+	UnitSystem = units.UnitSystem
+	Formatter  = i18n.Formatter
 )
+
+// NewSession generates a new Session for use with the Neato Beehive API
+func NewSession(opts ...SessionOption) (*Session, error) {
+	return beehive.NewSession(opts...)
+}
+
+// Discover finds the robots of the account on the local network, returning
+// their serials, IP addresses and models
+func Discover(ctx context.Context, s SessionService) ([]Discovered, error) {
+	robots, err := s.ListRobots()
+	if err != nil {
+		return nil, err
+	}
+	return discover.Discover(ctx, robots)
+}
+
+// NewRunReport builds a RunReport from the Map of a cleaning run performed by
+// the named Robot
+func NewRunReport(robot string, m *Map) *RunReport {
+	return beehive.NewRunReport(robot, m)
+}
+
+// NewFormatter returns a Formatter for the supplied locale
+func NewFormatter(locale string) *Formatter {
+	return i18n.NewFormatter(locale)
+}
+
+// WithRequestMetadata returns a copy of ctx carrying md, e.g.
+// {"trigger": "voice assistant"}, for middleware to read
+func WithRequestMetadata(ctx context.Context,
+	md map[string]string) context.Context {
+	return nucleo.WithRequestMetadata(ctx, md)
+}
+
+// RequestMetadata returns the metadata carried by ctx
+func RequestMetadata(ctx context.Context) map[string]string {
+	return nucleo.RequestMetadata(ctx)
+}
+
+// IsRetryable reports whether a request which failed with err may succeed if
+// repeated later
+func IsRetryable(err error) bool {
+	return nucleo.IsRetryable(err)
+}
+
+// IsAuthError reports whether err shows that credentials or a robot's
+// SecretKey were rejected
+func IsAuthError(err error) bool {
+	return nucleo.IsAuthError(err)
+}
+
+// IsRobotOffline reports whether err shows that a robot could not be reached
+func IsRobotOffline(err error) bool {
+	return nucleo.IsRobotOffline(err)
+}
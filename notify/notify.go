@@ -0,0 +1,122 @@
+// notify renders the text of notifications from Go templates, so that each
+// sink can word its messages as its users prefer, e.g.
+//
+//	{{.Robot.Name}} finished cleaning {{.Area.SquareMeters}} m²
+//
+// Templates are chosen by Event type. Events without a template keep the
+// default summary produced by the watch package.
+
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"text/template"
+
+	"github.com/richlj/neato/beehive"
+	"github.com/richlj/neato/i18n"
+	"github.com/richlj/neato/units"
+	"github.com/richlj/neato/watch"
+)
+
+// Robot identifies the robot a notification is about
+type Robot struct {
+	Serial string
+	Name   string
+}
+
+// Data is the model over which templates are executed
+type Data struct {
+	watch.Event
+
+	Robot Robot
+
+	// Run is the report of the run which an Event completed, if known,
+	// and Area and Duration are taken from it
+	Run      *beehive.RunReport
+	Area     units.Area
+	Duration units.Duration
+}
+
+// Templates holds the templates of a single sink
+type Templates struct {
+	// Formatter localises the area, duration and alert functions of the
+	// templates parsed after it is set. It defaults to
+	// i18n.DefaultFormatter.
+	Formatter *i18n.Formatter
+
+	byType map[watch.EventType]*template.Template
+}
+
+// New returns an empty set of Templates
+func New() *Templates {
+	return &Templates{byType: make(map[watch.EventType]*template.Template)}
+}
+
+// Parse sets the template of notifications for Events of type t. Besides the
+// builtins, templates may call area, duration and alert to format values in
+// the Formatter's locale, and round to round a number to some decimal
+// places, e.g. {{round .Area.SquareMeters 1}}.
+func (t *Templates) Parse(typ watch.EventType, text string) error {
+	tmpl, err := template.New(string(typ)).Funcs(t.funcs()).Parse(text)
+	if err != nil {
+		return fmt.Errorf("notify: %v", err)
+	}
+	t.byType[typ] = tmpl
+	return nil
+}
+
+// Render returns the text of the notification for d, and false if no
+// template is set for its Event type
+func (t *Templates) Render(d Data) (string, bool, error) {
+	if t == nil {
+		return "", false, nil
+	}
+	tmpl, ok := t.byType[d.Type]
+	if !ok {
+		return "", false, nil
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, d); err != nil {
+		return "", true, fmt.Errorf("notify: %v", err)
+	}
+	return b.String(), true, nil
+}
+
+// Apply returns e with its Summary replaced by the rendered template for its
+// type, if any. Templates which fail leave the Summary unchanged.
+func (t *Templates) Apply(e watch.Event) watch.Event {
+	s, ok, err := t.Render(NewData(e, nil))
+	if ok && err == nil {
+		e.Summary = s
+	}
+	return e
+}
+
+// NewData returns the Data describing e and, if not nil, the run it
+// completed
+func NewData(e watch.Event, run *beehive.RunReport) Data {
+	d := Data{Event: e, Robot: Robot{Serial: e.Robot, Name: e.Name},
+		Run: run}
+	if run != nil {
+		d.Area, d.Duration = run.Area, run.Duration
+	}
+	return d
+}
+
+func (t *Templates) funcs() template.FuncMap {
+	f := t.Formatter
+	if f == nil {
+		f = i18n.DefaultFormatter
+	}
+	return template.FuncMap{
+		"area":     f.Area,
+		"duration": f.Duration,
+		"alert":    f.Alert,
+		"round": func(v float64, places int) float64 {
+			p := math.Pow(10, float64(places))
+			return math.Round(v*p) / p
+		},
+	}
+}
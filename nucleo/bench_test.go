@@ -0,0 +1,138 @@
+package nucleo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	benchSerial = "OPS01234-0123456789AB"
+	benchKey    = "0123456789abcdef0123456789abcdef01234567"
+	benchDate   = "Mon, 05 Oct 2026 09:30:00 GMT"
+)
+
+// cannedTransport answers every request with the same response, but for
+// its reqId, which is copied from the request
+type cannedTransport struct {
+	before, after []byte
+	body          bytes.Buffer
+}
+
+func newCannedTransport(tb testing.TB, resp []byte) *cannedTransport {
+	const field = `"reqId": "`
+	i := bytes.Index(resp, []byte(field))
+	if i < 0 {
+		tb.Fatal("canned response has no reqId")
+	}
+	i += len(field)
+	j := i + bytes.IndexByte(resp[i:], '"')
+	return &cannedTransport{before: resp[:i], after: resp[j:]}
+}
+
+func (t *cannedTransport) RoundTrip(req *http.Request) (*http.Response,
+	error) {
+	const field = `"reqId":"`
+	t.body.Reset()
+	_, err := t.body.ReadFrom(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	id := t.body.Bytes()
+	if i := bytes.Index(id, []byte(field)); i >= 0 {
+		id = id[i+len(field):]
+		id = id[:bytes.IndexByte(id, '"')]
+	}
+	resp := make([]byte, 0, len(t.before)+len(id)+len(t.after))
+	resp = append(append(append(resp, t.before...), id...), t.after...)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(bytes.NewReader(resp)),
+		Request:    req,
+	}, nil
+}
+
+// readCorpus returns the named response from testdata/corpus
+func readCorpus(tb testing.TB, name string) []byte {
+	tb.Helper()
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "corpus", name))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return b
+}
+
+// withResponse answers the requests of the benchmark with the named
+// response from the corpus
+func withResponse(b *testing.B, name string) {
+	t := httpClient.Transport
+	httpClient.Transport = newCannedTransport(b, readCorpus(b, name))
+	b.Cleanup(func() {
+		httpClient.Transport = t
+	})
+}
+
+func BenchmarkSign(b *testing.B) {
+	r := NewRobot(benchSerial, benchKey)
+	body := []byte(`{"reqId":"1","cmd":"getRobotState"}`)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.authorization(body, benchDate)
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	r := NewRobot(benchSerial, benchKey)
+	a, err := newRequest("getRobotState", nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, err := r.encode(a)
+		if err != nil {
+			b.Fatal(err)
+		}
+		bufferPool.Put(buf)
+	}
+}
+
+func BenchmarkDecodeState(b *testing.B) {
+	r := NewRobot(benchSerial, benchKey)
+	body := readCorpus(b, "state_cleaning.json")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var result stateResponse
+		err := r.getCodec().Decode(bytes.NewReader(body), &result)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkState(b *testing.B) {
+	withResponse(b, "state_cleaning.json")
+	r := NewRobot(benchSerial, benchKey)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.State(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetRobotState(b *testing.B) {
+	withResponse(b, "state_cleaning.json")
+	r := NewRobot(benchSerial, benchKey)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.GetRobotState(nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+// Boundaries are the no-go lines and zones drawn on a persistent map. Their
+// vertices are given as fractions of the map's width and height.
+
+package nucleo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// The types of Boundary
+const (
+	BoundaryPolyline = "polyline"
+	BoundaryPolygon  = "polygon"
+)
+
+// Boundary is a no-go line or a cleaning zone on a persistent map
+type Boundary struct {
+	ID        string       `json:"id,omitempty"`
+	Type      string       `json:"type"`
+	Name      string       `json:"name,omitempty"`
+	Color     string       `json:"color,omitempty"`
+	Enabled   bool         `json:"enabled"`
+	Vertices  [][2]float64 `json:"vertices"`
+	Relevancy []float64    `json:"relevancy,omitempty"`
+}
+
+type boundariesResponse struct {
+	Response
+	Data struct {
+		MapID      string     `json:"mapId"`
+		Boundaries []Boundary `json:"boundaries"`
+	} `json:"data"`
+}
+
+// Boundaries returns the Boundaries of the persistent map with the supplied
+// ID
+func (r *Robot) Boundaries(mapID string) ([]Boundary, error) {
+	req, err := newRawRequest("getMapBoundaries", map[string]string{
+		"mapId": mapID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result boundariesResponse
+	if err := r.do(req, &result); err != nil {
+		return nil, err
+	}
+	if _, err := r.check(req, &result.Response); err != nil {
+		return nil, err
+	}
+	return result.Data.Boundaries, nil
+}
+
+// BoundaryError reports every problem found in a set of Boundaries
+type BoundaryError struct {
+	Problems []BoundaryProblem
+}
+
+// BoundaryProblem is a single problem found in a set of Boundaries. Index
+// identifies the offending Boundary, and Vertex the offending vertex or
+// edge, or -1 if the problem is with the Boundary as a whole.
+type BoundaryProblem struct {
+	Index  int
+	Vertex int
+	Err    error
+}
+
+func (e *BoundaryError) Error() string {
+	var a []string
+	for _, p := range e.Problems {
+		if p.Vertex < 0 {
+			a = append(a, fmt.Sprintf("boundary %d: %s", p.Index,
+				p.Err))
+			continue
+		}
+		a = append(a, fmt.Sprintf("boundary %d vertex %d: %s", p.Index,
+			p.Vertex, p.Err))
+	}
+	return "invalid boundaries: " + strings.Join(a, "; ")
+}
+
+// Unwrap returns the errors of the individual problems
+func (e *BoundaryError) Unwrap() []error {
+	var result []error
+	for _, p := range e.Problems {
+		result = append(result, p.Err)
+	}
+	return result
+}
+
+// ValidateBoundaries checks Boundaries before they are sent to a Robot,
+// returning a *BoundaryError listing unknown types, lines and polygons with
+// too few vertices, zero-length edges, vertices outside the map,
+// self-intersecting polygons and zones which overlap one another
+func ValidateBoundaries(boundaries []Boundary) error {
+	var problems []BoundaryProblem
+	add := func(i, v int, err error) {
+		problems = append(problems, BoundaryProblem{Index: i, Vertex: v,
+			Err: err})
+	}
+	for i, b := range boundaries {
+		min := 2
+		switch b.Type {
+		case BoundaryPolyline:
+		case BoundaryPolygon:
+			min = 3
+		default:
+			add(i, -1, fmt.Errorf("unknown type %q", b.Type))
+			continue
+		}
+		if len(b.Vertices) < min {
+			add(i, -1, fmt.Errorf("a %s needs at least %d vertices",
+				b.Type, min))
+			continue
+		}
+		for j, v := range b.Vertices {
+			if v[0] < 0 || v[0] > 1 || v[1] < 0 || v[1] > 1 {
+				add(i, j, fmt.Errorf("(%g, %g) is outside "+
+					"the map", v[0], v[1]))
+			}
+		}
+		edges := b.edges()
+		for j, e := range edges {
+			if e[0] == e[1] {
+				add(i, j, errors.New("edge has zero length"))
+			}
+		}
+		if b.Type != BoundaryPolygon {
+			continue
+		}
+		for _, c := range crossings(b.Vertices) {
+			add(i, c[0], fmt.Errorf("edge crosses edge %d", c[1]))
+		}
+	}
+	for i := range boundaries {
+		for j := i + 1; j < len(boundaries); j++ {
+			if overlaps(&boundaries[i], &boundaries[j]) {
+				add(j, -1, fmt.Errorf("zone overlaps "+
+					"boundary %d", i))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return &BoundaryError{Problems: problems}
+	}
+	return nil
+}
+
+// SetBoundaries validates Boundaries and replaces those of the persistent
+// map with the supplied ID with them
+func (r *Robot) SetBoundaries(mapID string, boundaries []Boundary) (*Response,
+	error) {
+	if err := ValidateBoundaries(boundaries); err != nil {
+		return nil, err
+	}
+	req, err := newRawRequest("setMapBoundaries", map[string]interface{}{
+		"mapId":      mapID,
+		"boundaries": boundaries,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// edges returns the segments between successive vertices, closing polygons
+func (b *Boundary) edges() [][2][2]float64 {
+	return edgesOf(b.Vertices, b.Type == BoundaryPolygon)
+}
+
+// edgesOf returns the segments between successive vertices, joining the last
+// to the first if closed
+func edgesOf(vertices [][2]float64, closed bool) [][2][2]float64 {
+	var result [][2][2]float64
+	for i := 0; i+1 < len(vertices); i++ {
+		result = append(result, [2][2]float64{vertices[i],
+			vertices[i+1]})
+	}
+	if closed && len(vertices) > 2 {
+		result = append(result, [2][2]float64{vertices[len(vertices)-1],
+			vertices[0]})
+	}
+	return result
+}
+
+// crossings returns the pairs of non-adjacent edges of a polygon which touch
+// or cross, identified by the index of their first vertex. Repeated vertices
+// are skipped, since they would appear to make the edges either side of them
+// touch; they are reported as zero-length edges instead.
+func crossings(vertices [][2]float64) [][2]int {
+	vertices, index := distinct(vertices)
+	edges := edgesOf(vertices, true)
+	var result [][2]int
+	for j := range edges {
+		for k := j + 2; k < len(edges); k++ {
+			if j == 0 && k == len(edges)-1 {
+				continue
+			}
+			if intersects(edges[j], edges[k]) {
+				result = append(result,
+					[2]int{index[j], index[k]})
+			}
+		}
+	}
+	return result
+}
+
+// distinct returns the vertices of a polygon without consecutive repeats,
+// and the index in vertices of each one returned
+func distinct(vertices [][2]float64) ([][2]float64, []int) {
+	var result [][2]float64
+	var index []int
+	for i, v := range vertices {
+		if i > 0 && v == vertices[i-1] {
+			continue
+		}
+		result = append(result, v)
+		index = append(index, i)
+	}
+	for len(result) > 1 && result[len(result)-1] == result[0] {
+		result, index = result[:len(result)-1], index[:len(index)-1]
+	}
+	return result, index
+}
+
+// overlaps reports whether two polygon Boundaries share any area. Zones
+// which only share edges or vertices do not overlap.
+func overlaps(a, b *Boundary) bool {
+	if a.Type != BoundaryPolygon || b.Type != BoundaryPolygon ||
+		len(a.Vertices) < 3 || len(b.Vertices) < 3 {
+		return false
+	}
+	for _, e := range a.edges() {
+		for _, f := range b.edges() {
+			if crosses(e, f) {
+				return true
+			}
+		}
+	}
+	// Without crossing edges, the zones overlap only if one lies within
+	// the other, in which case a point on the boundary of one, or failing
+	// that within it, lies strictly within the other
+	return within(a, b) || within(b, a)
+}
+
+// within reports whether a point on the boundary of a, or its centroid,
+// lies strictly inside b
+func within(a, b *Boundary) bool {
+	var c [2]float64
+	for _, e := range a.edges() {
+		mid := [2]float64{(e[0][0] + e[1][0]) / 2,
+			(e[0][1] + e[1][1]) / 2}
+		if strictlyInside(e[0], b) || strictlyInside(mid, b) {
+			return true
+		}
+		c[0] += e[0][0] / float64(len(a.Vertices))
+		c[1] += e[0][1] / float64(len(a.Vertices))
+	}
+	return strictlyInside(c, a) && strictlyInside(c, b)
+}
+
+// strictlyInside reports whether p lies within the polygon b and not on its
+// edges
+func strictlyInside(p [2]float64, b *Boundary) bool {
+	for _, e := range b.edges() {
+		if orientation(e[0], e[1], p) == 0 && onSegment(e, p) {
+			return false
+		}
+	}
+	return inside(p, b.Vertices)
+}
+
+// crosses reports whether two segments cross at a single point within both,
+// rather than merely touching
+func crosses(a, b [2][2]float64) bool {
+	d1 := orientation(b[0], b[1], a[0])
+	d2 := orientation(b[0], b[1], a[1])
+	d3 := orientation(a[0], a[1], b[0])
+	d4 := orientation(a[0], a[1], b[1])
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) &&
+		((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
+
+// intersects reports whether two segments touch or cross
+func intersects(a, b [2][2]float64) bool {
+	if crosses(a, b) {
+		return true
+	}
+	d1 := orientation(b[0], b[1], a[0])
+	d2 := orientation(b[0], b[1], a[1])
+	d3 := orientation(a[0], a[1], b[0])
+	d4 := orientation(a[0], a[1], b[1])
+	return (d1 == 0 && onSegment(b, a[0])) ||
+		(d2 == 0 && onSegment(b, a[1])) ||
+		(d3 == 0 && onSegment(a, b[0])) ||
+		(d4 == 0 && onSegment(a, b[1]))
+}
+
+func orientation(p, q, r [2]float64) float64 {
+	return (q[0]-p[0])*(r[1]-p[1]) - (q[1]-p[1])*(r[0]-p[0])
+}
+
+func onSegment(s [2][2]float64, p [2]float64) bool {
+	return p[0] >= math.Min(s[0][0], s[1][0]) &&
+		p[0] <= math.Max(s[0][0], s[1][0]) &&
+		p[1] >= math.Min(s[0][1], s[1][1]) &&
+		p[1] <= math.Max(s[0][1], s[1][1])
+}
+
+// inside reports whether p lies within the polygon with the supplied
+// vertices
+func inside(p [2]float64, vertices [][2]float64) bool {
+	result := false
+	for i, j := 0, len(vertices)-1; i < len(vertices); j, i = i, i+1 {
+		a, b := vertices[i], vertices[j]
+		if (a[1] > p[1]) != (b[1] > p[1]) &&
+			p[0] < (b[0]-a[0])*(p[1]-a[1])/(b[1]-a[1])+a[0] {
+			result = !result
+		}
+	}
+	return result
+}
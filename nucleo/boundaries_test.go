@@ -0,0 +1,96 @@
+package nucleo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func zone(vertices ...[2]float64) Boundary {
+	return Boundary{Type: BoundaryPolygon, Vertices: vertices}
+}
+
+func line(vertices ...[2]float64) Boundary {
+	return Boundary{Type: BoundaryPolyline, Vertices: vertices}
+}
+
+// square returns a zone with its lower left corner at (x, y). The tests use
+// fractions of powers of two, which sum exactly.
+func square(x, y, size float64) Boundary {
+	return zone([2]float64{x, y}, [2]float64{x + size, y},
+		[2]float64{x + size, y + size}, [2]float64{x, y + size})
+}
+
+func TestValidateBoundaries(t *testing.T) {
+	type problem struct {
+		index, vertex int
+	}
+	tests := []struct {
+		name       string
+		boundaries []Boundary
+		want       []problem
+	}{
+		{"empty", nil, nil},
+		{"line", []Boundary{line([2]float64{0.1, 0.1},
+			[2]float64{0.5, 0.5})}, nil},
+		{"zone", []Boundary{square(0.125, 0.125, 0.25)}, nil},
+		{"unknown type", []Boundary{{Type: "circle"}},
+			[]problem{{0, -1}}},
+		{"short line", []Boundary{line([2]float64{0.1, 0.1})},
+			[]problem{{0, -1}}},
+		{"short zone", []Boundary{zone([2]float64{0.1, 0.1},
+			[2]float64{0.2, 0.2})}, []problem{{0, -1}}},
+		{"outside", []Boundary{line([2]float64{0.1, 0.1},
+			[2]float64{1.1, 0.5})}, []problem{{0, 1}}},
+		{"zero length", []Boundary{line([2]float64{0.1, 0.1},
+			[2]float64{0.1, 0.1})}, []problem{{0, 0}}},
+		{"bow tie", []Boundary{zone([2]float64{0.1, 0.1},
+			[2]float64{0.3, 0.3}, [2]float64{0.3, 0.1},
+			[2]float64{0.1, 0.3})}, []problem{{0, 0}}},
+		// only the zero-length edge is reported, not a crossing
+		{"repeated vertex", []Boundary{zone([2]float64{0.1, 0.1},
+			[2]float64{0.3, 0.1}, [2]float64{0.3, 0.1},
+			[2]float64{0.3, 0.3}, [2]float64{0.1, 0.3})},
+			[]problem{{0, 1}}},
+		{"closing vertex repeated",
+			[]Boundary{zone([2]float64{0.1, 0.1},
+				[2]float64{0.3, 0.1}, [2]float64{0.3, 0.3},
+				[2]float64{0.1, 0.1})}, []problem{{0, 3}}},
+		{"overlapping", []Boundary{square(0.125, 0.125, 0.25),
+			square(0.25, 0.25, 0.25)}, []problem{{1, -1}}},
+		{"sharing an edge", []Boundary{square(0.125, 0.125, 0.25),
+			square(0.375, 0.125, 0.25)}, nil},
+		{"sharing part of an edge",
+			[]Boundary{square(0.125, 0.125, 0.25),
+				square(0.375, 0.25, 0.25)}, nil},
+		{"sharing a corner", []Boundary{square(0.125, 0.125, 0.25),
+			square(0.375, 0.375, 0.25)}, nil},
+		{"overlapping along an edge",
+			[]Boundary{square(0.125, 0.125, 0.25),
+				square(0.25, 0.125, 0.25)}, []problem{{1, -1}}},
+		{"nested", []Boundary{square(0.125, 0.125, 0.5),
+			square(0.25, 0.25, 0.125)}, []problem{{1, -1}}},
+		{"nested in a corner", []Boundary{square(0.125, 0.125, 0.5),
+			square(0.125, 0.125, 0.125)}, []problem{{1, -1}}},
+		{"identical", []Boundary{square(0.125, 0.125, 0.25),
+			square(0.125, 0.125, 0.25)}, []problem{{1, -1}}},
+		{"line across zone", []Boundary{square(0.125, 0.125, 0.25),
+			line([2]float64{0, 0}, [2]float64{1, 1})}, nil},
+	}
+	for _, tt := range tests {
+		err := ValidateBoundaries(tt.boundaries)
+		var got []problem
+		var be *BoundaryError
+		if errors.As(err, &be) {
+			for _, p := range be.Problems {
+				got = append(got, problem{p.Index, p.Vertex})
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: problems %v, want %v (%v)", tt.name, got,
+				tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Responses to read-only commands whose results rarely change can be cached
+// in memory, so that dashboards polling many robots do not repeat them on
+// every refresh. State reads and mutations are never cached, and any
+// mutation empties the cache.
+
+package nucleo
+
+import (
+	"sync"
+	"time"
+)
+
+// The classes of command which may be cached
+const (
+	// InfoCommands are getGeneralInfo and getRobotInfo
+	InfoCommands CommandClass = iota
+	// PreferenceCommands are getPreferences
+	PreferenceCommands
+)
+
+var (
+	commandClasses = map[string]CommandClass{
+		"getGeneralInfo": InfoCommands,
+		"getRobotInfo":   InfoCommands,
+		"getPreferences": PreferenceCommands,
+	}
+
+	// readCommands are neither cached nor invalidate the cache
+	readCommands = []string{"getRobotState", "getLocalStats",
+		"getRobotManualCleaningInfo", "getSchedule", "getMapBoundaries"}
+)
+
+// CommandClass is a group of read-only commands cached with the same TTL
+type CommandClass int
+
+type cachedResponse struct {
+	id      reqID
+	body    []byte
+	expires time.Time
+}
+
+type responseCache struct {
+	mu      sync.Mutex
+	ttls    map[CommandClass]time.Duration
+	entries map[string]cachedResponse
+}
+
+// WithCache caches responses to the commands in class for ttl. Responses are
+// only cached for commands issued without Params.
+func WithCache(class CommandClass, ttl time.Duration) Option {
+	return func(r *Robot) {
+		if r.cache == nil {
+			r.cache = &responseCache{
+				ttls:    make(map[CommandClass]time.Duration),
+				entries: make(map[string]cachedResponse),
+			}
+		}
+		r.cache.ttls[class] = ttl
+	}
+}
+
+// ClearCache discards any cached responses
+func (r *Robot) ClearCache() {
+	if r.cache != nil {
+		r.cache.clear()
+	}
+}
+
+// ttl returns how long the response to a request may be cached for
+func (c *responseCache) ttl(a *request) (time.Duration, bool) {
+	if a.Params != nil {
+		return 0, false
+	}
+	class, ok := commandClasses[a.Cmd]
+	if !ok {
+		return 0, false
+	}
+	ttl, ok := c.ttls[class]
+	return ttl, ok && ttl > 0
+}
+
+func (c *responseCache) get(cmd string, now time.Time) (reqID, []byte,
+	bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[cmd]
+	if !ok || now.After(e.expires) {
+		return nil, nil, false
+	}
+	return e.id, e.body, true
+}
+
+func (c *responseCache) put(cmd string, id reqID, body []byte,
+	expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[cmd] = cachedResponse{id: id, body: body, expires: expires}
+}
+
+func (c *responseCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]cachedResponse)
+}
+
+// invalidate empties the cache if the request may change what is cached
+func (c *responseCache) invalidate(a *request) {
+	if _, ok := commandClasses[a.Cmd]; ok || contains(readCommands, a.Cmd) {
+		return
+	}
+	c.clear()
+}
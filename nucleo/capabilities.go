@@ -0,0 +1,114 @@
+// Robots advertise the services they support, and the version of each, in
+// their state responses. The field sets and parameters accepted differ
+// substantially between models, so features are detected from these services
+// rather than assumed.
+
+package nucleo
+
+import (
+	"strings"
+
+	"github.com/richlj/neato/profiles"
+)
+
+// Capabilities describes the services supported by a Robot, mapping each
+// service name (e.g. "houseCleaning") to its version (e.g. "basic-4")
+type Capabilities map[string]string
+
+// Capabilities returns the Capabilities advertised in a state Response
+func (resp *Response) Capabilities() Capabilities {
+	s := resp.AvailableServices
+	c := make(Capabilities)
+	for k, v := range map[string]string{
+		"houseCleaning":  s.HouseCleaning,
+		"spotCleaning":   s.SpotCleaning,
+		"manualCleaning": s.ManualCleaning,
+		"schedule":       s.Schedule,
+		"findMe":         s.FindMe,
+		"generalInfo":    s.GeneralInfo,
+		"maps":           s.Maps,
+		"preferences":    s.Preferences,
+		"IECTest":        s.IECTest,
+		"logCopy":        s.LogCopy,
+		"softwareUpdate": s.SoftwareUpdate,
+		"wifi":           s.Wifi,
+		"easyConnect":    s.EasyConnect,
+		"dashboard":      s.Dashboard,
+	} {
+		if v != "" {
+			c[k] = v
+		}
+	}
+	return c
+}
+
+// Capabilities retrieves the current Capabilities of the Robot
+func (r *Robot) Capabilities() (Capabilities, error) {
+	resp, err := r.GetRobotState(nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Capabilities(), nil
+}
+
+// ModelCapabilities returns the Capabilities of a known model, e.g.
+// "BotVacD7Connected", as captured in the bundled profiles. Robots running
+// other firmware versions may differ.
+func ModelCapabilities(model string) (Capabilities, bool) {
+	p, ok := profiles.Lookup(model)
+	if !ok {
+		return nil, false
+	}
+	c := make(Capabilities)
+	for k, v := range p.AvailableServices {
+		c[k] = v
+	}
+	return c, true
+}
+
+// Supports reports whether the named service is available
+func (c Capabilities) Supports(service string) bool {
+	_, ok := c[service]
+	return ok
+}
+
+// SpotCleaning reports whether the Robot can perform spot cleaning
+func (c Capabilities) SpotCleaning() bool {
+	return c.Supports("spotCleaning")
+}
+
+// EcoMode reports whether house cleaning accepts the eco/turbo mode
+func (c Capabilities) EcoMode() bool {
+	return strings.HasPrefix(c["houseCleaning"], "basic-")
+}
+
+// ScheduleModes reports whether schedule events carry the eco/turbo mode.
+// The minimal-1 schedule service has no mode.
+func (c Capabilities) ScheduleModes() bool {
+	return strings.HasPrefix(c["schedule"], "basic-")
+}
+
+// NavigationModes reports whether house cleaning accepts a navigation mode
+func (c Capabilities) NavigationModes() bool {
+	switch c["houseCleaning"] {
+	case "basic-3", "basic-4", "minimal-3":
+		return true
+	}
+	return false
+}
+
+// PersistentMaps reports whether the Robot can clean using a persistent map,
+// honouring its no-go lines
+func (c Capabilities) PersistentMaps() bool {
+	switch c["houseCleaning"] {
+	case "basic-3", "basic-4", "minimal-3":
+		return c.Supports("maps")
+	}
+	return false
+}
+
+// Zones reports whether the Robot can clean individual zones of a persistent
+// map
+func (c Capabilities) Zones() bool {
+	return c["houseCleaning"] == "basic-4" && c.Supports("maps")
+}
@@ -0,0 +1,56 @@
+// A house cleaning run started with category 2 ignores the Robot's persistent
+// map, and with it the no-go lines drawn on the map, without any error. When
+// the Params of StartCleaning leave the category unset, it is chosen from the
+// Robot's capabilities and whether it has an active persistent map.
+
+package nucleo
+
+// The cleaning categories accepted by StartCleaning
+const (
+	// CategoryAuto selects CategoryPersistentMap if the Robot supports it
+	// and has an active persistent map, and CategoryHouse otherwise
+	CategoryAuto          = 0
+	CategoryHouse         = 2
+	CategorySpot          = 3
+	CategoryPersistentMap = 4
+)
+
+// prepareRun returns a copy of the Params of a run with the Robot's default
+// navigation mode applied and the category chosen, checking that the Robot
+// supports the navigation mode. The Robot's state is fetched only if needed.
+func (r *Robot) prepareRun(a *Params) (*Params, error) {
+	if a == nil {
+		return nil, nil
+	}
+	p := *a
+	if p.NavigationMode == 0 {
+		p.NavigationMode = r.defaultNavigation()
+	}
+	needsCheck := p.NavigationMode != 0 &&
+		p.NavigationMode != NavigationNormal
+	if p.Category != CategoryAuto && !needsCheck {
+		return &p, nil
+	}
+	req, err := newRequest("getRobotState", nil)
+	if err != nil {
+		return nil, err
+	}
+	var result activeMapResponse
+	if err := r.do(req, &result); err != nil {
+		return nil, err
+	}
+	if _, err := r.check(req, &result.Response); err != nil {
+		return nil, err
+	}
+	c := result.Capabilities()
+	if err := checkNavigation(c, &p); err != nil {
+		return nil, err
+	}
+	if p.Category == CategoryAuto {
+		p.Category = CategoryHouse
+		if c.PersistentMaps() && result.Cleaning.MapID != "" {
+			p.Category = CategoryPersistentMap
+		}
+	}
+	return &p, nil
+}
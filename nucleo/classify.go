@@ -0,0 +1,80 @@
+// Errors are classified by what a caller can do about them, so that retry and
+// alerting policies can be written without knowing every error the SDK
+// returns.
+
+package nucleo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// StatusError is returned when an API answers with an unexpected HTTP status
+type StatusError struct {
+	Code   int
+	Status string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %s", e.Status)
+}
+
+// IsRetryable reports whether the request which failed with err may succeed
+// if repeated later: it timed out, its connection could not be made or was
+// lost, the API was overloaded or unavailable, or the robot was offline.
+// Errors which would recur, such as a failed TLS handshake or a malformed
+// URL, are not retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if IsRobotOffline(err) {
+		return true
+	}
+	var s *StatusError
+	if errors.As(err, &s) {
+		switch s.Code {
+		case http.StatusTooManyRequests, http.StatusInternalServerError,
+			http.StatusBadGateway, http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var n net.Error
+	if errors.As(err, &n) && n.Timeout() {
+		return true
+	}
+	var dns *net.DNSError
+	if errors.As(err, &dns) {
+		return dns.IsTemporary
+	}
+	var op *net.OpError
+	if errors.As(err, &op) {
+		// TLS alerts are reported as a "remote error"
+		return op.Op == "dial" || op.Op == "read" || op.Op == "write"
+	}
+	// the server closed the connection before responding
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// IsAuthError reports whether err shows that the credentials or SecretKey
+// used were rejected
+func IsAuthError(err error) bool {
+	if errors.Is(err, ErrUnauthorized) {
+		return true
+	}
+	var s *StatusError
+	return errors.As(err, &s) && (s.Code == http.StatusUnauthorized ||
+		s.Code == http.StatusForbidden)
+}
+
+// IsRobotOffline reports whether err shows that the robot could not be
+// reached by the Nucleo API
+func IsRobotOffline(err error) bool {
+	return errors.Is(err, ErrRobotOffline)
+}
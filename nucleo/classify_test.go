@@ -0,0 +1,96 @@
+package nucleo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"syscall"
+	"testing"
+)
+
+// timeoutError is a net.Error which timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func urlError(err error) error {
+	return &url.Error{Op: "Post", URL: "https://example.com", Err: err}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"cancelled", urlError(context.Canceled), false},
+		{"offline", fmt.Errorf("state: %w", ErrRobotOffline), true},
+		{"unavailable", &StatusError{Code: 503}, true},
+		{"bad request", &StatusError{Code: 400}, false},
+		{"timeout", urlError(timeoutError{}), true},
+		{"deadline", urlError(context.DeadlineExceeded), true},
+		{"refused", urlError(&net.OpError{Op: "dial",
+			Err: syscall.ECONNREFUSED}), true},
+		{"reset", urlError(&net.OpError{Op: "read",
+			Err: syscall.ECONNRESET}), true},
+		{"closed", urlError(io.EOF), true},
+		{"dns temporary", urlError(&net.OpError{Op: "dial",
+			Err: &net.DNSError{IsTemporary: true}}), true},
+		{"dns not found", urlError(&net.OpError{Op: "dial",
+			Err: &net.DNSError{IsNotFound: true}}), false},
+		{"tls alert", urlError(&net.OpError{Op: "remote error",
+			Err: errors.New("tls: handshake failure")}), false},
+		{"scheme", urlError(errors.New("unsupported protocol scheme")),
+			false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: IsRetryable(%v) = %t", tt.name, tt.err,
+				got)
+		}
+	}
+}
+
+func TestIsRetryableRequests(t *testing.T) {
+	tlsSrv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	// the failed handshakes are expected
+	tlsSrv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	tlsSrv.StartTLS()
+	defer tlsSrv.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := "http://" + l.Addr().String()
+	l.Close()
+	tests := []struct {
+		name string
+		url  string
+		want bool
+	}{
+		{"untrusted certificate", tlsSrv.URL, false},
+		{"unsupported scheme", "ftp://example.com/", false},
+		{"refused", closed, true},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(tt.url)
+		if err == nil {
+			resp.Body.Close()
+			t.Errorf("%s: request succeeded", tt.name)
+			continue
+		}
+		if got := IsRetryable(err); got != tt.want {
+			t.Errorf("%s: IsRetryable(%v) = %t", tt.name, err, got)
+		}
+	}
+}
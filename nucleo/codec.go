@@ -0,0 +1,83 @@
+// Requests and responses are encoded as JSON by default. The encoding is
+// behind the Codec interface so that other wire formats, such as the msgpack
+// spoken by some endpoints or a future revision of the protocol, can be
+// supplied WithCodec without changing the command methods.
+
+package nucleo
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/richlj/neato/decode"
+)
+
+var (
+	// JSON is the Codec of the Nucleo API, and the default
+	JSON Codec = jsonCodec{}
+)
+
+// Codec encodes requests to and decodes responses from the Nucleo API. The
+// bytes appended by Encode are those sent and signed.
+type Codec interface {
+	// ContentType is sent as the Content-Type of requests
+	ContentType() string
+	// Encode appends the encoding of v to buf
+	Encode(buf *bytes.Buffer, v interface{}) error
+	// Decode reads a response from r into v
+	Decode(r io.Reader, v interface{}) error
+}
+
+// WithCodec selects the wire encoding used by the Robot
+func WithCodec(c Codec) Option {
+	return func(r *Robot) {
+		r.codec = c
+	}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return "application/json"
+}
+
+// Encode produces the same bytes as json.Marshal
+func (jsonCodec) Encode(buf *bytes.Buffer, v interface{}) error {
+	n := buf.Len()
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		buf.Truncate(n)
+		return err
+	}
+	// Encode terminates the value with a newline, which Marshal does not
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return decode.Decode(r, v)
+}
+
+// rawBody is a response target into which the body is read undecoded, so
+// that it can be cached and decoded later
+type rawBody []byte
+
+// getCodec returns the Robot's Codec
+func (r *Robot) getCodec() Codec {
+	if r.codec == nil {
+		return JSON
+	}
+	return r.codec
+}
+
+// encode encodes the request into a buffer from bufferPool, which the
+// caller must return
+func (r *Robot) encode(a *request) (*bytes.Buffer, error) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	if err := r.getCodec().Encode(buf, a); err != nil {
+		bufferPool.Put(buf)
+		return nil, err
+	}
+	return buf, nil
+}
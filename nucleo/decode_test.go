@@ -0,0 +1,147 @@
+package nucleo
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/richlj/neato/decode"
+)
+
+func TestDecodeState(t *testing.T) {
+	tests := []struct {
+		file   string
+		state  State
+		charge int
+		alert  string
+		err    string
+	}{
+		{"state_cleaning.json", StateBusy, 64, "ui_alert_dust_bin_full",
+			""},
+		{"state_idle.json", StateIdle, 98, "", ""},
+		{"state_null_strings.json", StateIdle, 98, "", ""},
+		{"rejected.json", StateError, 41, "",
+			"ui_error_navigation_noprogress"},
+	}
+	for _, tt := range tests {
+		var resp Response
+		err := decode.Unmarshal(readCorpus(t, tt.file), &resp)
+		if err != nil {
+			t.Errorf("%s: %v", tt.file, err)
+			continue
+		}
+		s := NewRobotState("", &resp)
+		if s.State != tt.state || s.Charge != tt.charge ||
+			s.Alert != tt.alert || s.Error != tt.err {
+			t.Errorf("%s: got %+v", tt.file, s)
+		}
+	}
+}
+
+func TestDecodeLocalStats(t *testing.T) {
+	tests := []struct {
+		file   string
+		starts []time.Time
+		ok     bool
+	}{
+		{"local_stats.json", nil, true},
+		{"local_stats_empty_timestamps.json", []time.Time{{},
+			time.Date(2019, 6, 5, 13, 20, 41, 0, time.UTC)}, true},
+		{"local_stats_truncated.json", nil, false},
+		{"empty.json", nil, false},
+	}
+	for _, tt := range tests {
+		var starts []time.Time
+		s := &statsStream{fn: func(category string,
+			h RunHistory) error {
+			if category == "houseCleaning" {
+				starts = append(starts, h.Start)
+			}
+			return nil
+		}}
+		b := readCorpus(t, tt.file)
+		err := s.decodeFrom(bytes.NewReader(b), JSON)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: %v", tt.file, err)
+			continue
+		}
+		if tt.starts == nil {
+			continue
+		}
+		if len(starts) != len(tt.starts) {
+			t.Errorf("%s: %d runs, want %d", tt.file, len(starts),
+				len(tt.starts))
+			continue
+		}
+		for i, want := range tt.starts {
+			if !starts[i].Equal(want) {
+				t.Errorf("%s: run %d started %v, want %v",
+					tt.file, i, starts[i], want)
+			}
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		file  string
+		empty bool
+	}{
+		{"empty.json", true},
+		{"local_stats_truncated.json", false},
+	}
+	for _, tt := range tests {
+		var resp Response
+		err := decode.Unmarshal(readCorpus(t, tt.file), &resp)
+		var de *decode.Error
+		if tt.empty && !errors.Is(err, decode.ErrEmpty) ||
+			!tt.empty && !errors.As(err, &de) {
+			t.Errorf("%s: error %v", tt.file, err)
+		}
+	}
+}
+
+// FuzzDecode feeds its input to each of the decoders applied to Nucleo
+// responses, which must return an error rather than panic. It is seeded
+// with testdata/corpus, which holds responses in the shapes robots send,
+// including the known firmware oddities.
+func FuzzDecode(f *testing.F) {
+	files, err := filepath.Glob(filepath.Join("testdata", "corpus", "*"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var resp Response
+		if decode.Unmarshal(data, &resp) == nil {
+			NewRobotState("", &resp).Summary()
+		}
+		var state stateResponse
+		_ = decode.Unmarshal(data, &state)
+		var info generalInfoResponse
+		if decode.Unmarshal(data, &info) == nil {
+			_, _ = info.Data.parse()
+		}
+		var hardware robotInfoResponse
+		if decode.Unmarshal(data, &hardware) == nil {
+			hardware.Data.hardwareInfo()
+		}
+		var boundaries boundariesResponse
+		_ = decode.Unmarshal(data, &boundaries)
+		var floor activeMapResponse
+		_ = decode.Unmarshal(data, &floor)
+		s := &statsStream{fn: func(string, RunHistory) error {
+			return nil
+		}}
+		_ = s.decodeFrom(bytes.NewReader(data), JSON)
+	})
+}
@@ -0,0 +1,94 @@
+package nucleo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+const defaultsFileMode = 0600
+
+// RunDefaults are the settings applied to a robot's cleaning runs which do
+// not choose their own
+type RunDefaults struct {
+	NavigationMode int `json:"navigation_mode,omitempty"`
+}
+
+// Defaults holds RunDefaults for each robot, keyed by serial number, in a
+// local JSON file so that they persist between restarts
+type Defaults struct {
+	path string
+
+	mu     sync.Mutex
+	robots map[string]RunDefaults
+}
+
+// OpenDefaults loads the Defaults stored at path, which need not exist yet.
+// An empty path keeps the Defaults in memory only.
+func OpenDefaults(path string) (*Defaults, error) {
+	d := &Defaults{path: path, robots: make(map[string]RunDefaults)}
+	if path == "" {
+		return d, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &d.robots); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return d, nil
+}
+
+// Get returns the RunDefaults of the robot with the supplied serial number
+func (d *Defaults) Get(serial string) (RunDefaults, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	result, ok := d.robots[serial]
+	return result, ok
+}
+
+// Set stores the RunDefaults of the robot with the supplied serial number
+func (d *Defaults) Set(serial string, rd RunDefaults) error {
+	if rd.NavigationMode != 0 && (rd.NavigationMode < NavigationNormal ||
+		rd.NavigationMode > NavigationDeep) {
+		return fmt.Errorf("navigation mode %d is not between %d and %d",
+			rd.NavigationMode, NavigationNormal, NavigationDeep)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.robots[serial] = rd
+	return d.save()
+}
+
+// Delete removes the RunDefaults of the robot with the supplied serial number
+func (d *Defaults) Delete(serial string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.robots, serial)
+	return d.save()
+}
+
+// save rewrites the Defaults' file, replacing it atomically. d.mu must be
+// held.
+func (d *Defaults) save() error {
+	if d.path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(d.robots, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := d.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, defaultsFileMode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.path)
+}
@@ -0,0 +1,121 @@
+// Diagnostics gathers what the Nucleo API reveals about the robot's
+// navigation hardware, for troubleshooting navigation failures. The API
+// offers third parties no command to run a scan test, so sensor status is
+// derived from the errors the robot reports.
+
+package nucleo
+
+import (
+	"strings"
+)
+
+const (
+	ldsErrorPrefix = "ui_error_lds_"
+)
+
+// The components to which SensorFaults are attributed
+const (
+	ComponentLDS        = "lds"
+	ComponentBumper     = "bumper"
+	ComponentDrop       = "drop"
+	ComponentWheels     = "wheels"
+	ComponentBrush      = "brush"
+	ComponentNavigation = "navigation"
+	ComponentHardware   = "hardware"
+)
+
+var (
+	// sensorErrors maps robot errors to the components responsible for
+	// them
+	sensorErrors = map[string]string{
+		"ui_error_bumper_stuck":             ComponentBumper,
+		"ui_error_rdrop_stuck":              ComponentDrop,
+		"ui_error_ldrop_stuck":              ComponentDrop,
+		"ui_error_picked_up":                ComponentDrop,
+		"ui_error_navigation_falling":       ComponentDrop,
+		"ui_error_lwheel_stuck":             ComponentWheels,
+		"ui_error_rwheel_stuck":             ComponentWheels,
+		"ui_error_brush_stuck":              ComponentBrush,
+		"ui_error_brush_overloaded":         ComponentBrush,
+		"ui_error_navigation_noprogress":    ComponentNavigation,
+		"ui_error_unable_to_return_to_base": ComponentNavigation,
+		"ui_error_hardware_failure":         ComponentHardware,
+	}
+)
+
+// Diagnostics groups the troubleshooting commands of a Robot
+type Diagnostics struct {
+	robot *Robot
+}
+
+// SensorFault is an error reported by a Robot, attributed to a component
+type SensorFault struct {
+	Component string
+	Code      string
+}
+
+// SensorStatus lists the SensorFaults a Robot is currently reporting
+type SensorStatus struct {
+	Faults []SensorFault
+}
+
+// Diagnostics returns the troubleshooting sub-API of the Robot
+func (r *Robot) Diagnostics() *Diagnostics {
+	return &Diagnostics{robot: r}
+}
+
+// LDS returns the identity of the Robot's laser distance sensor
+func (d *Diagnostics) LDS() (*LDSInfo, error) {
+	h, err := d.robot.GetRobotInfo(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &h.LDS, nil
+}
+
+// SensorStatus reports the faults in the Robot's sensors and drive hardware
+func (d *Diagnostics) SensorStatus() (*SensorStatus, error) {
+	s, err := d.robot.State()
+	if err != nil {
+		return nil, err
+	}
+	return NewSensorStatus(s), nil
+}
+
+// NewSensorStatus builds a SensorStatus from the errors and alerts in a
+// RobotState
+func NewSensorStatus(s *RobotState) *SensorStatus {
+	result := &SensorStatus{}
+	for _, code := range []string{s.Error, s.Alert} {
+		if c, ok := sensorComponent(code); ok {
+			result.Faults = append(result.Faults, SensorFault{
+				Component: c,
+				Code:      code,
+			})
+		}
+	}
+	return result
+}
+
+// OK reports whether no faults are present
+func (s *SensorStatus) OK() bool {
+	return len(s.Faults) == 0
+}
+
+// Component returns the fault reported for the named component, if any
+func (s *SensorStatus) Component(name string) (SensorFault, bool) {
+	for _, f := range s.Faults {
+		if f.Component == name {
+			return f, true
+		}
+	}
+	return SensorFault{}, false
+}
+
+func sensorComponent(code string) (string, bool) {
+	if strings.HasPrefix(code, ldsErrorPrefix) {
+		return ComponentLDS, true
+	}
+	c, ok := sensorErrors[code]
+	return c, ok
+}
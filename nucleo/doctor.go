@@ -0,0 +1,208 @@
+// Doctor runs a series of checks against a Robot and the Nucleo API, for
+// working out why a robot is misbehaving without reading through raw
+// responses.
+
+package nucleo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/richlj/neato/egress"
+	"github.com/richlj/neato/profiles"
+)
+
+const (
+	lowBattery = 20
+)
+
+// The possible outcomes of a doctor check
+const (
+	Pass Severity = iota
+	Warn
+	Fail
+)
+
+// Severity is the outcome of a single doctor check
+type Severity int
+
+// String returns the name of the Severity
+func (s Severity) String() string {
+	switch s {
+	case Pass:
+		return "pass"
+	case Warn:
+		return "warn"
+	}
+	return "fail"
+}
+
+// Finding is the outcome of a single doctor check
+type Finding struct {
+	Check    string
+	Severity Severity
+	Message  string
+}
+
+// DoctorReport holds the Findings of a Doctor run
+type DoctorReport struct {
+	Robot    string
+	Findings []Finding
+}
+
+// Doctor checks the reachability of the Nucleo API, the Robot's credentials
+// and its state, battery, alerts, firmware and schedule. Checks that depend
+// on an earlier failed check are skipped.
+func (r *Robot) Doctor(ctx context.Context) *DoctorReport {
+	d := &DoctorReport{Robot: r.Name}
+	if !d.add(checkReachability(ctx, r.dialAddr())) || ctx.Err() != nil {
+		return d
+	}
+	r = r.WithContext(ctx)
+	resp, err := r.GetRobotState(nil)
+	if !d.add(checkAuth(err)) || !d.add(checkState(err)) {
+		return d
+	}
+	s := NewRobotState(r.Name, resp)
+	d.add(checkBattery(s))
+	d.add(checkAlerts(s))
+	d.add(r.checkFirmware(resp.Meta))
+	if ctx.Err() != nil {
+		return d
+	}
+	d.add(r.checkSchedule(resp.Capabilities()))
+	return d
+}
+
+// add records f and reports whether later checks may proceed
+func (d *DoctorReport) add(f Finding) bool {
+	d.Findings = append(d.Findings, f)
+	return f.Severity != Fail
+}
+
+// Worst returns the most severe outcome amongst the Findings
+func (d *DoctorReport) Worst() Severity {
+	result := Pass
+	for _, f := range d.Findings {
+		if f.Severity > result {
+			result = f.Severity
+		}
+	}
+	return result
+}
+
+// String returns one line per Finding, e.g. "[warn] battery: charge at 12%"
+func (d *DoctorReport) String() string {
+	var b strings.Builder
+	for _, f := range d.Findings {
+		fmt.Fprintf(&b, "[%s] %s: %s\n", f.Severity, f.Check, f.Message)
+	}
+	return b.String()
+}
+
+func checkReachability(ctx context.Context, addr string) Finding {
+	f := Finding{Check: "cloud", Message: "Nucleo API is reachable"}
+	conn, err := egress.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		f.Severity = Fail
+		f.Message = fmt.Sprintf("cannot reach %s: %v", addr, err)
+		return f
+	}
+	_ = conn.Close()
+	return f
+}
+
+func checkAuth(err error) Finding {
+	f := Finding{Check: "auth", Message: "secret key accepted"}
+	if errors.Is(err, ErrUnauthorized) {
+		f.Severity = Fail
+		f.Message = "secret key rejected; relist robots to fetch the " +
+			"current key"
+	}
+	return f
+}
+
+func checkState(err error) Finding {
+	f := Finding{Check: "state", Message: "state retrieved"}
+	if err != nil {
+		f.Severity = Fail
+		f.Message = fmt.Sprintf("cannot retrieve state: %v", err)
+	}
+	return f
+}
+
+func checkBattery(s *RobotState) Finding {
+	f := Finding{Check: "battery",
+		Message: fmt.Sprintf("charge at %d%%", s.Charge)}
+	if s.Charge < lowBattery && !s.Charging {
+		f.Severity = Warn
+		if !s.Docked {
+			f.Message += " and not docked"
+		}
+	}
+	return f
+}
+
+func checkAlerts(s *RobotState) Finding {
+	f := Finding{Check: "alerts", Message: "no alerts pending"}
+	switch {
+	case s.Error != "":
+		f.Severity = Fail
+		f.Message = fmt.Sprintf("error %s", s.Error)
+	case s.Alert != "":
+		f.Severity = Warn
+		f.Message = fmt.Sprintf("alert %s", s.Alert)
+	}
+	return f
+}
+
+// checkFirmware compares the Robot's firmware with the newest known for its
+// model
+func (r *Robot) checkFirmware(m meta) Finding {
+	f := Finding{Check: "firmware", Message: m.Firmware}
+	current, err := ParseFirmwareVersion(m.Firmware)
+	if err != nil {
+		f.Severity = Warn
+		f.Message = fmt.Sprintf("unrecognised firmware %q", m.Firmware)
+		return f
+	}
+	model := m.ModelName
+	if model == "" {
+		model = r.Model
+	}
+	p, ok := profiles.Lookup(model)
+	if !ok {
+		f.Message += " (no reference firmware for this model)"
+		return f
+	}
+	known, err := ParseFirmwareVersion(p.Firmware)
+	if err == nil && current.Compare(known) < 0 {
+		f.Severity = Warn
+		f.Message = fmt.Sprintf("%s is older than %s", current, known)
+	}
+	return f
+}
+
+func (r *Robot) checkSchedule(c Capabilities) Finding {
+	f := Finding{Check: "schedule", Message: "schedule is valid"}
+	resp, err := r.GetSchedule(nil)
+	if err != nil {
+		f.Severity = Warn
+		f.Message = fmt.Sprintf("cannot retrieve schedule: %v", err)
+		return f
+	}
+	events := resp.Data.Events
+	switch {
+	case resp.Data.Enabled && len(events) == 0:
+		f.Severity = Warn
+		f.Message = "schedule is enabled but has no events"
+	case len(events) > 0:
+		if err := ValidateSchedule(events, c, nil); err != nil {
+			f.Severity = Warn
+			f.Message = err.Error()
+		}
+	}
+	return f
+}
@@ -0,0 +1,35 @@
+package nucleo
+
+var (
+	// dustbinAlerts are the codes with which Robots report a full dust bin
+	dustbinAlerts = map[string]bool{
+		"dustbin_full":           true,
+		"ui_alert_dust_bin_full": true,
+		"ui_error_dust_bin_full": true,
+	}
+)
+
+// DustbinFull reports whether the RobotState shows a full dust bin, as an
+// alert or as an error
+func (s *RobotState) DustbinFull() bool {
+	return dustbinAlerts[s.Alert] || dustbinAlerts[s.Error]
+}
+
+// ResumeAfterBinEmptied dismisses the full dust bin alert and resumes the
+// paused run, if any. It is intended to be called once the bin has been
+// emptied.
+func (r *Robot) ResumeAfterBinEmptied() error {
+	s, err := r.State()
+	if err != nil {
+		return err
+	}
+	if s.DustbinFull() {
+		if _, err := r.DismissCurrentAlert(nil); err != nil {
+			return err
+		}
+	}
+	if s.State == StatePaused {
+		_, err = r.ResumeCleaning(nil)
+	}
+	return err
+}
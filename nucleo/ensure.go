@@ -0,0 +1,73 @@
+// The Ensure methods read a Robot's state before commanding it, so that
+// automations can request an outcome repeatedly without tripping over
+// commands the Robot would reject.
+
+package nucleo
+
+import (
+	"context"
+)
+
+// cleaningActions are the Actions of a Robot part way through a run
+var cleaningActions = map[Action]bool{
+	ActionHouseCleaning:  true,
+	ActionSpotCleaning:   true,
+	ActionManualCleaning: true,
+	ActionMapCleaning:    true,
+	ActionSuspended:      true,
+}
+
+// EnsureCleaning makes the Robot clean: a paused run is resumed, a Robot
+// returning to base is stopped and started afresh, and an idle Robot is
+// started with the supplied parameters. It does nothing if the Robot is
+// already cleaning, and reports whether a command was issued.
+func (r *Robot) EnsureCleaning(ctx context.Context, a *Params) (bool,
+	error) {
+	r = r.WithContext(ctx)
+	s, err := r.State()
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case s.State == StateBusy && cleaningActions[s.Action]:
+		return false, nil
+	case s.State == StatePaused && cleaningActions[s.Action]:
+		_, err = r.ResumeCleaning(nil)
+		return true, err
+	case s.Action == ActionDocking && s.State != StateIdle:
+		if _, err := r.StopCleaning(nil); err != nil {
+			return true, err
+		}
+		if err := ctx.Err(); err != nil {
+			return true, err
+		}
+	}
+	_, err = r.StartCleaning(a)
+	return true, err
+}
+
+// EnsureDocked sends the Robot to its base, pausing any run in progress
+// first. It does nothing if the Robot is docked or already returning, and
+// reports whether a command was issued.
+func (r *Robot) EnsureDocked(ctx context.Context) (bool, error) {
+	r = r.WithContext(ctx)
+	s, err := r.State()
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case s.Docked:
+		return false, nil
+	case s.State == StateBusy && s.Action == ActionDocking:
+		return false, nil
+	case s.State == StateBusy:
+		if _, err := r.PauseCleaning(nil); err != nil {
+			return true, err
+		}
+		if err := ctx.Err(); err != nil {
+			return true, err
+		}
+	}
+	_, err = r.SendToBase(nil)
+	return true, err
+}
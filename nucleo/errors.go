@@ -0,0 +1,64 @@
+package nucleo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RequestError records the command, robot and endpoint of a failed request,
+// so that errors from a program driving several robots identify which robot
+// and which call failed. The underlying error is available to errors.Is and
+// errors.As.
+type RequestError struct {
+	Cmd string
+	// Serial is masked by MaskSerial, so that the error may be logged
+	Serial   string
+	Endpoint string
+	Err      error
+}
+
+func (e *RequestError) Error() string {
+	msg := e.Err.Error()
+	if !strings.HasPrefix(msg, e.Cmd+":") {
+		msg = e.Cmd + ": " + msg
+	}
+	return fmt.Sprintf("robot %s at %s: %s", e.Serial, e.Endpoint, msg)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// MaskSerial returns serial with all but its last four characters hidden
+func MaskSerial(serial string) string {
+	n := len(serial) - 4
+	if n < 0 {
+		n = len(serial)
+	}
+	return strings.Repeat("*", n) + serial[n:]
+}
+
+// wrap returns err annotated with the request and Robot it arose from, or nil
+// if err is nil
+func (r *Robot) wrap(a *request, err error) error {
+	if err == nil {
+		return nil
+	}
+	host := r.host()
+	var re *routeError
+	if errors.As(err, &re) {
+		host, err = re.host, re.err
+	}
+	return &RequestError{
+		Cmd:      a.Cmd,
+		Serial:   MaskSerial(r.Serial),
+		Endpoint: host,
+		Err:      err,
+	}
+}
+
+// host returns the host and port to which the Robot's requests are sent
+func (r *Robot) host() string {
+	return r.routeHost(Route{})
+}
@@ -0,0 +1,122 @@
+// Robots with persistent maps may hold a floor plan for each floor of a
+// house. A zone can only be cleaned on the floor plan the Robot is currently
+// using, and Nucleo accepts a boundary from another floor plan without
+// cleaning anything, so zone commands are checked against the active floor
+// plan once the floor plans are known.
+
+package nucleo
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNoActiveFloorPlan is returned when the Robot does not report the
+	// floor plan it is using
+	ErrNoActiveFloorPlan = errors.New("robot reports no active floor plan")
+)
+
+// FloorPlan is a persistent map of one floor, and the Boundaries drawn on it
+type FloorPlan struct {
+	ID         string
+	Name       string
+	Boundaries []Boundary
+}
+
+// Boundary returns the Boundary with the supplied ID, if it is on the
+// FloorPlan
+func (f *FloorPlan) Boundary(id string) (*Boundary, bool) {
+	for i := range f.Boundaries {
+		if f.Boundaries[i].ID == id {
+			return &f.Boundaries[i], true
+		}
+	}
+	return nil, false
+}
+
+// FloorPlanError is returned when a zone command names a Boundary on a
+// FloorPlan other than the one the Robot is using
+type FloorPlanError struct {
+	BoundaryID string
+	FloorPlan  string
+	Active     string
+}
+
+func (e *FloorPlanError) Error() string {
+	return fmt.Sprintf("boundary %s is on floor plan %s, but the robot is "+
+		"using floor plan %s", e.BoundaryID, e.FloorPlan, e.Active)
+}
+
+type activeMapResponse struct {
+	Response
+	Cleaning struct {
+		MapID string `json:"mapId"`
+	} `json:"cleaning"`
+}
+
+// ActiveFloorPlan returns the ID of the persistent map the Robot is using,
+// or ErrNoActiveFloorPlan if it reports none
+func (r *Robot) ActiveFloorPlan() (string, error) {
+	req, err := newRequest("getRobotState", nil)
+	if err != nil {
+		return "", err
+	}
+	var result activeMapResponse
+	if err := r.do(req, &result); err != nil {
+		return "", err
+	}
+	if _, err := r.check(req, &result.Response); err != nil {
+		return "", err
+	}
+	if result.Cleaning.MapID == "" {
+		return "", ErrNoActiveFloorPlan
+	}
+	return result.Cleaning.MapID, nil
+}
+
+// LoadFloorPlans fetches the Boundaries of the persistent maps with the
+// supplied IDs, e.g. those listed by the Beehive API, and records the
+// resulting FloorPlans so that subsequent zone commands are checked against
+// the active one
+func (r *Robot) LoadFloorPlans(mapIDs ...string) ([]FloorPlan, error) {
+	var result []FloorPlan
+	for _, id := range mapIDs {
+		b, err := r.Boundaries(id)
+		if err != nil {
+			return nil, fmt.Errorf("floor plan %s: %v", id, err)
+		}
+		result = append(result, FloorPlan{ID: id, Boundaries: b})
+	}
+	r.shared().setFloorPlans(result)
+	return result, nil
+}
+
+// checkFloorPlan returns a *FloorPlanError if the Params name a Boundary on
+// a known FloorPlan which is not active. Boundaries on no known FloorPlan
+// are passed through unchecked.
+func (r *Robot) checkFloorPlan(a *Params) error {
+	if a == nil || a.BoundaryID == "" {
+		return nil
+	}
+	plans := r.floorPlans()
+	var plan string
+	for i := range plans {
+		if _, ok := plans[i].Boundary(a.BoundaryID); ok {
+			plan = plans[i].ID
+			break
+		}
+	}
+	if plan == "" {
+		return nil
+	}
+	active, err := r.ActiveFloorPlan()
+	if err != nil {
+		return err
+	}
+	if active != plan {
+		return &FloorPlanError{BoundaryID: a.BoundaryID,
+			FloorPlan: plan, Active: active}
+	}
+	return nil
+}
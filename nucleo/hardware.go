@@ -0,0 +1,347 @@
+// The getRobotInfo command reports the manufacturing details of the robot's
+// components. HardwareInfo groups them by component, and HardwareReport
+// formats them for inclusion in support tickets.
+
+package nucleo
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	// mfgDateFormats are the layouts used by firmwares for manufacturing
+	// dates
+	mfgDateFormats = []string{"20060102", "2006-01-02", "2006/01/02"}
+)
+
+// HardwareInfo describes the components of a Robot
+type HardwareInfo struct {
+	ModelName        string
+	ManufacturedAt   time.Time
+	Firmware         RobotFirmware
+	Board            BoardInfo
+	LDS              LDSInfo
+	BatteryPack      BatteryPackInfo
+	Sensors          SensorInfo
+	Motors           MotorInfo
+	Locale           int
+	USMode           int
+	NeatoServer      string
+	CartridgeRuntime time.Duration
+}
+
+// RobotFirmware is the version of the main firmware as reported by
+// getRobotInfo
+type RobotFirmware struct {
+	Major int
+	Minor int
+	Build int
+}
+
+// BoardInfo describes the main and user interface boards
+type BoardInfo struct {
+	CPUMACID          string
+	ManufacturedAt    time.Time
+	Revision          int
+	ChassisRevision   int
+	BootLoaderVersion int
+	UIBoardHardware   int
+	UIBoardSoftware   int
+	QAState           int
+}
+
+// LDSInfo describes the laser distance sensor
+type LDSInfo struct {
+	Version   string
+	Serial    string
+	CPU       string
+	Build     string
+	MotorType int
+}
+
+// BatteryPackInfo describes the battery pack and its management chip
+type BatteryPackInfo struct {
+	Type            int
+	Manufacturer    string
+	DeviceName      string
+	Chemistry       string
+	SerialNumber    int
+	ManufacturedAt  time.Time
+	HardwareVersion string
+	SoftwareVersion string
+	DesignCapacity  int
+	DesignVoltage   int
+	MaxVoltage      int
+	MaxCurrent      int
+}
+
+// SensorInfo describes the revisions of the Robot's sensors
+type SensorInfo struct {
+	Drop               int
+	Magnetic           int
+	Wall               int
+	WheelPod           int
+	UltrasonicHardware int
+	UltrasonicSoftware int
+}
+
+// MotorInfo describes the brush and blower motors
+type MotorInfo struct {
+	Blower          int
+	BlowerHardware  int
+	BlowerSoftware  string
+	BrushMotor      int
+	SideBrush       int
+	SideBrushPower  int
+	BrushPresent    bool
+	VacuumPresent   bool
+	BrushDirection  int
+	VacuumDirection int
+}
+
+type robotInfoResponse struct {
+	Response
+	Data robotInfo `json:"data"`
+}
+
+type robotInfo struct {
+	ModelName                     string `json:"modelName"`
+	CPUMACID                      string `json:"CPUMACID"`
+	MainBrdMfgDate                string `json:"MainBrdMfgDate"`
+	RobotMfgDate                  string `json:"RobotMfgDate"`
+	BoardRev                      int    `json:"BoardRev"`
+	ChassisRev                    int    `json:"ChassisRev"`
+	BatteryType                   int    `json:"BatteryType"`
+	WheelPodType                  int    `json:"WheelPodType"`
+	DropSensorType                int    `json:"DropSensorType"`
+	MagSensorType                 int    `json:"MagSensorType"`
+	WallSensorType                int    `json:"WallSensorType"`
+	LDSMotorType                  int    `json:"LDSMotorType"`
+	Locale                        int    `json:"Locale"`
+	USMode                        int    `json:"USMode"`
+	NeatoServer                   string `json:"NeatoServer"`
+	BrushPresent                  int    `json:"BrushPresent"`
+	VacuumPresent                 int    `json:"VacuumPresent"`
+	BrushDirection                int    `json:"BrushDirection"`
+	VacuumDirection               int    `json:"VacuumDirection"`
+	CumulativeCartridgeTimeInSecs int    `json:"CumulativeCartridgeTimeInSecs"`
+	BlowerType                    int    `json:"BlowerType"`
+	BrushMotorType                int    `json:"BrushMotorType"`
+	SideBrushType                 int    `json:"SideBrushType"`
+	SideBrushPower                int    `json:"SideBrushPower"`
+	HardwareVersionMajor          int    `json:"hardware_version_major"`
+	HardwareVersionMinor          int    `json:"hardware_version_minor"`
+	SoftwareVersionMajor          int    `json:"software_version_major"`
+	SoftwareVersionMinor          int    `json:"software_version_minor"`
+	MaxVoltage                    int    `json:"max_voltage"`
+	MaxCurrent                    int    `json:"max_current"`
+	DesignCapacity                int    `json:"design_capacity"`
+	DesignVoltage                 int    `json:"design_voltage"`
+	MfgDay                        int    `json:"mfg_day"`
+	MfgMonth                      int    `json:"mfg_month"`
+	MfgYear                       int    `json:"mfg_year"`
+	SerialNumber                  int    `json:"serial_number"`
+	MfgName                       string `json:"mfg_name"`
+	DeviceName                    string `json:"device_name"`
+	ChemistryName                 string `json:"chemistry_name"`
+	Major                         int    `json:"Major"`
+	Minor                         int    `json:"Minor"`
+	Build                         int    `json:"Build"`
+	LdsVer                        string `json:"ldsVer"`
+	LdsSerial                     string `json:"ldsSerial"`
+	LdsCPU                        string `json:"ldsCPU"`
+	LdsBuildNum                   string `json:"ldsBuildNum"`
+	BootLoaderVersion             int    `json:"bootLoaderVersion"`
+	UIBoardSWVer                  int    `json:"uiBoardSWVer"`
+	UIBoardHWVer                  int    `json:"uiBoardHWVer"`
+	QAState                       int    `json:"qaState"`
+	UltrasonicSW                  int    `json:"ultrasonicSW"`
+	UltrasonicHW                  int    `json:"ultrasonicHW"`
+	BlowerHW                      int    `json:"blowerHW"`
+	BlowerSWMajor                 int    `json:"blowerSWMajor"`
+	BlowerSWMinor                 int    `json:"blowerSWMinor"`
+}
+
+// GetRobotInfo returns information about the Robot's hardware
+func (r *Robot) GetRobotInfo(a *Params) (*HardwareInfo, error) {
+	req, err := newRequest("getRobotInfo", a)
+	if err != nil {
+		return nil, err
+	}
+	var result robotInfoResponse
+	if err := r.do(req, &result); err != nil {
+		return nil, err
+	}
+	if _, err := r.check(req, &result.Response); err != nil {
+		return nil, err
+	}
+	return result.Data.hardwareInfo(), nil
+}
+
+func (i *robotInfo) hardwareInfo() *HardwareInfo {
+	h := &HardwareInfo{
+		ModelName:      i.ModelName,
+		ManufacturedAt: parseMfgDate(i.RobotMfgDate),
+		Firmware: RobotFirmware{
+			Major: i.Major,
+			Minor: i.Minor,
+			Build: i.Build,
+		},
+		Board: BoardInfo{
+			CPUMACID:          i.CPUMACID,
+			ManufacturedAt:    parseMfgDate(i.MainBrdMfgDate),
+			Revision:          i.BoardRev,
+			ChassisRevision:   i.ChassisRev,
+			BootLoaderVersion: i.BootLoaderVersion,
+			UIBoardHardware:   i.UIBoardHWVer,
+			UIBoardSoftware:   i.UIBoardSWVer,
+			QAState:           i.QAState,
+		},
+		LDS: LDSInfo{
+			Version:   i.LdsVer,
+			Serial:    i.LdsSerial,
+			CPU:       i.LdsCPU,
+			Build:     i.LdsBuildNum,
+			MotorType: i.LDSMotorType,
+		},
+		BatteryPack: BatteryPackInfo{
+			Type:         i.BatteryType,
+			Manufacturer: i.MfgName,
+			DeviceName:   i.DeviceName,
+			Chemistry:    i.ChemistryName,
+			SerialNumber: i.SerialNumber,
+			HardwareVersion: fmt.Sprintf("%d.%d",
+				i.HardwareVersionMajor, i.HardwareVersionMinor),
+			SoftwareVersion: fmt.Sprintf("%d.%d",
+				i.SoftwareVersionMajor, i.SoftwareVersionMinor),
+			DesignCapacity: i.DesignCapacity,
+			DesignVoltage:  i.DesignVoltage,
+			MaxVoltage:     i.MaxVoltage,
+			MaxCurrent:     i.MaxCurrent,
+		},
+		Sensors: SensorInfo{
+			Drop:               i.DropSensorType,
+			Magnetic:           i.MagSensorType,
+			Wall:               i.WallSensorType,
+			WheelPod:           i.WheelPodType,
+			UltrasonicHardware: i.UltrasonicHW,
+			UltrasonicSoftware: i.UltrasonicSW,
+		},
+		Motors: MotorInfo{
+			Blower:         i.BlowerType,
+			BlowerHardware: i.BlowerHW,
+			BlowerSoftware: fmt.Sprintf("%d.%d", i.BlowerSWMajor,
+				i.BlowerSWMinor),
+			BrushMotor:      i.BrushMotorType,
+			SideBrush:       i.SideBrushType,
+			SideBrushPower:  i.SideBrushPower,
+			BrushPresent:    i.BrushPresent != 0,
+			VacuumPresent:   i.VacuumPresent != 0,
+			BrushDirection:  i.BrushDirection,
+			VacuumDirection: i.VacuumDirection,
+		},
+		Locale:           i.Locale,
+		USMode:           i.USMode,
+		NeatoServer:      i.NeatoServer,
+		CartridgeRuntime: seconds(i.CumulativeCartridgeTimeInSecs),
+	}
+	if i.MfgYear != 0 {
+		h.BatteryPack.ManufacturedAt = time.Date(i.MfgYear,
+			time.Month(i.MfgMonth), i.MfgDay, 0, 0, 0, 0, time.UTC)
+	}
+	return h
+}
+
+// parseMfgDate parses a manufacturing date, returning the zero time.Time
+// when it is missing or in an unrecognised format
+func parseMfgDate(s string) time.Time {
+	for _, f := range mfgDateFormats {
+		if t, err := time.Parse(f, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// String returns the RobotFirmware in the form "major.minor.build"
+func (f RobotFirmware) String() string {
+	return fmt.Sprintf("%d.%d.%d", f.Major, f.Minor, f.Build)
+}
+
+// HardwareReport returns a plain text description of the HardwareInfo,
+// grouped by component, for inclusion in support tickets
+func (h *HardwareInfo) HardwareReport() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	section := func(name string, fields ...interface{}) {
+		fmt.Fprintf(w, "%s\n", name)
+		for i := 0; i+1 < len(fields); i += 2 {
+			fmt.Fprintf(w, "  %s:\t%v\n", fields[i], fields[i+1])
+		}
+	}
+	section("Robot",
+		"Model", h.ModelName,
+		"Manufactured", formatMfgDate(h.ManufacturedAt),
+		"Firmware", h.Firmware,
+		"Locale", h.Locale,
+		"US mode", h.USMode,
+		"Server", h.NeatoServer,
+		"Cartridge runtime", h.CartridgeRuntime)
+	section("Main board",
+		"CPU MAC ID", h.Board.CPUMACID,
+		"Manufactured", formatMfgDate(h.Board.ManufacturedAt),
+		"Revision", h.Board.Revision,
+		"Chassis revision", h.Board.ChassisRevision,
+		"Boot loader", h.Board.BootLoaderVersion,
+		"UI board hardware", h.Board.UIBoardHardware,
+		"UI board software", h.Board.UIBoardSoftware,
+		"QA state", h.Board.QAState)
+	section("Laser distance sensor",
+		"Version", h.LDS.Version,
+		"Serial", h.LDS.Serial,
+		"CPU", h.LDS.CPU,
+		"Build", h.LDS.Build,
+		"Motor type", h.LDS.MotorType)
+	p := h.BatteryPack
+	section("Battery pack",
+		"Type", p.Type,
+		"Manufacturer", p.Manufacturer,
+		"Device", p.DeviceName,
+		"Chemistry", p.Chemistry,
+		"Serial number", p.SerialNumber,
+		"Manufactured", formatMfgDate(p.ManufacturedAt),
+		"Hardware version", p.HardwareVersion,
+		"Software version", p.SoftwareVersion,
+		"Design capacity", fmt.Sprintf("%d mAh", p.DesignCapacity),
+		"Design voltage", fmt.Sprintf("%d mV", p.DesignVoltage),
+		"Max voltage", fmt.Sprintf("%d mV", p.MaxVoltage),
+		"Max current", fmt.Sprintf("%d mA", p.MaxCurrent))
+	section("Sensors",
+		"Drop", h.Sensors.Drop,
+		"Magnetic", h.Sensors.Magnetic,
+		"Wall", h.Sensors.Wall,
+		"Wheel pod", h.Sensors.WheelPod,
+		"Ultrasonic hardware", h.Sensors.UltrasonicHardware,
+		"Ultrasonic software", h.Sensors.UltrasonicSoftware)
+	section("Motors",
+		"Blower", h.Motors.Blower,
+		"Blower hardware", h.Motors.BlowerHardware,
+		"Blower software", h.Motors.BlowerSoftware,
+		"Brush motor", h.Motors.BrushMotor,
+		"Side brush", h.Motors.SideBrush,
+		"Side brush power", h.Motors.SideBrushPower,
+		"Brush present", h.Motors.BrushPresent,
+		"Vacuum present", h.Motors.VacuumPresent)
+	_ = w.Flush()
+	return b.String()
+}
+
+func formatMfgDate(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.Format("2006-01-02")
+}
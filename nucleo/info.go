@@ -0,0 +1,189 @@
+// The getGeneralInfo command describes the robot's identity, firmware and
+// battery. Its response is decoded into a GeneralInfo rather than the
+// general-purpose Response data, with dates and durations parsed.
+
+package nucleo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	manufacturingDateFormat = "2006-01-02"
+)
+
+// GeneralInfo describes a Robot's identity, firmware and battery
+type GeneralInfo struct {
+	Product  ProductInfo
+	Firmware FirmwareVersion
+	Battery  BatteryInfo
+	Language string
+	Clock24h bool
+}
+
+// ProductInfo identifies a Robot's hardware
+type ProductInfo struct {
+	Number string
+	Serial string
+	Model  string
+}
+
+// FirmwareVersion is a firmware release such as "4.5.3-189"
+type FirmwareVersion struct {
+	Major int
+	Minor int
+	Patch int
+	Build int
+}
+
+// BatteryInfo describes a Robot's battery. The durations are zero when the
+// Robot does not report them, as when it is docked.
+type BatteryInfo struct {
+	Level               int
+	TimeToEmpty         time.Duration
+	TimeToFullCharge    time.Duration
+	TotalCharges        int
+	ManufacturingDate   time.Time
+	AuthorizationStatus int
+	Vendor              string
+}
+
+type generalInfoResponse struct {
+	Response
+	Data generalInfo `json:"data"`
+}
+
+type generalInfo struct {
+	ProductNumber string  `json:"productNumber"`
+	Serial        string  `json:"serial"`
+	Model         string  `json:"model"`
+	Firmware      string  `json:"firmware"`
+	Language      string  `json:"language"`
+	Clock24h      bool    `json:"clock24h"`
+	Battery       battery `json:"battery"`
+}
+
+type battery struct {
+	Level               int    `json:"level"`
+	TimeToEmpty         int    `json:"timeToEmpty"`
+	TimeToFullCharge    int    `json:"timeToFullCharge"`
+	TotalCharges        int    `json:"totalCharges"`
+	ManufacturingDate   string `json:"manufacturingDate"`
+	AuthorizationStatus int    `json:"authorizationStatus"`
+	Vendor              string `json:"vendor"`
+}
+
+// GetGeneralInfo returns a variety of information about the Robot
+func (r *Robot) GetGeneralInfo(a *Params) (*GeneralInfo, error) {
+	req, err := newRequest("getGeneralInfo", a)
+	if err != nil {
+		return nil, err
+	}
+	var result generalInfoResponse
+	if err := r.do(req, &result); err != nil {
+		return nil, err
+	}
+	if _, err := r.check(req, &result.Response); err != nil {
+		return nil, err
+	}
+	return result.Data.parse()
+}
+
+func (g *generalInfo) parse() (*GeneralInfo, error) {
+	b := g.Battery
+	result := &GeneralInfo{
+		Product: ProductInfo{
+			Number: g.ProductNumber,
+			Serial: g.Serial,
+			Model:  g.Model,
+		},
+		Battery: BatteryInfo{
+			Level:               b.Level,
+			TimeToEmpty:         seconds(b.TimeToEmpty),
+			TimeToFullCharge:    seconds(b.TimeToFullCharge),
+			TotalCharges:        b.TotalCharges,
+			AuthorizationStatus: b.AuthorizationStatus,
+			Vendor:              b.Vendor,
+		},
+		Language: g.Language,
+		Clock24h: g.Clock24h,
+	}
+	if g.Firmware != "" {
+		v, err := ParseFirmwareVersion(g.Firmware)
+		if err != nil {
+			return nil, err
+		}
+		result.Firmware = v
+	}
+	if b.ManufacturingDate != "" {
+		t, err := time.Parse(manufacturingDateFormat,
+			b.ManufacturingDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid battery manufacturing "+
+				"date: %v", err)
+		}
+		result.Battery.ManufacturingDate = t
+	}
+	return result, nil
+}
+
+// seconds converts a number of seconds reported by the Robot, where negative
+// values mean unknown, into a time.Duration
+func seconds(s int) time.Duration {
+	if s < 0 {
+		return 0
+	}
+	return time.Duration(s) * time.Second
+}
+
+// ParseFirmwareVersion parses a firmware version of the form
+// "major.minor.patch[-build]"
+func ParseFirmwareVersion(s string) (FirmwareVersion, error) {
+	var v FirmwareVersion
+	release, build, hasBuild := strings.Cut(s, "-")
+	parts := strings.Split(release, ".")
+	if len(parts) != 3 {
+		return v, fmt.Errorf("invalid firmware version %q", s)
+	}
+	fields := []*int{&v.Major, &v.Minor, &v.Patch}
+	if hasBuild {
+		parts = append(parts, build)
+		fields = append(fields, &v.Build)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return FirmwareVersion{}, fmt.Errorf(
+				"invalid firmware version %q", s)
+		}
+		*fields[i] = n
+	}
+	return v, nil
+}
+
+// String returns the FirmwareVersion in the form used by the Robots
+func (v FirmwareVersion) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Build != 0 {
+		s += fmt.Sprintf("-%d", v.Build)
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 as v is older than, the same as, or newer than o
+func (v FirmwareVersion) Compare(o FirmwareVersion) int {
+	a := []int{v.Major, v.Minor, v.Patch, v.Build}
+	b := []int{o.Major, o.Minor, o.Patch, o.Build}
+	for i := range a {
+		switch {
+		case a[i] < b[i]:
+			return -1
+		case a[i] > b[i]:
+			return 1
+		}
+	}
+	return 0
+}
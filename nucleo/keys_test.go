@@ -0,0 +1,84 @@
+package nucleo_test
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/richlj/neato/nucleo"
+	"github.com/richlj/neato/profiles"
+	"github.com/richlj/neato/simulator"
+)
+
+const testSerial = "OPS01234-0123456789AB"
+
+// newSimulated returns a Robot, configured with opts, for a simulated D7
+// whose secret key is key
+func newSimulated(t *testing.T, key string,
+	opts ...nucleo.Option) *nucleo.Robot {
+	t.Helper()
+	p, _ := profiles.Lookup("BotVacD7Connected")
+	srv := httptest.NewServer(simulator.NewServer(simulator.NewModel(p,
+		testSerial, key)))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = append([]nucleo.Option{nucleo.WithEndpoint(u)}, opts...)
+	return nucleo.NewRobot(testSerial, "old", opts...)
+}
+
+func TestKeyRotation(t *testing.T) {
+	var refreshes int32
+	r := newSimulated(t, "new", nucleo.WithKeyRefresh(
+		func(serial string) (string, error) {
+			atomic.AddInt32(&refreshes, 1)
+			return "new", nil
+		}))
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				_, err := r.LoadFloorPlans("map1")
+				errs <- err
+				return
+			}
+			_, err := r.State()
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if r.SecretKey != "old" {
+		t.Errorf("SecretKey changed to %q", r.SecretKey)
+	}
+	n := atomic.LoadInt32(&refreshes)
+	if _, err := r.State(); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&refreshes) != n {
+		t.Error("key refreshed again after rotation was picked up")
+	}
+}
+
+func TestKeyRotationUnchanged(t *testing.T) {
+	r := newSimulated(t, "new", nucleo.WithKeyRefresh(
+		func(serial string) (string, error) {
+			return "old", nil
+		}))
+	if _, err := r.State(); !nucleo.IsAuthError(err) {
+		t.Errorf("State with a stale key: got %v, want an auth error",
+			err)
+	}
+}
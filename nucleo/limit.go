@@ -0,0 +1,58 @@
+package nucleo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/richlj/neato/clock"
+)
+
+// Limiter paces the requests sent to the Nucleo API. A single Limiter shared
+// by every Robot, e.g. through beehive.WithRobotOptions, bounds the rate of
+// requests from the whole process.
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// RateLimiter is a Limiter which spaces requests evenly, so that bursts of
+// commands are smoothed out rather than sent together
+type RateLimiter struct {
+	// Clock paces the requests. It defaults to clock.Real.
+	Clock clock.Clock
+
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewRateLimiter returns a RateLimiter allowing rate requests per second
+func NewRateLimiter(rate float64) *RateLimiter {
+	interval := time.Duration(float64(time.Second) / rate)
+	return &RateLimiter{interval: interval}
+}
+
+// Wait blocks until the next request may be sent, or ctx is cancelled
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	c := clock.Or(l.Clock)
+	l.mu.Lock()
+	now := c.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+	d := at.Sub(now)
+	if d <= 0 {
+		return nil
+	}
+	t := c.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C():
+		return nil
+	}
+}
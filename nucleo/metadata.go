@@ -0,0 +1,37 @@
+package nucleo
+
+import (
+	"context"
+)
+
+type metadataKey struct{}
+
+// WithRequestMetadata returns a copy of ctx carrying md, merged over any
+// metadata ctx already carries. Metadata labels the commands issued on behalf
+// of a request, e.g. {"trigger": "schedule"}, so that middleware such as the
+// audit log can record why they were issued.
+func WithRequestMetadata(ctx context.Context,
+	md map[string]string) context.Context {
+	merged := RequestMetadata(ctx)
+	if merged == nil {
+		merged = make(map[string]string, len(md))
+	}
+	for k, v := range md {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, metadataKey{}, merged)
+}
+
+// RequestMetadata returns a copy of the metadata carried by ctx, or nil if
+// there is none
+func RequestMetadata(ctx context.Context) map[string]string {
+	md, _ := ctx.Value(metadataKey{}).(map[string]string)
+	if md == nil {
+		return nil
+	}
+	result := make(map[string]string, len(md))
+	for k, v := range md {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,61 @@
+package nucleo
+
+import "sync"
+
+// mutable holds the state of a Robot which changes whilst it is in use, so
+// that a Robot may be shared by goroutines: a rotated SecretKey picked up
+// through WithKeyRefresh, the FloorPlans recorded by LoadFloorPlans and the
+// pooled HMAC states of the current key. It is shared by copies of the
+// Robot, such as IgnoringQuietHours returns.
+type mutable struct {
+	mu         sync.RWMutex
+	secretKey  string
+	floorPlans []FloorPlan
+	macs       *macPool
+}
+
+// shared returns the Robot's mutable state. It is allocated by NewRobot and
+// Configure; Robots made otherwise allocate it on first use, and must not be
+// shared before then.
+func (r *Robot) shared() *mutable {
+	if r.mutable == nil {
+		r.mutable = &mutable{}
+	}
+	return r.mutable
+}
+
+// secretKey returns the key with which requests are signed: the rotated key
+// if one has been picked up, and otherwise the SecretKey
+func (r *Robot) secretKey() string {
+	if m := r.mutable; m != nil {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		if m.secretKey != "" {
+			return m.secretKey
+		}
+	}
+	return r.SecretKey
+}
+
+func (m *mutable) setSecretKey(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.secretKey = key
+}
+
+// floorPlans returns the FloorPlans recorded by LoadFloorPlans
+func (r *Robot) floorPlans() []FloorPlan {
+	m := r.mutable
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.floorPlans
+}
+
+func (m *mutable) setFloorPlans(plans []FloorPlan) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.floorPlans = plans
+}
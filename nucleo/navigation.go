@@ -0,0 +1,113 @@
+// Robots with newer house cleaning services accept a navigation mode for each
+// run: normal, extra care, which moves more gently around furniture, or deep,
+// which covers the floor twice in turbo mode. A default can be set for each
+// Robot, and overridden for a single run with RunOptions.
+
+package nucleo
+
+import (
+	"fmt"
+)
+
+// The navigation modes accepted by house cleaning runs
+const (
+	NavigationNormal    = 1
+	NavigationExtraCare = 2
+	NavigationDeep      = 3
+
+	deepNavigationService = "basic-4"
+)
+
+// RunOption adjusts the Params of a single cleaning run
+type RunOption func(*Params)
+
+// WithExtraCare makes the run navigate gently around furniture
+func WithExtraCare() RunOption {
+	return WithNavigation(NavigationExtraCare)
+}
+
+// WithDeepNavigation makes the run cover the floor thoroughly, which the
+// robots only do in turbo mode
+func WithDeepNavigation() RunOption {
+	return func(p *Params) {
+		p.NavigationMode = NavigationDeep
+		p.Mode = modeTurbo
+	}
+}
+
+// WithNavigation sets the navigation mode of the run
+func WithNavigation(mode int) RunOption {
+	return func(p *Params) {
+		p.NavigationMode = mode
+	}
+}
+
+// WithNavigationMode sets the navigation mode used by runs which do not
+// choose one
+func WithNavigationMode(mode int) Option {
+	return func(r *Robot) {
+		r.navigationMode = mode
+	}
+}
+
+// WithDefaults applies the RunDefaults stored for the Robot in d to runs which
+// do not choose their own settings, in preference to WithNavigationMode
+func WithDefaults(d *Defaults) Option {
+	return func(r *Robot) {
+		r.runDefaults = d
+	}
+}
+
+// StartCleaningWith starts a house cleaning run with the Robot's persistent
+// map if it has one, eco mode and the Robot's default navigation mode, as
+// adjusted by opts
+func (r *Robot) StartCleaningWith(opts ...RunOption) (*Response, error) {
+	p := &Params{
+		Category: CategoryAuto,
+		Mode:     modeEco,
+		Modifier: modifierNormal,
+	}
+	for _, o := range opts {
+		o(p)
+	}
+	return r.StartCleaning(p)
+}
+
+// defaultNavigation returns the navigation mode used by runs which do not
+// choose one, which may be 0 if the Robot has no default
+func (r *Robot) defaultNavigation() int {
+	if r.runDefaults != nil {
+		if d, ok := r.runDefaults.Get(r.Serial); ok &&
+			d.NavigationMode != 0 {
+			return d.NavigationMode
+		}
+	}
+	return r.navigationMode
+}
+
+// checkNavigation returns an error if the Robot with the supplied
+// Capabilities cannot run with the Params' navigation mode
+func checkNavigation(c Capabilities, p *Params) error {
+	switch p.NavigationMode {
+	case 0, NavigationNormal:
+		return nil
+	case NavigationExtraCare, NavigationDeep:
+	default:
+		return fmt.Errorf("navigation mode %d is not between %d and %d",
+			p.NavigationMode, NavigationNormal, NavigationDeep)
+	}
+	if !c.NavigationModes() {
+		return fmt.Errorf("house cleaning service %q does not support "+
+			"navigation modes", c["houseCleaning"])
+	}
+	if p.NavigationMode == NavigationDeep {
+		if c["houseCleaning"] != deepNavigationService {
+			return fmt.Errorf("house cleaning service %q does not "+
+				"support deep navigation", c["houseCleaning"])
+		}
+		if p.Mode != modeTurbo {
+			return fmt.Errorf("deep navigation requires turbo mode")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,683 @@
+// The Nucelo API is used to interact with the robots themselves via some kind
+// of authenticating proxy. It covers a whole range of robot commands and
+// actions, all the way down to establishing a direct network connection to the
+// device and issuing remote control commands.
+
+package nucleo
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/richlj/neato/clock"
+)
+
+const (
+	nucleoAcceptHeader = "application/vnd.neato.nucleo.v1"
+	nucleoHost         = "nucleo.neatocloud.com:4443"
+	scheme             = "https"
+
+	timeFormat = "Mon, 02 Jan 2006 15:04:05 MST"
+	authScheme = "NEATOAPP "
+	idLength   = 16
+
+	// Addr is the network address of the Nucleo API
+	Addr = nucleoHost
+)
+
+var (
+	// ErrUnauthorized is returned when Nucleo rejects a request's
+	// signature, typically because the Robot's SecretKey is wrong or has
+	// been rotated
+	ErrUnauthorized = errors.New("request signature rejected")
+
+	// ErrRobotOffline is returned when Nucleo cannot reach the Robot, which
+	// it reports as the robot not being found
+	ErrRobotOffline = errors.New("robot is not connected")
+)
+
+// A Robot is the target of Nucleo commands. The Serial and SecretKey are
+// supplied by the Beehive API, and the Name and Model are used for display
+// and capability detection.
+type Robot struct {
+	Serial    string     `json:"serial"`
+	Name      string     `json:"name"`
+	Model     string     `json:"model"`
+	SecretKey string     `json:"secret_key"`
+	Version   APIVersion `json:"-"`
+
+	quietHours       *QuietHours
+	ignoreQuietHours bool
+	cache            *responseCache
+	keyRefresh       func(serial string) (string, error)
+	mutable          *mutable
+	rawResults       bool
+	endpoint         *url.URL
+	limiter          Limiter
+	maxResponseBytes int64
+	clock            clock.Clock
+	codec            Codec
+	navigationMode   int
+	runDefaults      *Defaults
+	timeouts         map[string]time.Duration
+	router           Router
+	ctx              context.Context
+}
+
+// APIVersion selects the version of the Nucleo API used to issue commands.
+// The zero value selects V1, currently the only version.
+type APIVersion int
+
+// The supported versions of the Nucleo API
+const (
+	V1 APIVersion = 1
+)
+
+// acceptHeader returns the Accept header value which requests v
+func (v APIVersion) acceptHeader() string {
+	if v <= V1 {
+		return nucleoAcceptHeader
+	}
+	return fmt.Sprintf("application/vnd.neato.nucleo.v%d", v)
+}
+
+type reqID []byte
+
+type request struct {
+	ReqID  reqID       `json:"reqId"`
+	Cmd    string      `json:"cmd"`
+	Params interface{} `json:"params,omitempty"`
+}
+
+// Params are values supplied to modify Nucleo requests. In some cases there
+// are particular mandatory values. This varies between robots and software
+// versions.
+type Params struct {
+	Category                     int      `json:"category"`
+	Mode                         int      `json:"mode"`
+	Modifier                     int      `json:"modifier"`
+	RobotSounds                  bool     `json:"robotSounds"`
+	DirtbinAlert                 bool     `json:"dirtbinAlert"`
+	AllAlerts                    bool     `json:"allAlerts"`
+	Leds                         bool     `json:"leds"`
+	ButtonClicks                 bool     `json:"buttonClicks"`
+	DirtbinAlertReminderInterval int      `json:"dirtbinAlertReminderInterval"`
+	FilterChangeReminderInterval int      `json:"filterChangeReminderInterval"`
+	BrushChangeReminderInterval  int      `json:"brushChangeReminderInterval"`
+	Clock24H                     bool     `json:"clock24h"`
+	Locale                       string   `json:"locale"`
+	AvailableLocales             []string `json:"availableLocales"`
+	NavigationMode               int      `json:"navigationMode"`
+	BoundaryID                   string   `json:"boundaryId"`
+	SpotWidth                    int      `json:"spotWidth"`
+	SpotHeight                   int      `json:"spotHeight"`
+	Events                       []Event  `json:"events"`
+}
+
+// Response combines the Standard Response and the State Response values
+type Response struct {
+	Version           int               `json:"version"`
+	ReqID             reqID             `json:"reqId"`
+	Result            Result            `json:"result"`
+	Data              data              `json:"data"`
+	State             State             `json:"state,omitempty"`
+	Action            Action            `json:"action,omitempty"`
+	Error             interface{}       `json:"error,omitempty"`
+	Alert             string            `json:"alert,omitempty"`
+	Cleaning          cleaning          `json:"cleaning,omitempty"`
+	Details           details           `json:"details,omitempty"`
+	AvailableCommands availableCommands `json:"availableCommands,omitempty"`
+	AvailableServices availableServices `json:"availableServices,omitempty"`
+	Meta              meta              `json:"meta,omitempty"`
+}
+
+type details struct {
+	IsCharging        bool `json:"isCharging"`
+	IsDocked          bool `json:"isDocked"`
+	DockHasBeenSeen   bool `json:"dockHasBeenSeen"`
+	Charge            int  `json:"charge"`
+	IsScheduleEnabled bool `json:"isScheduleEnabled"`
+}
+
+type availableCommands struct {
+	Start    bool `json:"start"`
+	Stop     bool `json:"stop"`
+	Pause    bool `json:"pause"`
+	Resume   bool `json:"resume"`
+	GoToBase bool `json:"goToBase"`
+}
+
+type availableServices struct {
+	HouseCleaning  string `json:"houseCleaning"`
+	SpotCleaning   string `json:"spotCleaning"`
+	ManualCleaning string `json:"manualCleaning"`
+	Schedule       string `json:"schedule"`
+	FindMe         string `json:"findMe"`
+	GeneralInfo    string `json:"generalInfo"`
+	Maps           string `json:"maps"`
+	Preferences    string `json:"preferences"`
+	IECTest        string `json:"IECTest"`
+	LogCopy        string `json:"logCopy"`
+	SoftwareUpdate string `json:"softwareUpdate"`
+	Wifi           string `json:"wifi"`
+	EasyConnect    string `json:"easyConnect"`
+	Dashboard      string `json:"dashboard"`
+}
+
+type meta struct {
+	ModelName string `json:"modelName"`
+	Firmware  string `json:"firmware"`
+}
+
+// signingString appends the string signed for a request to dst
+func (r *Robot) signingString(dst, body []byte, ts string) []byte {
+	for i := 0; i < len(r.Serial); i++ {
+		c := r.Serial[i]
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		dst = append(dst, c)
+	}
+	dst = append(dst, '\n')
+	dst = append(dst, ts...)
+	dst = append(dst, '\n')
+	return append(dst, body...)
+}
+
+// authorization returns the Authorization header of a request dated ts,
+// signing body, which must be the exact bytes sent
+func (r *Robot) authorization(body []byte, ts string) string {
+	sig := r.sign(body, ts)
+	h := make([]byte, len(authScheme)+hex.EncodedLen(len(sig)))
+	copy(h, authScheme)
+	hex.Encode(h[len(authScheme):], sig)
+	return string(h)
+}
+
+func (r *Robot) sign(body []byte, ts string) []byte {
+	p := r.macs(r.secretKey())
+	h := p.get()
+	defer p.put(h)
+	buf := bufferPool.Get().(*bytes.Buffer)
+	defer bufferPool.Put(buf)
+	buf.Reset()
+	buf.Grow(len(r.Serial) + len(ts) + len(body) + 2)
+	h.Write(r.signingString(buf.Bytes(), body, ts))
+	return h.Sum(nil)
+}
+
+// addHeaders sets the headers of a request whose body is body
+func (r *Robot) addHeaders(req *http.Request, body []byte) {
+	r.setHeaders(req.Header, r.now().Format(timeFormat), body)
+}
+
+// setHeaders sets the headers of a request dated ts whose body is body in h
+func (r *Robot) setHeaders(h http.Header, ts string, body []byte) {
+	h.Set("Accept", r.Version.acceptHeader())
+	h.Set("Content-Type", r.getCodec().ContentType())
+	h.Set("Date", ts)
+	h.Set("Authorization", r.authorization(body, ts))
+}
+
+func (r *Robot) exec(a *request) (*Response, error) {
+	var result Response
+	if err := r.do(a, &result); err != nil {
+		return nil, err
+	}
+	return r.check(a, &result)
+}
+
+// do issues the request to the Robot and decodes the response into v,
+// serving it from the cache where permitted. Errors are returned as a
+// *RequestError.
+func (r *Robot) do(a *request, v interface{}) error {
+	return r.wrap(a, r.fetch(a, v))
+}
+
+func (r *Robot) fetch(a *request, v interface{}) error {
+	if err := r.checkQuietHours(a.Cmd); err != nil {
+		return err
+	}
+	if r.cache == nil {
+		return r.send(a, v)
+	}
+	r.cache.invalidate(a)
+	ttl, ok := r.cache.ttl(a)
+	if !ok {
+		return r.send(a, v)
+	}
+	if id, body, ok := r.cache.get(a.Cmd, r.now()); ok {
+		// the response carries the ID of the request which fetched it
+		a.ReqID = id
+		return r.getCodec().Decode(bytes.NewReader(body), v)
+	}
+	var body rawBody
+	if err := r.send(a, &body); err != nil {
+		return err
+	}
+	if err := r.getCodec().Decode(bytes.NewReader(body), v); err != nil {
+		return err
+	}
+	var result struct {
+		Result string `json:"result"`
+	}
+	if r.getCodec().Decode(bytes.NewReader(body), &result) == nil &&
+		result.Result == "ok" {
+		r.cache.put(a.Cmd, a.ReqID, body, r.now().Add(ttl))
+	}
+	return nil
+}
+
+// send issues the request to the Robot and decodes the response into v,
+// retrying once with a refreshed SecretKey if the signature is rejected
+func (r *Robot) send(a *request, v interface{}) error {
+	used := r.secretKey()
+	err := r.post(a, v)
+	if !errors.Is(err, ErrUnauthorized) || r.keyRefresh == nil {
+		return err
+	}
+	// another request may have picked up the rotated key meanwhile
+	key, kerr := r.keyRefresh(r.Serial)
+	if kerr != nil || key == used {
+		return err
+	}
+	r.shared().setSecretKey(key)
+	return r.post(a, v)
+}
+
+func (r *Robot) post(a *request, v interface{}) error {
+	if r.limiter != nil {
+		if err := r.limiter.Wait(r.context()); err != nil {
+			return err
+		}
+	}
+	buf, err := r.encode(a)
+	if err != nil {
+		return err
+	}
+	p := path.Join("vendors/neato/robots", r.Serial, "messages")
+	ctx, cancel := r.requestContext(a.Cmd)
+	defer cancel()
+	if r.router != nil {
+		return r.postRouted(ctx, a, p, buf, v)
+	}
+	body := &pooledBody{Reader: bytes.NewReader(buf.Bytes()), buf: buf}
+	resp, err := r.transmit(ctx, r.url(p), body, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return r.read(resp, v)
+}
+
+// transmit sends the encoded request b, read from body, to u
+func (r *Robot) transmit(ctx context.Context, u string, body io.ReadCloser,
+	b []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	req.ContentLength = int64(len(b))
+	// the signature covers the same bytes as are read from body
+	r.addHeaders(req, b)
+	return httpClient.Do(req)
+}
+
+// read decodes resp into v, closing its body
+func (r *Robot) read(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized ||
+		resp.StatusCode == http.StatusForbidden {
+		return ErrUnauthorized
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrRobotOffline
+	}
+	if resp.StatusCode/100 != 2 {
+		return &StatusError{Code: resp.StatusCode, Status: resp.Status}
+	}
+	var rd io.Reader = resp.Body
+	if r.maxResponseBytes > 0 {
+		rd = &cappedReader{r: rd, remaining: r.maxResponseBytes}
+	}
+	switch t := v.(type) {
+	case *rawBody:
+		b, err := ioutil.ReadAll(rd)
+		*t = b
+		return err
+	case streamDecoder:
+		return t.decodeFrom(rd, r.getCodec())
+	}
+	return r.getCodec().Decode(rd, v)
+}
+
+// url returns the URL of the Nucleo resource at p, relative to the Robot's
+// endpoint if one is set
+func (r *Robot) url(p string) string {
+	return resolve(r.endpoint, p)
+}
+
+// base returns a copy of the base URL of the Robot's requests
+func (r *Robot) base() *url.URL {
+	if r.endpoint == nil {
+		return &url.URL{Scheme: scheme, Host: nucleoHost}
+	}
+	u := *r.endpoint
+	return &u
+}
+
+// dialAddr returns the network address to which the Robot's requests are
+// sent, with the scheme's default port if none is set
+func (r *Robot) dialAddr() string {
+	u := r.base()
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "443"
+	if u.Scheme == "http" {
+		port = "80"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// resolve returns the URL of the Nucleo resource at p relative to base, or
+// to the Nucleo API if base is nil
+func resolve(base *url.URL, p string) string {
+	if base == nil {
+		return (&url.URL{Scheme: scheme, Host: nucleoHost,
+			Path: p}).String()
+	}
+	u := *base
+	u.Path = path.Join(u.Path, p)
+	return u.String()
+}
+
+type data struct {
+	Enabled                      bool      `json:"enabled,omitempty"`
+	Events                       []Event   `json:"events,omitempty"`
+	RobotSounds                  bool      `json:"robotSounds,omitempty"`
+	DirtbinAlertReminderInterval int       `json:"dirtbinAlertReminderInterval,omitempty"`
+	FilterChangeReminderInterval int       `json:"filterChangeReminderInterval,omitempty"`
+	BrushChangeReminderInterval  int       `json:"brushChangeReminderInterval,omitempty"`
+	HouseCleaning                cleaning  `json:"houseCleaning"`
+	SpotCleaning                 cleaning  `json:"spotCleaning"`
+	TotalCleanedArea             float64   `json:"totalCleanedArea"`
+	TotalCleaningTime            int       `json:"totalCleaningTime"`
+	AverageCleanedArea           float64   `json:"averageCleanedArea"`
+	AverageCleaningTime          int       `json:"averageCleaningTime"`
+	History                      []history `json:"history"`
+}
+
+type cleaning struct {
+	TotalCleanedArea    float64   `json:"totalCleanedArea"`
+	TotalCleaningTime   int       `json:"totalCleaningTime"`
+	AverageCleanedArea  float64   `json:"averageCleanedArea"`
+	AverageCleaningTime int       `json:"averageCleaningTime"`
+	History             []history `json:"history"`
+}
+
+type history = RunHistory
+
+// RunHistory is a single cleaning run in the statistics kept by a Robot
+type RunHistory struct {
+	Start                         time.Time `json:"start"`
+	End                           time.Time `json:"end"`
+	SuspendedCleaningChargingTime int       `json:"suspendedCleaningChargingTime"`
+	ErrorTime                     int       `json:"errorTime"`
+	PauseTime                     int       `json:"pauseTime"`
+	Mode                          int       `json:"mode"`
+	Area                          float64   `json:"area"`
+	LaunchedFrom                  string    `json:"launchedFrom"`
+	Completed                     bool      `json:"completed"`
+}
+
+func newRequest(cmd string, p *Params) (*request, error) {
+	if p == nil {
+		return newRawRequest(cmd, nil)
+	}
+	return newRawRequest(cmd, p)
+}
+
+// newRawRequest creates a request with an arbitrary params payload, for
+// commands whose accepted fields vary between service versions
+func newRawRequest(cmd string, p interface{}) (*request, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	return &request{
+		ReqID:  id,
+		Cmd:    cmd,
+		Params: p,
+	}, nil
+}
+
+// FindMe causes the Robot in question to emit an audible alert
+func (r *Robot) FindMe(a *Params) (*Response, error) {
+	req, err := newRequest("findMe", a)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// DismissCurrentAlert clears the alert the Robot is currently reporting
+func (r *Robot) DismissCurrentAlert(a *Params) (*Response, error) {
+	req, err := newRequest("dismissCurrentAlert", a)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// StartCleaning makes the Robot begin a cleaning run with the supplied
+// parameters. If their Category is CategoryAuto, the Robot's persistent map
+// is used when it has one. If they leave NavigationMode unset, the Robot's
+// default navigation mode is used, and extra care and deep navigation are
+// refused unless the Robot supports them. Zone runs are refused with a
+// *FloorPlanError if the zone is not on the active FloorPlan.
+func (r *Robot) StartCleaning(a *Params) (*Response, error) {
+	a, err := r.prepareRun(a)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkFloorPlan(a); err != nil {
+		return nil, err
+	}
+	req, err := newRequest("startCleaning", a)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// StopCleaning causes the Robot to start cleaning
+func (r *Robot) StopCleaning(a *Params) (*Response, error) {
+	req, err := newRequest("stopCleaning", a)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// PauseCleaning causes the Robot to stop cleaning
+func (r *Robot) PauseCleaning(a *Params) (*Response, error) {
+	req, err := newRequest("pauseCleaning", a)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// ResumeCleaning causes the Robot to resume a cleaning run
+func (r *Robot) ResumeCleaning(a *Params) (*Response, error) {
+	req, err := newRequest("resumeCleaning", a)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// SendToBase sends the Robot back to the charging base
+func (r *Robot) SendToBase(a *Params) (*Response, error) {
+	req, err := newRequest("sendToBase", a)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// GetLocalStats returns local statistics about the Robot in question
+func (r *Robot) GetLocalStats(a *Params) (*Response, error) {
+	req, err := newRequest("getLocalStats", a)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// GetRobotManualCleaningInfo returns manual cleaning info for the given robot
+func (r *Robot) GetRobotManualCleaningInfo(a *Params) (*Response, error) {
+	req, err := newRequest("getRobotManualCleaningInfo", a)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// SetMapBoundaries sets boundary parameters for the given robot and Map
+func (r *Robot) SetMapBoundaries(a *Params) (*Response, error) {
+	req, err := newRequest("setMapBoundaries", a)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// GetMapBoundaries returns the boundary parameters for the given Robot and Map
+func (r *Robot) GetMapBoundaries(a *Params) (*Response, error) {
+	req, err := newRequest("getMapBoundaries", a)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// StartPersistentMapExploration sends the Robot on a new map exploration
+func (r *Robot) StartPersistentMapExploration(a *Params) (*Response, error) {
+	req, err := newRequest("startPersistentMapExploration", a)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// GetPreferences retrieves preferences for a Robot
+func (r *Robot) GetPreferences(a *Params) (*Response, error) {
+	req, err := newRequest("getPreferences", a)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// SetPreferences sets preferences for a Robot
+func (r *Robot) SetPreferences(a *Params) (*Response, error) {
+	req, err := newRequest("setPreferences", a)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// GetSchedule returns details of the schedule for the Robot
+func (r *Robot) GetSchedule(a *Params) (*Response, error) {
+	req, err := newRequest("getSchedule", a)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// SetSchedule sets the schedule on the Robot in question. The events are
+// first checked with ValidateSchedule, without reference to the Robot's
+// capabilities or boundaries, so events without a mode are accepted for
+// firmware which takes none.
+func (r *Robot) SetSchedule(a *Params) (*Response, error) {
+	if a != nil {
+		if err := ValidateSchedule(a.Events, nil, nil); err != nil {
+			return nil, err
+		}
+		if r.quietHours != nil && r.quietHours.ShiftSchedule {
+			p := *a
+			p.Events = r.quietHours.Shift(a.Events)
+			a = &p
+			// shifting may move events onto each other
+			err := ValidateSchedule(a.Events, nil, nil)
+			if err != nil {
+				return nil, fmt.Errorf("shifted for quiet "+
+					"hours: %w", err)
+			}
+		}
+	}
+	req, err := newRequest("setSchedule", a)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// EnableSchedule enables the schedule on the Robot in question
+func (r *Robot) EnableSchedule(a *Params) (*Response, error) {
+	req, err := newRequest("enableSchedule", a)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// DisableSchedule disables the schedule on the Robot in question
+func (r *Robot) DisableSchedule(a *Params) (*Response, error) {
+	req, err := newRequest("disableSchedule", a)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// GetRobotState returns the current state of the Robot
+func (r *Robot) GetRobotState(a *Params) (*Response, error) {
+	req, err := newRequest("getRobotState", a)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+func (resp *Response) checkID(a *request) (*Response, error) {
+	if string(resp.ReqID) != string(a.ReqID) {
+		return nil, fmt.Errorf("conflicting ReqID value")
+	}
+	return resp, nil
+}
+
+func newID() (reqID, error) {
+	raw := make([]byte, idLength)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	result := make(reqID, hex.EncodedLen(len(raw)))
+	_ = hex.Encode(result, raw)
+	return result, nil
+}
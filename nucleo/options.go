@@ -0,0 +1,146 @@
+package nucleo
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/richlj/neato/clock"
+)
+
+// Option configures a Robot
+type Option func(*Robot)
+
+// NewRobot returns a Robot for the device with the supplied serial number and
+// secret key, configured with the supplied Options
+func NewRobot(serial, secretKey string, opts ...Option) *Robot {
+	r := &Robot{Serial: serial, SecretKey: secretKey}
+	r.Configure(opts...)
+	return r
+}
+
+// Configure applies the supplied Options to the Robot
+func (r *Robot) Configure(opts ...Option) {
+	if r.mutable == nil {
+		r.mutable = &mutable{}
+	}
+	for _, o := range opts {
+		o(r)
+	}
+}
+
+// WithAPIVersion selects the version of the Nucleo API used by the Robot
+func WithAPIVersion(v APIVersion) Option {
+	return func(r *Robot) {
+		r.Version = v
+	}
+}
+
+// WithQuietHours blocks commands that start the Robot between start and end,
+// given as times of day in the supplied location. The window may span
+// midnight, e.g. from 22 * time.Hour to 7 * time.Hour.
+func WithQuietHours(start, end time.Duration, tz *time.Location) Option {
+	return func(r *Robot) {
+		shift := r.quietHours != nil && r.quietHours.ShiftSchedule
+		r.quietHours = &QuietHours{Start: start, End: end, Location: tz,
+			ShiftSchedule: shift}
+	}
+}
+
+// WithQuietHoursShift moves scheduled events which would start during quiet
+// hours to the end of the window when the schedule is set. It has no effect
+// unless WithQuietHours is also supplied, in either order.
+func WithQuietHoursShift() Option {
+	return func(r *Robot) {
+		if r.quietHours == nil {
+			r.quietHours = &QuietHours{}
+		}
+		r.quietHours.ShiftSchedule = true
+	}
+}
+
+// WithKeyRefresh supplies a function which looks up the current SecretKey of
+// the Robot with the given serial. When Nucleo rejects a request's signature,
+// the Robot fetches its key with it and, if the key has been rotated, retries
+// the request once. Robots listed by a beehive.Session are configured with
+// this automatically.
+func WithKeyRefresh(f func(serial string) (string, error)) Option {
+	return func(r *Robot) {
+		r.keyRefresh = f
+	}
+}
+
+// WithFloorPlans records the Robot's FloorPlans, as returned by
+// LoadFloorPlans, so that zone commands are checked against the active one
+// without fetching the Boundaries again
+func WithFloorPlans(plans ...FloorPlan) Option {
+	return func(r *Robot) {
+		r.shared().setFloorPlans(plans)
+	}
+}
+
+// WithRawResults returns Responses whose Result is not ResultOK to the
+// caller to inspect, rather than reporting them as a *ResultError
+func WithRawResults() Option {
+	return func(r *Robot) {
+		r.rawResults = true
+	}
+}
+
+// WithEndpoint sends the Robot's requests to the supplied base URL rather
+// than to the Nucleo API directly, e.g. to a CORS proxy when the SDK runs in
+// a browser
+func WithEndpoint(u *url.URL) Option {
+	return func(r *Robot) {
+		r.endpoint = u
+	}
+}
+
+// WithScheme sends the Robot's requests with the supplied URL scheme, e.g.
+// "http" for a test server
+func WithScheme(scheme string) Option {
+	return func(r *Robot) {
+		u := r.base()
+		u.Scheme = scheme
+		r.endpoint = u
+	}
+}
+
+// WithHost sends the Robot's requests to the supplied host and port rather
+// than to the Nucleo API, e.g. through a corporate egress proxy
+func WithHost(host string) Option {
+	return func(r *Robot) {
+		u := r.base()
+		u.Host = host
+		r.endpoint = u
+	}
+}
+
+// WithLimiter makes the Robot wait for l before each request it sends.
+// Responses served from the cache are not limited.
+func WithLimiter(l Limiter) Option {
+	return func(r *Robot) {
+		r.limiter = l
+	}
+}
+
+// WithMaxResponseSize fails requests whose responses exceed n bytes with
+// ErrResponseTooLarge, bounding the memory used to decode them on
+// constrained devices
+func WithMaxResponseSize(n int64) Option {
+	return func(r *Robot) {
+		r.maxResponseBytes = n
+	}
+}
+
+// WithClock supplies the Clock used to date and sign requests, expire cached
+// responses and apply quiet hours, e.g. a clock.Fake in tests
+func WithClock(c clock.Clock) Option {
+	return func(r *Robot) {
+		r.clock = c
+	}
+}
+
+// now returns the current time according to the Robot's Clock
+func (r *Robot) now() time.Time {
+	return clock.Or(r.clock).Now()
+}
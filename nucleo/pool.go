@@ -0,0 +1,88 @@
+// Fleets are polled frequently, so the buffers and hash states used to encode
+// and sign each request are pooled rather than allocated afresh.
+
+package nucleo
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"hash"
+	"net/http"
+	"sync"
+
+	"github.com/richlj/neato/egress"
+)
+
+var (
+	// httpClient is shared so that connections are reused between requests,
+	// and dials as configured by the egress package
+	httpClient = &http.Client{Transport: egress.Transport()}
+
+	bufferPool = sync.Pool{
+		New: func() interface{} { return new(bytes.Buffer) },
+	}
+)
+
+// pooledBody is a request body which returns its buffer to bufferPool once
+// the transport has closed it
+type pooledBody struct {
+	*bytes.Reader
+	buf  *bytes.Buffer
+	once sync.Once
+}
+
+func (b *pooledBody) Close() error {
+	b.once.Do(func() {
+		bufferPool.Put(b.buf)
+	})
+	return nil
+}
+
+// macPool holds HMAC-SHA256 states keyed with a single SecretKey
+type macPool struct {
+	key  string
+	pool sync.Pool
+}
+
+func newMACPool(key string) *macPool {
+	p := &macPool{key: key}
+	p.pool.New = func() interface{} {
+		return hmac.New(sha256.New, []byte(key))
+	}
+	return p
+}
+
+// get returns a reset HMAC state
+func (p *macPool) get() hash.Hash {
+	h := p.pool.Get().(hash.Hash)
+	h.Reset()
+	return h
+}
+
+// put returns an HMAC state obtained from get
+func (p *macPool) put(h hash.Hash) {
+	p.pool.Put(h)
+}
+
+// macs returns a pool of HMAC states keyed with key. Each Robot keeps one
+// pool, replaced when its key is rotated, so that the states of old keys are
+// not kept. Robots without mutable state get a pool of their own each time.
+func (r *Robot) macs(key string) *macPool {
+	m := r.mutable
+	if m == nil {
+		return newMACPool(key)
+	}
+	m.mu.RLock()
+	p := m.macs
+	m.mu.RUnlock()
+	if p != nil && p.key == key {
+		return p
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.macs == nil || m.macs.key != key {
+		m.macs = newMACPool(key)
+	}
+	return m.macs
+}
@@ -0,0 +1,26 @@
+package nucleo
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestMACPoolRotation(t *testing.T) {
+	r := NewRobot(benchSerial, "old")
+	body := []byte(`{"reqId":"1","cmd":"getRobotState"}`)
+	before := hex.EncodeToString(r.sign(body, benchDate))
+	if p := r.mutable.macs; p == nil || p.key != "old" {
+		t.Fatalf("pool %+v after signing with the old key", p)
+	}
+	r.shared().setSecretKey("new")
+	after := hex.EncodeToString(r.sign(body, benchDate))
+	if p := r.mutable.macs; p.key != "new" {
+		t.Errorf("pool keyed with %q after rotation", p.key)
+	}
+	if want := Sign(benchSerial, "new", benchDate, body); after != want {
+		t.Errorf("signature %s after rotation, want %s", after, want)
+	}
+	if after == before {
+		t.Error("signature unchanged by rotation")
+	}
+}
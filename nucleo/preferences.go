@@ -0,0 +1,263 @@
+// Preferences are changed by reading the Robot's current preferences,
+// changing a single setting and writing them all back, since setPreferences
+// replaces every preference and the settings offered differ by firmware.
+// Firmware generations also differ in the capitalisation of keys, in whether
+// flags are booleans or 0 and 1, and in how locales are spelt, so each
+// setting is written back in the form the Robot reported it.
+
+package nucleo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/richlj/neato/i18n"
+)
+
+// Locale is a language setting of a Robot
+type Locale struct {
+	// Code is the Robot's identifier for the locale, e.g. "de"
+	Code string
+	// Name is the name of the language in that language, e.g. "Deutsch"
+	Name string
+}
+
+// Preferences are the user settings of a Robot. Settings the Robot does not
+// report are left at their zero values.
+type Preferences struct {
+	Sounds       bool
+	LEDs         bool
+	ButtonClicks bool
+	AllAlerts    bool
+	DirtbinAlert bool
+	Clock24h     bool
+
+	Locale           Locale
+	AvailableLocales []Locale
+
+	// The intervals after which the Robot reminds the user to empty its
+	// bin, and change its filter and brush
+	DirtbinReminder time.Duration
+	FilterReminder  time.Duration
+	BrushReminder   time.Duration
+}
+
+// flag decodes a preference reported as a boolean, or as a number or string
+// by some firmware
+type flag bool
+
+func (f *flag) UnmarshalJSON(b []byte) error {
+	switch s := strings.Trim(string(b), `"`); strings.ToLower(s) {
+	case "true", "1", "on":
+		*f = true
+	case "false", "0", "off", "", "null":
+		*f = false
+	default:
+		return fmt.Errorf("cannot decode %s as a flag", b)
+	}
+	return nil
+}
+
+type preferences struct {
+	RobotSounds                  flag     `json:"robotSounds"`
+	LEDs                         flag     `json:"leds"`
+	ButtonClicks                 flag     `json:"buttonClicks"`
+	AllAlerts                    flag     `json:"allAlerts"`
+	DirtbinAlert                 flag     `json:"dirtbinAlert"`
+	Clock24h                     flag     `json:"clock24h"`
+	Locale                       string   `json:"locale"`
+	AvailableLocales             []string `json:"availableLocales"`
+	DirtbinAlertReminderInterval int      `json:"dirtbinAlertReminderInterval"`
+	FilterChangeReminderInterval int      `json:"filterChangeReminderInterval"`
+	BrushChangeReminderInterval  int      `json:"brushChangeReminderInterval"`
+}
+
+type preferencesResponse struct {
+	Response
+	Data preferences `json:"data"`
+}
+
+// Preferences returns the Robot's current Preferences
+func (r *Robot) Preferences() (*Preferences, error) {
+	req, err := newRequest("getPreferences", nil)
+	if err != nil {
+		return nil, err
+	}
+	var result preferencesResponse
+	if err := r.do(req, &result); err != nil {
+		return nil, err
+	}
+	if _, err := r.check(req, &result.Response); err != nil {
+		return nil, err
+	}
+	return result.Data.parse(), nil
+}
+
+func (p *preferences) parse() *Preferences {
+	result := &Preferences{
+		Sounds:          bool(p.RobotSounds),
+		LEDs:            bool(p.LEDs),
+		ButtonClicks:    bool(p.ButtonClicks),
+		AllAlerts:       bool(p.AllAlerts),
+		DirtbinAlert:    bool(p.DirtbinAlert),
+		Clock24h:        bool(p.Clock24h),
+		DirtbinReminder: minutes(p.DirtbinAlertReminderInterval),
+		FilterReminder:  minutes(p.FilterChangeReminderInterval),
+		BrushReminder:   minutes(p.BrushChangeReminderInterval),
+	}
+	if p.Locale != "" {
+		result.Locale = newLocale(p.Locale)
+	}
+	for _, l := range p.AvailableLocales {
+		result.AvailableLocales = append(result.AvailableLocales,
+			newLocale(l))
+	}
+	return result
+}
+
+func newLocale(code string) Locale {
+	return Locale{Code: code, Name: i18n.LanguageName(code)}
+}
+
+func minutes(n int) time.Duration {
+	return time.Duration(n) * time.Minute
+}
+
+type rawPreferencesResponse struct {
+	Response
+	Data map[string]json.RawMessage `json:"data"`
+}
+
+// SetSounds turns the Robot's voice and sound effects on or off
+func (r *Robot) SetSounds(enabled bool) (*Response, error) {
+	return r.setPreference("robotSounds", enabled)
+}
+
+// SetLEDs turns the Robot's indicator lights on or off
+func (r *Robot) SetLEDs(enabled bool) (*Response, error) {
+	return r.setPreference("leds", enabled)
+}
+
+// SetButtonClicks turns the clicks made by the Robot's buttons on or off
+func (r *Robot) SetButtonClicks(enabled bool) (*Response, error) {
+	return r.setPreference("buttonClicks", enabled)
+}
+
+// SetClock24h selects whether the Robot displays times in 24-hour format
+func (r *Robot) SetClock24h(enabled bool) (*Response, error) {
+	return r.setPreference("clock24h", enabled)
+}
+
+// SetLocale sets the language of the Robot, e.g. "de", which must be one of
+// the locales the Robot reports as available
+func (r *Robot) SetLocale(locale string) (*Response, error) {
+	return r.updatePreferences(func(p map[string]json.RawMessage) error {
+		key, ok := findKey(p, "locale")
+		if !ok {
+			return fmt.Errorf("robot has no locale preference")
+		}
+		var available []string
+		if k, ok := findKey(p, "availableLocales"); ok {
+			_ = json.Unmarshal(p[k], &available)
+		}
+		if len(available) == 0 {
+			return fmt.Errorf("robot reports no available locales")
+		}
+		for _, l := range available {
+			if sameLocale(l, locale) {
+				b, err := json.Marshal(l)
+				if err != nil {
+					return err
+				}
+				p[key] = b
+				return nil
+			}
+		}
+		return fmt.Errorf("locale %q is not available, choose from %s",
+			locale, strings.Join(available, ", "))
+	})
+}
+
+// setPreference sets a flag which the Robot already reports, leaving the
+// rest unchanged
+func (r *Robot) setPreference(name string, enabled bool) (*Response,
+	error) {
+	return r.updatePreferences(func(p map[string]json.RawMessage) error {
+		key, ok := findKey(p, name)
+		if !ok {
+			return fmt.Errorf("robot has no %s preference", name)
+		}
+		p[key] = encodeFlag(p[key], enabled)
+		return nil
+	})
+}
+
+// findKey returns the key under which the Robot reports the named
+// preference, ignoring differences in case
+func findKey(p map[string]json.RawMessage, name string) (string, bool) {
+	if _, ok := p[name]; ok {
+		return name, true
+	}
+	for k := range p {
+		if strings.EqualFold(k, name) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// encodeFlag encodes enabled as a boolean, or as 0 or 1 if the Robot
+// reported the flag as a number
+func encodeFlag(current json.RawMessage, enabled bool) json.RawMessage {
+	numeric := len(current) > 0 && bytes.IndexByte([]byte("-0123456789"),
+		current[0]) >= 0
+	switch {
+	case numeric && enabled:
+		return json.RawMessage("1")
+	case numeric:
+		return json.RawMessage("0")
+	case enabled:
+		return json.RawMessage("true")
+	}
+	return json.RawMessage("false")
+}
+
+// sameLocale reports whether two locales are the same, ignoring case and
+// whether "-" or "_" separates the region
+func sameLocale(a, b string) bool {
+	norm := func(s string) string {
+		return strings.Replace(strings.TrimSpace(s), "-", "_", -1)
+	}
+	return strings.EqualFold(norm(a), norm(b))
+}
+
+// updatePreferences reads the Robot's preferences, bypassing the cache so as
+// not to write back stale values, applies fn to them and writes them back
+func (r *Robot) updatePreferences(
+	fn func(p map[string]json.RawMessage) error) (*Response, error) {
+	req, err := newRequest("getPreferences", nil)
+	if err != nil {
+		return nil, err
+	}
+	var result rawPreferencesResponse
+	if err := r.wrap(req, r.send(req, &result)); err != nil {
+		return nil, err
+	}
+	if _, err := r.check(req, &result.Response); err != nil {
+		return nil, err
+	}
+	if result.Data == nil {
+		return nil, fmt.Errorf("robot reported no preferences")
+	}
+	if err := fn(result.Data); err != nil {
+		return nil, err
+	}
+	req, err = newRawRequest("setPreferences", result.Data)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
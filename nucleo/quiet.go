@@ -0,0 +1,105 @@
+package nucleo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrQuietHours is returned when a command which would start the Robot
+	// is issued during quiet hours
+	ErrQuietHours = errors.New("command blocked during quiet hours")
+
+	// startCommands are blocked during quiet hours
+	startCommands = []string{"startCleaning", "resumeCleaning",
+		"startPersistentMapExploration"}
+)
+
+// QuietHours is a daily window during which a Robot should not be started.
+// Start and End are times of day, as offsets from midnight in Location.
+type QuietHours struct {
+	Start         time.Duration
+	End           time.Duration
+	Location      *time.Location
+	ShiftSchedule bool
+}
+
+// Contains reports whether t falls within the QuietHours
+func (q *QuietHours) Contains(t time.Time) bool {
+	if q.Start == q.End {
+		return false
+	}
+	return q.contains(timeOfDay(t.In(q.location())))
+}
+
+func (q *QuietHours) contains(d time.Duration) bool {
+	if q.Start < q.End {
+		return d >= q.Start && d < q.End
+	}
+	return d >= q.Start || d < q.End
+}
+
+func (q *QuietHours) location() *time.Location {
+	if q.Location == nil {
+		return time.Local
+	}
+	return q.Location
+}
+
+// Shift returns a copy of events in which those starting during the
+// QuietHours start at the end of the window instead, moving to the following
+// day if the window spans midnight
+func (q *QuietHours) Shift(events []Event) []Event {
+	result := make([]Event, len(events))
+	copy(result, events)
+	if q.Start == q.End {
+		return result
+	}
+	for i, e := range result {
+		start, err := e.Start()
+		if err != nil || !q.contains(start) {
+			continue
+		}
+		if q.Start > q.End && start >= q.Start {
+			e.Day = (e.Day + 1) % 7
+		}
+		e.StartTime = formatTimeOfDay(q.End)
+		result[i] = e
+	}
+	return result
+}
+
+// IgnoringQuietHours returns a copy of the Robot which issues commands
+// regardless of any quiet hours, for deliberate overrides
+func (r *Robot) IgnoringQuietHours() *Robot {
+	o := *r
+	o.ignoreQuietHours = true
+	return &o
+}
+
+// checkQuietHours returns an error if cmd would start the Robot during its
+// quiet hours
+func (r *Robot) checkQuietHours(cmd string) error {
+	q := r.quietHours
+	if q == nil || r.ignoreQuietHours || !contains(startCommands, cmd) {
+		return nil
+	}
+	if q.Contains(r.now()) {
+		return fmt.Errorf("%w: %s is not permitted until %s",
+			ErrQuietHours, cmd, formatTimeOfDay(q.End))
+	}
+	return nil
+}
+
+func timeOfDay(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+}
+
+// formatTimeOfDay formats an offset from midnight as a start time, e.g. "07:00"
+func formatTimeOfDay(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d/time.Hour)%24,
+		int(d%time.Hour/time.Minute))
+}
@@ -0,0 +1,60 @@
+package nucleo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQuietHoursShift(t *testing.T) {
+	night := &QuietHours{Start: 22 * time.Hour, End: 7 * time.Hour,
+		Location: time.UTC}
+	lunch := &QuietHours{Start: 12 * time.Hour, End: 14 * time.Hour,
+		Location: time.UTC}
+	tests := []struct {
+		q     *QuietHours
+		event Event
+		want  Event
+	}{
+		{night, NewEvent(time.Monday, "21:59", modeEco),
+			NewEvent(time.Monday, "21:59", modeEco)},
+		{night, NewEvent(time.Monday, "23:00", modeEco),
+			NewEvent(time.Tuesday, "07:00", modeEco)},
+		{night, NewEvent(time.Saturday, "22:00", modeEco),
+			NewEvent(time.Sunday, "07:00", modeEco)},
+		{night, NewEvent(time.Monday, "03:00", modeEco),
+			NewEvent(time.Monday, "07:00", modeEco)},
+		{night, NewEvent(time.Monday, "07:00", modeEco),
+			NewEvent(time.Monday, "07:00", modeEco)},
+		{lunch, NewEvent(time.Monday, "12:30", modeTurbo),
+			NewEvent(time.Monday, "14:00", modeTurbo)},
+		{&QuietHours{}, NewEvent(time.Monday, "03:00", modeEco),
+			NewEvent(time.Monday, "03:00", modeEco)},
+	}
+	for _, tt := range tests {
+		got := tt.q.Shift([]Event{tt.event})
+		if !reflect.DeepEqual(got, []Event{tt.want}) {
+			t.Errorf("Shift(%+v) = %+v, want %+v", tt.event, got[0],
+				tt.want)
+		}
+	}
+}
+
+func TestSetScheduleShiftClash(t *testing.T) {
+	r := NewRobot("OPS01234-0123456789AB", "key",
+		WithQuietHours(22*time.Hour, 7*time.Hour, time.UTC),
+		WithQuietHoursShift())
+	events := []Event{
+		NewEvent(time.Monday, "23:00", modeEco),
+		NewEvent(time.Tuesday, "07:00", modeEco),
+	}
+	_, err := r.SetSchedule(&Params{Events: events})
+	var se *ScheduleError
+	if !errors.As(err, &se) {
+		t.Fatalf("SetSchedule: got %v, want a *ScheduleError", err)
+	}
+	if len(se.Problems) != 1 || se.Problems[0].Index != 1 {
+		t.Errorf("SetSchedule: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+// Robots reject commands which make no sense in their current state with a
+// bare command_rejected result. The state, action and alert returned with
+// the rejection are usually enough to say why, and what to do instead.
+
+package nucleo
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	// commandVerbs describe commands in rejection messages
+	commandVerbs = map[string]string{
+		"startCleaning":                 "start",
+		"stopCleaning":                  "stop",
+		"pauseCleaning":                 "pause",
+		"resumeCleaning":                "resume",
+		"sendToBase":                    "send to base",
+		"startPersistentMapExploration": "explore",
+		"findMe":                        "find",
+	}
+)
+
+// CommandRejectedError is returned when a Robot rejects a command. Reason
+// explains the rejection in terms of the Robot's State, and Remedy, if not
+// empty, names the command which would be accepted instead.
+type CommandRejectedError struct {
+	Cmd    string
+	State  *RobotState
+	Reason string
+	Remedy string
+}
+
+func (e *CommandRejectedError) Error() string {
+	verb, ok := commandVerbs[e.Cmd]
+	if !ok {
+		verb = e.Cmd
+	}
+	msg := fmt.Sprintf("cannot %s: %s", verb, e.Reason)
+	if e.Remedy != "" {
+		msg += fmt.Sprintf(" (try %s)", e.Remedy)
+	}
+	return msg
+}
+
+// Unwrap returns the underlying *ResultError
+func (e *CommandRejectedError) Unwrap() error {
+	return &ResultError{Cmd: e.Cmd, Result: ResultCommandRejected}
+}
+
+// newCommandRejectedError explains the rejection of cmd by a Robot in the
+// State s
+func newCommandRejectedError(cmd string, s *RobotState) *CommandRejectedError {
+	e := &CommandRejectedError{Cmd: cmd, State: s}
+	e.Reason, e.Remedy = explain(cmd, s)
+	return e
+}
+
+func explain(cmd string, s *RobotState) (reason, remedy string) {
+	switch {
+	case s.Error != "":
+		return fmt.Sprintf("robot has an error (%s)", s.Error),
+			"dismissCurrentAlert"
+	case strings.Contains(s.Alert, "not_localized") ||
+		strings.Contains(s.Alert, "lost"):
+		return "robot not localized", ""
+	}
+	busy := s.State == StateBusy
+	switch cmd {
+	case "startCleaning", "startPersistentMapExploration":
+		switch {
+		case s.State == StatePaused:
+			return "robot is paused", "resumeCleaning"
+		case busy && s.Action == ActionDocking:
+			return "robot is returning to base", "stopCleaning"
+		case busy:
+			return "robot is already cleaning", ""
+		}
+	case "stopCleaning", "pauseCleaning":
+		if s.State == StateIdle {
+			return "robot is not cleaning", ""
+		}
+		if cmd == "pauseCleaning" && s.State == StatePaused {
+			return "robot is already paused", ""
+		}
+	case "resumeCleaning":
+		switch {
+		case busy:
+			return "robot is not paused", ""
+		case s.State == StateIdle:
+			return "robot is not paused", "startCleaning"
+		}
+	case "sendToBase":
+		switch {
+		case s.Docked:
+			return "robot is already on its base", ""
+		case busy && s.Action == ActionDocking:
+			return "robot is already returning to base", ""
+		case busy:
+			return "robot is cleaning", "pauseCleaning"
+		}
+	}
+	return fmt.Sprintf("robot is %s", s.State), ""
+}
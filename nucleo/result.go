@@ -0,0 +1,64 @@
+// Every Nucleo response carries a result, which is "ok" unless the command
+// failed. Robots return failures with a successful HTTP status, so a Response
+// with any other Result is reported as a *ResultError unless the Robot is
+// configured WithRawResults.
+
+package nucleo
+
+import (
+	"fmt"
+)
+
+// Result is the outcome of a Nucleo command
+type Result string
+
+// The Results returned by Nucleo
+const (
+	ResultOK              Result = "ok"
+	ResultKO              Result = "ko"
+	ResultInvalidJSON     Result = "invalid_json"
+	ResultBadRequest      Result = "bad_request"
+	ResultCommandNotFound Result = "command_not_found"
+	ResultCommandRejected Result = "command_rejected"
+	ResultNotOnChargeBase Result = "not_on_charge_base"
+)
+
+// OK reports whether the command succeeded
+func (r Result) OK() bool {
+	return r == ResultOK
+}
+
+func (r Result) String() string {
+	return string(r)
+}
+
+// ResultError is returned when a Robot responds to a command with a Result
+// other than ResultOK
+type ResultError struct {
+	Cmd    string
+	Result Result
+}
+
+func (e *ResultError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Cmd, e.Result)
+}
+
+// check verifies that resp answers the request a, and unless the Robot is
+// configured WithRawResults, that the command succeeded. Rejected commands
+// are reported as a *CommandRejectedError. Errors are wrapped in a
+// *RequestError.
+func (r *Robot) check(a *request, resp *Response) (*Response, error) {
+	if _, err := resp.checkID(a); err != nil {
+		return nil, r.wrap(a, err)
+	}
+	switch {
+	case r.rawResults || resp.Result.OK():
+	case resp.Result == ResultCommandRejected:
+		return nil, r.wrap(a, newCommandRejectedError(a.Cmd,
+			NewRobotState(r.Name, resp)))
+	default:
+		return nil, r.wrap(a, &ResultError{Cmd: a.Cmd,
+			Result: resp.Result})
+	}
+	return resp, nil
+}
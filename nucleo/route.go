@@ -0,0 +1,135 @@
+// A Router chooses the path each request takes to the robot, e.g. directly to
+// its address on the local network before falling back to the Nucleo cloud
+// proxy. Routes are tried in order, moving to the next only when no response
+// was received, and the Router is told which route answered each call.
+
+package nucleo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"time"
+)
+
+// The Names of the usual Routes
+const (
+	RouteLocal = "local"
+	RouteCloud = "cloud"
+)
+
+var (
+	// ErrNoRoute is returned when a Router offers no route to the robot
+	ErrNoRoute = errors.New("no route to robot")
+)
+
+// Route is a path by which requests reach a robot
+type Route struct {
+	Name string
+
+	// Endpoint is the base URL to which requests are sent, as for
+	// WithEndpoint. If nil, the Robot's own endpoint is used.
+	Endpoint *url.URL
+
+	// Timeout, if positive, limits attempts by this route within the
+	// command's deadline, leaving time to fall back to the next route
+	Timeout time.Duration
+}
+
+// Router chooses the Routes by which requests reach robots
+type Router interface {
+	// Routes returns the routes to try for the robot with serial, in
+	// order of preference
+	Routes(serial string) []Route
+
+	// Done is called after each attempt to send cmd by route, with the
+	// attempt's error, if any
+	Done(serial, cmd string, route Route, err error)
+}
+
+// WithRouter sends the Robot's requests by the Routes which rt chooses.
+// Requests fail over to the next route only when no response is received, so
+// a command whose response was lost may reach the robot twice.
+func WithRouter(rt Router) Option {
+	return func(r *Robot) {
+		r.router = rt
+	}
+}
+
+// postRouted sends the encoded request buf to the resource at p by each of
+// the Router's routes in turn until one responds, decoding the response into
+// v
+func (r *Robot) postRouted(ctx context.Context, a *request, p string,
+	buf *bytes.Buffer, v interface{}) error {
+	// each attempt reads its own copy, as a failed transport may still be
+	// reading the last
+	b := append([]byte(nil), buf.Bytes()...)
+	bufferPool.Put(buf)
+	routes := r.router.Routes(r.Serial)
+	if len(routes) == 0 {
+		return ErrNoRoute
+	}
+	var err error
+	for _, rt := range routes {
+		var responded bool
+		responded, err = r.attempt(ctx, rt, p, b, v)
+		r.router.Done(r.Serial, a.Cmd, rt, err)
+		if err == nil {
+			return nil
+		}
+		err = &routeError{host: r.routeHost(rt), err: err}
+		if responded || ctx.Err() != nil {
+			break
+		}
+	}
+	return err
+}
+
+// attempt sends b by rt, reporting whether a response was received
+func (r *Robot) attempt(ctx context.Context, rt Route, p string, b []byte,
+	v interface{}) (bool, error) {
+	if rt.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rt.Timeout)
+		defer cancel()
+	}
+	resp, err := r.transmit(ctx, resolve(r.routeBase(rt), p),
+		ioutil.NopCloser(bytes.NewReader(b)), b)
+	if err != nil {
+		return false, err
+	}
+	return true, r.read(resp, v)
+}
+
+// routeBase returns the base URL of the requests sent by rt
+func (r *Robot) routeBase(rt Route) *url.URL {
+	if rt.Endpoint != nil {
+		return rt.Endpoint
+	}
+	return r.endpoint
+}
+
+// routeHost returns the host and port to which rt sends requests
+func (r *Robot) routeHost(rt Route) string {
+	if u, err := url.Parse(resolve(r.routeBase(rt), "")); err == nil {
+		return u.Host
+	}
+	return nucleoHost
+}
+
+// routeError is an error from the last route attempted, so that a
+// RequestError names the host which failed rather than the Robot's endpoint
+type routeError struct {
+	host string
+	err  error
+}
+
+func (e *routeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *routeError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,208 @@
+package nucleo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	startTimeFormat = "15:04"
+
+	modeTurbo = 2
+
+	zoneScheduleService = "basic-2"
+)
+
+// Event is a single scheduled cleaning run. Newer firmwares accept a cleaning
+// mode, navigation mode, zone and maximum duration per event, e.g. to clean
+// only the kitchen on Mondays in turbo mode.
+type Event struct {
+	// Mode is the cleaning mode, 1 for eco or 2 for turbo
+	Mode int `json:"mode"`
+
+	// Day is the day of the week, from 0 for Sunday to 6 for Saturday
+	Day int `json:"day"`
+
+	// StartTime is the time of day to start cleaning, e.g. "09:30"
+	StartTime string `json:"startTime"`
+
+	// BoundaryID restricts cleaning to a zone of the persistent map
+	BoundaryID string `json:"boundaryId"`
+
+	// NavigationMode is 1 for normal, 2 for extra care or 3 for deep
+	NavigationMode int `json:"navigationMode,omitempty"`
+
+	// Duration limits the run to a number of minutes
+	Duration int `json:"duration,omitempty"`
+}
+
+// NewEvent returns an Event starting on the supplied day and time of day,
+// e.g. "09:30", in the supplied mode
+func NewEvent(day time.Weekday, start string, mode int) Event {
+	return Event{Mode: mode, Day: int(day), StartTime: start}
+}
+
+// Weekday returns the day of the week on which the Event occurs
+func (e Event) Weekday() time.Weekday {
+	return time.Weekday(e.Day)
+}
+
+// Start returns the time of day at which the Event starts, as an offset from
+// midnight
+func (e Event) Start() (time.Duration, error) {
+	t, err := time.Parse(startTimeFormat, e.StartTime)
+	if err != nil {
+		return 0, fmt.Errorf("malformed start time %q", e.StartTime)
+	}
+	return time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Validate checks that the Event is well formed and, when the Capabilities of
+// the Robot are supplied, that the Robot supports the features it uses and
+// that it has the cleaning mode required by schedule services which take one
+func (e Event) Validate(c Capabilities) error {
+	if e.Day < int(time.Sunday) || e.Day > int(time.Saturday) {
+		return fmt.Errorf("day %d is not between 0 and 6", e.Day)
+	}
+	if _, err := e.Start(); err != nil {
+		return err
+	}
+	if e.NavigationMode != 0 && (e.NavigationMode < NavigationNormal ||
+		e.NavigationMode > NavigationDeep) {
+		return fmt.Errorf("navigation mode %d is not between %d and %d",
+			e.NavigationMode, NavigationNormal, NavigationDeep)
+	}
+	if e.Duration < 0 {
+		return fmt.Errorf("duration of %d minutes is negative",
+			e.Duration)
+	}
+	if c == nil {
+		return nil
+	}
+	if c.ScheduleModes() && e.Mode != modeEco && e.Mode != modeTurbo {
+		return fmt.Errorf("mode %d is neither eco (%d) nor turbo (%d)",
+			e.Mode, modeEco, modeTurbo)
+	}
+	if e.BoundaryID != "" && c["schedule"] != zoneScheduleService {
+		return fmt.Errorf("schedule service %q does not support zones",
+			c["schedule"])
+	}
+	if e.NavigationMode != 0 && !c.NavigationModes() {
+		return fmt.Errorf("house cleaning service %q does not support "+
+			"navigation modes", c["houseCleaning"])
+	}
+	return nil
+}
+
+var (
+	// maxScheduleEvents is the number of events each version of the
+	// schedule service accepts
+	maxScheduleEvents = map[string]int{
+		"minimal-1": 7,
+		"basic-1":   7,
+		"basic-2":   14,
+	}
+)
+
+// ScheduleError reports every problem found in a schedule
+type ScheduleError struct {
+	Problems []ScheduleProblem
+}
+
+// ScheduleProblem is a single problem found in a schedule. Index identifies
+// the offending Event, or is -1 for problems with the schedule as a whole.
+type ScheduleProblem struct {
+	Index int
+	Err   error
+}
+
+func (e *ScheduleError) Error() string {
+	var a []string
+	for _, p := range e.Problems {
+		if p.Index < 0 {
+			a = append(a, p.Err.Error())
+			continue
+		}
+		a = append(a, fmt.Sprintf("event %d: %s", p.Index, p.Err))
+	}
+	return "invalid schedule: " + strings.Join(a, "; ")
+}
+
+// Unwrap returns the errors of the individual problems
+func (e *ScheduleError) Unwrap() []error {
+	var result []error
+	for _, p := range e.Problems {
+		result = append(result, p.Err)
+	}
+	return result
+}
+
+// ValidateSchedule checks a list of events before it is sent to a Robot,
+// returning a *ScheduleError listing malformed events, events which clash
+// with an earlier event on the same day and time, and, when supplied, events
+// which exceed the Capabilities of the Robot or refer to boundaries missing
+// from boundaryIDs, the boundaries of the active floor plan
+func ValidateSchedule(events []Event, c Capabilities,
+	boundaryIDs []string) error {
+	var problems []ScheduleProblem
+	add := func(i int, err error) {
+		problems = append(problems, ScheduleProblem{Index: i, Err: err})
+	}
+	if c != nil {
+		max, ok := maxScheduleEvents[c["schedule"]]
+		if ok && len(events) > max {
+			add(-1, fmt.Errorf("%d events exceeds the maximum "+
+				"of %d", len(events), max))
+		}
+	}
+	type slot struct {
+		day   int
+		start time.Duration
+	}
+	seen := make(map[slot]int)
+	for i, e := range events {
+		if err := e.Validate(c); err != nil {
+			add(i, err)
+			continue
+		}
+		start, _ := e.Start()
+		key := slot{e.Day, start}
+		if j, ok := seen[key]; ok {
+			add(i, fmt.Errorf("starts at the same time as event %d",
+				j))
+		} else {
+			seen[key] = i
+		}
+		if e.BoundaryID != "" && boundaryIDs != nil &&
+			!contains(boundaryIDs, e.BoundaryID) {
+			add(i, fmt.Errorf("boundary %q is not on the active "+
+				"floor plan", e.BoundaryID))
+		}
+	}
+	if len(problems) > 0 {
+		return &ScheduleError{Problems: problems}
+	}
+	return nil
+}
+
+// ValidateSchedule checks a list of events against the Capabilities of the
+// Robot and the supplied boundaries of its active floor plan, as well as for
+// the problems identified by the package-level ValidateSchedule
+func (r *Robot) ValidateSchedule(events []Event, boundaryIDs []string) error {
+	c, err := r.Capabilities()
+	if err != nil {
+		return err
+	}
+	return ValidateSchedule(events, c, boundaryIDs)
+}
+
+func contains(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package nucleo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEventStart(t *testing.T) {
+	tests := []struct {
+		start string
+		want  time.Duration
+		ok    bool
+	}{
+		{"00:00", 0, true},
+		{"09:30", 9*time.Hour + 30*time.Minute, true},
+		{"9:30", 9*time.Hour + 30*time.Minute, true},
+		{"23:59", 23*time.Hour + 59*time.Minute, true},
+		{"24:00", 0, false},
+		{"9.30", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := Event{StartTime: tt.start}.Start()
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("Start(%q) = %v, %v", tt.start, got, err)
+		}
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	basic1 := Capabilities{"schedule": "basic-1",
+		"houseCleaning": "basic-1"}
+	basic2 := Capabilities{"schedule": "basic-2",
+		"houseCleaning": "basic-4"}
+	monday := func(start string) Event {
+		return NewEvent(time.Monday, start, modeEco)
+	}
+	week := func(n int) []Event {
+		var result []Event
+		for i := 0; i < n; i++ {
+			result = append(result, NewEvent(time.Weekday(i%7),
+				[]string{"09:00", "18:00"}[i/7], modeEco))
+		}
+		return result
+	}
+	minimal1 := Capabilities{"schedule": "minimal-1",
+		"houseCleaning": "minimal-2"}
+	noMode := NewEvent(time.Monday, "09:00", 0)
+	zoned := monday("09:00")
+	zoned.BoundaryID = "kitchen"
+	tests := []struct {
+		name       string
+		events     []Event
+		c          Capabilities
+		boundaries []string
+		want       []int
+	}{
+		{"empty", nil, nil, nil, nil},
+		{"valid", []Event{monday("09:00"), monday("18:00")}, basic1,
+			nil, nil},
+		{"duplicate", []Event{monday("09:00"), monday("09:00")}, nil,
+			nil, []int{1}},
+		{"duplicate unpadded", []Event{monday("09:30"), monday("9:30")},
+			nil, nil, []int{1}},
+		{"same time other day", []Event{monday("09:00"),
+			NewEvent(time.Tuesday, "09:00", modeEco)}, nil, nil,
+			nil},
+		{"malformed", []Event{monday("9am")}, nil, nil, []int{0}},
+		{"bad day", []Event{{Day: 7, StartTime: "09:00",
+			Mode: modeEco}}, nil, nil, []int{0}},
+		{"bad mode", []Event{NewEvent(time.Monday, "09:00", 3)},
+			basic1, nil, []int{0}},
+		{"no mode", []Event{noMode}, basic1, nil, []int{0}},
+		{"no mode unchecked", []Event{noMode}, nil, nil, nil},
+		{"no mode minimal", []Event{noMode}, minimal1, nil, nil},
+		{"too many", week(8), basic1, nil, []int{-1}},
+		{"within limit", week(8), basic2, nil, nil},
+		{"zone unsupported", []Event{zoned}, basic1, nil, []int{0}},
+		{"zone", []Event{zoned}, basic2, []string{"kitchen"}, nil},
+		{"zone missing", []Event{zoned}, basic2, []string{"hall"},
+			[]int{0}},
+	}
+	for _, tt := range tests {
+		err := ValidateSchedule(tt.events, tt.c, tt.boundaries)
+		var got []int
+		var se *ScheduleError
+		if errors.As(err, &se) {
+			for _, p := range se.Problems {
+				got = append(got, p.Index)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: problems at %v, want %v (%v)", tt.name,
+				got, tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// The interfaces here cover the public operations of Robots, so that code
+// built on the SDK can depend on them and inject fakes in place of the
+// concrete types, such as those in the mocks package.
+
+package nucleo
+
+var (
+	_ RobotService = (*Robot)(nil)
+)
+
+// CleaningService controls the cleaning runs of a Robot
+type CleaningService interface {
+	StartCleaning(a *Params) (*Response, error)
+	StopCleaning(a *Params) (*Response, error)
+	PauseCleaning(a *Params) (*Response, error)
+	ResumeCleaning(a *Params) (*Response, error)
+	SendToBase(a *Params) (*Response, error)
+	StartSpotCleaning(width, height int, repeat bool) (*Response, error)
+}
+
+// StateService reports on the condition of a Robot
+type StateService interface {
+	GetRobotState(a *Params) (*Response, error)
+	State() (*RobotState, error)
+	Capabilities() (Capabilities, error)
+	GetGeneralInfo(a *Params) (*GeneralInfo, error)
+	GetLocalStats(a *Params) (*Response, error)
+	GetRobotInfo(a *Params) (*HardwareInfo, error)
+	GetRobotManualCleaningInfo(a *Params) (*Response, error)
+}
+
+// ScheduleService manages the cleaning schedule of a Robot
+type ScheduleService interface {
+	GetSchedule(a *Params) (*Response, error)
+	SetSchedule(a *Params) (*Response, error)
+	EnableSchedule(a *Params) (*Response, error)
+	DisableSchedule(a *Params) (*Response, error)
+}
+
+// MapService manages the maps and boundaries of a Robot
+type MapService interface {
+	GetMapBoundaries(a *Params) (*Response, error)
+	SetMapBoundaries(a *Params) (*Response, error)
+	StartPersistentMapExploration(a *Params) (*Response, error)
+}
+
+// PreferencesService manages the preferences of a Robot
+type PreferencesService interface {
+	GetPreferences(a *Params) (*Response, error)
+	SetPreferences(a *Params) (*Response, error)
+}
+
+// RobotService covers all of the operations available on a Robot
+type RobotService interface {
+	CleaningService
+	StateService
+	ScheduleService
+	MapService
+	PreferencesService
+	FindMe(a *Params) (*Response, error)
+	DismissCurrentAlert(a *Params) (*Response, error)
+}
@@ -0,0 +1,36 @@
+package nucleo
+
+import (
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// Sign returns the hex-encoded signature of a Nucleo request: the
+// HMAC-SHA256, keyed with secretKey, of the lower-cased serial, the Date
+// header value and the body, separated by newlines. The Authorization header
+// is "NEATOAPP " followed by the signature.
+func Sign(serial, secretKey, date string, body []byte) string {
+	r := &Robot{Serial: serial, SecretKey: secretKey}
+	return hex.EncodeToString(r.sign(body, date))
+}
+
+// SignRequest returns the Authorization header of a Nucleo request for the
+// robot with serial and secretKey, whose Date header is date and whose body
+// is body, exactly as sent
+func SignRequest(serial, secretKey, date string, body []byte) string {
+	r := &Robot{Serial: serial, SecretKey: secretKey}
+	return r.authorization(body, date)
+}
+
+// BuildHeaders returns the headers with which a Robot sends a request to
+// Nucleo at t: Accept, Content-Type, Date and Authorization. The body is taken
+// to be JSON for version v of the API, the zero value selecting V1. Clients
+// in other languages and proxies can check their requests against these.
+func BuildHeaders(serial, secretKey string, v APIVersion, t time.Time,
+	body []byte) http.Header {
+	r := &Robot{Serial: serial, SecretKey: secretKey, Version: v}
+	h := make(http.Header)
+	r.setHeaders(h, t.Format(timeFormat), body)
+	return h
+}
@@ -0,0 +1,59 @@
+package nucleo
+
+import "testing"
+
+// The signatures were computed independently of this package with Python's
+// hmac module, from the scheme documented on Sign. None are captured from the
+// official apps' traffic.
+func TestSign(t *testing.T) {
+	const (
+		serial = "OPS01234-0123456789AB"
+		key    = "0123456789ABCDEF0123456789ABCDEF"
+		date   = "Sun, 01 Mar 2020 12:00:00 GMT"
+		state  = `{"reqId":"1","cmd":"getRobotState"}`
+	)
+	tests := []struct {
+		name, serial, secretKey, date, body, want string
+	}{
+		{"upper-case serial, state request", serial, key, date, state,
+			"5213f5fd2ec0724927ae28971ac4d153" +
+				"2790a0cacc5d004750bfde3114260c60"},
+		{"serial already lower case signs identically",
+			"ops01234-0123456789ab", key, date, state,
+			"5213f5fd2ec0724927ae28971ac4d153" +
+				"2790a0cacc5d004750bfde3114260c60"},
+		{"command with params", serial, key,
+			"Mon, 14 Sep 2020 08:30:05 GMT",
+			`{"reqId":"77","cmd":"startCleaning","params":` +
+				`{"category":4,"mode":1,"navigationMode":1}}`,
+			"809042c48bfeacc38aa2fd3a9a2df89d" +
+				"9fb93d59a078f2c03a71cb3536e20927"},
+		{"empty body", serial, "FEDCBA9876543210FEDCBA9876543210",
+			"Mon, 14 Sep 2020 08:30:05 GMT", "",
+			"7cf7c3d0b5d3c7e3583ab7063b7906b1" +
+				"61446a591d157ec6a9b574f9afc3a841"},
+		{"lower-case secret, schedule with events",
+			"VR2206A-BDC1234567EF",
+			"a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6",
+			"Tue, 29 Feb 2028 23:59:59 GMT",
+			`{"reqId":"2","cmd":"setSchedule","params":{"type":1,` +
+				`"events":[{"mode":1,"day":1,` +
+				`"startTime":"09:30"}]}}`,
+			"f3133336b5d8553a9ab6c20970ee22c8" +
+				"db8eb89a2c0954809c548198e56740f6"},
+	}
+	for _, tt := range tests {
+		body := []byte(tt.body)
+		got := Sign(tt.serial, tt.secretKey, tt.date, body)
+		if got != tt.want {
+			t.Errorf("%s: Sign() = %s, want %s", tt.name, got,
+				tt.want)
+		}
+		want := authScheme + tt.want
+		got = SignRequest(tt.serial, tt.secretKey, tt.date, body)
+		if got != want {
+			t.Errorf("%s: SignRequest() = %s, want %s", tt.name,
+				got, want)
+		}
+	}
+}
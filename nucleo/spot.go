@@ -0,0 +1,72 @@
+package nucleo
+
+import (
+	"fmt"
+)
+
+const (
+	modeEco = 1
+
+	modifierNormal = 1
+	modifierDouble = 2
+
+	// minSpotSize is the smallest spot dimension, in centimetres, accepted
+	// by the robots
+	minSpotSize = 100
+)
+
+// StartSpotCleaning makes the Robot clean a spot of the supplied width and
+// height, in centimetres, around its current position, covering it twice if
+// repeat is set. The payload is built for the version of the spot cleaning
+// service the Robot advertises, which is retrieved first.
+func (r *Robot) StartSpotCleaning(width, height int, repeat bool) (*Response,
+	error) {
+	if width < minSpotSize || height < minSpotSize {
+		return nil, fmt.Errorf("spot of %dx%dcm is smaller than the "+
+			"minimum of %dx%dcm", width, height, minSpotSize,
+			minSpotSize)
+	}
+	c, err := r.Capabilities()
+	if err != nil {
+		return nil, err
+	}
+	p, err := spotParams(c["spotCleaning"], width, height, repeat)
+	if err != nil {
+		return nil, err
+	}
+	req, err := newRawRequest("startCleaning", p)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// spotParams returns the startCleaning payload for a spot cleaning run using
+// the supplied version of the spot cleaning service
+func spotParams(version string, width, height int,
+	repeat bool) (map[string]interface{}, error) {
+	modifier := modifierNormal
+	if repeat {
+		modifier = modifierDouble
+	}
+	p := map[string]interface{}{
+		"category": CategorySpot,
+		"modifier": modifier,
+	}
+	switch version {
+	case "basic-1", "basic-3":
+		p["mode"] = modeEco
+		p["spotWidth"] = width
+		p["spotHeight"] = height
+	case "basic-2":
+		p["spotWidth"] = width
+		p["spotHeight"] = height
+	case "minimal-2":
+	case "":
+		return nil, fmt.Errorf("spot cleaning is not supported")
+	default:
+		return nil, fmt.Errorf("unsupported spot cleaning service %q",
+			version)
+	}
+	return p, nil
+}
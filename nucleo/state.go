@@ -0,0 +1,226 @@
+package nucleo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/richlj/neato/i18n"
+)
+
+const (
+	// noError is reported in place of an error by some firmwares
+	noError = "ui_alert_invalid"
+
+	// nullString is sent in place of null by some firmwares
+	nullString = "null"
+)
+
+// State is the overall condition of a Robot
+type State int
+
+// The States reported by Robots
+const (
+	StateIdle   State = 1
+	StateBusy   State = 2
+	StatePaused State = 3
+	StateError  State = 4
+)
+
+// Action is the activity a Robot is engaged in
+type Action int
+
+// The Actions reported by Robots
+const (
+	ActionNone             Action = 0
+	ActionHouseCleaning    Action = 1
+	ActionSpotCleaning     Action = 2
+	ActionManualCleaning   Action = 3
+	ActionDocking          Action = 4
+	ActionUserMenu         Action = 5
+	ActionSuspended        Action = 6
+	ActionUpdating         Action = 7
+	ActionCopyingLogs      Action = 8
+	ActionRecovering       Action = 9
+	ActionIECTest          Action = 10
+	ActionMapCleaning      Action = 11
+	ActionExploring        Action = 12
+	ActionAcquiringIDs     Action = 13
+	ActionUploadingMap     Action = 14
+	ActionSuspendedExplore Action = 15
+)
+
+var (
+	// StateMessages maps robot states to the keys of their descriptions in
+	// the i18n catalog
+	StateMessages = map[int]string{
+		1: "state.idle",
+		2: "state.busy",
+		3: "state.paused",
+		4: "state.error",
+	}
+
+	// ActionMessages maps robot actions to the keys of their descriptions
+	// in the i18n catalog
+	ActionMessages = map[int]string{
+		1:  "action.house_cleaning",
+		2:  "action.spot_cleaning",
+		3:  "action.manual_cleaning",
+		4:  "action.docking",
+		5:  "action.user_menu",
+		6:  "action.suspended",
+		7:  "action.updating",
+		8:  "action.copying_logs",
+		9:  "action.recovering",
+		10: "action.iec_test",
+		11: "action.map_cleaning",
+		12: "action.exploring",
+		13: "action.acquiring_ids",
+		14: "action.uploading_map",
+		15: "action.suspended_explore",
+	}
+)
+
+// String returns the name of the State, e.g. "paused"
+func (s State) String() string {
+	return name(StateMessages, int(s), "state")
+}
+
+// String returns the name of the Action, e.g. "house_cleaning"
+func (a Action) String() string {
+	if a == ActionNone {
+		return "none"
+	}
+	return name(ActionMessages, int(a), "action")
+}
+
+// name derives a name from the i18n catalog key of v
+func name(keys map[int]string, v int, kind string) string {
+	if key, ok := keys[v]; ok {
+		return strings.TrimPrefix(key, kind+".")
+	}
+	return fmt.Sprintf("%s(%d)", kind, v)
+}
+
+// RobotState describes the condition of a Robot at a point in time
+type RobotState struct {
+	Robot    string
+	State    State
+	Action   Action
+	Charge   int
+	Charging bool
+	Docked   bool
+	Alert    string
+	Error    string
+}
+
+// NewRobotState builds a RobotState from the Response to a getRobotState
+// command issued to the named Robot
+func NewRobotState(robot string, resp *Response) *RobotState {
+	s := &RobotState{
+		Robot:    robot,
+		State:    resp.State,
+		Action:   resp.Action,
+		Charge:   resp.Details.Charge,
+		Charging: resp.Details.IsCharging,
+		Docked:   resp.Details.IsDocked,
+	}
+	if resp.Alert != nullString {
+		s.Alert = resp.Alert
+	}
+	e, ok := resp.Error.(string)
+	if ok && e != noError && e != nullString {
+		s.Error = e
+	}
+	return s
+}
+
+// stateResponse decodes only the fields of a state Response which make up a
+// RobotState, since State is called repeatedly when polling
+type stateResponse struct {
+	ReqID   reqID       `json:"reqId"`
+	Result  Result      `json:"result"`
+	State   State       `json:"state"`
+	Action  Action      `json:"action"`
+	Error   interface{} `json:"error"`
+	Alert   string      `json:"alert"`
+	Details details     `json:"details"`
+}
+
+// State returns the current RobotState of the Robot
+func (r *Robot) State() (*RobotState, error) {
+	req, err := newRequest("getRobotState", nil)
+	if err != nil {
+		return nil, err
+	}
+	var result stateResponse
+	if err := r.do(req, &result); err != nil {
+		return nil, err
+	}
+	resp := Response{
+		ReqID:   result.ReqID,
+		Result:  result.Result,
+		State:   result.State,
+		Action:  result.Action,
+		Error:   result.Error,
+		Alert:   result.Alert,
+		Details: result.Details,
+	}
+	if _, err := r.check(req, &resp); err != nil {
+		return nil, err
+	}
+	return NewRobotState(r.Name, &resp), nil
+}
+
+// Summary returns a one-line description of the RobotState
+func (s *RobotState) Summary() string {
+	return s.SummaryWith(i18n.DefaultFormatter)
+}
+
+// Details returns a multi-line description of the RobotState
+func (s *RobotState) Details() string {
+	return s.DetailsWith(i18n.DefaultFormatter)
+}
+
+// SummaryWith returns a one-line description of the RobotState in the locale
+// of the supplied Formatter
+func (s *RobotState) SummaryWith(f *i18n.Formatter) string {
+	var a string
+	switch {
+	case s.Error != "":
+		a = f.Message("status.error", f.Alert(s.Error))
+	case s.Docked && s.Charging:
+		a = f.Message("status.docked_charging")
+	case s.Docked:
+		a = f.Message("status.docked")
+	case s.Action != 0:
+		a = f.Message("status.active",
+			f.Describe(ActionMessages, int(s.Action)))
+	default:
+		a = f.Message("status.active",
+			f.Describe(StateMessages, int(s.State)))
+	}
+	return f.Message("status.summary", s.Robot, a, s.Charge)
+}
+
+// DetailsWith returns a multi-line description of the RobotState in the
+// locale of the supplied Formatter
+func (s *RobotState) DetailsWith(f *i18n.Formatter) string {
+	var b strings.Builder
+	fmt.Fprintln(&b, s.SummaryWith(f))
+	state := f.Describe(StateMessages, int(s.State))
+	b.WriteString(f.Line("label.state", state))
+	if s.Action != 0 {
+		b.WriteString(f.Line("label.action", f.Describe(ActionMessages,
+			int(s.Action))))
+	}
+	b.WriteString(f.Line("label.battery", fmt.Sprintf("%d%%", s.Charge)))
+	b.WriteString(f.Line("label.docked", f.Message(fmt.Sprintf("bool.%t",
+		s.Docked))))
+	if s.Alert != "" {
+		b.WriteString(f.Line("label.alert", f.Alert(s.Alert)))
+	}
+	if s.Error != "" {
+		b.WriteString(f.Line("label.error", f.Alert(s.Error)))
+	}
+	return b.String()
+}
@@ -0,0 +1,210 @@
+// The statistics kept by a Robot include every run it has performed, which
+// after some years is a large response to hold in memory on a small device.
+// StreamLocalStats decodes the runs one at a time instead.
+
+package nucleo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/richlj/neato/decode"
+)
+
+var (
+	// ErrResponseTooLarge is returned when a response exceeds the size set
+	// by WithMaxResponseSize or StreamOptions
+	ErrResponseTooLarge = errors.New("response too large")
+
+	// ErrStopStream may be returned by a StreamLocalStats callback to stop
+	// decoding without error
+	ErrStopStream = errors.New("stop stream")
+)
+
+// StreamOptions limit the resources used by StreamLocalStats. Zero values
+// impose no limit.
+type StreamOptions struct {
+	// MaxBytes fails the stream with ErrResponseTooLarge once this many
+	// bytes have been read
+	MaxBytes int64
+
+	// MaxEntries stops the stream once this many runs have been passed to
+	// the callback
+	MaxEntries int
+}
+
+// streamDecoder is implemented by response targets which decode the body
+// themselves as it arrives, given the Robot's Codec
+type streamDecoder interface {
+	decodeFrom(r io.Reader, c Codec) error
+}
+
+// cappedReader fails with ErrResponseTooLarge once remaining is exhausted
+type cappedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	if c.remaining <= 0 {
+		return 0, ErrResponseTooLarge
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	return n, err
+}
+
+type statsStream struct {
+	ReqID  reqID
+	Result Result
+	opts   StreamOptions
+	fn     func(category string, h RunHistory) error
+	count  int
+}
+
+// StreamLocalStats fetches the Robot's statistics, passing each run in its
+// history to fn as it is decoded, along with the category of cleaning it is
+// recorded under, e.g. "houseCleaning", or "" for the overall history. The
+// totals and averages are skipped.
+func (r *Robot) StreamLocalStats(opts StreamOptions,
+	fn func(category string, h RunHistory) error) error {
+	req, err := newRequest("getLocalStats", nil)
+	if err != nil {
+		return err
+	}
+	if err := r.checkQuietHours(req.Cmd); err != nil {
+		return r.wrap(req, err)
+	}
+	s := &statsStream{opts: opts, fn: fn}
+	err = r.send(req, s)
+	if errors.Is(err, ErrStopStream) {
+		return nil
+	}
+	if err != nil {
+		return r.wrap(req, err)
+	}
+	_, err = r.check(req, &Response{ReqID: s.ReqID, Result: s.Result})
+	return err
+}
+
+func (s *statsStream) decodeFrom(rd io.Reader, c Codec) error {
+	if s.opts.MaxBytes > 0 {
+		rd = &cappedReader{r: rd, remaining: s.opts.MaxBytes}
+	}
+	if c != JSON {
+		return s.decodeWhole(rd, c)
+	}
+	return s.object(json.NewDecoder(rd), "")
+}
+
+// decodeWhole decodes the entire response with a Codec which cannot be
+// streamed, then passes each run to the callback as object would
+func (s *statsStream) decodeWhole(rd io.Reader, c Codec) error {
+	var resp Response
+	if err := c.Decode(rd, &resp); err != nil {
+		return err
+	}
+	s.ReqID, s.Result = resp.ReqID, resp.Result
+	categories := []struct {
+		name    string
+		history []RunHistory
+	}{
+		{"", resp.Data.History},
+		{"houseCleaning", resp.Data.HouseCleaning.History},
+		{"spotCleaning", resp.Data.SpotCleaning.History},
+	}
+	max := s.opts.MaxEntries
+	for _, cat := range categories {
+		for _, h := range cat.history {
+			if max > 0 && s.count >= max {
+				return ErrStopStream
+			}
+			s.count++
+			if err := s.fn(cat.name, h); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// object decodes an object at path, descending into those which may hold a
+// history and skipping everything else
+func (s *statsStream) object(dec *json.Decoder, path string) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return nil
+	}
+	if t != json.Delim('{') {
+		return fmt.Errorf("stats: unexpected %v at %q", t, path)
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := t.(string)
+		child := key
+		if path != "" {
+			child = path + "." + key
+		}
+		switch child {
+		case "reqId":
+			err = dec.Decode(&s.ReqID)
+		case "result":
+			err = dec.Decode(&s.Result)
+		case "data", "data.houseCleaning", "data.spotCleaning":
+			err = s.object(dec, child)
+		case "data.history":
+			err = s.history(dec, "")
+		case "data.houseCleaning.history", "data.spotCleaning.history":
+			err = s.history(dec, path[len("data."):])
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// history passes each entry of a history array to the callback
+func (s *statsStream) history(dec *json.Decoder, category string) error {
+	t, err := dec.Token()
+	if err != nil || t == nil {
+		return err
+	}
+	if t != json.Delim('[') {
+		return fmt.Errorf("stats: unexpected %v in history", t)
+	}
+	for dec.More() {
+		if s.opts.MaxEntries > 0 && s.count >= s.opts.MaxEntries {
+			return ErrStopStream
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		var h RunHistory
+		if err := decode.Unmarshal(raw, &h); err != nil {
+			return err
+		}
+		s.count++
+		if err := s.fn(category, h); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
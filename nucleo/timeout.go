@@ -0,0 +1,93 @@
+// Commands take very different times to answer. A state read returns in a
+// second or two, but startCleaning on a robot which has gone to sleep can
+// take over thirty seconds while it wakes, so each command has its own
+// default deadline rather than one which is either too short or too long.
+
+package nucleo
+
+import (
+	"context"
+	"time"
+)
+
+const defaultTimeout = 20 * time.Second
+
+// commandTimeouts are the default deadlines of the commands which answer
+// much faster or slower than defaultTimeout
+var commandTimeouts = map[string]time.Duration{
+	"startCleaning":  45 * time.Second,
+	"resumeCleaning": 45 * time.Second,
+	"sendToBase":     30 * time.Second,
+	"stopCleaning":   30 * time.Second,
+	"pauseCleaning":  30 * time.Second,
+	"findMe":         30 * time.Second,
+	"getRobotState":  10 * time.Second,
+	"getGeneralInfo": 10 * time.Second,
+	"getSchedule":    10 * time.Second,
+	"getPreferences": 10 * time.Second,
+	"getLocalStats":  2 * time.Minute,
+}
+
+// WithTimeout sets the deadline of every command without one set by
+// WithCommandTimeout, replacing the per-command defaults. A deadline of zero
+// or less lets requests run until they complete.
+func WithTimeout(d time.Duration) Option {
+	return WithCommandTimeout("", d)
+}
+
+// WithCommandTimeout sets the deadline of the named command, e.g.
+// "startCleaning". A deadline of zero or less lets its requests run until
+// they complete.
+func WithCommandTimeout(cmd string, d time.Duration) Option {
+	return func(r *Robot) {
+		if r.timeouts == nil {
+			r.timeouts = make(map[string]time.Duration)
+		}
+		r.timeouts[cmd] = d
+	}
+}
+
+// Timeout returns the deadline of requests issuing the named command, or
+// zero if they have none
+func (r *Robot) Timeout(cmd string) time.Duration {
+	d, ok := r.timeouts[cmd]
+	if !ok {
+		d, ok = r.timeouts[""]
+	}
+	if !ok {
+		if d, ok = commandTimeouts[cmd]; !ok {
+			d = defaultTimeout
+		}
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// WithContext returns a copy of the Robot whose requests are made within
+// ctx, so that cancelling it abandons them, including while they wait for
+// the Limiter. Each request still has its own deadline.
+func (r *Robot) WithContext(ctx context.Context) *Robot {
+	o := *r
+	o.ctx = ctx
+	return &o
+}
+
+// context returns the context within which the Robot's requests are made
+func (r *Robot) context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// requestContext returns the context of a request issuing cmd, with its
+// deadline if it has one
+func (r *Robot) requestContext(cmd string) (context.Context,
+	context.CancelFunc) {
+	if d := r.Timeout(cmd); d > 0 {
+		return context.WithTimeout(r.context(), d)
+	}
+	return context.WithCancel(r.context())
+}
@@ -0,0 +1,78 @@
+package nucleo_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/richlj/neato/nucleo"
+)
+
+// waitLimiter admits no requests until ctx is done
+type waitLimiter struct{}
+
+func (waitLimiter) Wait(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestWithContextLimiter(t *testing.T) {
+	r := newSimulated(t, "old", nucleo.WithLimiter(waitLimiter{}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.WithContext(ctx).State(); !errors.Is(err,
+		context.Canceled) {
+		t.Errorf("State() = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestWithContextDeadline(t *testing.T) {
+	// the server answers nothing until the test is over
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			<-done
+		}))
+	defer srv.Close()
+	defer close(done)
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := nucleo.NewRobot(testSerial, "old", nucleo.WithEndpoint(u))
+	tests := []struct {
+		name string
+		f    func(ctx context.Context) error
+	}{
+		{"State", func(ctx context.Context) error {
+			_, err := r.WithContext(ctx).State()
+			return err
+		}},
+		{"EnsureCleaning", func(ctx context.Context) error {
+			_, err := r.EnsureCleaning(ctx, nil)
+			return err
+		}},
+		{"EnsureDocked", func(ctx context.Context) error {
+			_, err := r.EnsureDocked(ctx)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(),
+			20*time.Millisecond)
+		start := time.Now()
+		err := tt.f(ctx)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: %v, want %v", tt.name, err,
+				context.DeadlineExceeded)
+		}
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf("%s: returned after %v", tt.name, d)
+		}
+	}
+}
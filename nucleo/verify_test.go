@@ -0,0 +1,124 @@
+package nucleo
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errBadSignature = errors.New("request signature does not match body")
+
+// verifySignature checks that the Authorization header of req is the
+// signature of body, the bytes received, for the robot with the supplied
+// serial number and SecretKey
+func verifySignature(req *http.Request, body []byte, serial,
+	secretKey string) error {
+	a := req.Header.Get("Authorization")
+	if !strings.HasPrefix(a, authScheme) {
+		return errBadSignature
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(a, authScheme))
+	if err != nil {
+		return errBadSignature
+	}
+	r := &Robot{Serial: serial, SecretKey: secretKey}
+	if !hmac.Equal(got, r.sign(body, req.Header.Get("Date"))) {
+		return errBadSignature
+	}
+	return nil
+}
+
+// newVerifyingServer returns a Robot whose requests are answered by a
+// server which accepts them only if they are signed over the bytes received.
+// Each body received is passed to check, which may alter it before it is
+// verified.
+func newVerifyingServer(t *testing.T,
+	check func(body []byte) []byte) *Robot {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if check != nil {
+				body = check(body)
+			}
+			if err := verifySignature(req, body, benchSerial,
+				benchKey); err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			var a struct {
+				ReqID string `json:"reqId"`
+			}
+			if err := json.Unmarshal(body, &a); err != nil {
+				t.Error(err)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"version": 1, "reqId": a.ReqID, "result": "ok",
+				"state": 1, "action": 0,
+			})
+		}))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewRobot(benchSerial, benchKey, WithEndpoint(u))
+}
+
+func TestSignedBody(t *testing.T) {
+	r := newVerifyingServer(t, nil)
+	tests := []struct {
+		name string
+		f    func() error
+	}{
+		{"State", func() error {
+			_, err := r.State()
+			return err
+		}},
+		{"StartCleaning", func() error {
+			_, err := r.StartCleaning(&Params{Category: 4, Mode: 1,
+				Modifier: 1, NavigationMode: 1,
+				BoundaryID: "<hall & stairs> é "})
+			return err
+		}},
+		{"SetSchedule", func() error {
+			_, err := r.SetSchedule(&Params{Events: []Event{
+				NewEvent(time.Monday, "09:00", 1),
+			}})
+			return err
+		}},
+		// minimal-1 schedules have no mode
+		{"SetSchedule without mode", func() error {
+			_, err := r.SetSchedule(&Params{Events: []Event{
+				{Day: int(time.Monday), StartTime: "09:00"},
+			}})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		if err := tt.f(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
+
+func TestSignedBodyTampered(t *testing.T) {
+	r := newVerifyingServer(t, func(body []byte) []byte {
+		return append(body, ' ')
+	})
+	if _, err := r.State(); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("State() = %v, want %v", err, ErrUnauthorized)
+	}
+}
@@ -0,0 +1,76 @@
+// Robots which advertise the wifi service can list the networks they are
+// configured for and be put back into setup mode, so that a robot can be
+// reprovisioned after a router change without the mobile app.
+
+package nucleo
+
+import (
+	"fmt"
+)
+
+const (
+	cmdGetWifiNetworks    = "getConfiguredWifiNetworks"
+	cmdEnterWifiSetupMode = "startWifiSetupMode"
+)
+
+// WifiNetwork is a network the Robot is configured to join
+type WifiNetwork struct {
+	SSID     string `json:"ssid"`
+	Security string `json:"security"`
+	// Signal is the received signal strength, in dBm, of the network if it
+	// is in range
+	Signal    int  `json:"rssi"`
+	Connected bool `json:"connected"`
+}
+
+type wifiNetworksResponse struct {
+	Response
+	Data struct {
+		Networks []WifiNetwork `json:"networks"`
+	} `json:"data"`
+}
+
+// WifiNetworks returns the networks the Robot is configured to join
+func (r *Robot) WifiNetworks() ([]WifiNetwork, error) {
+	if err := r.checkWifi(); err != nil {
+		return nil, err
+	}
+	req, err := newRequest(cmdGetWifiNetworks, nil)
+	if err != nil {
+		return nil, err
+	}
+	var result wifiNetworksResponse
+	if err := r.do(req, &result); err != nil {
+		return nil, err
+	}
+	if _, err := r.check(req, &result.Response); err != nil {
+		return nil, err
+	}
+	return result.Data.Networks, nil
+}
+
+// EnterWifiSetupMode makes the Robot broadcast its setup network, as it does
+// when first installed, so that it can be given new network credentials. The
+// Robot drops off the Nucleo API until it has been reprovisioned.
+func (r *Robot) EnterWifiSetupMode() (*Response, error) {
+	if err := r.checkWifi(); err != nil {
+		return nil, err
+	}
+	req, err := newRequest(cmdEnterWifiSetupMode, nil)
+	if err != nil {
+		return nil, err
+	}
+	return r.exec(req)
+}
+
+// checkWifi returns an error unless the Robot advertises the wifi service
+func (r *Robot) checkWifi() error {
+	c, err := r.Capabilities()
+	if err != nil {
+		return err
+	}
+	if !c.Supports("wifi") {
+		return fmt.Errorf("robot does not support wifi management")
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package planner
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/richlj/neato/battery"
+	"github.com/richlj/neato/nucleo"
+	"github.com/richlj/neato/store"
+	"github.com/richlj/neato/units"
+)
+
+const (
+	// minRuns is the number of completed runs needed to estimate a
+	// cleaning rate
+	minRuns = 3
+
+	// maxChargeLevel is the highest level from which a reported time to
+	// full is scaled to a full recharge
+	maxChargeLevel = 80
+
+	defaultRechargeTime = 2 * time.Hour
+)
+
+// ErrNoHistory is returned by NewProfile when there are too few completed
+// runs to estimate from
+var ErrNoHistory = errors.New("planner: not enough cleaning history")
+
+// Profile describes how a robot performs, as learnt from its history
+type Profile struct {
+	// AreaPerHour is the rate at which the robot cleans
+	AreaPerHour units.Area
+
+	// AreaPerCharge is the area cleaned on a full charge
+	AreaPerCharge units.Area
+
+	// RechargeTime is the time taken to recharge part way through a run
+	RechargeTime time.Duration
+
+	// HouseArea is the typical area of a completed house cleaning run
+	HouseArea units.Area
+}
+
+// PlanEstimate predicts the cost of cleaning an area before starting
+type PlanEstimate struct {
+	Area units.Area
+
+	// Duration is the expected time from start to finish, including
+	// Recharges
+	Duration time.Duration
+
+	// Recharges is the number of times the robot is expected to return to
+	// its base to recharge part way through
+	Recharges int
+}
+
+// NewProfile learns a Profile from a robot's completed runs and battery
+// samples. The area per charge is the cleaning rate over the runtime of a
+// full charge estimated by battery.Analyze, or without enough samples, the
+// largest area of a completed run, which understates it.
+func NewProfile(runs []store.Run, samples []store.BatterySample) (Profile,
+	error) {
+	var (
+		rates       []float64
+		house       []float64
+		largest     units.Area
+		chargeTimes []time.Duration
+	)
+	for _, r := range runs {
+		d := r.End.Sub(r.Start)
+		if !r.Completed || d <= 0 || r.Area <= 0 {
+			continue
+		}
+		rates = append(rates, r.Area/d.Hours())
+		if r.Category == nucleo.CategoryHouse ||
+			r.Category == nucleo.CategoryPersistentMap {
+			house = append(house, r.Area)
+		}
+		if a := units.Area(r.Area); a > largest {
+			largest = a
+		}
+	}
+	if len(rates) < minRuns {
+		return Profile{}, ErrNoHistory
+	}
+	p := Profile{
+		AreaPerHour:   units.Area(medianFloat(rates)),
+		AreaPerCharge: largest,
+		RechargeTime:  defaultRechargeTime,
+	}
+	if len(house) > 0 {
+		p.HouseArea = units.Area(medianFloat(house))
+	}
+	if t, err := battery.Analyze(samples, 0); err == nil {
+		p.AreaPerCharge = units.Area(float64(p.AreaPerHour) *
+			t.Current.Hours())
+	}
+	for _, b := range samples {
+		if b.Charging && b.TimeToFull > 0 && b.Level <= maxChargeLevel {
+			chargeTimes = append(chargeTimes,
+				b.TimeToFull*100/time.Duration(100-b.Level))
+		}
+	}
+	if len(chargeTimes) > 0 {
+		sort.Slice(chargeTimes, func(i, j int) bool {
+			return chargeTimes[i] < chargeTimes[j]
+		})
+		p.RechargeTime = chargeTimes[len(chargeTimes)/2]
+	}
+	return p, nil
+}
+
+// Estimate predicts the cost of cleaning area starting from a charge level
+// between 0 and 100
+func (p Profile) Estimate(area units.Area, level int) PlanEstimate {
+	result := PlanEstimate{Area: area}
+	if p.AreaPerHour <= 0 {
+		return result
+	}
+	hours := float64(area) / float64(p.AreaPerHour)
+	result.Duration = time.Duration(hours * float64(time.Hour))
+	if p.AreaPerCharge > 0 {
+		first := p.AreaPerCharge * units.Area(level) / 100
+		if area > first {
+			result.Recharges = int(math.Ceil(float64(area-first) /
+				float64(p.AreaPerCharge)))
+		}
+	}
+	result.Duration += time.Duration(result.Recharges) * p.RechargeTime
+	return result
+}
+
+// EstimateHouse predicts the cost of a house cleaning run starting from a
+// charge level between 0 and 100
+func (p Profile) EstimateHouse(level int) PlanEstimate {
+	return p.Estimate(p.HouseArea, level)
+}
+
+// EstimateZones predicts the cost of cleaning zones one after another,
+// starting from a charge level between 0 and 100
+func (p Profile) EstimateZones(zones []Zone, level int) PlanEstimate {
+	var area units.Area
+	for _, z := range zones {
+		area += z.Area
+	}
+	return p.Estimate(area, level)
+}
+
+func medianFloat(a []float64) float64 {
+	sort.Float64s(a)
+	if len(a)%2 == 1 {
+		return a[len(a)/2]
+	}
+	return (a[len(a)/2-1] + a[len(a)/2]) / 2
+}
@@ -0,0 +1,174 @@
+// planner finds the zones of a persistent map which have not been cleaned
+// recently and cleans them, one zone at a time. When the area a robot can
+// clean on one charge is known, the stale zones are spread over as many days
+// as needed so that no day's cleaning needs a recharge.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/richlj/neato/floorplan"
+	"github.com/richlj/neato/nucleo"
+	"github.com/richlj/neato/units"
+)
+
+const (
+	categoryMap      = 4
+	modeEco          = 1
+	modifierNormal   = 1
+	navigationNormal = 1
+
+	fullCharge = 100
+)
+
+// Zone is a cleaning zone of a persistent map
+type Zone struct {
+	ID          string
+	Name        string
+	Area        units.Area
+	LastCleaned time.Time
+}
+
+// Robot is the subset of a nucleo.RobotService used to clean zones
+type Robot interface {
+	StartCleaning(a *nucleo.Params) (*nucleo.Response, error)
+	State() (*nucleo.RobotState, error)
+}
+
+// Planner selects zones which have not been cleaned within MaxAge. A
+// non-zero AreaPerCharge limits the area planned for each day. If Profile is
+// set, it supplies the AreaPerCharge when none is given, and each day of the
+// Plan is estimated with it.
+type Planner struct {
+	MaxAge        time.Duration
+	AreaPerCharge units.Area
+	Profile       *Profile
+}
+
+// Plan lists the zones to clean on each successive day, and when the Planner
+// has a Profile, the estimated cost of each day from a full charge
+type Plan struct {
+	Days      [][]Zone
+	Estimates []PlanEstimate
+}
+
+// Zones returns the zones of a floor plan, with the times each was last
+// cleaned taken from lastCleaned, keyed by boundary ID
+func Zones(p *floorplan.Plan, lastCleaned map[string]time.Time) []Zone {
+	var result []Zone
+	for i := range p.Boundaries {
+		b := &p.Boundaries[i]
+		if b.Type != nucleo.BoundaryPolygon || !b.Enabled {
+			continue
+		}
+		result = append(result, Zone{
+			ID:          b.ID,
+			Name:        b.Name,
+			Area:        p.Area(b),
+			LastCleaned: lastCleaned[b.ID],
+		})
+	}
+	return result
+}
+
+// Stale returns the zones last cleaned more than MaxAge before now, least
+// recently cleaned first
+func (p *Planner) Stale(zones []Zone, now time.Time) []Zone {
+	var result []Zone
+	for _, z := range zones {
+		if now.Sub(z.LastCleaned) > p.MaxAge {
+			result = append(result, z)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].LastCleaned.Before(result[j].LastCleaned)
+	})
+	return result
+}
+
+// Plan divides the stale zones into days, least recently cleaned first. A
+// zone larger than AreaPerCharge is given a day of its own.
+func (p *Planner) Plan(zones []Zone, now time.Time) *Plan {
+	result := &Plan{}
+	var (
+		day  []Zone
+		area units.Area
+	)
+	perCharge := p.AreaPerCharge
+	if perCharge <= 0 && p.Profile != nil {
+		perCharge = p.Profile.AreaPerCharge
+	}
+	for _, z := range p.Stale(zones, now) {
+		if perCharge > 0 && len(day) > 0 && area+z.Area > perCharge {
+			result.Days = append(result.Days, day)
+			day, area = nil, 0
+		}
+		day = append(day, z)
+		area += z.Area
+	}
+	if len(day) > 0 {
+		result.Days = append(result.Days, day)
+	}
+	if p.Profile != nil {
+		for _, d := range result.Days {
+			result.Estimates = append(result.Estimates,
+				p.Profile.EstimateZones(d, fullCharge))
+		}
+	}
+	return result
+}
+
+// Execute cleans each zone in turn, checking the Robot's state every
+// interval to find when each run has finished. done, if not nil, is called
+// after each zone is cleaned so that its LastCleaned can be recorded.
+func Execute(ctx context.Context, r Robot, zones []Zone,
+	interval time.Duration, done func(Zone)) error {
+	for _, z := range zones {
+		if _, err := r.StartCleaning(&nucleo.Params{
+			Category:       categoryMap,
+			Mode:           modeEco,
+			Modifier:       modifierNormal,
+			NavigationMode: navigationNormal,
+			BoundaryID:     z.ID,
+		}); err != nil {
+			return fmt.Errorf("zone %s: %v", z.ID, err)
+		}
+		if err := wait(ctx, r, interval); err != nil {
+			return fmt.Errorf("zone %s: %v", z.ID, err)
+		}
+		if done != nil {
+			done(z)
+		}
+	}
+	return nil
+}
+
+// wait returns once the Robot has started and then finished a run
+func wait(ctx context.Context, r Robot, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	started := false
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+		s, err := r.State()
+		if err != nil {
+			return err
+		}
+		switch {
+		case s.State == nucleo.StateError:
+			return fmt.Errorf("robot reported %s", s.Error)
+		case s.State != nucleo.StateIdle:
+			started = true
+		case started:
+			return nil
+		}
+	}
+}
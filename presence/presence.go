@@ -0,0 +1,334 @@
+// presence starts and stops a robot in response to people arriving at and
+// leaving home, as reported by external presence signals such as phone
+// geofencing or a home automation system.
+//
+// A Controller tracks who is home. When the last person leaves it can start
+// cleaning, and when someone returns it can pause cleaning or send the robot
+// back to its base. Signals are supplied by calling Set, by POSTing to the
+// Controller's HTTP handler, or by publishing to an MQTT topic.
+//
+// A Controller following a watch.Watcher decides from the robot's last
+// watched state rather than polling it, and starts a run it had to put off,
+// e.g. whilst the robot finished docking, once the robot is idle. Commands
+// which cannot be delivered are held in a queue.Queue, if one is set.
+
+package presence
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/richlj/neato/nucleo"
+	"github.com/richlj/neato/queue"
+	"github.com/richlj/neato/watch"
+)
+
+const (
+	defaultQueueTTL = 15 * time.Minute
+)
+
+var (
+	// commands maps the names of the commands a Controller issues, as
+	// known to a queue.Queue, to the methods which issue them
+	commands = map[string]func(nucleo.CleaningService,
+		*nucleo.Params) (*nucleo.Response, error){
+		"startCleaning":  nucleo.CleaningService.StartCleaning,
+		"pauseCleaning":  nucleo.CleaningService.PauseCleaning,
+		"resumeCleaning": nucleo.CleaningService.ResumeCleaning,
+		"sendToBase":     nucleo.CleaningService.SendToBase,
+	}
+)
+
+// Robot is the subset of a nucleo.RobotService used by a Controller
+type Robot interface {
+	nucleo.CleaningService
+	State() (*nucleo.RobotState, error)
+}
+
+// Rules determine how a Controller reacts to presence changes
+type Rules struct {
+	// StartWhenAway starts cleaning when the last person leaves
+	StartWhenAway bool
+
+	// PauseOnReturn pauses cleaning when someone returns
+	PauseOnReturn bool
+
+	// DockOnReturn sends the robot to its base when someone returns,
+	// taking precedence over PauseOnReturn
+	DockOnReturn bool
+
+	// Params are supplied when starting cleaning
+	Params *nucleo.Params
+}
+
+// Controller applies Rules to a Robot as people arrive and leave
+type Controller struct {
+	// ID identifies the robot to a Watcher and Queue, typically its
+	// serial number
+	ID string
+
+	// Queue, if set, holds commands the robot could not be reached for,
+	// for QueueTTL, which defaults to 15 minutes
+	Queue    *queue.Queue
+	QueueTTL time.Duration
+
+	robot Robot
+	rules Rules
+
+	mu      sync.Mutex
+	home    map[string]bool
+	watcher *watch.Watcher
+	last    *nucleo.RobotState
+	// deferred records that cleaning was due to start when the last
+	// person left, but the robot was not ready
+	deferred bool
+}
+
+// NewController returns a Controller applying rules to robot. Everyone is
+// assumed to be away until reported otherwise.
+func NewController(robot Robot, rules Rules) *Controller {
+	return &Controller{
+		robot: robot,
+		rules: rules,
+		home:  make(map[string]bool),
+	}
+}
+
+// Home reports whether anyone is home
+func (c *Controller) Home() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.anyoneHome()
+}
+
+func (c *Controller) anyoneHome() bool {
+	for _, v := range c.home {
+		if v {
+			return true
+		}
+	}
+	return false
+}
+
+// Set records whether the named person is home, and issues any command the
+// Rules call for when the household becomes empty or occupied
+func (c *Controller) Set(person string, home bool) error {
+	c.mu.Lock()
+	before := c.anyoneHome()
+	c.home[person] = home
+	after := c.anyoneHome()
+	if after {
+		c.deferred = false
+	}
+	c.mu.Unlock()
+	switch {
+	case before && !after:
+		return c.left()
+	case !before && after:
+		return c.returned()
+	}
+	return nil
+}
+
+// left is called when the last person leaves
+func (c *Controller) left() error {
+	if !c.rules.StartWhenAway {
+		return nil
+	}
+	s, err := c.state()
+	if err != nil {
+		return err
+	}
+	return c.start(s)
+}
+
+// start starts or resumes cleaning if the robot in state s is ready to,
+// otherwise deferring it until a followed Watcher sees the robot idle
+func (c *Controller) start(s *nucleo.RobotState) error {
+	ready := s.State == nucleo.StateIdle || s.State == nucleo.StatePaused
+	c.mu.Lock()
+	c.deferred = !ready && c.watcher != nil
+	c.mu.Unlock()
+	switch s.State {
+	case nucleo.StateIdle:
+		return c.issue("startCleaning", c.rules.Params)
+	case nucleo.StatePaused:
+		return c.issue("resumeCleaning", nil)
+	}
+	return nil
+}
+
+// returned is called when the first person returns
+func (c *Controller) returned() error {
+	if !c.rules.PauseOnReturn && !c.rules.DockOnReturn {
+		return nil
+	}
+	s, err := c.state()
+	if err != nil {
+		return err
+	}
+	paused := s.State == nucleo.StatePaused
+	cleaning := s.State == nucleo.StateBusy &&
+		s.Action != nucleo.ActionDocking
+	switch {
+	case c.rules.DockOnReturn && (cleaning || paused):
+		return c.issue("sendToBase", nil)
+	case c.rules.PauseOnReturn && cleaning:
+		return c.issue("pauseCleaning", nil)
+	}
+	return nil
+}
+
+// state returns the robot's state as last seen by a followed Watcher, or
+// polls the robot if there is none
+func (c *Controller) state() (*nucleo.RobotState, error) {
+	c.mu.Lock()
+	s := c.last
+	c.mu.Unlock()
+	if s != nil {
+		return s, nil
+	}
+	return c.robot.State()
+}
+
+// issue sends the named command to the robot. If the robot cannot be
+// reached and a Queue is set the command is queued and queue.ErrQueued
+// returned. A followed Watcher is asked to recheck the robot after a
+// command is delivered.
+func (c *Controller) issue(cmd string, a *nucleo.Params) error {
+	_, err := commands[cmd](c.robot, a)
+	if queue.Unreachable(err) && c.Queue != nil {
+		ttl := c.QueueTTL
+		if ttl == 0 {
+			ttl = defaultQueueTTL
+		}
+		if _, err := c.Queue.Enqueue(c.ID, cmd, a, ttl); err != nil {
+			return err
+		}
+		return queue.ErrQueued
+	}
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	w := c.watcher
+	c.mu.Unlock()
+	if w != nil {
+		w.Recheck(c.ID)
+	}
+	return nil
+}
+
+// Follow tracks the state of the robot identified by the Controller's ID
+// from the Events of w until ctx is cancelled or w is shut down. A run put
+// off when the last person left is started once the robot is seen idle or
+// paused, if nobody has returned since. Errors starting it are passed to
+// onError, if not nil.
+func (c *Controller) Follow(ctx context.Context, w *watch.Watcher,
+	onError func(error)) error {
+	events, cancel := w.Subscribe()
+	defer cancel()
+	c.mu.Lock()
+	c.watcher = w
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.watcher, c.last, c.deferred = nil, nil, false
+		c.mu.Unlock()
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if e.Robot != c.ID || e.Type == watch.PollFailed ||
+				e.Type == watch.BatteryDegraded {
+				continue
+			}
+			s := robotState(e)
+			c.mu.Lock()
+			c.last = s
+			deferred := c.deferred && !c.anyoneHome()
+			c.mu.Unlock()
+			if !deferred {
+				continue
+			}
+			err := c.start(s)
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// robotState returns the state of the robot reported by e
+func robotState(e watch.Event) *nucleo.RobotState {
+	return &nucleo.RobotState{
+		Robot:    e.Name,
+		State:    nucleo.State(e.State),
+		Action:   nucleo.Action(e.Action),
+		Charge:   e.Charge,
+		Charging: e.Charging,
+		Docked:   e.Docked,
+		Alert:    e.Alert,
+		Error:    e.Error,
+	}
+}
+
+type signal struct {
+	Person string `json:"person"`
+	Home   bool   `json:"home"`
+}
+
+// ServeHTTP accepts presence signals POSTed either as JSON, e.g.
+// {"person": "alex", "home": false}, or as the form values person and home
+func (c *Controller) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	s, err := parseSignal(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = c.Set(s.Person, s.Home)
+	switch {
+	case errors.Is(err, queue.ErrQueued):
+		w.WriteHeader(http.StatusAccepted)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseSignal(req *http.Request) (*signal, error) {
+	var s signal
+	if req.Header.Get("Content-Type") == "application/json" {
+		if err := json.NewDecoder(req.Body).Decode(&s); err != nil {
+			return nil, err
+		}
+	} else {
+		home, err := strconv.ParseBool(req.FormValue("home"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid home value %q",
+				req.FormValue("home"))
+		}
+		s = signal{Person: req.FormValue("person"), Home: home}
+	}
+	if s.Person == "" {
+		return nil, fmt.Errorf("person is required")
+	}
+	return &s, nil
+}
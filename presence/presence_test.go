@@ -0,0 +1,336 @@
+package presence
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/richlj/neato/mocks"
+	"github.com/richlj/neato/nucleo"
+	"github.com/richlj/neato/queue"
+	"github.com/richlj/neato/watch"
+)
+
+// fakeRobot is a mocks.Robot reporting a settable state
+type fakeRobot struct {
+	mocks.Robot
+	mu    sync.Mutex
+	state nucleo.RobotState
+}
+
+func newFakeRobot(s nucleo.State, a nucleo.Action) *fakeRobot {
+	r := &fakeRobot{state: nucleo.RobotState{State: s, Action: a}}
+	r.StateFunc = func() (*nucleo.RobotState, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		s := r.state
+		return &s, nil
+	}
+	return r
+}
+
+func (r *fakeRobot) set(s nucleo.State, a nucleo.Action) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state = nucleo.RobotState{State: s, Action: a}
+}
+
+// commands returns the robot's calls other than State
+func (r *fakeRobot) commands() string {
+	var a []string
+	for _, c := range r.Calls() {
+		if c.Method != "State" {
+			a = append(a, c.Method)
+		}
+	}
+	return strings.Join(a, ",")
+}
+
+// waitFor polls f until it returns true or a second has passed
+func waitFor(t *testing.T, what string, f func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !f(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  Rules
+		state  nucleo.State
+		action nucleo.Action
+		home   []bool
+		want   string
+	}{
+		{"start when away", Rules{StartWhenAway: true},
+			nucleo.StateIdle, 0, []bool{true, false},
+			"StartCleaning"},
+		{"resume when away", Rules{StartWhenAway: true},
+			nucleo.StatePaused, nucleo.ActionHouseCleaning,
+			[]bool{true, false}, "ResumeCleaning"},
+		{"pause on return", Rules{PauseOnReturn: true},
+			nucleo.StateBusy, nucleo.ActionHouseCleaning,
+			[]bool{true}, "PauseCleaning"},
+		{"dock on return", Rules{PauseOnReturn: true,
+			DockOnReturn: true}, nucleo.StateBusy,
+			nucleo.ActionHouseCleaning, []bool{true}, "SendToBase"},
+		{"already docking", Rules{DockOnReturn: true},
+			nucleo.StateBusy, nucleo.ActionDocking, []bool{true},
+			""},
+		{"no rules", Rules{}, nucleo.StateIdle, 0,
+			[]bool{true, false}, ""},
+	}
+	for _, tt := range tests {
+		r := newFakeRobot(tt.state, tt.action)
+		c := NewController(r, tt.rules)
+		for _, home := range tt.home {
+			if err := c.Set("alex", home); err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+		}
+		if got := r.commands(); got != tt.want {
+			t.Errorf("%s: issued %q, want %q", tt.name, got,
+				tt.want)
+		}
+	}
+}
+
+func TestQueued(t *testing.T) {
+	r := newFakeRobot(nucleo.StateIdle, 0)
+	r.StartCleaningFunc = func(*nucleo.Params) (*nucleo.Response,
+		error) {
+		return nil, &url.Error{Op: "Post", URL: "https://nucleo",
+			Err: errors.New("connection refused")}
+	}
+	q, err := queue.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewController(r, Rules{StartWhenAway: true})
+	c.ID, c.Queue = "robot-1", q
+	c.Set("alex", true)
+	if err := c.Set("alex", false); !errors.Is(err, queue.ErrQueued) {
+		t.Fatalf("Set = %v, want %v", err, queue.ErrQueued)
+	}
+	p := q.Pending()
+	if len(p) != 1 || p[0].Robot != "robot-1" ||
+		p[0].Cmd != "startCleaning" {
+		t.Errorf("queued %+v", p)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	r := newFakeRobot(nucleo.StateBusy, nucleo.ActionDocking)
+	w := watch.New(time.Hour)
+	w.Add("robot-1", r)
+	c := NewController(r, Rules{StartWhenAway: true})
+	c.ID = "robot-1"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- c.Follow(ctx, w, func(err error) { t.Error(err) })
+	}()
+	waitFor(t, "Follow to subscribe", func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.watcher != nil
+	})
+	w.Poll()
+	waitFor(t, "the docking state", func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.last != nil
+	})
+	polls := len(r.Calls())
+
+	// the robot is docking when the household leaves, so the run waits
+	c.Set("alex", true)
+	if err := c.Set("alex", false); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Calls()) != polls {
+		t.Errorf("Set polled the robot: %v", r.Calls()[polls:])
+	}
+	r.set(nucleo.StateIdle, 0)
+	w.Poll()
+	waitFor(t, "StartCleaning", func() bool {
+		return r.commands() == "StartCleaning"
+	})
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Follow = %v", err)
+	}
+}
+
+// broker accepts a single MQTT connection, answering its CONNECT and
+// SUBSCRIBE, then sends it the packets received on publish
+type broker struct {
+	t       *testing.T
+	ln      net.Listener
+	publish chan []byte
+	acks    chan []byte
+	served  chan struct{}
+	connect []byte
+	topic   string
+}
+
+func newBroker(t *testing.T) *broker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &broker{t: t, ln: ln, publish: make(chan []byte),
+		acks: make(chan []byte, 1), served: make(chan struct{})}
+	t.Cleanup(func() { ln.Close() })
+	return b
+}
+
+func (b *broker) serve() {
+	defer close(b.served)
+	conn, err := b.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	typ, body, err := readPacket(r)
+	if err != nil || typ>>4 != mqttConnect {
+		b.t.Errorf("CONNECT: %d, %v", typ, err)
+		return
+	}
+	b.connect = body
+	conn.Write([]byte{mqttConnack << 4, 2, 0, 0})
+	typ, body, err = readPacket(r)
+	if err != nil || typ != mqttSubscribe<<4|2 {
+		b.t.Errorf("SUBSCRIBE: %d, %v", typ, err)
+		return
+	}
+	n := int(body[2])<<8 | int(body[3])
+	b.topic = string(body[4 : 4+n])
+	conn.Write([]byte{mqttSuback << 4, 3, body[0], body[1], 1})
+	go func() {
+		for {
+			typ, body, err := readPacket(r)
+			if err != nil {
+				close(b.acks)
+				return
+			}
+			if typ>>4 == mqttPuback {
+				b.acks <- body
+			}
+		}
+	}()
+	for p := range b.publish {
+		conn.Write(p)
+	}
+}
+
+func publish(topic, payload string, id uint16) []byte {
+	body := appendString(nil, topic)
+	typ := byte(mqttPublish<<4 | 1)
+	if id != 0 {
+		typ |= 2
+		body = append(body, byte(id>>8), byte(id))
+	}
+	return packet(typ, append(body, payload...))
+}
+
+func TestMQTT(t *testing.T) {
+	r := newFakeRobot(nucleo.StateIdle, 0)
+	c := NewController(r, Rules{StartWhenAway: true,
+		DockOnReturn: true})
+	c.Set("alex", true)
+	b := newBroker(t)
+	go b.serve()
+	var errs []string
+	var mu sync.Mutex
+	m := &MQTT{URL: "mqtt://user:secret@" + b.ln.Addr().String(),
+		ClientID: "test", OnError: func(topic string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, topic+": "+err.Error())
+		}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- m.Run(ctx, c) }()
+
+	b.publish <- publish("neato/presence/alex", "not_home", 0)
+	waitFor(t, "StartCleaning", func() bool {
+		return r.commands() == "StartCleaning"
+	})
+	r.set(nucleo.StateBusy, nucleo.ActionHouseCleaning)
+	b.publish <- publish("neato/presence/alex", "maybe", 0)
+	b.publish <- publish("neato/presence/alex",
+		`{"person": "sam", "home": true}`, 7)
+	if ack := <-b.acks; string(ack) != "\x00\x07" {
+		t.Errorf("PUBACK %x", ack)
+	}
+	waitFor(t, "SendToBase", func() bool {
+		return r.commands() == "StartCleaning,SendToBase"
+	})
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Run = %v", err)
+	}
+	close(b.publish)
+	<-b.served
+
+	if b.topic != mqttDefaultTopic {
+		t.Errorf("subscribed to %q", b.topic)
+	}
+	for _, s := range []string{"MQTT", "test", "user", "secret"} {
+		if !strings.Contains(string(b.connect), s) {
+			t.Errorf("CONNECT %q lacks %q", b.connect, s)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 1 ||
+		errs[0] != `neato/presence/alex: invalid presence "maybe"` {
+		t.Errorf("errors %q", errs)
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		topic   string
+		payload string
+		person  string
+		home    bool
+		ok      bool
+	}{
+		{"neato/presence/alex", "home", "alex", true, true},
+		{"neato/presence/alex", "not_home", "alex", false, true},
+		{"neato/presence/alex", " false\n", "alex", false, true},
+		{"neato/presence/alex", "1", "alex", true, true},
+		{"alex", "true", "alex", true, true},
+		{"neato/presence/alex", `{"home": true}`, "alex", true, true},
+		{"neato/presence", `{"person": "sam", "home": true}`, "sam",
+			true, true},
+		{"neato/presence/alex", "work", "", false, false},
+		{"neato/presence/alex", `{"home": 1}`, "", false, false},
+		{"neato/presence/", "home", "", false, false},
+	}
+	for _, tt := range tests {
+		s, err := parseMessage(tt.topic, []byte(tt.payload))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s %q: %v", tt.topic, tt.payload, err)
+			continue
+		}
+		if err == nil && (s.Person != tt.person || s.Home != tt.home) {
+			t.Errorf("%s %q: %+v", tt.topic, tt.payload, s)
+		}
+	}
+}
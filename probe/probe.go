@@ -0,0 +1,296 @@
+// probe measures the latency and availability of the Beehive and Nucleo APIs
+// and of individual robots, so that a slow robot can be told apart from a
+// slow cloud. Samples are kept over a sliding window for percentiles, and
+// can be served in the Prometheus text exposition format.
+
+package probe
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/richlj/neato/beehive"
+	"github.com/richlj/neato/egress"
+	"github.com/richlj/neato/nucleo"
+)
+
+const (
+	defaultWindow = 100
+
+	// The targets of the cloud probes
+	TargetBeehive = "beehive"
+	TargetNucleo  = "nucleo"
+)
+
+var (
+	quantiles = []float64{0.5, 0.9, 0.99}
+)
+
+// Robot is the subset of a nucleo.RobotService used to echo a robot
+type Robot interface {
+	GetRobotState(a *nucleo.Params) (*nucleo.Response, error)
+}
+
+// Result holds the outcome of a single Ping. Targets which failed appear in
+// Errors rather than Latencies.
+type Result struct {
+	Time      time.Time
+	Latencies map[string]time.Duration
+	Errors    map[string]error
+}
+
+type series struct {
+	samples  []time.Duration
+	next     int
+	count    int
+	failures int
+}
+
+// Prober pings the cloud APIs and a set of robots, tracking their latencies
+type Prober struct {
+	// Window is the number of samples kept per target for percentiles
+	Window int
+
+	// Beehive and Nucleo override the base URLs of the cloud APIs probed,
+	// as WithEndpoint does for the clients, e.g. for an egress proxy or a
+	// test server. Hosts with the http scheme are dialled without TLS.
+	Beehive *url.URL
+	Nucleo  *url.URL
+
+	mu     sync.Mutex
+	robots map[string]Robot
+	series map[string]*series
+}
+
+// New returns a Prober keeping window samples per target
+func New(window int) *Prober {
+	if window <= 0 {
+		window = defaultWindow
+	}
+	return &Prober{
+		Window: window,
+		robots: make(map[string]Robot),
+		series: make(map[string]*series),
+	}
+}
+
+// AddRobot includes the named robot in subsequent Pings
+func (p *Prober) AddRobot(name string, r Robot) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.robots[name] = r
+}
+
+// Ping measures the time taken to establish a TLS connection to each cloud
+// API, and for each robot to answer a getRobotState command, concurrently
+func (p *Prober) Ping(ctx context.Context) *Result {
+	result := &Result{
+		Time:      time.Now(),
+		Latencies: make(map[string]time.Duration),
+		Errors:    make(map[string]error),
+	}
+	probes := map[string]func() error{
+		TargetBeehive: func() error {
+			return dial(ctx, p.Beehive, beehive.Addr)
+		},
+		TargetNucleo: func() error {
+			return dial(ctx, p.Nucleo, nucleo.Addr)
+		},
+	}
+	p.mu.Lock()
+	for name, r := range p.robots {
+		r := r
+		probes[name] = func() error { return echo(ctx, r) }
+	}
+	p.mu.Unlock()
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for target, probe := range probes {
+		wg.Add(1)
+		go func(target string, probe func() error) {
+			defer wg.Done()
+			start := time.Now()
+			err := probe()
+			d := time.Since(start)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.Errors[target] = err
+			} else {
+				result.Latencies[target] = d
+			}
+		}(target, probe)
+	}
+	wg.Wait()
+	p.record(result)
+	return result
+}
+
+// Run Pings every interval until ctx is cancelled
+func (p *Prober) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		p.Ping(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Percentile returns the latency below which the fraction q of the target's
+// recent samples fall, e.g. 0.9 for the 90th percentile
+func (p *Prober) Percentile(target string, q float64) (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.series[target]
+	if !ok || len(s.samples) == 0 {
+		return 0, false
+	}
+	return s.percentile(q), true
+}
+
+// ServeHTTP writes the latency percentiles and failure counts of every target
+// in the Prometheus text exposition format
+func (p *Prober) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_ = p.WriteMetrics(w)
+}
+
+// WriteMetrics writes the latency percentiles and failure counts of every
+// target to w in the Prometheus text exposition format
+func (p *Prober) WriteMetrics(w io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var targets []string
+	for t := range p.series {
+		targets = append(targets, t)
+	}
+	sort.Strings(targets)
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "# HELP neato_probe_latency_seconds Round-trip time "+
+		"of probes against the Neato cloud and robots.")
+	fmt.Fprintln(b, "# TYPE neato_probe_latency_seconds summary")
+	for _, t := range targets {
+		s := p.series[t]
+		if len(s.samples) == 0 {
+			continue
+		}
+		for _, q := range quantiles {
+			fmt.Fprintf(b, "neato_probe_latency_seconds{target=%q,"+
+				"quantile=\"%g\"} %g\n", t, q,
+				s.percentile(q).Seconds())
+		}
+		fmt.Fprintf(b, "neato_probe_latency_seconds_count{target=%q} "+
+			"%d\n", t, s.count)
+	}
+	fmt.Fprintln(b, "# HELP neato_probe_failures_total Probes which "+
+		"failed.")
+	fmt.Fprintln(b, "# TYPE neato_probe_failures_total counter")
+	for _, t := range targets {
+		fmt.Fprintf(b, "neato_probe_failures_total{target=%q} %d\n", t,
+			p.series[t].failures)
+	}
+	return b.Flush()
+}
+
+func (p *Prober) record(r *Result) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for t, d := range r.Latencies {
+		p.target(t).add(d, p.Window)
+	}
+	for t := range r.Errors {
+		p.target(t).failures++
+	}
+}
+
+func (p *Prober) target(name string) *series {
+	s, ok := p.series[name]
+	if !ok {
+		s = &series{}
+		p.series[name] = s
+	}
+	return s
+}
+
+func (s *series) add(d time.Duration, window int) {
+	s.count++
+	if len(s.samples) < window {
+		s.samples = append(s.samples, d)
+		return
+	}
+	s.samples[s.next] = d
+	s.next = (s.next + 1) % window
+}
+
+// percentile returns the nearest-rank percentile of the samples
+func (s *series) percentile(q float64) time.Duration {
+	sorted := make([]time.Duration, len(s.samples))
+	copy(sorted, s.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	switch {
+	case i < 0:
+		i = 0
+	case i >= len(sorted):
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// dial opens and closes a connection to the API at u, or by TLS to addr if u
+// is nil, dialling as configured by the egress package
+func dial(ctx context.Context, u *url.URL, addr string) error {
+	secure := true
+	if u != nil {
+		port := "443"
+		if u.Scheme == "http" {
+			secure, port = false, "80"
+		}
+		if u.Port() != "" {
+			port = u.Port()
+		}
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+	conn, err := egress.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if !secure {
+		return nil
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	tc := tls.Client(conn, &tls.Config{ServerName: host})
+	return tc.HandshakeContext(ctx)
+}
+
+// echo issues getRobotState, abandoning the wait if ctx is cancelled
+func echo(ctx context.Context, r Robot) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.GetRobotState(nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
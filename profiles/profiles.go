@@ -0,0 +1,80 @@
+// profiles bundles canonical Nucleo responses for each supported robot model
+// and firmware. The field sets returned differ substantially between models,
+// so the profiles are used to drive model-specific behaviour in the simulator
+// and to detect the capabilities of a robot from its model name.
+
+package profiles
+
+import (
+	"embed"
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+)
+
+//go:embed data/*.json
+var data embed.FS
+
+var (
+	profiles = mustLoad()
+)
+
+// Profile holds the canonical responses of a particular model and firmware
+type Profile struct {
+	Model             string            `json:"model"`
+	Firmware          string            `json:"firmware"`
+	AvailableServices map[string]string `json:"availableServices"`
+	State             json.RawMessage   `json:"state"`
+	GeneralInfo       json.RawMessage   `json:"generalInfo,omitempty"`
+	Preferences       json.RawMessage   `json:"preferences,omitempty"`
+	LocalStats        json.RawMessage   `json:"localStats,omitempty"`
+}
+
+// Supports reports whether the model offers the named service, e.g.
+// "generalInfo"
+func (p *Profile) Supports(service string) bool {
+	_, ok := p.AvailableServices[service]
+	return ok
+}
+
+// All returns every bundled Profile, ordered by model name
+func All() []*Profile {
+	result := make([]*Profile, len(profiles))
+	copy(result, profiles)
+	return result
+}
+
+// Lookup returns the Profile for the named model, e.g. "BotVacD7Connected".
+// Model names are matched case-insensitively.
+func Lookup(model string) (*Profile, bool) {
+	for _, p := range profiles {
+		if strings.EqualFold(p.Model, model) {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+func mustLoad() []*Profile {
+	entries, err := data.ReadDir("data")
+	if err != nil {
+		panic(err)
+	}
+	var result []*Profile
+	for _, e := range entries {
+		b, err := data.ReadFile(path.Join("data", e.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var p Profile
+		if err := json.Unmarshal(b, &p); err != nil {
+			panic(err)
+		}
+		result = append(result, &p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Model < result[j].Model
+	})
+	return result
+}
@@ -0,0 +1,159 @@
+// provision onboards a new robot without the mobile app: its wifi
+// credentials are pushed over the robot's setup access point, it is linked
+// to the account through Beehive, and Nucleo is polled until the robot comes
+// online on the new network.
+//
+// Neato has not published the local setup protocol spoken on the access
+// point, so the first step is a Setup supplied by the caller. Joining the
+// access point is likewise left to the host, as it is specific to the
+// operating system.
+
+package provision
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/richlj/neato/beehive"
+	"github.com/richlj/neato/clock"
+	"github.com/richlj/neato/nucleo"
+)
+
+const (
+	defaultOnlineTimeout = 5 * time.Minute
+	defaultPollInterval  = 10 * time.Second
+)
+
+var (
+	// ErrNotOnline is returned when a linked robot does not come online
+	// within the Provisioner's OnlineTimeout
+	ErrNotOnline = errors.New("robot did not come online")
+)
+
+// Network is the wifi network to which a robot is to connect
+type Network struct {
+	SSID     string
+	Password string
+}
+
+// Identity is what a robot reports of itself over its setup access point
+type Identity struct {
+	Serial    string
+	SecretKey string
+	Model     string
+}
+
+// Setup speaks the robot's local setup protocol, once the host has joined
+// the robot's setup access point
+type Setup interface {
+	// Configure sends the robot the credentials of n, returning its
+	// identity. The robot leaves setup mode and joins n afterwards.
+	Configure(ctx context.Context, n Network) (Identity, error)
+}
+
+// Linker links robots to an account, as a *beehive.Session does
+type Linker interface {
+	LinkRobot(l beehive.Link) (*beehive.Robot, error)
+}
+
+// Stage identifies the step reported to a Provisioner's Progress
+type Stage string
+
+// The Stages of provisioning
+const (
+	Configuring Stage = "configuring"
+	Linking     Stage = "linking"
+	Waiting     Stage = "waiting"
+	Online      Stage = "online"
+)
+
+// Provisioner onboards robots
+type Provisioner struct {
+	Setup  Setup
+	Linker Linker
+
+	// OnlineTimeout limits the wait for the robot to come online, polled
+	// every PollInterval. They default to five minutes and ten seconds.
+	OnlineTimeout time.Duration
+	PollInterval  time.Duration
+
+	// Progress, if not nil, is called as each Stage begins
+	Progress func(Stage)
+
+	// Clock paces the polling. It defaults to clock.Real.
+	Clock clock.Clock
+}
+
+// Provision configures the robot on the setup access point to join n, links
+// it to the account under name, and waits for it to come online. The linked
+// robot is returned even if it does not come online, with ErrNotOnline.
+func (p *Provisioner) Provision(ctx context.Context, n Network,
+	name string) (*beehive.Robot, error) {
+	if n.SSID == "" {
+		return nil, errors.New("provision: no network SSID")
+	}
+	p.report(Configuring)
+	id, err := p.Setup.Configure(ctx, n)
+	if err != nil {
+		return nil, fmt.Errorf("provision: configuring robot: %v", err)
+	}
+	p.report(Linking)
+	r, err := p.Linker.LinkRobot(beehive.Link{
+		Serial:    id.Serial,
+		SecretKey: id.SecretKey,
+		Name:      name,
+		Model:     id.Model,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("provision: linking robot %s: %v",
+			nucleo.MaskSerial(id.Serial), err)
+	}
+	p.report(Waiting)
+	if err := p.waitOnline(ctx, &r.Robot); err != nil {
+		return r, err
+	}
+	p.report(Online)
+	return r, nil
+}
+
+// waitOnline polls r until Nucleo reaches it, the timeout passes or ctx is
+// cancelled
+func (p *Provisioner) waitOnline(ctx context.Context, r *nucleo.Robot) error {
+	c := clock.Or(p.Clock)
+	timeout := p.OnlineTimeout
+	if timeout <= 0 {
+		timeout = defaultOnlineTimeout
+	}
+	interval := p.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	deadline := c.Now().Add(timeout)
+	for {
+		_, err := r.State()
+		if err == nil {
+			return nil
+		}
+		if !nucleo.IsRetryable(err) {
+			return fmt.Errorf("provision: %v", err)
+		}
+		if !c.Now().Before(deadline) {
+			return ErrNotOnline
+		}
+		t := c.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C():
+		}
+	}
+}
+
+func (p *Provisioner) report(s Stage) {
+	if p.Progress != nil {
+		p.Progress(s)
+	}
+}
@@ -0,0 +1,112 @@
+// push publishes metrics to a Prometheus Pushgateway on an interval, for
+// deployments which Prometheus cannot reach to scrape. Any Source which writes
+// the Prometheus text exposition format can be pushed, such as a
+// probe.Prober.
+
+package push
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+)
+
+const (
+	contentType = "text/plain; version=0.0.4"
+)
+
+// Source writes metrics in the Prometheus text exposition format
+type Source interface {
+	WriteMetrics(w io.Writer) error
+}
+
+// Pusher publishes the metrics of its Sources to the Pushgateway at URL,
+// grouped under Job and, if set, Instance. Each push replaces the metrics
+// previously pushed to the group.
+type Pusher struct {
+	URL      string
+	Job      string
+	Instance string
+	Client   *http.Client
+
+	sources []Source
+}
+
+// New returns a Pusher publishing the metrics of sources to the Pushgateway
+// at gateway, e.g. "http://pushgateway:9091", under job
+func New(gateway, job string, sources ...Source) *Pusher {
+	return &Pusher{URL: gateway, Job: job, sources: sources}
+}
+
+// Push publishes the current metrics of every Source
+func (p *Pusher) Push(ctx context.Context) error {
+	var b bytes.Buffer
+	for _, s := range p.sources {
+		if err := s.WriteMetrics(&b); err != nil {
+			return err
+		}
+	}
+	u, err := p.groupURL()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, u, &b)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("pushgateway responded %s", resp.Status)
+	}
+	return nil
+}
+
+// Run pushes every interval until ctx is cancelled. Failed pushes are
+// passed to onError, if not nil, and retried at the next interval.
+func (p *Pusher) Run(ctx context.Context, interval time.Duration,
+	onError func(error)) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err := p.Push(ctx); err != nil && onError != nil &&
+			ctx.Err() == nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+func (p *Pusher) groupURL() (string, error) {
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return "", err
+	}
+	if p.Job == "" {
+		return "", fmt.Errorf("no job given")
+	}
+	elems := []string{u.Path, "metrics/job", url.PathEscape(p.Job)}
+	if p.Instance != "" {
+		elems = append(elems, "instance", url.PathEscape(p.Instance))
+	}
+	u.RawPath = path.Join(elems...)
+	u.Path, _ = url.PathUnescape(u.RawPath)
+	return u.String(), nil
+}
@@ -0,0 +1,275 @@
+// queue holds commands which could not be delivered because a robot or the
+// Nucleo API was unreachable, and replays them once connectivity returns.
+// Queued commands survive restarts, expire if they are not delivered in
+// time, and are superseded by later commands they conflict with: a queued
+// start is pointless once a dock has been queued after it.
+
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/richlj/neato/clock"
+	"github.com/richlj/neato/nucleo"
+)
+
+const (
+	fileMode = 0600
+	idLength = 8
+)
+
+var (
+	// ErrQueued is returned by Do when a command could not be delivered
+	// and was queued for replay
+	ErrQueued = errors.New("robot unreachable; command queued")
+
+	// commands maps the supported command names to the methods which issue
+	// them
+	commands = map[string]func(nucleo.RobotService,
+		*nucleo.Params) (*nucleo.Response, error){
+		"startCleaning":       nucleo.RobotService.StartCleaning,
+		"stopCleaning":        nucleo.RobotService.StopCleaning,
+		"pauseCleaning":       nucleo.RobotService.PauseCleaning,
+		"resumeCleaning":      nucleo.RobotService.ResumeCleaning,
+		"sendToBase":          nucleo.RobotService.SendToBase,
+		"findMe":              nucleo.RobotService.FindMe,
+		"dismissCurrentAlert": nucleo.RobotService.DismissCurrentAlert,
+		"setSchedule":         nucleo.RobotService.SetSchedule,
+		"enableSchedule":      nucleo.RobotService.EnableSchedule,
+		"disableSchedule":     nucleo.RobotService.DisableSchedule,
+		"setPreferences":      nucleo.RobotService.SetPreferences,
+	}
+
+	// conflicts groups commands of which only the latest queued for a robot
+	// is kept. Commands in no group conflict only with themselves.
+	conflicts = map[string]string{
+		"startCleaning":   "motion",
+		"stopCleaning":    "motion",
+		"pauseCleaning":   "motion",
+		"resumeCleaning":  "motion",
+		"sendToBase":      "motion",
+		"enableSchedule":  "schedule",
+		"disableSchedule": "schedule",
+	}
+)
+
+// Command is a queued command
+type Command struct {
+	ID      string         `json:"id"`
+	Robot   string         `json:"robot"`
+	Cmd     string         `json:"cmd"`
+	Params  *nucleo.Params `json:"params,omitempty"`
+	Queued  time.Time      `json:"queued"`
+	Expires time.Time      `json:"expires"`
+}
+
+func (c *Command) conflictsWith(o *Command) bool {
+	if c.Robot != o.Robot {
+		return false
+	}
+	if g, ok := conflicts[c.Cmd]; ok {
+		return g == conflicts[o.Cmd]
+	}
+	return c.Cmd == o.Cmd
+}
+
+// Outcome reports what became of a Command during a Replay
+type Outcome struct {
+	Command  Command
+	Response *nucleo.Response
+	Err      error
+	Expired  bool
+}
+
+// Queue is a durable queue of Commands, held in memory and, optionally, in a
+// file which is rewritten as the Queue changes
+type Queue struct {
+	// Clock dates and expires Commands and paces Run. It defaults to
+	// clock.Real.
+	Clock clock.Clock
+
+	mu       sync.Mutex
+	path     string
+	commands []Command
+}
+
+// Open returns a Queue persisted to the file at path, loading any Commands
+// already queued there. An empty path keeps the Queue in memory only.
+func Open(path string) (*Queue, error) {
+	q := &Queue{path: path}
+	if path == "" {
+		return q, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &q.commands); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return q, nil
+}
+
+// Enqueue adds a command for the identified robot, which expires after ttl,
+// dropping any queued commands it supersedes
+func (q *Queue) Enqueue(robot, cmd string, a *nucleo.Params,
+	ttl time.Duration) (*Command, error) {
+	if _, ok := commands[cmd]; !ok {
+		return nil, fmt.Errorf("command %q cannot be queued", cmd)
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	now := clock.Or(q.Clock).Now()
+	c := Command{ID: id, Robot: robot, Cmd: cmd, Params: a, Queued: now,
+		Expires: now.Add(ttl)}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	kept := q.commands[:0]
+	for _, o := range q.commands {
+		if !c.conflictsWith(&o) {
+			kept = append(kept, o)
+		}
+	}
+	q.commands = append(kept, c)
+	return &c, q.save()
+}
+
+// Pending returns the queued Commands, oldest first
+func (q *Queue) Pending() []Command {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	result := make([]Command, len(q.commands))
+	copy(result, q.commands)
+	return result
+}
+
+// Do issues cmd to robot, identified by id. If the robot cannot be reached
+// the command is queued for ttl and ErrQueued returned.
+func (q *Queue) Do(robot nucleo.RobotService, id, cmd string,
+	a *nucleo.Params, ttl time.Duration) (*nucleo.Response, error) {
+	f, ok := commands[cmd]
+	if !ok {
+		return nil, fmt.Errorf("unsupported command %q", cmd)
+	}
+	resp, err := f(robot, a)
+	if !Unreachable(err) {
+		return resp, err
+	}
+	if _, err := q.Enqueue(id, cmd, a, ttl); err != nil {
+		return nil, err
+	}
+	return nil, ErrQueued
+}
+
+// Replay delivers the queued Commands to the robots returned by lookup, in
+// the order they were queued. Expired Commands are dropped. Delivery to a
+// robot stops at the first Command which finds it still unreachable, leaving
+// that Command and its successors queued; Commands the robot rejects are
+// dropped and reported.
+func (q *Queue) Replay(ctx context.Context,
+	lookup func(id string) (nucleo.RobotService, bool)) ([]Outcome, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var (
+		result  []Outcome
+		kept    []Command
+		blocked = make(map[string]bool)
+	)
+	now := clock.Or(q.Clock).Now()
+	for _, c := range q.commands {
+		robot, ok := lookup(c.Robot)
+		switch {
+		case now.After(c.Expires):
+			result = append(result,
+				Outcome{Command: c, Expired: true})
+			continue
+		case !ok || blocked[c.Robot] || ctx.Err() != nil:
+			kept = append(kept, c)
+			continue
+		}
+		resp, err := commands[c.Cmd](robot, c.Params)
+		if Unreachable(err) {
+			blocked[c.Robot] = true
+			kept = append(kept, c)
+			continue
+		}
+		result = append(result, Outcome{Command: c, Response: resp,
+			Err: err})
+	}
+	q.commands = kept
+	if err := q.save(); err != nil {
+		return result, err
+	}
+	return result, ctx.Err()
+}
+
+// Run replays the Queue every interval until ctx is cancelled, passing the
+// Outcomes of each Replay to report, if not nil
+func (q *Queue) Run(ctx context.Context, interval time.Duration,
+	lookup func(id string) (nucleo.RobotService, bool),
+	report func([]Outcome, error)) error {
+	t := clock.Or(q.Clock).NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C():
+		}
+		if len(q.Pending()) == 0 {
+			continue
+		}
+		outcomes, err := q.Replay(ctx, lookup)
+		if report != nil && (len(outcomes) > 0 || err != nil) {
+			report(outcomes, err)
+		}
+	}
+}
+
+// Unreachable reports whether err shows that a command could not be
+// delivered, rather than that it was delivered and refused
+func Unreachable(err error) bool {
+	var u *url.Error
+	return errors.As(err, &u) || nucleo.IsRobotOffline(err)
+}
+
+// save rewrites the Queue's file, replacing it atomically
+func (q *Queue) save() error {
+	if q.path == "" {
+		return nil
+	}
+	b, err := json.Marshal(q.commands)
+	if err != nil {
+		return err
+	}
+	tmp := q.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, fileMode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.path)
+}
+
+func newID() (string, error) {
+	b := make([]byte, idLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,66 @@
+package relay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Any matches every client, robot or command in a Rule
+const Any = "*"
+
+// Rule permits a client to send some commands to some robots
+type Rule struct {
+	// Client is the name of an access client, as given to access.Tokens,
+	// or Any
+	Client string `json:"client"`
+
+	// Robots are serial numbers, or Any
+	Robots []string `json:"robots"`
+
+	// Commands are Nucleo commands, e.g. "startCleaning", or Any
+	Commands []string `json:"commands"`
+}
+
+// ACL is a list of Rules. A message is relayed if any Rule permits it.
+type ACL []Rule
+
+// LoadACL reads an ACL from the JSON file at path, a list of Rules
+func LoadACL(path string) (ACL, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var result ACL
+	d := json.NewDecoder(f)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&result); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return result, nil
+}
+
+// Allows reports whether client may send cmd to the robot with serial
+func (a ACL) Allows(client, serial, cmd string) bool {
+	for _, r := range a {
+		if matches(r.Client, client) && matchesAny(r.Robots, serial) &&
+			matchesAny(r.Commands, cmd) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(pattern, s string) bool {
+	return pattern == Any || pattern == s
+}
+
+func matchesAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if matches(p, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+// relay lets devices which cannot hold a robot's secret key, such as
+// microcontrollers on the LAN, command robots. They POST unsigned messages in
+// the Nucleo format to the relay's /vendors/neato/robots/:serial/messages,
+// as they would to Nucleo itself. Each message is checked against an ACL,
+// signed with the robot's stored key and forwarded to Nucleo, and Nucleo's
+// response is returned unchanged.
+//
+// Devices authenticate with access tokens, so that the ACL can tell them
+// apart. The body is forwarded byte for byte, and so is signed as received.
+
+package relay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/richlj/neato/access"
+	"github.com/richlj/neato/beehive"
+	"github.com/richlj/neato/egress"
+	"github.com/richlj/neato/nucleo"
+)
+
+const (
+	maxMessageBytes = 64 << 10
+	defaultTimeout  = 45 * time.Second
+	robotsPrefix    = "/vendors/neato/robots/"
+	messagesSuffix  = "/messages"
+)
+
+// Relay is an http.Handler which signs and forwards messages to Nucleo
+type Relay struct {
+	// Robots returns the robots which may be commanded, with their secret
+	// keys, typically beehive.Session.ListRobots
+	Robots func() ([]beehive.Robot, error)
+
+	// Tokens authenticates devices. Without it, devices are anonymous and
+	// only Rules for Any client apply.
+	Tokens *access.Tokens
+
+	ACL ACL
+
+	// Endpoint is the base URL of Nucleo, as for nucleo.WithEndpoint. It
+	// defaults to the Nucleo API.
+	Endpoint *url.URL
+
+	// Timeout limits each forwarded request. It defaults to 45 seconds,
+	// enough for a sleeping robot to wake and start cleaning.
+	Timeout time.Duration
+
+	// Client sends the forwarded requests. It defaults to a client of the
+	// transport shared through the egress package.
+	Client *http.Client
+}
+
+// Handler returns the Relay, behind Tokens if they are set. Any valid token
+// may use the relay, and the ACL decides what it may do.
+func (rl *Relay) Handler() http.Handler {
+	if rl.Tokens == nil {
+		return rl
+	}
+	return rl.Tokens.Require(access.Reader, rl)
+}
+
+// ServeHTTP relays a message
+func (rl *Relay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	serial, ok := parsePath(req.URL.Path)
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body,
+		maxMessageBytes))
+	if err != nil {
+		http.Error(w, "message too large",
+			http.StatusRequestEntityTooLarge)
+		return
+	}
+	var msg struct {
+		Cmd string `json:"cmd"`
+	}
+	if err := json.Unmarshal(body, &msg); err != nil || msg.Cmd == "" {
+		http.Error(w, "invalid message", http.StatusBadRequest)
+		return
+	}
+	var client string
+	if t, ok := access.FromContext(req.Context()); ok {
+		client = t.Name
+	}
+	if !rl.ACL.Allows(client, serial, msg.Cmd) {
+		http.Error(w, "command not permitted", http.StatusForbidden)
+		return
+	}
+	key, err := rl.key(serial)
+	if err != nil {
+		http.Error(w, "robots unavailable", http.StatusBadGateway)
+		return
+	}
+	if key == "" {
+		http.NotFound(w, req)
+		return
+	}
+	resp, err := rl.forward(req.Context(), serial, key, body)
+	if err != nil {
+		http.Error(w, "nucleo unreachable", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// key returns the secret key of the robot with serial, or "" if it is not
+// one of the Robots
+func (rl *Relay) key(serial string) (string, error) {
+	robots, err := rl.Robots()
+	if err != nil {
+		return "", err
+	}
+	for _, r := range robots {
+		if r.Serial == serial {
+			return r.SecretKey, nil
+		}
+	}
+	return "", nil
+}
+
+// forward signs body for the robot with serial and sends it to Nucleo
+func (rl *Relay) forward(ctx context.Context, serial, key string,
+	body []byte) (*http.Response, error) {
+	timeout := rl.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	req, err := http.NewRequest(http.MethodPost, rl.url(serial),
+		bytes.NewReader(body))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header = nucleo.BuildHeaders(serial, key, nucleo.V1, time.Now(),
+		body)
+	client := rl.Client
+	if client == nil {
+		client = &http.Client{Transport: egress.Transport()}
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// url returns the Nucleo URL of the messages of the robot with serial
+func (rl *Relay) url(serial string) string {
+	p := path.Join("vendors/neato/robots", serial, "messages")
+	if rl.Endpoint == nil {
+		return (&url.URL{Scheme: "https", Host: nucleo.Addr,
+			Path: "/" + p}).String()
+	}
+	u := *rl.Endpoint
+	u.Path = path.Join(u.Path, p)
+	return u.String()
+}
+
+// parsePath returns the serial number in a messages path
+func parsePath(p string) (string, bool) {
+	if !strings.HasPrefix(p, robotsPrefix) ||
+		!strings.HasSuffix(p, messagesSuffix) {
+		return "", false
+	}
+	serial := strings.TrimSuffix(strings.TrimPrefix(p, robotsPrefix),
+		messagesSuffix)
+	if serial == "" || strings.Contains(serial, "/") {
+		return "", false
+	}
+	return serial, true
+}
+
+// cancelBody cancels a forwarded request's context once its response has
+// been read
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,249 @@
+// report summarises a week in the life of a robot, in HTML and plain text
+// suitable for an email body: the runs it performed, the area it covered,
+// the errors it reported, how its battery held up and any maintenance which
+// has fallen due. Runs come from the Beehive API and everything else from the
+// states recorded in a store.Store.
+
+package report
+
+import (
+	"bytes"
+	"context"
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/richlj/neato/beehive"
+	"github.com/richlj/neato/i18n"
+	"github.com/richlj/neato/store"
+	"github.com/richlj/neato/units"
+)
+
+const (
+	// Week is the period covered by a Weekly report
+	Week = 7 * 24 * time.Hour
+
+	dateFormat = "Mon 2 Jan"
+)
+
+var (
+	// maintenanceAlerts are the alerts which show maintenance is due
+	maintenanceAlerts = map[string]bool{
+		"ui_alert_brush_change":  true,
+		"ui_alert_filter_change": true,
+		"maint_brush_change":     true,
+		"maint_filter_change":    true,
+	}
+)
+
+// Weekly is the report for a single robot over the week starting at From
+type Weekly struct {
+	Robot       string
+	From        time.Time
+	To          time.Time
+	Runs        []*beehive.RunReport
+	Area        units.Area
+	Duration    units.Duration
+	Errors      []ErrorCount
+	Battery     []BatteryDay
+	Maintenance []string
+}
+
+// ErrorCount is the number of times a robot reported an error in the week
+type ErrorCount struct {
+	Code  string
+	Count int
+}
+
+// BatteryDay is the range of charge observed on a single day
+type BatteryDay struct {
+	Date time.Time
+	Min  int
+	Max  int
+}
+
+// NewWeekly builds the report for the named robot over the week starting at
+// from, from its runs and the states recorded for it. Runs and states
+// outside the week are ignored.
+func NewWeekly(robot string, from time.Time, runs []*beehive.RunReport,
+	states []store.State) *Weekly {
+	w := &Weekly{Robot: robot, From: from, To: from.Add(Week)}
+	for _, r := range runs {
+		if !w.contains(r.Start) {
+			continue
+		}
+		w.Runs = append(w.Runs, r)
+		w.Area += r.Area
+		w.Duration += r.Duration
+	}
+	sort.SliceStable(w.Runs, func(i, j int) bool {
+		return w.Runs[i].Start.Before(w.Runs[j].Start)
+	})
+	errors := make(map[string]int)
+	maintenance := make(map[string]bool)
+	days := make(map[time.Time]*BatteryDay)
+	for _, e := range states {
+		if !w.contains(e.Time) {
+			continue
+		}
+		if e.Error != "" {
+			errors[e.Error]++
+		}
+		if maintenanceAlerts[e.Alert] {
+			maintenance[e.Alert] = true
+		}
+		y, m, d := e.Time.Date()
+		date := time.Date(y, m, d, 0, 0, 0, 0, e.Time.Location())
+		if b, ok := days[date]; !ok {
+			days[date] = &BatteryDay{Date: date, Min: e.Charge,
+				Max: e.Charge}
+		} else if e.Charge < b.Min {
+			b.Min = e.Charge
+		} else if e.Charge > b.Max {
+			b.Max = e.Charge
+		}
+	}
+	for code, n := range errors {
+		w.Errors = append(w.Errors, ErrorCount{Code: code, Count: n})
+	}
+	sort.Slice(w.Errors, func(i, j int) bool {
+		if w.Errors[i].Count != w.Errors[j].Count {
+			return w.Errors[i].Count > w.Errors[j].Count
+		}
+		return w.Errors[i].Code < w.Errors[j].Code
+	})
+	for _, b := range days {
+		w.Battery = append(w.Battery, *b)
+	}
+	sort.Slice(w.Battery, func(i, j int) bool {
+		return w.Battery[i].Date.Before(w.Battery[j].Date)
+	})
+	for code := range maintenance {
+		w.Maintenance = append(w.Maintenance, code)
+	}
+	sort.Strings(w.Maintenance)
+	return w
+}
+
+// Load builds the report for the named robot over the week starting at from,
+// as NewWeekly does, from its runs and the states recorded in s for the robot
+// with serial
+func Load(ctx context.Context, s store.Store, robot, serial string,
+	from time.Time, runs []*beehive.RunReport) (*Weekly, error) {
+	states, err := s.States(ctx, store.Query{Robot: serial, Since: from,
+		Until: from.Add(Week)})
+	if err != nil {
+		return nil, err
+	}
+	return NewWeekly(robot, from, runs, states), nil
+}
+
+func (w *Weekly) contains(t time.Time) bool {
+	return !t.Before(w.From) && t.Before(w.To)
+}
+
+// Subject returns a subject line for the report
+func (w *Weekly) Subject() string {
+	return w.Robot + ": week of " + w.From.Format(dateFormat)
+}
+
+// Text writes the report as plain text
+func (w *Weekly) Text(out io.Writer) error {
+	return w.TextWith(out, i18n.DefaultFormatter)
+}
+
+// HTML writes the report as an HTML document
+func (w *Weekly) HTML(out io.Writer) error {
+	return w.HTMLWith(out, i18n.DefaultFormatter)
+}
+
+// TextWith writes the report as plain text, formatting values in the locale
+// of the supplied Formatter
+func (w *Weekly) TextWith(out io.Writer, f *i18n.Formatter) error {
+	t, err := template.New("text").Funcs(funcs(f)).Parse(textTemplate)
+	if err != nil {
+		return err
+	}
+	return t.Execute(out, w)
+}
+
+// HTMLWith writes the report as an HTML document, formatting values in the
+// locale of the supplied Formatter
+func (w *Weekly) HTMLWith(out io.Writer, f *i18n.Formatter) error {
+	t, err := htmltemplate.New("html").Funcs(funcs(f)).Parse(htmlTemplate)
+	if err != nil {
+		return err
+	}
+	return t.Execute(out, w)
+}
+
+// String returns the report as plain text
+func (w *Weekly) String() string {
+	var b bytes.Buffer
+	_ = w.Text(&b)
+	return b.String()
+}
+
+func funcs(f *i18n.Formatter) map[string]interface{} {
+	return map[string]interface{}{
+		"area":     f.Area,
+		"duration": f.Duration,
+		"alert":    f.Alert,
+		"date": func(t time.Time) string {
+			return t.Format(dateFormat)
+		},
+		"run": func(r *beehive.RunReport) string {
+			return r.SummaryWith(f)
+		},
+		"bar": func(n int) string {
+			return strings.Repeat("#", n/10)
+		},
+	}
+}
+
+const textTemplate = `{{.Robot}}: week of {{date .From}}
+
+Runs: {{len .Runs}}, {{area .Area}} in {{duration .Duration}}
+{{range .Runs}}  {{date .Start}}  {{run .}}
+{{end}}
+{{- if .Errors}}
+Errors:
+{{range .Errors}}  {{alert .Code}} ({{.Count}}x)
+{{end}}{{end}}
+{{- if .Battery}}
+Battery:
+{{range .Battery}}  {{date .Date}}  {{printf "%3d" .Min}}% - {{printf "%3d" .Max}}%  {{bar .Max}}
+{{end}}{{end}}
+{{- if .Maintenance}}
+Maintenance due:
+{{range .Maintenance}}  {{alert .}}
+{{end}}{{end}}`
+
+const htmlTemplate = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Robot}}: week of {{date .From}}</title></head>
+<body style="font-family: sans-serif">
+<h1>{{.Robot}}</h1>
+<p>Week of {{date .From}}: {{len .Runs}} runs, {{area .Area}} in {{duration .Duration}}</p>
+{{if .Runs}}<h2>Runs</h2>
+<table>
+{{range .Runs}}<tr><td>{{date .Start}}</td><td>{{run .}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Errors}}<h2>Errors</h2>
+<ul>
+{{range .Errors}}<li>{{alert .Code}} ({{.Count}}&times;)</li>
+{{end}}</ul>
+{{end}}{{if .Battery}}<h2>Battery</h2>
+<table>
+{{range .Battery}}<tr><td>{{date .Date}}</td><td>{{.Min}}% &ndash; {{.Max}}%</td><td><div style="background: #4a4; height: 1em; width: {{.Max}}px"></div></td></tr>
+{{end}}</table>
+{{end}}{{if .Maintenance}}<h2>Maintenance due</h2>
+<ul>
+{{range .Maintenance}}<li>{{alert .}}</li>
+{{end}}</ul>
+{{end}}</body>
+</html>
+`
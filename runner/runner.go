@@ -0,0 +1,182 @@
+// runner starts a cleaning run and supervises it until it finishes, reporting
+// progress along the way. Robots suspend long runs to recharge on their
+// base; most resume by themselves, but some models and firmwares wait for
+// the user, so a Runner can resume them once they have charged sufficiently.
+
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/richlj/neato/clock"
+	"github.com/richlj/neato/nucleo"
+)
+
+const (
+	// DefaultResumeCharge is the charge at which suspended runs are resumed
+	// when no ResumeCharge is set
+	DefaultResumeCharge = 80
+)
+
+// Stage identifies the progress reported by an Event
+type Stage string
+
+// The Stages of a run
+const (
+	Started   Stage = "started"
+	Suspended Stage = "suspended"
+	Charging  Stage = "charging"
+	Resumed   Stage = "resumed"
+	BinFull   Stage = "bin_full"
+	Finished  Stage = "finished"
+	Failed    Stage = "failed"
+)
+
+// Event reports the progress of a run
+type Event struct {
+	Stage   Stage
+	Time    time.Time
+	State   *nucleo.RobotState
+	Message string
+}
+
+// Robot is the subset of a nucleo.RobotService used by a Runner
+type Robot interface {
+	StartCleaning(a *nucleo.Params) (*nucleo.Response, error)
+	PauseCleaning(a *nucleo.Params) (*nucleo.Response, error)
+	ResumeCleaning(a *nucleo.Params) (*nucleo.Response, error)
+	State() (*nucleo.RobotState, error)
+}
+
+// Runner supervises the cleaning runs of a Robot, checking its state every
+// Interval. If AutoResume is set, a run suspended to recharge is resumed once
+// the charge reaches ResumeCharge. A full dust bin mid-run is reported as a
+// BinFull Event, and if PauseOnBinFull is set, the run is paused until the
+// bin is emptied and nucleo.Robot.ResumeAfterBinEmptied is called. Progress,
+// if not nil, receives an Event at each stage of the run.
+type Runner struct {
+	Interval       time.Duration
+	AutoResume     bool
+	ResumeCharge   int
+	PauseOnBinFull bool
+	Progress       func(Event)
+
+	// Clock paces the checks and dates Events. It defaults to clock.Real.
+	Clock clock.Clock
+
+	robot Robot
+}
+
+// New returns a Runner which checks robot every interval
+func New(robot Robot, interval time.Duration) *Runner {
+	return &Runner{Interval: interval, robot: robot}
+}
+
+// Run starts a cleaning run with the supplied parameters and supervises it
+// until it finishes
+func (r *Runner) Run(ctx context.Context, a *nucleo.Params) error {
+	if _, err := r.robot.StartCleaning(a); err != nil {
+		return err
+	}
+	r.report(Started, nil, "")
+	return r.supervise(ctx, false)
+}
+
+// Supervise follows a run already in progress until it finishes
+func (r *Runner) Supervise(ctx context.Context) error {
+	return r.supervise(ctx, true)
+}
+
+func (r *Runner) supervise(ctx context.Context, started bool) error {
+	t := clock.Or(r.Clock).NewTicker(r.Interval)
+	defer t.Stop()
+	suspended, binFull := false, false
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C():
+		}
+		s, err := r.robot.State()
+		if err != nil {
+			return err
+		}
+		full := s.DustbinFull()
+		if full && !binFull && started {
+			if err := r.binFull(s); err != nil {
+				return err
+			}
+		}
+		binFull = full
+		switch {
+		case s.State == nucleo.StateError && !full:
+			r.report(Failed, s, s.Error)
+			return fmt.Errorf("robot reported %s", s.Error)
+		case s.Action == nucleo.ActionSuspended:
+			started = true
+			if !suspended {
+				suspended = true
+				r.report(Suspended, s, "")
+			}
+			r.report(Charging, s, fmt.Sprintf("%d%%", s.Charge))
+			if err := r.resume(s); err != nil {
+				return err
+			}
+		case s.State == nucleo.StateIdle:
+			if started {
+				r.report(Finished, s, "")
+				return nil
+			}
+		default:
+			started = true
+			if suspended {
+				suspended = false
+				r.report(Resumed, s, "")
+			}
+		}
+	}
+}
+
+// binFull reports a full dust bin, pausing the run if PauseOnBinFull is set
+func (r *Runner) binFull(s *nucleo.RobotState) error {
+	r.report(BinFull, s, "")
+	if !r.PauseOnBinFull || s.State != nucleo.StateBusy {
+		return nil
+	}
+	_, err := r.robot.PauseCleaning(nil)
+	return err
+}
+
+// resume resumes a suspended run if AutoResume is set and the Robot has
+// charged sufficiently. A rejection is reported and retried at the next
+// check, since the Robot may not yet be ready.
+func (r *Runner) resume(s *nucleo.RobotState) error {
+	if !r.AutoResume || s.Charge < r.resumeCharge() {
+		return nil
+	}
+	_, err := r.robot.ResumeCleaning(nil)
+	var rejected *nucleo.CommandRejectedError
+	if errors.As(err, &rejected) {
+		r.report(Charging, s, err.Error())
+		return nil
+	}
+	return err
+}
+
+func (r *Runner) resumeCharge() int {
+	if r.ResumeCharge > 0 {
+		return r.ResumeCharge
+	}
+	return DefaultResumeCharge
+}
+
+func (r *Runner) report(stage Stage, s *nucleo.RobotState, msg string) {
+	if r.Progress == nil {
+		return
+	}
+	r.Progress(Event{Stage: stage, Time: clock.Or(r.Clock).Now(), State: s,
+		Message: msg})
+}
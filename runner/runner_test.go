@@ -0,0 +1,153 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/richlj/neato/nucleo"
+)
+
+// fakeRobot reports a scripted sequence of states, repeating the last
+type fakeRobot struct {
+	states    []*nucleo.RobotState
+	rejects   int
+	pauses    int
+	resumes   int
+	cancelled func()
+}
+
+func (f *fakeRobot) StartCleaning(*nucleo.Params) (*nucleo.Response, error) {
+	return &nucleo.Response{}, nil
+}
+
+func (f *fakeRobot) PauseCleaning(*nucleo.Params) (*nucleo.Response, error) {
+	f.pauses++
+	return &nucleo.Response{}, nil
+}
+
+func (f *fakeRobot) ResumeCleaning(*nucleo.Params) (*nucleo.Response,
+	error) {
+	if f.rejects > 0 {
+		f.rejects--
+		return nil, &nucleo.CommandRejectedError{Cmd: "resumeCleaning",
+			Reason: "not ready"}
+	}
+	f.resumes++
+	return &nucleo.Response{}, nil
+}
+
+func (f *fakeRobot) State() (*nucleo.RobotState, error) {
+	s := f.states[0]
+	if len(f.states) > 1 {
+		f.states = f.states[1:]
+	} else if f.cancelled != nil {
+		f.cancelled()
+	}
+	return s, nil
+}
+
+func state(s nucleo.State, a nucleo.Action, charge int,
+	alert string) *nucleo.RobotState {
+	return &nucleo.RobotState{State: s, Action: a, Charge: charge,
+		Alert: alert}
+}
+
+func states(s ...*nucleo.RobotState) []*nucleo.RobotState {
+	return s
+}
+
+func TestRun(t *testing.T) {
+	const (
+		busy     = nucleo.StateBusy
+		cleaning = nucleo.ActionHouseCleaning
+		suspend  = nucleo.ActionSuspended
+	)
+	var (
+		idle      = state(nucleo.StateIdle, nucleo.ActionNone, 100, "")
+		run       = state(busy, cleaning, 90, "")
+		low       = state(busy, suspend, 30, "")
+		charged   = state(busy, suspend, 85, "")
+		full      = state(busy, cleaning, 60, "dustbin_full")
+		fullError = &nucleo.RobotState{State: nucleo.StateError,
+			Error: "ui_error_dust_bin_full"}
+		stuck = &nucleo.RobotState{State: nucleo.StateError,
+			Error: "ui_error_brush_stuck"}
+	)
+	tests := []struct {
+		name       string
+		states     []*nucleo.RobotState
+		autoResume bool
+		pauseFull  bool
+		rejects    int
+		want       []Stage
+		resumes    int
+		pauses     int
+		fails      bool
+	}{
+		{name: "finished", states: states(run, idle),
+			want: []Stage{Started, Finished}},
+		{name: "idle before starting", states: states(idle, run, idle),
+			want: []Stage{Started, Finished}},
+		{name: "resumes itself",
+			states: states(run, low, charged, run, idle),
+			want: []Stage{Started, Suspended, Charging, Charging,
+				Resumed, Finished}},
+		{name: "auto resume", autoResume: true,
+			states: states(run, low, charged, run, idle),
+			want: []Stage{Started, Suspended, Charging, Charging,
+				Resumed, Finished},
+			resumes: 1},
+		{name: "auto resume rejected", autoResume: true, rejects: 1,
+			states: states(run, charged, charged, run, idle),
+			want: []Stage{Started, Suspended, Charging, Charging,
+				Charging, Resumed, Finished},
+			resumes: 1},
+		{name: "bin full", states: states(run, full, idle),
+			want: []Stage{Started, BinFull, Finished}},
+		{name: "bin full paused", pauseFull: true,
+			states: states(run, full, full, idle),
+			want:   []Stage{Started, BinFull, Finished},
+			pauses: 1},
+		{name: "bin full error", states: states(run, fullError, idle),
+			want: []Stage{Started, BinFull, Finished}},
+		{name: "error", states: states(run, stuck),
+			want: []Stage{Started, Failed}, fails: true},
+	}
+	for _, tt := range tests {
+		robot := &fakeRobot{states: tt.states, rejects: tt.rejects}
+		var got []Stage
+		r := New(robot, time.Millisecond)
+		r.AutoResume = tt.autoResume
+		r.PauseOnBinFull = tt.pauseFull
+		r.Progress = func(e Event) {
+			got = append(got, e.Stage)
+		}
+		err := r.Run(context.Background(), nil)
+		if (err != nil) != tt.fails {
+			t.Errorf("%s: Run() = %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: stages %v, want %v", tt.name, got,
+				tt.want)
+		}
+		if robot.resumes != tt.resumes || robot.pauses != tt.pauses {
+			t.Errorf("%s: resumed %d and paused %d times, "+
+				"want %d and %d", tt.name, robot.resumes,
+				robot.pauses, tt.resumes, tt.pauses)
+		}
+	}
+}
+
+func TestSuperviseCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	robot := &fakeRobot{states: []*nucleo.RobotState{
+		state(nucleo.StateBusy, nucleo.ActionHouseCleaning, 90, ""),
+	}, cancelled: cancel}
+	err := New(robot, time.Millisecond).Supervise(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Supervise() = %v, want %v", err, context.Canceled)
+	}
+}
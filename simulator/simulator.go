@@ -0,0 +1,510 @@
+// simulator provides fake robots that speak the Nucleo wire protocol, so that
+// integrations can be exercised end-to-end and demonstrated without hardware.
+//
+// A Server validates the signature of each request in the same way as the
+// Nucleo API, and passes the command to the addressed Robot, which maintains
+// a simple state machine: idle on the base, cleaning, returning to base and
+// docked again, draining and recharging its battery as time passes and
+// raising occasional alerts.
+
+package simulator
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/richlj/neato/clock"
+	"github.com/richlj/neato/profiles"
+)
+
+const (
+	messagesPrefix = "/vendors/neato/robots/"
+	messagesSuffix = "/messages"
+	authScheme     = "NEATOAPP "
+
+	stateIdle   = 1
+	stateBusy   = 2
+	statePaused = 3
+	stateError  = 4
+
+	actionNone          = 0
+	actionHouseCleaning = 1
+	actionSpotCleaning  = 2
+	actionDocking       = 4
+	actionSuspended     = 6
+
+	categorySpot = 3
+
+	drainPerMinute    = 1.0
+	chargePerMinute   = 2.0
+	returnTime        = time.Minute
+	lowBatteryLevel   = 15
+	resumeChargeLevel = 80
+
+	defaultModel = "BotVacConnected"
+)
+
+var (
+	// alerts are raised at random whilst the Robot is cleaning
+	alerts = []string{"ui_alert_dust_bin_full", "ui_alert_brush_change",
+		"ui_alert_filter_change"}
+
+	// wifiNetworks is reported by every simulated Robot with the wifi
+	// service
+	wifiNetworks = json.RawMessage(`{"networks":[{"ssid":"simulated",` +
+		`"security":"wpa2","rssi":-52,"connected":true}]}`)
+
+	// services maps commands to the service a Profile must offer for the
+	// command to be accepted
+	services = map[string]string{
+		"findMe":                        "findMe",
+		"getGeneralInfo":                "generalInfo",
+		"getPreferences":                "preferences",
+		"setPreferences":                "preferences",
+		"getSchedule":                   "schedule",
+		"setSchedule":                   "schedule",
+		"enableSchedule":                "schedule",
+		"disableSchedule":               "schedule",
+		"getMapBoundaries":              "maps",
+		"setMapBoundaries":              "maps",
+		"startPersistentMapExploration": "maps",
+		"getConfiguredWifiNetworks":     "wifi",
+		"startWifiSetupMode":            "wifi",
+	}
+)
+
+// Server serves the Nucleo messages endpoint for a set of simulated Robots
+type Server struct {
+	mu     sync.Mutex
+	robots map[string]*Robot
+}
+
+// NewServer returns a Server for the supplied Robots
+func NewServer(robots ...*Robot) *Server {
+	s := &Server{robots: make(map[string]*Robot)}
+	for _, r := range robots {
+		s.Add(r)
+	}
+	return s
+}
+
+// Add makes a Robot available on the Server
+func (s *Server) Add(r *Robot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.robots[strings.ToLower(r.Serial)] = r
+}
+
+// ServeHTTP handles requests to /vendors/neato/robots/{serial}/messages
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !strings.HasPrefix(req.URL.Path, messagesPrefix) ||
+		!strings.HasSuffix(req.URL.Path, messagesSuffix) {
+		http.NotFound(w, req)
+		return
+	}
+	serial := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path,
+		messagesPrefix), messagesSuffix)
+	s.mu.Lock()
+	r, ok := s.robots[strings.ToLower(serial)]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	r.ServeHTTP(w, req)
+}
+
+// Robot is a simulated robot. Its exported fields configure the simulation
+// and should not be modified once it is serving requests.
+type Robot struct {
+	Serial    string
+	SecretKey string
+	Profile   *profiles.Profile
+
+	// AlertProbability is the chance of an alert being raised on each
+	// request whilst the Robot is cleaning
+	AlertProbability float64
+
+	// MapID is the persistent map the Robot reports as active, if any
+	MapID string
+
+	// Clock advances the simulation. It defaults to clock.Real.
+	Clock clock.Clock
+
+	mu         sync.Mutex
+	rand       *rand.Rand
+	updated    time.Time
+	state      int
+	action     int
+	category   int
+	mode       int
+	charge     float64
+	docked     bool
+	returning  time.Time
+	suspended  bool
+	alert      string
+	scheduleOn bool
+	// resumeAction is the cleaning action of a suspended run
+	resumeAction int
+	// preferences replaces the Profile's preferences once set
+	preferences json.RawMessage
+}
+
+// New returns a simulated Botvac Connected, idle and fully charged on its base
+func New(serial, secretKey string) *Robot {
+	p, _ := profiles.Lookup(defaultModel)
+	return NewModel(p, serial, secretKey)
+}
+
+// NewModel returns a simulated Robot which behaves as the model described by
+// the supplied Profile, idle and fully charged on its base
+func NewModel(p *profiles.Profile, serial, secretKey string) *Robot {
+	seed := rand.NewSource(time.Now().UnixNano())
+	return &Robot{
+		Serial:           serial,
+		SecretKey:        secretKey,
+		Profile:          p,
+		AlertProbability: 0.01,
+		rand:             rand.New(seed),
+		state:            stateIdle,
+		charge:           100,
+		docked:           true,
+	}
+}
+
+type request struct {
+	ReqID  json.RawMessage `json:"reqId"`
+	Cmd    string          `json:"cmd"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+type params struct {
+	Category int `json:"category"`
+	Mode     int `json:"mode"`
+}
+
+type response struct {
+	Version           int               `json:"version"`
+	ReqID             json.RawMessage   `json:"reqId"`
+	Result            string            `json:"result"`
+	Data              interface{}       `json:"data"`
+	State             int               `json:"state"`
+	Action            int               `json:"action"`
+	Error             *string           `json:"error"`
+	Alert             *string           `json:"alert"`
+	Cleaning          cleaning          `json:"cleaning"`
+	Details           details           `json:"details"`
+	AvailableCommands availableCommands `json:"availableCommands"`
+	AvailableServices map[string]string `json:"availableServices"`
+	Meta              meta              `json:"meta"`
+}
+
+type cleaning struct {
+	Category int    `json:"category"`
+	Mode     int    `json:"mode"`
+	Modifier int    `json:"modifier"`
+	MapID    string `json:"mapId"`
+}
+
+type details struct {
+	IsCharging        bool `json:"isCharging"`
+	IsDocked          bool `json:"isDocked"`
+	IsScheduleEnabled bool `json:"isScheduleEnabled"`
+	DockHasBeenSeen   bool `json:"dockHasBeenSeen"`
+	Charge            int  `json:"charge"`
+}
+
+type availableCommands struct {
+	Start    bool `json:"start"`
+	Stop     bool `json:"stop"`
+	Pause    bool `json:"pause"`
+	Resume   bool `json:"resume"`
+	GoToBase bool `json:"goToBase"`
+}
+
+type meta struct {
+	ModelName string `json:"modelName"`
+	Firmware  string `json:"firmware"`
+}
+
+// ServeHTTP handles a single Nucleo message addressed to the Robot
+func (r *Robot) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !r.validSignature(req, body) {
+		w.WriteHeader(http.StatusUnauthorized)
+		writeJSON(w, map[string]string{"message": "Could not find " +
+			"robot with serial and valid signature"})
+		return
+	}
+	var a request
+	if err := json.Unmarshal(body, &a); err != nil {
+		writeJSON(w, &response{Version: 1, Result: "invalid_json"})
+		return
+	}
+	writeJSON(w, r.handle(&a))
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// validSignature checks the Authorization header against the HMAC of the
+// lower-cased serial, the Date header and the request body as received
+func (r *Robot) validSignature(req *http.Request, body []byte) bool {
+	a := req.Header.Get("Authorization")
+	if !strings.HasPrefix(a, authScheme) {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(a, authScheme))
+	if err != nil {
+		return false
+	}
+	h := hmac.New(sha256.New, []byte(r.SecretKey))
+	fmt.Fprintf(h, "%s\n%s\n%s", strings.ToLower(r.Serial),
+		req.Header.Get("Date"), body)
+	return hmac.Equal(got, h.Sum(nil))
+}
+
+func (r *Robot) handle(a *request) *response {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.advance()
+	result := r.command(a)
+	resp := r.response()
+	resp.ReqID = a.ReqID
+	resp.Result = result
+	if result == "ok" {
+		resp.Data = r.data(a.Cmd)
+	}
+	return resp
+}
+
+// data returns the data payload of the Profile for read commands
+func (r *Robot) data(cmd string) interface{} {
+	var d json.RawMessage
+	switch cmd {
+	case "getGeneralInfo":
+		d = r.Profile.GeneralInfo
+	case "getPreferences":
+		d = r.Profile.Preferences
+		if r.preferences != nil {
+			d = r.preferences
+		}
+	case "getLocalStats":
+		d = r.Profile.LocalStats
+	case "getConfiguredWifiNetworks":
+		d = wifiNetworks
+	}
+	if len(d) == 0 {
+		return struct{}{}
+	}
+	return d
+}
+
+// supports reports whether the Profile offers the service required by cmd
+func (r *Robot) supports(a *request) bool {
+	if s, ok := services[a.Cmd]; ok && !r.Profile.Supports(s) {
+		return false
+	}
+	if a.Cmd == "getLocalStats" && len(r.Profile.LocalStats) == 0 {
+		return false
+	}
+	return true
+}
+
+// command applies a to the state machine and returns the Nucleo result
+func (r *Robot) command(a *request) string {
+	if !r.supports(a) {
+		return "command_not_found"
+	}
+	switch a.Cmd {
+	case "getRobotState", "findMe", "getGeneralInfo", "getLocalStats",
+		"getPreferences", "getSchedule", "setSchedule",
+		"getRobotInfo", "getMapBoundaries", "setMapBoundaries",
+		"getConfiguredWifiNetworks":
+		return "ok"
+	case "startCleaning":
+		if r.state != stateIdle {
+			return "command_rejected"
+		}
+		var p params
+		if len(a.Params) > 0 {
+			if err := json.Unmarshal(a.Params, &p); err != nil {
+				return "bad_request"
+			}
+		}
+		action := actionHouseCleaning
+		if p.Category == categorySpot {
+			if !r.Profile.Supports("spotCleaning") {
+				return "command_not_found"
+			}
+			action = actionSpotCleaning
+		}
+		r.state, r.action = stateBusy, action
+		r.category, r.mode = p.Category, p.Mode
+		r.docked = false
+	case "pauseCleaning":
+		if r.state != stateBusy {
+			return "command_rejected"
+		}
+		r.state = statePaused
+	case "resumeCleaning":
+		switch {
+		case r.state == statePaused:
+			r.state = stateBusy
+		case r.action == actionSuspended:
+			r.resume()
+		default:
+			return "command_rejected"
+		}
+	case "stopCleaning":
+		if r.state != stateBusy && r.state != statePaused {
+			return "command_rejected"
+		}
+		r.state, r.action = stateIdle, actionNone
+		r.suspended = false
+	case "sendToBase":
+		if r.docked || r.state == stateError {
+			return "command_rejected"
+		}
+		r.returnToBase(false)
+	case "setPreferences":
+		var p map[string]json.RawMessage
+		if err := json.Unmarshal(a.Params, &p); err != nil || p == nil {
+			return "bad_request"
+		}
+		r.preferences = a.Params
+	case "dismissCurrentAlert":
+		r.alert = ""
+	case "startWifiSetupMode":
+		if !r.docked {
+			return "command_rejected"
+		}
+	case "enableSchedule":
+		r.scheduleOn = true
+	case "disableSchedule":
+		r.scheduleOn = false
+	default:
+		return "command_not_found"
+	}
+	return "ok"
+}
+
+// returnToBase sends the Robot to its base. If suspend is set, the run
+// resumes once the Robot has recharged.
+func (r *Robot) returnToBase(suspend bool) {
+	if suspend {
+		r.resumeAction = r.action
+	}
+	r.state, r.action = stateBusy, actionDocking
+	r.suspended = suspend
+	r.returning = clock.Or(r.Clock).Now().Add(returnTime)
+}
+
+// resume continues a suspended run
+func (r *Robot) resume() {
+	r.suspended = false
+	r.docked = false
+	r.state, r.action = stateBusy, r.resumeAction
+}
+
+// advance moves the simulation on to the current time, draining or charging
+// the battery and completing any return to base. A run suspended to recharge
+// stays busy, with the suspended action, until it resumes.
+func (r *Robot) advance() {
+	now := clock.Or(r.Clock).Now()
+	if r.updated.IsZero() {
+		r.updated = now
+	}
+	minutes := now.Sub(r.updated).Minutes()
+	r.updated = now
+	switch {
+	case r.docked:
+		r.charge += minutes * chargePerMinute
+	case r.state == stateBusy:
+		r.charge -= minutes * drainPerMinute
+	}
+	if r.charge > 100 {
+		r.charge = 100
+	}
+	if r.charge < 0 {
+		r.charge = 0
+	}
+	if r.action == actionDocking && !now.Before(r.returning) {
+		r.docked = true
+		r.state, r.action = stateIdle, actionNone
+		if r.suspended {
+			r.state, r.action = stateBusy, actionSuspended
+		}
+	}
+	cleaning := r.state == stateBusy && r.action != actionDocking &&
+		r.action != actionSuspended
+	if cleaning && r.charge <= lowBatteryLevel {
+		r.returnToBase(true)
+		return
+	}
+	if r.action == actionSuspended && r.charge >= resumeChargeLevel {
+		r.resume()
+	}
+	if cleaning && r.alert == "" && r.rand.Float64() < r.AlertProbability {
+		r.alert = alerts[r.rand.Intn(len(alerts))]
+	}
+}
+
+func (r *Robot) response() *response {
+	active := r.state == stateBusy || r.state == statePaused
+	resp := &response{
+		Version: 1,
+		Data:    struct{}{},
+		State:   r.state,
+		Action:  r.action,
+		Cleaning: cleaning{
+			Category: r.category,
+			Mode:     r.mode,
+			Modifier: 1,
+			MapID:    r.MapID,
+		},
+		Details: details{
+			IsCharging:        r.docked && r.charge < 100,
+			IsDocked:          r.docked,
+			IsScheduleEnabled: r.scheduleOn,
+			DockHasBeenSeen:   true,
+			Charge:            int(r.charge),
+		},
+		AvailableCommands: availableCommands{
+			Start:    r.state == stateIdle,
+			Stop:     active,
+			Pause:    r.state == stateBusy,
+			Resume:   r.state == statePaused,
+			GoToBase: !r.docked && r.state != stateError,
+		},
+		AvailableServices: r.Profile.AvailableServices,
+		Meta: meta{
+			ModelName: r.Profile.Model,
+			Firmware:  r.Profile.Firmware,
+		},
+	}
+	if r.alert != "" {
+		alert := r.alert
+		resp.Alert = &alert
+	}
+	return resp
+}
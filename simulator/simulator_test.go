@@ -0,0 +1,175 @@
+package simulator
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/richlj/neato/clock"
+	"github.com/richlj/neato/profiles"
+)
+
+func newTestRobot(t *testing.T, model string) (*Robot, *clock.Fake) {
+	t.Helper()
+	p, ok := profiles.Lookup(model)
+	if !ok {
+		t.Fatalf("no profile for %s", model)
+	}
+	c := clock.NewFake(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	r := NewModel(p, "OPS01234-0123456789AB", "key")
+	r.AlertProbability = 0
+	r.Clock = c
+	return r, c
+}
+
+func send(r *Robot, cmd, params string) *response {
+	a := &request{ReqID: json.RawMessage(`"1"`), Cmd: cmd}
+	if params != "" {
+		a.Params = json.RawMessage(params)
+	}
+	return r.handle(a)
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		name   string
+		cmds   []string
+		result string
+		state  int
+		action int
+	}{
+		{"idle", []string{"getRobotState"}, "ok", stateIdle,
+			actionNone},
+		{"start", []string{"startCleaning"}, "ok", stateBusy,
+			actionHouseCleaning},
+		{"start twice", []string{"startCleaning", "startCleaning"},
+			"command_rejected", stateBusy, actionHouseCleaning},
+		{"pause", []string{"startCleaning", "pauseCleaning"}, "ok",
+			statePaused, actionHouseCleaning},
+		{"resume", []string{"startCleaning", "pauseCleaning",
+			"resumeCleaning"}, "ok", stateBusy,
+			actionHouseCleaning},
+		{"resume idle", []string{"resumeCleaning"}, "command_rejected",
+			stateIdle, actionNone},
+		{"stop", []string{"startCleaning", "stopCleaning"}, "ok",
+			stateIdle, actionNone},
+		{"send to base", []string{"startCleaning", "sendToBase"}, "ok",
+			stateBusy, actionDocking},
+		{"send docked to base", []string{"sendToBase"},
+			"command_rejected", stateIdle, actionNone},
+		{"unknown", []string{"selfDestruct"}, "command_not_found",
+			stateIdle, actionNone},
+	}
+	for _, tt := range tests {
+		r, _ := newTestRobot(t, "BotVacD7Connected")
+		var resp *response
+		for _, cmd := range tt.cmds {
+			resp = send(r, cmd, "")
+		}
+		if resp.Result != tt.result || resp.State != tt.state ||
+			resp.Action != tt.action {
+			t.Errorf("%s: got %s, state %d, action %d; want %s, "+
+				"state %d, action %d", tt.name, resp.Result,
+				resp.State, resp.Action, tt.result, tt.state,
+				tt.action)
+		}
+	}
+}
+
+func TestRechargeMidRun(t *testing.T) {
+	r, c := newTestRobot(t, "BotVacD7Connected")
+	if resp := send(r, "startCleaning", ""); resp.Result != "ok" {
+		t.Fatalf("startCleaning: %s", resp.Result)
+	}
+	steps := []struct {
+		after  time.Duration
+		state  int
+		action int
+		docked bool
+	}{
+		// 85 minutes drains the battery to the low level
+		{85 * time.Minute, stateBusy, actionDocking, false},
+		{returnTime, stateBusy, actionSuspended, true},
+		{10 * time.Minute, stateBusy, actionSuspended, true},
+		// recharged to the resume level
+		{30 * time.Minute, stateBusy, actionHouseCleaning, false},
+	}
+	for i, s := range steps {
+		c.Advance(s.after)
+		resp := send(r, "getRobotState", "")
+		docked := resp.Details.IsDocked
+		if resp.State != s.state || resp.Action != s.action ||
+			docked != s.docked {
+			t.Errorf("step %d: state %d, action %d, docked %t; "+
+				"want %d, %d, %t", i, resp.State, resp.Action,
+				docked, s.state, s.action, s.docked)
+		}
+	}
+}
+
+func TestResumeSuspended(t *testing.T) {
+	r, c := newTestRobot(t, "BotVacD7Connected")
+	send(r, "startCleaning", "")
+	c.Advance(85 * time.Minute)
+	send(r, "getRobotState", "")
+	c.Advance(returnTime)
+	resp := send(r, "getRobotState", "")
+	if resp.Action != actionSuspended {
+		t.Fatalf("action %d, want suspended", resp.Action)
+	}
+	resp = send(r, "resumeCleaning", "")
+	if resp.Result != "ok" || resp.State != stateBusy ||
+		resp.Action != actionHouseCleaning {
+		t.Errorf("resumeCleaning: %s, state %d, action %d", resp.Result,
+			resp.State, resp.Action)
+	}
+}
+
+func TestStopSuspended(t *testing.T) {
+	r, c := newTestRobot(t, "BotVacD7Connected")
+	send(r, "startCleaning", "")
+	c.Advance(85 * time.Minute)
+	send(r, "getRobotState", "")
+	c.Advance(returnTime)
+	if resp := send(r, "stopCleaning", ""); resp.Result != "ok" {
+		t.Fatalf("stopCleaning: %s", resp.Result)
+	}
+	c.Advance(time.Hour)
+	resp := send(r, "getRobotState", "")
+	if resp.State != stateIdle || resp.Action != actionNone {
+		t.Errorf("state %d, action %d after stopping a suspended run",
+			resp.State, resp.Action)
+	}
+}
+
+func TestStartSpotUnsupported(t *testing.T) {
+	p, _ := profiles.Lookup("BotVacD7Connected")
+	noSpot := *p
+	noSpot.AvailableServices = make(map[string]string)
+	for k, v := range p.AvailableServices {
+		if k != "spotCleaning" {
+			noSpot.AvailableServices[k] = v
+		}
+	}
+	tests := []struct {
+		profile *profiles.Profile
+		result  string
+		state   int
+		action  int
+		docked  bool
+	}{
+		{p, "ok", stateBusy, actionSpotCleaning, false},
+		{&noSpot, "command_not_found", stateIdle, actionNone, true},
+	}
+	for i, tt := range tests {
+		r := NewModel(tt.profile, "OPS01234-0123456789AB", "key")
+		r.AlertProbability = 0
+		resp := send(r, "startCleaning", `{"category":3}`)
+		docked := resp.Details.IsDocked
+		if resp.Result != tt.result || resp.State != tt.state ||
+			resp.Action != tt.action || docked != tt.docked {
+			t.Errorf("%d: got %s, state %d, action %d, docked %t",
+				i, resp.Result, resp.State, resp.Action, docked)
+		}
+	}
+}
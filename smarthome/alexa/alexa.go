@@ -0,0 +1,319 @@
+// alexa implements an Alexa Smart Home skill's fulfillment for robots, using
+// version 3 of the Smart Home API. Each robot is discovered as a vacuum
+// cleaner endpoint whose PowerController starts cleaning when turned on and
+// sends the robot back to its base when turned off.
+//
+// Handle processes a single directive, as received by a skill's Lambda
+// function, and Handler serves directives POSTed over HTTP for self-hosted
+// setups behind a forwarding Lambda.
+
+package alexa
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/richlj/neato/nucleo"
+	"github.com/richlj/neato/smarthome"
+)
+
+const (
+	payloadVersion   = "3"
+	manufacturerName = "Neato Robotics"
+	displayCategory  = "VACUUM_CLEANER"
+	uncertainty      = 500
+
+	namespaceAlexa     = "Alexa"
+	namespaceDiscovery = "Alexa.Discovery"
+	namespacePower     = "Alexa.PowerController"
+	namespaceHealth    = "Alexa.EndpointHealth"
+
+	errorUnreachable    = "ENDPOINT_UNREACHABLE"
+	errorNoSuchEndpoint = "NO_SUCH_ENDPOINT"
+	errorInvalid        = "INVALID_DIRECTIVE"
+	errorInternal       = "INTERNAL_ERROR"
+)
+
+// Message is a directive sent by Alexa or an event sent in reply
+type Message struct {
+	Directive *Body    `json:"directive,omitempty"`
+	Event     *Body    `json:"event,omitempty"`
+	Context   *Context `json:"context,omitempty"`
+}
+
+// Body is the content of a directive or event
+type Body struct {
+	Header   Header          `json:"header"`
+	Endpoint *Endpoint       `json:"endpoint,omitempty"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// Header identifies a directive or event
+type Header struct {
+	Namespace        string `json:"namespace"`
+	Name             string `json:"name"`
+	PayloadVersion   string `json:"payloadVersion"`
+	MessageID        string `json:"messageId"`
+	CorrelationToken string `json:"correlationToken,omitempty"`
+}
+
+// Endpoint identifies the device a directive or event concerns
+type Endpoint struct {
+	EndpointID string          `json:"endpointId"`
+	Scope      json.RawMessage `json:"scope,omitempty"`
+}
+
+// Context reports the state of an endpoint
+type Context struct {
+	Properties []Property `json:"properties"`
+}
+
+// Property is a single reported state value
+type Property struct {
+	Namespace                 string      `json:"namespace"`
+	Name                      string      `json:"name"`
+	Value                     interface{} `json:"value"`
+	TimeOfSample              time.Time   `json:"timeOfSample"`
+	UncertaintyInMilliseconds int         `json:"uncertaintyInMilliseconds"`
+}
+
+type discoveredEndpoint struct {
+	EndpointID        string       `json:"endpointId"`
+	ManufacturerName  string       `json:"manufacturerName"`
+	FriendlyName      string       `json:"friendlyName"`
+	Description       string       `json:"description"`
+	DisplayCategories []string     `json:"displayCategories"`
+	Capabilities      []capability `json:"capabilities"`
+}
+
+type capability struct {
+	Type       string      `json:"type"`
+	Interface  string      `json:"interface"`
+	Version    string      `json:"version"`
+	Properties *properties `json:"properties,omitempty"`
+}
+
+type properties struct {
+	Supported   []supported `json:"supported"`
+	Retrievable bool        `json:"retrievable"`
+	Proactive   bool        `json:"proactivelyReported"`
+}
+
+type supported struct {
+	Name string `json:"name"`
+}
+
+type errorPayload struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// Handler fulfils Alexa Smart Home directives for a set of Devices
+type Handler struct {
+	Devices smarthome.Devices
+}
+
+// Handle processes a directive and returns the event to send in reply
+func (h *Handler) Handle(m *Message) *Message {
+	if m.Directive == nil {
+		return errorEvent(nil, errorInvalid, "no directive")
+	}
+	d := m.Directive
+	switch {
+	case d.Header.Namespace == namespaceDiscovery &&
+		d.Header.Name == "Discover":
+		return h.discover(d)
+	case d.Header.Namespace == namespaceAlexa &&
+		d.Header.Name == "ReportState":
+		return h.reportState(d)
+	case d.Header.Namespace == namespacePower:
+		return h.power(d)
+	}
+	return errorEvent(d, errorInvalid, fmt.Sprintf("unsupported directive "+
+		"%s.%s", d.Header.Namespace, d.Header.Name))
+}
+
+// ServeHTTP accepts a directive POSTed as JSON and replies with the event
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	b, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var m Message
+	if err := json.Unmarshal(b, &m); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.Handle(&m))
+}
+
+func (h *Handler) discover(d *Body) *Message {
+	var endpoints []discoveredEndpoint
+	capabilities := []capability{
+		interfaceCapability(namespaceAlexa, ""),
+		interfaceCapability(namespacePower, "powerState"),
+		interfaceCapability(namespaceHealth, "connectivity"),
+	}
+	for _, device := range h.Devices.All() {
+		endpoints = append(endpoints, discoveredEndpoint{
+			EndpointID:        device.ID,
+			ManufacturerName:  manufacturerName,
+			FriendlyName:      device.Name,
+			Description:       "Neato robot vacuum",
+			DisplayCategories: []string{displayCategory},
+			Capabilities:      capabilities,
+		})
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"endpoints": endpoints,
+	})
+	return &Message{Event: &Body{
+		Header:  header(namespaceDiscovery, "Discover.Response", d),
+		Payload: payload,
+	}}
+}
+
+func (h *Handler) reportState(d *Body) *Message {
+	device, s, errEvent := h.state(d)
+	if errEvent != nil {
+		return errEvent
+	}
+	return &Message{
+		Event: &Body{
+			Header:   header(namespaceAlexa, "StateReport", d),
+			Endpoint: &Endpoint{EndpointID: device.ID},
+			Payload:  json.RawMessage("{}"),
+		},
+		Context: stateContext(s),
+	}
+}
+
+func (h *Handler) power(d *Body) *Message {
+	device, s, errEvent := h.state(d)
+	if errEvent != nil {
+		return errEvent
+	}
+	var err error
+	switch d.Header.Name {
+	case "TurnOn":
+		err = smarthome.Start(device.Robot, s)
+	case "TurnOff":
+		if smarthome.Running(s) {
+			_, err = device.Robot.SendToBase(nil)
+		}
+	default:
+		return errorEvent(d, errorInvalid, fmt.Sprintf("unsupported "+
+			"directive %s.%s", d.Header.Namespace, d.Header.Name))
+	}
+	if err != nil {
+		return errorEvent(d, errorUnreachable, err.Error())
+	}
+	if s, err = device.Robot.State(); err != nil {
+		return errorEvent(d, errorUnreachable, err.Error())
+	}
+	return &Message{
+		Event: &Body{
+			Header:   header(namespaceAlexa, "Response", d),
+			Endpoint: &Endpoint{EndpointID: device.ID},
+			Payload:  json.RawMessage("{}"),
+		},
+		Context: stateContext(s),
+	}
+}
+
+// state returns the Device addressed by d and its current state, or an error
+// event to send in reply
+func (h *Handler) state(d *Body) (*smarthome.Device, *nucleo.RobotState,
+	*Message) {
+	if d.Endpoint == nil {
+		return nil, nil, errorEvent(d, errorInvalid, "no endpoint")
+	}
+	device, err := h.Devices.Get(d.Endpoint.EndpointID)
+	if err != nil {
+		return nil, nil, errorEvent(d, errorNoSuchEndpoint, err.Error())
+	}
+	s, err := device.Robot.State()
+	if err != nil {
+		return nil, nil, errorEvent(d, errorUnreachable, err.Error())
+	}
+	return device, s, nil
+}
+
+func stateContext(s *nucleo.RobotState) *Context {
+	now := time.Now().UTC()
+	connected := map[string]string{"value": "OK"}
+	power := "OFF"
+	if smarthome.Running(s) {
+		power = "ON"
+	}
+	return &Context{Properties: []Property{
+		{Namespace: namespacePower, Name: "powerState", Value: power,
+			TimeOfSample:              now,
+			UncertaintyInMilliseconds: uncertainty},
+		{Namespace: namespaceHealth, Name: "connectivity",
+			Value:                     connected,
+			TimeOfSample:              now,
+			UncertaintyInMilliseconds: uncertainty},
+	}}
+}
+
+func errorEvent(d *Body, kind, message string) *Message {
+	payload, err := json.Marshal(&errorPayload{Type: kind,
+		Message: message})
+	if err != nil {
+		payload = json.RawMessage(`{"type":"` + errorInternal + `"}`)
+	}
+	b := &Body{
+		Header:  header(namespaceAlexa, "ErrorResponse", d),
+		Payload: payload,
+	}
+	if d != nil && d.Endpoint != nil {
+		b.Endpoint = &Endpoint{EndpointID: d.Endpoint.EndpointID}
+	}
+	return &Message{Event: b}
+}
+
+// interfaceCapability returns the capability of an interface, reporting the
+// named property if not empty
+func interfaceCapability(namespace, property string) capability {
+	c := capability{Type: "AlexaInterface", Interface: namespace,
+		Version: payloadVersion}
+	if property != "" {
+		c.Properties = &properties{
+			Supported:   []supported{{Name: property}},
+			Retrievable: true,
+		}
+	}
+	return c
+}
+
+// header returns the header of an event replying to the directive d
+func header(namespace, name string, d *Body) Header {
+	h := Header{
+		Namespace:      namespace,
+		Name:           name,
+		PayloadVersion: payloadVersion,
+		MessageID:      messageID(),
+	}
+	if d != nil {
+		h.CorrelationToken = d.Header.CorrelationToken
+	}
+	return h
+}
+
+func messageID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,340 @@
+// google implements the fulfillment of a Google Smart Home Action for robots.
+// Each robot is synced as a vacuum supporting the StartStop, Dock and
+// EnergyStorage traits, and the Locator trait where its firmware supports
+// findMe. Account linking and request authentication are left to the caller,
+// which should only pass authenticated requests to the Handler.
+
+package google
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/richlj/neato/nucleo"
+	"github.com/richlj/neato/smarthome"
+)
+
+const (
+	deviceType = "action.devices.types.VACUUM"
+
+	traitStartStop     = "action.devices.traits.StartStop"
+	traitDock          = "action.devices.traits.Dock"
+	traitEnergyStorage = "action.devices.traits.EnergyStorage"
+	traitLocator       = "action.devices.traits.Locator"
+
+	intentSync       = "action.devices.SYNC"
+	intentQuery      = "action.devices.QUERY"
+	intentExecute    = "action.devices.EXECUTE"
+	intentDisconnect = "action.devices.DISCONNECT"
+
+	commandStartStop    = "action.devices.commands.StartStop"
+	commandPauseUnpause = "action.devices.commands.PauseUnpause"
+	commandDock         = "action.devices.commands.Dock"
+	commandLocate       = "action.devices.commands.Locate"
+
+	statusSuccess = "SUCCESS"
+	statusOffline = "OFFLINE"
+	statusError   = "ERROR"
+
+	errorOffline        = "deviceOffline"
+	errorNotSupported   = "functionNotSupported"
+	errorDeviceNotFound = "deviceNotFound"
+	errorProtocolError  = "protocolError"
+	errorAlreadyDocked  = "alreadyDocked"
+	errorAlreadyStarted = "alreadyStarted"
+	errorAlreadyPaused  = "alreadyPaused"
+
+	findMeService = "findMe"
+)
+
+// Request is a fulfillment request sent by Google
+type Request struct {
+	RequestID string `json:"requestId"`
+	Inputs    []struct {
+		Intent  string `json:"intent"`
+		Payload struct {
+			Devices  []device  `json:"devices"`
+			Commands []command `json:"commands"`
+		} `json:"payload"`
+	} `json:"inputs"`
+}
+
+type device struct {
+	ID string `json:"id"`
+}
+
+type command struct {
+	Devices   []device    `json:"devices"`
+	Execution []execution `json:"execution"`
+}
+
+type execution struct {
+	Command string `json:"command"`
+	Params  struct {
+		Start *bool `json:"start"`
+		Pause *bool `json:"pause"`
+	} `json:"params"`
+}
+
+// Response is the reply to a fulfillment Request
+type Response struct {
+	RequestID string      `json:"requestId"`
+	Payload   interface{} `json:"payload,omitempty"`
+}
+
+type syncPayload struct {
+	AgentUserID string       `json:"agentUserId"`
+	Devices     []syncDevice `json:"devices"`
+}
+
+type syncDevice struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Name struct {
+		Name string `json:"name"`
+	} `json:"name"`
+	Traits          []string          `json:"traits"`
+	WillReportState bool              `json:"willReportState"`
+	Attributes      map[string]bool   `json:"attributes"`
+	DeviceInfo      map[string]string `json:"deviceInfo"`
+}
+
+type queryPayload struct {
+	Devices map[string]map[string]interface{} `json:"devices"`
+}
+
+type executePayload struct {
+	Commands []commandResult `json:"commands"`
+}
+
+type commandResult struct {
+	IDs       []string               `json:"ids"`
+	Status    string                 `json:"status"`
+	States    map[string]interface{} `json:"states,omitempty"`
+	ErrorCode string                 `json:"errorCode,omitempty"`
+}
+
+type errorPayload struct {
+	ErrorCode   string `json:"errorCode"`
+	DebugString string `json:"debugString,omitempty"`
+}
+
+// Handler fulfils Google Smart Home intents for a set of Devices
+type Handler struct {
+	// AgentUserID identifies the user's account to Google
+	AgentUserID string
+	Devices     smarthome.Devices
+}
+
+// Handle processes a Request and returns the Response to send in reply
+func (h *Handler) Handle(req *Request) *Response {
+	resp := &Response{RequestID: req.RequestID}
+	if len(req.Inputs) == 0 {
+		resp.Payload = &errorPayload{ErrorCode: errorProtocolError}
+		return resp
+	}
+	in := req.Inputs[0]
+	switch in.Intent {
+	case intentSync:
+		resp.Payload = h.sync()
+	case intentQuery:
+		resp.Payload = h.query(in.Payload.Devices)
+	case intentExecute:
+		resp.Payload = h.execute(in.Payload.Commands)
+	case intentDisconnect:
+		resp.Payload = nil
+	default:
+		resp.Payload = &errorPayload{ErrorCode: errorProtocolError,
+			DebugString: "unsupported intent"}
+	}
+	return resp
+}
+
+// ServeHTTP accepts a Request POSTed as JSON and replies with the Response
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	b, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var r Request
+	if err := json.Unmarshal(b, &r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.Handle(&r))
+}
+
+func (h *Handler) sync() *syncPayload {
+	p := &syncPayload{AgentUserID: h.AgentUserID, Devices: []syncDevice{}}
+	for _, d := range h.Devices.All() {
+		s := syncDevice{
+			ID:   d.ID,
+			Type: deviceType,
+			Traits: []string{traitStartStop, traitDock,
+				traitEnergyStorage},
+			Attributes: map[string]bool{
+				"pausable":               true,
+				"isRechargeable":         true,
+				"queryOnlyEnergyStorage": true,
+			},
+			DeviceInfo: map[string]string{
+				"manufacturer": "Neato Robotics",
+			},
+		}
+		s.Name.Name = d.Name
+		if d.Capabilities.Supports(findMeService) {
+			s.Traits = append(s.Traits, traitLocator)
+		}
+		p.Devices = append(p.Devices, s)
+	}
+	return p
+}
+
+func (h *Handler) query(devices []device) *queryPayload {
+	p := &queryPayload{Devices: make(map[string]map[string]interface{})}
+	for _, d := range devices {
+		device, err := h.Devices.Get(d.ID)
+		if err != nil {
+			p.Devices[d.ID] = map[string]interface{}{
+				"online":    false,
+				"status":    statusError,
+				"errorCode": errorDeviceNotFound,
+			}
+			continue
+		}
+		s, err := device.Robot.State()
+		if err != nil {
+			p.Devices[d.ID] = map[string]interface{}{
+				"online":    false,
+				"status":    statusOffline,
+				"errorCode": errorOffline,
+			}
+			continue
+		}
+		states := deviceStates(s)
+		states["status"] = statusSuccess
+		p.Devices[d.ID] = states
+	}
+	return p
+}
+
+func (h *Handler) execute(commands []command) *executePayload {
+	p := &executePayload{Commands: []commandResult{}}
+	for _, c := range commands {
+		for _, d := range c.Devices {
+			for _, e := range c.Execution {
+				p.Commands = append(p.Commands, h.run(d.ID, &e))
+			}
+		}
+	}
+	return p
+}
+
+// run performs a single execution on the Device with the supplied ID
+func (h *Handler) run(id string, e *execution) commandResult {
+	result := commandResult{IDs: []string{id}, Status: statusError}
+	device, err := h.Devices.Get(id)
+	if err != nil {
+		result.ErrorCode = errorDeviceNotFound
+		return result
+	}
+	s, err := device.Robot.State()
+	if err != nil {
+		result.Status, result.ErrorCode = statusOffline, errorOffline
+		return result
+	}
+	if code := perform(device, s, e); code != "" {
+		result.ErrorCode = code
+		return result
+	}
+	if s, err = device.Robot.State(); err != nil {
+		result.Status, result.ErrorCode = statusOffline, errorOffline
+		return result
+	}
+	result.Status = statusSuccess
+	result.States = deviceStates(s)
+	return result
+}
+
+// perform carries out the execution e on a Device in state s, returning the
+// Google error code describing any failure
+func perform(device *smarthome.Device, s *nucleo.RobotState,
+	e *execution) string {
+	var err error
+	r := device.Robot
+	switch e.Command {
+	case commandStartStop:
+		start := e.Params.Start != nil && *e.Params.Start
+		switch {
+		case start && smarthome.Running(s) && !smarthome.Paused(s):
+			return errorAlreadyStarted
+		case start:
+			err = smarthome.Start(r, s)
+		case smarthome.Running(s):
+			_, err = r.StopCleaning(nil)
+		}
+	case commandPauseUnpause:
+		pause := e.Params.Pause != nil && *e.Params.Pause
+		switch {
+		case pause && smarthome.Paused(s):
+			return errorAlreadyPaused
+		case pause:
+			_, err = r.PauseCleaning(nil)
+		case smarthome.Paused(s):
+			_, err = r.ResumeCleaning(nil)
+		}
+	case commandDock:
+		if s.Docked {
+			return errorAlreadyDocked
+		}
+		_, err = r.SendToBase(nil)
+	case commandLocate:
+		if !device.Capabilities.Supports(findMeService) {
+			return errorNotSupported
+		}
+		_, err = r.FindMe(nil)
+	default:
+		return errorNotSupported
+	}
+	if err != nil {
+		return errorOffline
+	}
+	return ""
+}
+
+// deviceStates describes s in terms of the traits synced for each Device
+func deviceStates(s *nucleo.RobotState) map[string]interface{} {
+	return map[string]interface{}{
+		"online":                       true,
+		"isRunning":                    smarthome.Running(s),
+		"isPaused":                     smarthome.Paused(s),
+		"isDocked":                     s.Docked,
+		"isCharging":                   s.Charging,
+		"descriptiveCapacityRemaining": capacity(s.Charge),
+		"capacityRemaining": []map[string]interface{}{
+			{"rawValue": s.Charge, "unit": "PERCENTAGE"},
+		},
+	}
+}
+
+// capacity describes a battery charge in Google's descriptive terms
+func capacity(charge int) string {
+	switch {
+	case charge >= 90:
+		return "FULL"
+	case charge >= 60:
+		return "HIGH"
+	case charge >= 30:
+		return "MEDIUM"
+	case charge >= 10:
+		return "LOW"
+	}
+	return "CRITICALLY_LOW"
+}
@@ -0,0 +1,89 @@
+// smarthome holds what the Alexa and Google smart home fulfillment handlers
+// have in common: the registry of robots exposed to the voice assistant and
+// the interpretation of robot state in voice assistant terms.
+
+package smarthome
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/richlj/neato/nucleo"
+)
+
+// Device is a robot exposed to a voice assistant
+type Device struct {
+	ID           string
+	Name         string
+	Robot        nucleo.RobotService
+	Capabilities nucleo.Capabilities
+}
+
+// Devices is a registry of Devices, keyed by ID
+type Devices struct {
+	mu      sync.RWMutex
+	devices map[string]*Device
+}
+
+// Add registers a Device, replacing any with the same ID
+func (d *Devices) Add(device *Device) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.devices == nil {
+		d.devices = make(map[string]*Device)
+	}
+	d.devices[device.ID] = device
+}
+
+// Get returns the Device with the supplied ID
+func (d *Devices) Get(id string) (*Device, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	device, ok := d.devices[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown device %q", id)
+	}
+	return device, nil
+}
+
+// All returns every registered Device, ordered by ID
+func (d *Devices) All() []*Device {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var result []*Device
+	for _, device := range d.devices {
+		result = append(result, device)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// Running reports whether the robot is out cleaning, including when paused
+func Running(s *nucleo.RobotState) bool {
+	switch s.State {
+	case nucleo.StateBusy:
+		return s.Action != nucleo.ActionDocking
+	case nucleo.StatePaused:
+		return true
+	}
+	return false
+}
+
+// Paused reports whether the robot's cleaning run is paused
+func Paused(s *nucleo.RobotState) bool {
+	return s.State == nucleo.StatePaused
+}
+
+// Start begins cleaning, or resumes a paused run
+func Start(r nucleo.RobotService, s *nucleo.RobotState) error {
+	var err error
+	if Paused(s) {
+		_, err = r.ResumeCleaning(nil)
+	} else if !Running(s) {
+		_, err = r.StartCleaning(nil)
+	}
+	return err
+}
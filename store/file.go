@@ -0,0 +1,451 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	fileMode = 0600
+
+	runsFile    = "runs.json"
+	statesFile  = "states.jsonl"
+	batteryFile = "battery.jsonl"
+	mapsFile    = "maps.json"
+	tokensFile  = "tokens.json"
+
+	schedulesFile = "schedules.json"
+)
+
+// FileStore is a Store kept in memory and persisted to JSON files in a
+// directory. It needs no database, which suits embedded devices, but every
+// record is held in memory.
+type FileStore struct {
+	dir string
+
+	mu          sync.Mutex
+	runs        []Run
+	states      []State
+	battery     []BatterySample
+	maps        []MapMeta
+	schedules   map[string]Schedule
+	tokens      map[string]Token
+	file        *os.File
+	batteryFile *os.File
+}
+
+// OpenFile returns a FileStore persisted to the directory dir, creating it if
+// necessary and loading any records already stored there
+func OpenFile(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	s := &FileStore{dir: dir, tokens: make(map[string]Token),
+		schedules: make(map[string]Schedule)}
+	for name, v := range map[string]interface{}{
+		runsFile:      &s.runs,
+		mapsFile:      &s.maps,
+		schedulesFile: &s.schedules,
+		tokensFile:    &s.tokens,
+	} {
+		if err := s.load(name, v); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	s.file, err = s.openLines(statesFile, func(b []byte) error {
+		var st State
+		err := json.Unmarshal(b, &st)
+		s.states = append(s.states, st)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.batteryFile, err = s.openLines(batteryFile, func(b []byte) error {
+		var bs BatterySample
+		err := json.Unmarshal(b, &bs)
+		s.battery = append(s.battery, bs)
+		return err
+	})
+	if err != nil {
+		s.file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// openLines opens the named file of JSON lines for appending, passing each
+// line already in it to fn
+func (s *FileStore) openLines(name string, fn func([]byte) error) (*os.File,
+	error) {
+	f, err := os.OpenFile(filepath.Join(s.dir, name),
+		os.O_RDWR|os.O_CREATE|os.O_APPEND, fileMode)
+	if err != nil {
+		return nil, err
+	}
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		if err := fn(sc.Bytes()); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s:%d: %v", f.Name(), line, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// PutRun records a cleaning run
+func (s *FileStore) PutRun(ctx context.Context, r Run) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	replaced := false
+	for i, o := range s.runs {
+		if o.Robot == r.Robot && o.Start.Equal(r.Start) {
+			s.runs[i], replaced = r, true
+		}
+	}
+	if !replaced {
+		s.runs = append(s.runs, r)
+	}
+	return s.save(runsFile, s.runs)
+}
+
+// Runs returns the runs matching q, oldest first
+func (s *FileStore) Runs(ctx context.Context, q Query) ([]Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []Run
+	for _, r := range s.runs {
+		if q.matches(r.Robot, r.Start) {
+			result = append(result, r)
+		}
+	}
+	sort.SliceStable(result, func(a, b int) bool {
+		return result[a].Start.Before(result[b].Start)
+	})
+	return result[limit(len(result), q.Limit):], nil
+}
+
+// AddState records an observed state
+func (s *FileStore) AddState(ctx context.Context, st State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := appendLine(s.file, &st); err != nil {
+		return err
+	}
+	s.states = append(s.states, st)
+	return nil
+}
+
+// States returns the states matching q, oldest first
+func (s *FileStore) States(ctx context.Context, q Query) ([]State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []State
+	for _, st := range s.states {
+		if q.matches(st.Robot, st.Time) {
+			result = append(result, st)
+		}
+	}
+	sort.SliceStable(result, func(a, b int) bool {
+		return result[a].Time.Before(result[b].Time)
+	})
+	return result[limit(len(result), q.Limit):], nil
+}
+
+// AddBattery records a sample of a robot's battery
+func (s *FileStore) AddBattery(ctx context.Context, b BatterySample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := appendLine(s.batteryFile, &b); err != nil {
+		return err
+	}
+	s.battery = append(s.battery, b)
+	return nil
+}
+
+// Battery returns the battery samples matching q, oldest first
+func (s *FileStore) Battery(ctx context.Context,
+	q Query) ([]BatterySample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []BatterySample
+	for _, b := range s.battery {
+		if q.matches(b.Robot, b.Time) {
+			result = append(result, b)
+		}
+	}
+	sort.SliceStable(result, func(a, b int) bool {
+		return result[a].Time.Before(result[b].Time)
+	})
+	return result[limit(len(result), q.Limit):], nil
+}
+
+// PutMap records the metadata of a map
+func (s *FileStore) PutMap(ctx context.Context, m MapMeta) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	replaced := false
+	for i, o := range s.maps {
+		if o.Robot == m.Robot && o.ID == m.ID {
+			s.maps[i], replaced = m, true
+		}
+	}
+	if !replaced {
+		s.maps = append(s.maps, m)
+	}
+	return s.save(mapsFile, s.maps)
+}
+
+// Maps returns the metadata of the maps matching q, oldest first
+func (s *FileStore) Maps(ctx context.Context, q Query) ([]MapMeta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []MapMeta
+	for _, m := range s.maps {
+		if q.matches(m.Robot, m.Start) {
+			result = append(result, m)
+		}
+	}
+	sort.SliceStable(result, func(a, b int) bool {
+		return result[a].Start.Before(result[b].Start)
+	})
+	return result[limit(len(result), q.Limit):], nil
+}
+
+// PutSchedule records the last known schedule of a robot
+func (s *FileStore) PutSchedule(ctx context.Context, sc Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schedules[sc.Robot] = sc
+	return s.save(schedulesFile, s.schedules)
+}
+
+// Schedule returns the last known schedule of the robot with serial
+func (s *FileStore) Schedule(ctx context.Context,
+	robot string) (Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, ok := s.schedules[robot]
+	if !ok {
+		return Schedule{}, ErrNotFound
+	}
+	return sc, nil
+}
+
+// PutToken stores a token under its name
+func (s *FileStore) PutToken(ctx context.Context, t Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[t.Name] = t
+	return s.save(tokensFile, s.tokens)
+}
+
+// Token returns the named token
+func (s *FileStore) Token(ctx context.Context, name string) (Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[name]
+	if !ok {
+		return Token{}, ErrNotFound
+	}
+	return t, nil
+}
+
+// DeleteToken removes the named token
+func (s *FileStore) DeleteToken(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, name)
+	return s.save(tokensFile, s.tokens)
+}
+
+// Compact removes the records which p does not keep at now
+func (s *FileStore) Compact(ctx context.Context, p Policy,
+	now time.Time) error {
+	states, sample, maps, runs, battery := p.cutoffs(now)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !battery.IsZero() {
+		var kept []BatterySample
+		for _, b := range s.battery {
+			if !b.Time.Before(battery) {
+				kept = append(kept, b)
+			}
+		}
+		if len(kept) < len(s.battery) {
+			f, err := s.rewriteLines(s.batteryFile, batteryFile,
+				len(kept), func(i int) interface{} {
+					return &kept[i]
+				})
+			if err != nil {
+				return err
+			}
+			s.batteryFile, s.battery = f, kept
+		}
+	}
+	if !runs.IsZero() {
+		var kept []Run
+		for _, r := range s.runs {
+			if !r.Start.Before(runs) {
+				kept = append(kept, r)
+			}
+		}
+		if len(kept) < len(s.runs) {
+			s.runs = kept
+			if err := s.save(runsFile, s.runs); err != nil {
+				return err
+			}
+		}
+	}
+	if !maps.IsZero() {
+		var kept []MapMeta
+		for _, m := range s.maps {
+			if !m.Start.Before(maps) {
+				kept = append(kept, m)
+			}
+		}
+		if len(kept) < len(s.maps) {
+			s.maps = kept
+			if err := s.save(mapsFile, s.maps); err != nil {
+				return err
+			}
+		}
+	}
+	var keep []bool
+	if !sample.IsZero() {
+		keep = thin(s.states, sample, p.DownsampleInterval)
+	}
+	var kept []State
+	for i, st := range s.states {
+		if (states.IsZero() || !st.Time.Before(states)) &&
+			(keep == nil || keep[i]) {
+			kept = append(kept, st)
+		}
+	}
+	if len(kept) == len(s.states) {
+		return nil
+	}
+	f, err := s.rewriteLines(s.file, statesFile, len(kept),
+		func(i int) interface{} {
+			return &kept[i]
+		})
+	if err != nil {
+		return err
+	}
+	s.file, s.states = f, kept
+	return nil
+}
+
+// rewriteLines replaces the named file of JSON lines, open as f, with n
+// lines, atomically, and returns it reopened for appending. s.mu must be
+// held.
+func (s *FileStore) rewriteLines(f *os.File, name string, n int,
+	line func(i int) interface{}) (*os.File, error) {
+	if f == nil {
+		return nil, fmt.Errorf("store: closed")
+	}
+	var b []byte
+	for i := 0; i < n; i++ {
+		l, err := json.Marshal(line(i))
+		if err != nil {
+			return nil, err
+		}
+		b = append(append(b, l...), '\n')
+	}
+	path := filepath.Join(s.dir, name)
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, fileMode); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+	reopened, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, fileMode)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return reopened, nil
+}
+
+// appendLine writes v to f as a line of JSON
+func appendLine(f *os.File, v interface{}) error {
+	if f == nil {
+		return fmt.Errorf("store: closed")
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
+
+// Close closes the FileStore's files
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	if berr := s.batteryFile.Close(); err == nil {
+		err = berr
+	}
+	s.file, s.batteryFile = nil, nil
+	return err
+}
+
+// load decodes the named file into v, if it exists
+func (s *FileStore) load(name string, v interface{}) error {
+	path := filepath.Join(s.dir, name)
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, v); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// save rewrites the named file with v, replacing it atomically. s.mu must be
+// held.
+func (s *FileStore) save(name string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, name)
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, fileMode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// limit returns the index from which the last n of count records begin
+func limit(count, n int) int {
+	if n <= 0 || n >= count {
+		return 0
+	}
+	return count - n
+}
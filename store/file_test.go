@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	day0 = time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	testRuns = []Run{
+		{Robot: "a", Start: day0.AddDate(0, 0, 2), Area: 3},
+		{Robot: "a", Start: day0, Area: 1},
+		{Robot: "b", Start: day0.AddDate(0, 0, 1), Area: 2},
+		{Robot: "a", Start: day0.AddDate(0, 0, 3), Area: 4},
+	}
+)
+
+func openTest(t *testing.T, dir string) *FileStore {
+	t.Helper()
+	s, err := OpenFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func areas(runs []Run) []float64 {
+	var result []float64
+	for _, r := range runs {
+		result = append(result, r.Area)
+	}
+	return result
+}
+
+func TestFileStoreRuns(t *testing.T) {
+	ctx := context.Background()
+	s := openTest(t, t.TempDir())
+	for _, r := range testRuns {
+		if err := s.PutRun(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// replaces the run recorded with the same robot and start
+	if err := s.PutRun(ctx, Run{Robot: "a", Start: day0,
+		Area: 1.5}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		q    Query
+		want []float64
+	}{
+		{"all", Query{}, []float64{1.5, 2, 3, 4}},
+		{"robot", Query{Robot: "a"}, []float64{1.5, 3, 4}},
+		{"since", Query{Since: day0.AddDate(0, 0, 2)}, []float64{3, 4}},
+		{"until", Query{Until: day0.AddDate(0, 0, 2)},
+			[]float64{1.5, 2}},
+		{"limit", Query{Robot: "a", Limit: 2}, []float64{3, 4}},
+		{"none", Query{Robot: "c"}, nil},
+	}
+	for _, tt := range tests {
+		runs, err := s.Runs(ctx, tt.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := areas(runs); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: areas %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFileStoreReopen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openTest(t, dir)
+	st := State{Robot: "a", Time: day0, State: 2, Action: 1, Charge: 80}
+	b := BatterySample{Robot: "a", Time: day0, Level: 80,
+		TimeToEmpty: time.Hour}
+	sc := Schedule{Robot: "a", Time: day0, Enabled: true}
+	tok := Token{Name: "beehive", Value: "secret", Expires: day0}
+	for _, err := range []error{
+		s.AddState(ctx, st),
+		s.AddBattery(ctx, b),
+		s.PutSchedule(ctx, sc),
+		s.PutToken(ctx, tok),
+		s.PutToken(ctx, Token{Name: "gone"}),
+		s.DeleteToken(ctx, "gone"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+	s = openTest(t, dir)
+	states, err := s.States(ctx, Query{})
+	if err != nil || len(states) != 1 || !reflect.DeepEqual(states[0], st) {
+		t.Errorf("States() = %+v, %v", states, err)
+	}
+	battery, err := s.Battery(ctx, Query{Robot: "a"})
+	if err != nil || len(battery) != 1 ||
+		!reflect.DeepEqual(battery[0], b) {
+		t.Errorf("Battery() = %+v, %v", battery, err)
+	}
+	if got, err := s.Schedule(ctx, "a"); err != nil ||
+		!reflect.DeepEqual(got, sc) {
+		t.Errorf("Schedule() = %+v, %v", got, err)
+	}
+	if got, err := s.Token(ctx, "beehive"); err != nil || got != tok {
+		t.Errorf("Token() = %+v, %v", got, err)
+	}
+	if _, err := s.Token(ctx, "gone"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted token: %v, want %v", err, ErrNotFound)
+	}
+	if _, err := s.Schedule(ctx, "b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing schedule: %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestFileStoreCompact(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openTest(t, dir)
+	now := day0.AddDate(0, 6, 0)
+	states := []State{
+		// older than StateMonths
+		{Robot: "a", Time: day0},
+		// thinned to the first in each hour
+		{Robot: "a", Time: now.AddDate(0, -1, 0)},
+		{Robot: "a", Time: now.AddDate(0, -1, 0).Add(time.Minute)},
+		{Robot: "a", Time: now.AddDate(0, -1, 0).Add(2 * time.Minute),
+			Alert: "dustbin_full"},
+		// recent
+		{Robot: "a", Time: now.Add(-time.Minute)},
+		{Robot: "a", Time: now.Add(-time.Second)},
+	}
+	for _, st := range states {
+		if err := s.AddState(ctx, st); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, r := range testRuns {
+		if err := s.PutRun(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := Policy{StateMonths: 3, DownsampleAfter: 7 * 24 * time.Hour,
+		DownsampleInterval: time.Hour, RunMonths: 6}
+	// removes the runs started before day0 plus two days
+	if err := s.Compact(ctx, p, now.AddDate(0, 0, 2)); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	s = openTest(t, dir)
+	got, err := s.States(ctx, Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []State{states[1], states[3], states[4], states[5]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("states after compaction %+v, want %+v", got, want)
+	}
+	runs, err := s.Runs(ctx, Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := areas(runs); !reflect.DeepEqual(got, []float64{3, 4}) {
+		t.Errorf("runs after compaction %v", got)
+	}
+}
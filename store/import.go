@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/richlj/neato/beehive"
+	"github.com/richlj/neato/decode"
+	"github.com/richlj/neato/nucleo"
+)
+
+const mapCompleted = "completed"
+
+// RunFromMap returns the Run recorded by the Map of a cleaning run
+func RunFromMap(robot string, m *beehive.Map) Run {
+	return Run{
+		Robot:        robot,
+		Start:        m.StartAt,
+		End:          m.EndAt,
+		Category:     m.Category,
+		Mode:         m.Mode,
+		Area:         m.CleanedArea,
+		LaunchedFrom: m.LaunchedFrom,
+		Completed:    m.Status == mapCompleted,
+	}
+}
+
+// ImportMaps records the runs and map metadata in a Beehive maps response,
+// as returned for the robot with the supplied serial number, returning the
+// number of maps imported
+func ImportMaps(ctx context.Context, s Store, robot string,
+	r io.Reader) (int, error) {
+	var result beehive.MapsResult
+	if err := decode.Decode(r, &result); err != nil {
+		return 0, fmt.Errorf("import maps: %v", err)
+	}
+	return putMaps(ctx, s, robot, result.Maps)
+}
+
+// ImportPybotvac records the runs and map metadata in a JSON dump of the
+// maps held by a pybotvac Account, which keys a Beehive maps response by
+// robot serial number, returning the number of maps imported
+func ImportPybotvac(ctx context.Context, s Store, r io.Reader) (int, error) {
+	var dump map[string]beehive.MapsResult
+	if err := decode.Decode(r, &dump); err != nil {
+		return 0, fmt.Errorf("import pybotvac: %v", err)
+	}
+	total := 0
+	for robot, result := range dump {
+		n, err := putMaps(ctx, s, robot, result.Maps)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// ImportLocalStats records the runs in the history of a getLocalStats
+// response from the robot with the supplied serial number, returning the
+// number of runs imported
+func ImportLocalStats(ctx context.Context, s Store, robot string,
+	r io.Reader) (int, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	var resp nucleo.Response
+	if err := decode.Unmarshal(b, &resp); err != nil {
+		return 0, fmt.Errorf("import local stats: %v", err)
+	}
+	categories := []struct {
+		name    string
+		history []nucleo.RunHistory
+	}{
+		{"", resp.Data.History},
+		{"houseCleaning", resp.Data.HouseCleaning.History},
+		{"spotCleaning", resp.Data.SpotCleaning.History},
+	}
+	// The overall history and those of each category may record the same
+	// run, so the categories are matched to the overall entries by start
+	var runs []Run
+	index := make(map[int64]int)
+	for _, c := range categories {
+		for _, h := range c.history {
+			if h.Start.IsZero() {
+				continue
+			}
+			r := RunFromHistory(robot, c.name, h)
+			if i, ok := index[h.Start.UnixNano()]; ok {
+				if runs[i].Category == 0 {
+					runs[i].Category = r.Category
+				}
+				continue
+			}
+			index[h.Start.UnixNano()] = len(runs)
+			runs = append(runs, r)
+		}
+	}
+	for i, r := range runs {
+		if err := s.PutRun(ctx, r); err != nil {
+			return i, err
+		}
+	}
+	return len(runs), nil
+}
+
+// putMaps records the runs and metadata of maps, skipping those without a
+// start time
+func putMaps(ctx context.Context, s Store, robot string,
+	maps []beehive.Map) (int, error) {
+	n := 0
+	for i := range maps {
+		m := &maps[i]
+		if m.StartAt.IsZero() {
+			continue
+		}
+		if err := s.PutRun(ctx, RunFromMap(robot, m)); err != nil {
+			return n, err
+		}
+		if err := s.PutMap(ctx, MapFromBeehive(robot, m)); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
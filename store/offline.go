@@ -0,0 +1,155 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/richlj/neato/beehive"
+	"github.com/richlj/neato/clock"
+	"github.com/richlj/neato/nucleo"
+)
+
+// MapLister lists the maps of robots, as a *beehive.Session does
+type MapLister interface {
+	ListRobotMaps(robot string) (*beehive.MapsResult, error)
+}
+
+// CachedState is a State which is Stale if it was read from the Store
+// because the robot could not be reached. AsOf is when it was observed.
+type CachedState struct {
+	State
+	Stale bool      `json:"stale"`
+	AsOf  time.Time `json:"as_of"`
+}
+
+// CachedSchedule is a Schedule which is Stale if it was read from the Store.
+// AsOf is when it was fetched.
+type CachedSchedule struct {
+	Schedule
+	Stale bool      `json:"stale"`
+	AsOf  time.Time `json:"as_of"`
+}
+
+// CachedMaps are the metadata of a robot's maps, Stale if they were read
+// from the Store. AsOf is when they were fetched or, if Stale, the end of
+// the latest run mapped.
+type CachedMaps struct {
+	Maps  []MapMeta `json:"maps"`
+	Stale bool      `json:"stale"`
+	AsOf  time.Time `json:"as_of"`
+}
+
+// Offline reads a robot's state, schedule and maps, recording each in the
+// Store, and serves the last recorded copy when neither the robot nor the
+// cloud can be reached, so that dashboards degrade gracefully during
+// outages. Other errors, such as rejected credentials, are returned.
+type Offline struct {
+	Serial string
+	Robot  nucleo.RobotService
+
+	// Lister lists the robot's maps, typically a *beehive.Session.
+	// Without it, maps are only read from the Store, and are always
+	// Stale.
+	Lister MapLister
+
+	Store Store
+
+	// Clock dates what is fetched. It defaults to clock.Real.
+	Clock clock.Clock
+}
+
+// State returns the robot's state
+func (o *Offline) State(ctx context.Context) (*CachedState, error) {
+	rs, err := o.Robot.State()
+	if err == nil {
+		st := State{
+			Robot:    o.Serial,
+			Time:     clock.Or(o.Clock).Now(),
+			State:    int(rs.State),
+			Action:   int(rs.Action),
+			Charge:   rs.Charge,
+			Charging: rs.Charging,
+			Docked:   rs.Docked,
+			Alert:    rs.Alert,
+			Error:    rs.Error,
+		}
+		if err := o.Store.AddState(ctx, st); err != nil {
+			return nil, err
+		}
+		return &CachedState{State: st, AsOf: st.Time}, nil
+	}
+	if !nucleo.IsRetryable(err) {
+		return nil, err
+	}
+	states, serr := o.Store.States(ctx, Query{Robot: o.Serial, Limit: 1})
+	if serr != nil || len(states) == 0 {
+		return nil, err
+	}
+	return &CachedState{State: states[0], Stale: true,
+		AsOf: states[0].Time}, nil
+}
+
+// Schedule returns the robot's schedule
+func (o *Offline) Schedule(ctx context.Context) (*CachedSchedule, error) {
+	resp, err := o.Robot.GetSchedule(nil)
+	if err == nil {
+		now := clock.Or(o.Clock).Now()
+		sc := ScheduleFromResponse(o.Serial, now, resp)
+		if err := o.Store.PutSchedule(ctx, sc); err != nil {
+			return nil, err
+		}
+		return &CachedSchedule{Schedule: sc, AsOf: sc.Time}, nil
+	}
+	if !nucleo.IsRetryable(err) {
+		return nil, err
+	}
+	sc, serr := o.Store.Schedule(ctx, o.Serial)
+	if serr != nil {
+		return nil, err
+	}
+	return &CachedSchedule{Schedule: sc, Stale: true, AsOf: sc.Time}, nil
+}
+
+// Maps returns the metadata of the robot's maps, oldest first. When stale,
+// they include any maps recorded which Beehive has since dropped.
+func (o *Offline) Maps(ctx context.Context) (*CachedMaps, error) {
+	err := ErrNotFound
+	if o.Lister != nil {
+		var result *beehive.MapsResult
+		result, err = o.Lister.ListRobotMaps(o.Serial)
+		if err == nil {
+			return o.putMaps(ctx, result.Maps)
+		}
+		if !nucleo.IsRetryable(err) {
+			return nil, err
+		}
+	}
+	maps, serr := o.Store.Maps(ctx, Query{Robot: o.Serial})
+	if serr != nil || len(maps) == 0 {
+		return nil, err
+	}
+	c := &CachedMaps{Maps: maps, Stale: true}
+	for _, m := range maps {
+		if m.End.After(c.AsOf) {
+			c.AsOf = m.End
+		}
+	}
+	return c, nil
+}
+
+// putMaps records the fetched maps, returning their metadata
+func (o *Offline) putMaps(ctx context.Context,
+	maps []beehive.Map) (*CachedMaps, error) {
+	if _, err := putMaps(ctx, o.Store, o.Serial, maps); err != nil {
+		return nil, err
+	}
+	c := &CachedMaps{AsOf: clock.Or(o.Clock).Now()}
+	for i := range maps {
+		c.Maps = append(c.Maps, MapFromBeehive(o.Serial, &maps[i]))
+	}
+	sort.SliceStable(c.Maps, func(a, b int) bool {
+		return c.Maps[a].Start.Before(c.Maps[b].Start)
+	})
+	return c, nil
+}
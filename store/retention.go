@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/richlj/neato/clock"
+)
+
+// Policy limits how much history a Store keeps. Zero values keep
+// everything.
+type Policy struct {
+	// StateMonths deletes states older than this many months
+	StateMonths int
+
+	// DownsampleAfter thins states older than this to the first of each
+	// robot in every DownsampleInterval. States with an alert or error are
+	// always kept.
+	DownsampleAfter    time.Duration
+	DownsampleInterval time.Duration
+
+	// MapMonths deletes the metadata of maps older than this many months
+	MapMonths int
+
+	// RunMonths deletes runs older than this many months
+	RunMonths int
+
+	// BatteryMonths deletes battery samples older than this many months
+	BatteryMonths int
+}
+
+// cutoffs returns the times before which states, downsampled states, maps,
+// runs and battery samples are removed at now. Zero times remove nothing.
+func (p Policy) cutoffs(now time.Time) (states, sample, maps, runs,
+	battery time.Time) {
+	if p.StateMonths > 0 {
+		states = now.AddDate(0, -p.StateMonths, 0)
+	}
+	if p.DownsampleAfter > 0 && p.DownsampleInterval > 0 {
+		sample = now.Add(-p.DownsampleAfter)
+	}
+	if p.MapMonths > 0 {
+		maps = now.AddDate(0, -p.MapMonths, 0)
+	}
+	if p.RunMonths > 0 {
+		runs = now.AddDate(0, -p.RunMonths, 0)
+	}
+	if p.BatteryMonths > 0 {
+		battery = now.AddDate(0, -p.BatteryMonths, 0)
+	}
+	return
+}
+
+// Compactor applies a Policy to a Store periodically
+type Compactor struct {
+	Store  Store
+	Policy Policy
+
+	// Clock dates and paces the compaction. It defaults to clock.Real.
+	Clock clock.Clock
+}
+
+// Run compacts the Store now and then every interval until ctx is
+// cancelled, passing the result of each compaction to report, if not nil
+func (c *Compactor) Run(ctx context.Context, interval time.Duration,
+	report func(error)) error {
+	t := clock.Or(c.Clock).NewTicker(interval)
+	defer t.Stop()
+	for {
+		err := c.Store.Compact(ctx, c.Policy, clock.Or(c.Clock).Now())
+		if report != nil {
+			report(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C():
+		}
+	}
+}
+
+// thin reports which of states, in chronological order, a downsampling
+// Policy keeps: those after cutoff, those with an alert or error, and the
+// first of each robot's in every interval
+func thin(states []State, cutoff time.Time, interval time.Duration) []bool {
+	keep := make([]bool, len(states))
+	type bucket struct {
+		robot string
+		n     int64
+	}
+	seen := make(map[bucket]bool)
+	for i, st := range states {
+		if !st.Time.Before(cutoff) || st.Alert != "" || st.Error != "" {
+			keep[i] = true
+			continue
+		}
+		b := bucket{st.Robot, st.Time.UnixNano() / int64(interval)}
+		keep[i] = !seen[b]
+		seen[b] = true
+	}
+	return keep
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/richlj/neato/beehive"
+)
+
+// DefaultRobotsToken is the name of the token in which RobotTokens keeps
+// the robot list when no Name is set
+const DefaultRobotsToken = "beehive.robots"
+
+// RobotTokens is a beehive.RobotStore which keeps a Session's robot list,
+// including each robot's secret key, as a token of Store, so that robots and
+// rotated keys are remembered across restarts
+type RobotTokens struct {
+	Store Store
+	Name  string
+}
+
+type storedRobots struct {
+	Fetched time.Time       `json:"fetched"`
+	Robots  []beehive.Robot `json:"robots"`
+}
+
+// LoadRobots returns the stored robots and when they were fetched
+func (t *RobotTokens) LoadRobots() ([]beehive.Robot, time.Time, error) {
+	tok, err := t.Store.Token(context.Background(), t.name())
+	if errors.Is(err, ErrNotFound) {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var v storedRobots
+	if err := json.Unmarshal([]byte(tok.Value), &v); err != nil {
+		return nil, time.Time{}, fmt.Errorf("token %s: %v", t.name(),
+			err)
+	}
+	return v.Robots, v.Fetched, nil
+}
+
+// SaveRobots stores robots, fetched at fetched
+func (t *RobotTokens) SaveRobots(robots []beehive.Robot,
+	fetched time.Time) error {
+	b, err := json.Marshal(storedRobots{Fetched: fetched, Robots: robots})
+	if err != nil {
+		return err
+	}
+	return t.Store.PutToken(context.Background(), Token{Name: t.name(),
+		Value: string(b)})
+}
+
+func (t *RobotTokens) name() string {
+	if t.Name == "" {
+		return DefaultRobotsToken
+	}
+	return t.Name
+}
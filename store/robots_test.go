@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/richlj/neato/beehive"
+	"github.com/richlj/neato/nucleo"
+)
+
+func TestRobotTokens(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := &RobotTokens{Store: s}
+	robots, _, err := rt.LoadRobots()
+	if err != nil || robots != nil {
+		t.Fatalf("LoadRobots from an empty store: %v, %v", robots, err)
+	}
+	fetched := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	want := []beehive.Robot{{Robot: nucleo.Robot{
+		Serial: "OPS01234-0123456789AB", Name: "Kitchen",
+		SecretKey: "key"}}}
+	if err := rt.SaveRobots(want, fetched); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if s, err = OpenFile(dir); err != nil {
+		t.Fatal(err)
+	}
+	rt = &RobotTokens{Store: s}
+	robots, at, err := rt.LoadRobots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(robots) != 1 || robots[0].Serial != want[0].Serial ||
+		robots[0].SecretKey != "key" || !at.Equal(fetched) {
+		t.Errorf("LoadRobots after reopening: %+v at %v", robots, at)
+	}
+	ctx := context.Background()
+	if _, err := s.Token(ctx, DefaultRobotsToken); err != nil {
+		t.Errorf("token %s: %v", DefaultRobotsToken, err)
+	}
+}
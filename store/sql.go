@@ -0,0 +1,592 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrLocked is returned by TryLock when another process holds the lock
+var ErrLocked = errors.New("store: locked by another process")
+
+// Dialect describes the differences between the SQL databases supported by
+// SQLStore
+type Dialect struct {
+	// Name identifies the Dialect in errors
+	Name string
+
+	// Placeholder returns the placeholder for the nth argument of a
+	// statement, counting from 1
+	Placeholder func(n int) string
+
+	// TryLock and Unlock are statements which take and release an
+	// advisory lock identified by a single integer argument. TryLock
+	// returns whether the lock was taken. Without them, locks are held
+	// only within the process.
+	TryLock string
+	Unlock  string
+
+	// LockMigrations is a statement which blocks, within the migration
+	// transaction, until no other process is migrating the database
+	LockMigrations string
+}
+
+// SQLite is the Dialect of SQLite 3.24 or later, e.g. with the pure-Go
+// modernc.org/sqlite driver on embedded devices
+var SQLite = Dialect{
+	Name:        "sqlite",
+	Placeholder: func(int) string { return "?" },
+}
+
+// Postgres is the Dialect of PostgreSQL 9.5 or later, which several daemons
+// may share, coordinating through advisory locks
+var Postgres = Dialect{
+	Name:           "postgres",
+	Placeholder:    func(n int) string { return "$" + strconv.Itoa(n) },
+	TryLock:        "SELECT pg_try_advisory_lock(?)",
+	Unlock:         "SELECT pg_advisory_unlock(?)",
+	LockMigrations: "SELECT pg_advisory_xact_lock(?)",
+}
+
+// migrations are the statements which create the schema, in order. A
+// database records how many have been applied, so new statements are only
+// ever appended.
+var migrations = []string{
+	`CREATE TABLE runs (
+		robot TEXT NOT NULL,
+		start_at BIGINT NOT NULL,
+		end_at BIGINT NOT NULL,
+		category INTEGER NOT NULL,
+		mode INTEGER NOT NULL,
+		area DOUBLE PRECISION NOT NULL,
+		launched_from TEXT NOT NULL,
+		completed BOOLEAN NOT NULL,
+		PRIMARY KEY (robot, start_at))`,
+	`CREATE TABLE states (
+		robot TEXT NOT NULL,
+		at BIGINT NOT NULL,
+		state INTEGER NOT NULL,
+		action INTEGER NOT NULL,
+		charge INTEGER NOT NULL,
+		charging BOOLEAN NOT NULL,
+		docked BOOLEAN NOT NULL,
+		alert TEXT NOT NULL,
+		error TEXT NOT NULL)`,
+	`CREATE INDEX states_robot_at ON states (robot, at)`,
+	`CREATE TABLE maps (
+		robot TEXT NOT NULL,
+		id TEXT NOT NULL,
+		run_id TEXT NOT NULL,
+		start_at BIGINT NOT NULL,
+		end_at BIGINT NOT NULL,
+		status TEXT NOT NULL,
+		area DOUBLE PRECISION NOT NULL,
+		url TEXT NOT NULL,
+		PRIMARY KEY (robot, id))`,
+	`CREATE TABLE tokens (
+		name TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		expires BIGINT NOT NULL)`,
+	`CREATE TABLE battery (
+		robot TEXT NOT NULL,
+		at BIGINT NOT NULL,
+		level INTEGER NOT NULL,
+		charging BOOLEAN NOT NULL,
+		time_to_empty BIGINT NOT NULL,
+		time_to_full BIGINT NOT NULL,
+		total_charges INTEGER NOT NULL)`,
+	`CREATE INDEX battery_robot_at ON battery (robot, at)`,
+	`CREATE TABLE schedules (
+		robot TEXT PRIMARY KEY,
+		at BIGINT NOT NULL,
+		enabled BOOLEAN NOT NULL,
+		events TEXT NOT NULL)`,
+	`ALTER TABLE states ADD COLUMN command TEXT NOT NULL DEFAULT ''`,
+}
+
+// SQLStore is a Store in a SQL database, accessed through database/sql. The
+// caller registers and opens the driver, so this package depends on none.
+// With Postgres, several daemons may share a SQLStore, and with it their
+// history, using TryLock to divide the work between them.
+type SQLStore struct {
+	db      *sql.DB
+	dialect Dialect
+
+	mu    sync.Mutex
+	local map[string]*Lock
+}
+
+// Lock is an advisory lock taken with TryLock
+type Lock struct {
+	s    *SQLStore
+	name string
+	conn *sql.Conn
+}
+
+// OpenSQL returns a SQLStore using db, creating or upgrading its schema
+func OpenSQL(ctx context.Context, db *sql.DB, d Dialect) (*SQLStore,
+	error) {
+	s := &SQLStore{db: db, dialect: d, local: make(map[string]*Lock)}
+	if err := s.migrate(ctx); err != nil {
+		return nil, fmt.Errorf("%s: migrate: %v", d.Name, err)
+	}
+	return s, nil
+}
+
+// migrate applies the migrations which the database has not yet seen
+func (s *SQLStore) migrate(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if s.dialect.LockMigrations != "" {
+		_, err := tx.ExecContext(ctx, s.bind(s.dialect.LockMigrations),
+			lockKey("migrate"))
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS
+		schema_migrations (version INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+	var version int
+	err = tx.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).
+		Scan(&version)
+	if err != nil {
+		return err
+	}
+	for i := version; i < len(migrations); i++ {
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %v", i+1, err)
+		}
+	}
+	if version < len(migrations) {
+		if _, err := tx.ExecContext(ctx, s.bind(
+			`INSERT INTO schema_migrations (version) VALUES (?)`),
+			len(migrations)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// PutRun records a cleaning run
+func (s *SQLStore) PutRun(ctx context.Context, r Run) error {
+	_, err := s.db.ExecContext(ctx, s.bind(`INSERT INTO runs (robot,
+		start_at, end_at, category, mode, area, launched_from,
+		completed) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (robot, start_at) DO UPDATE SET
+		end_at = excluded.end_at, category = excluded.category,
+		mode = excluded.mode, area = excluded.area,
+		launched_from = excluded.launched_from,
+		completed = excluded.completed`), r.Robot, unixNano(r.Start),
+		unixNano(r.End), r.Category, r.Mode, r.Area, r.LaunchedFrom,
+		r.Completed)
+	return err
+}
+
+// Runs returns the runs matching q, oldest first
+func (s *SQLStore) Runs(ctx context.Context, q Query) ([]Run, error) {
+	rows, err := s.query(ctx, `SELECT robot, start_at, end_at, category,
+		mode, area, launched_from, completed FROM runs`, "start_at", q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []Run
+	for rows.Next() {
+		var (
+			r          Run
+			start, end int64
+		)
+		err := rows.Scan(&r.Robot, &start, &end, &r.Category,
+			&r.Mode, &r.Area, &r.LaunchedFrom, &r.Completed)
+		if err != nil {
+			return nil, err
+		}
+		r.Start, r.End = fromUnixNano(start), fromUnixNano(end)
+		result = append(result, r)
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result, rows.Err()
+}
+
+// AddState records an observed state
+func (s *SQLStore) AddState(ctx context.Context, st State) error {
+	_, err := s.db.ExecContext(ctx, s.bind(`INSERT INTO states (robot, at,
+		state, action, charge, charging, docked, alert, error, command)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`), st.Robot,
+		unixNano(st.Time), st.State, st.Action, st.Charge, st.Charging,
+		st.Docked, st.Alert, st.Error, st.Command)
+	return err
+}
+
+// States returns the states matching q, oldest first
+func (s *SQLStore) States(ctx context.Context, q Query) ([]State, error) {
+	rows, err := s.query(ctx, `SELECT robot, at, state, action, charge,
+		charging, docked, alert, error, command FROM states`, "at", q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []State
+	for rows.Next() {
+		var (
+			st State
+			at int64
+		)
+		if err := rows.Scan(&st.Robot, &at, &st.State, &st.Action,
+			&st.Charge, &st.Charging, &st.Docked, &st.Alert,
+			&st.Error, &st.Command); err != nil {
+			return nil, err
+		}
+		st.Time = fromUnixNano(at)
+		result = append(result, st)
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result, rows.Err()
+}
+
+// AddBattery records a sample of a robot's battery
+func (s *SQLStore) AddBattery(ctx context.Context, b BatterySample) error {
+	_, err := s.db.ExecContext(ctx, s.bind(`INSERT INTO battery (robot, at,
+		level, charging, time_to_empty, time_to_full, total_charges)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`), b.Robot, unixNano(b.Time),
+		b.Level, b.Charging, int64(b.TimeToEmpty), int64(b.TimeToFull),
+		b.TotalCharges)
+	return err
+}
+
+// Battery returns the battery samples matching q, oldest first
+func (s *SQLStore) Battery(ctx context.Context,
+	q Query) ([]BatterySample, error) {
+	rows, err := s.query(ctx, `SELECT robot, at, level, charging,
+		time_to_empty, time_to_full, total_charges FROM battery`, "at",
+		q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []BatterySample
+	for rows.Next() {
+		var (
+			b                   BatterySample
+			at, toEmpty, toFull int64
+		)
+		if err := rows.Scan(&b.Robot, &at, &b.Level, &b.Charging,
+			&toEmpty, &toFull, &b.TotalCharges); err != nil {
+			return nil, err
+		}
+		b.Time = fromUnixNano(at)
+		b.TimeToEmpty, b.TimeToFull = time.Duration(toEmpty),
+			time.Duration(toFull)
+		result = append(result, b)
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result, rows.Err()
+}
+
+// PutMap records the metadata of a map
+func (s *SQLStore) PutMap(ctx context.Context, m MapMeta) error {
+	_, err := s.db.ExecContext(ctx, s.bind(`INSERT INTO maps (robot, id,
+		run_id, start_at, end_at, status, area, url)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (robot, id) DO UPDATE SET run_id = excluded.run_id,
+		start_at = excluded.start_at, end_at = excluded.end_at,
+		status = excluded.status, area = excluded.area,
+		url = excluded.url`), m.Robot, m.ID, m.RunID, unixNano(m.Start),
+		unixNano(m.End), m.Status, m.Area, m.URL)
+	return err
+}
+
+// Maps returns the metadata of the maps matching q, oldest first
+func (s *SQLStore) Maps(ctx context.Context, q Query) ([]MapMeta, error) {
+	rows, err := s.query(ctx, `SELECT robot, id, run_id, start_at, end_at,
+		status, area, url FROM maps`, "start_at", q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []MapMeta
+	for rows.Next() {
+		var (
+			m          MapMeta
+			start, end int64
+		)
+		if err := rows.Scan(&m.Robot, &m.ID, &m.RunID, &start, &end,
+			&m.Status, &m.Area, &m.URL); err != nil {
+			return nil, err
+		}
+		m.Start, m.End = fromUnixNano(start), fromUnixNano(end)
+		result = append(result, m)
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result, rows.Err()
+}
+
+// PutSchedule records the last known schedule of a robot. The events are
+// stored as JSON.
+func (s *SQLStore) PutSchedule(ctx context.Context, sc Schedule) error {
+	events, err := json.Marshal(sc.Events)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, s.bind(`INSERT INTO schedules (robot,
+		at, enabled, events) VALUES (?, ?, ?, ?)
+		ON CONFLICT (robot) DO UPDATE SET at = excluded.at,
+		enabled = excluded.enabled, events = excluded.events`),
+		sc.Robot, unixNano(sc.Time), sc.Enabled, string(events))
+	return err
+}
+
+// Schedule returns the last known schedule of the robot with serial
+func (s *SQLStore) Schedule(ctx context.Context,
+	robot string) (Schedule, error) {
+	sc := Schedule{Robot: robot}
+	var (
+		at     int64
+		events string
+	)
+	err := s.db.QueryRowContext(ctx, s.bind(`SELECT at, enabled, events
+		FROM schedules WHERE robot = ?`), robot).Scan(&at, &sc.Enabled,
+		&events)
+	if err == sql.ErrNoRows {
+		return Schedule{}, ErrNotFound
+	}
+	if err != nil {
+		return Schedule{}, err
+	}
+	sc.Time = fromUnixNano(at)
+	if err := json.Unmarshal([]byte(events), &sc.Events); err != nil {
+		return Schedule{}, fmt.Errorf("%s: schedule of %s: %v",
+			s.dialect.Name, robot, err)
+	}
+	return sc, nil
+}
+
+// PutToken stores a token under its name
+func (s *SQLStore) PutToken(ctx context.Context, t Token) error {
+	_, err := s.db.ExecContext(ctx, s.bind(`INSERT INTO tokens (name, value,
+		expires) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET value = excluded.value,
+		expires = excluded.expires`), t.Name, t.Value,
+		unixNano(t.Expires))
+	return err
+}
+
+// Token returns the named token
+func (s *SQLStore) Token(ctx context.Context, name string) (Token, error) {
+	t := Token{Name: name}
+	var expires int64
+	err := s.db.QueryRowContext(ctx, s.bind(`SELECT value, expires FROM
+		tokens WHERE name = ?`), name).Scan(&t.Value, &expires)
+	if err == sql.ErrNoRows {
+		return Token{}, ErrNotFound
+	}
+	if err != nil {
+		return Token{}, err
+	}
+	t.Expires = fromUnixNano(expires)
+	return t, nil
+}
+
+// DeleteToken removes the named token
+func (s *SQLStore) DeleteToken(ctx context.Context, name string) error {
+	_, err := s.db.ExecContext(ctx,
+		s.bind(`DELETE FROM tokens WHERE name = ?`), name)
+	return err
+}
+
+// Compact removes the records which p does not keep at now. When several
+// daemons share the database, only the one which takes the compaction lock
+// does the work.
+func (s *SQLStore) Compact(ctx context.Context, p Policy,
+	now time.Time) error {
+	l, err := s.TryLock(ctx, "compact")
+	if err == ErrLocked {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer l.Release(ctx)
+	states, sample, maps, runs, battery := p.cutoffs(now)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	deletes := []struct {
+		cutoff time.Time
+		stmt   string
+		args   []interface{}
+	}{
+		{runs, `DELETE FROM runs WHERE start_at < ?`, nil},
+		{maps, `DELETE FROM maps WHERE start_at < ?`, nil},
+		{states, `DELETE FROM states WHERE at < ?`, nil},
+		{battery, `DELETE FROM battery WHERE at < ?`, nil},
+		{sample, `DELETE FROM states WHERE at < ? AND alert = '' AND
+			error = '' AND EXISTS (SELECT 1 FROM states o
+			WHERE o.robot = states.robot AND o.alert = '' AND
+			o.error = '' AND o.at < states.at AND
+			o.at / ? = states.at / ?)`,
+			[]interface{}{int64(p.DownsampleInterval),
+				int64(p.DownsampleInterval)}},
+	}
+	for _, d := range deletes {
+		if d.cutoff.IsZero() {
+			continue
+		}
+		args := append([]interface{}{unixNano(d.cutoff)}, d.args...)
+		_, err := tx.ExecContext(ctx, s.bind(d.stmt), args...)
+		if err != nil {
+			return fmt.Errorf("%s: compact: %v", s.dialect.Name,
+				err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Close closes the database
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// TryLock takes the named advisory lock, returning ErrLocked if another
+// process holds it. Daemons sharing a database use locks to agree which of
+// them performs work that must only be done once, such as polling a robot.
+// The lock is held until it is released or the process exits.
+func (s *SQLStore) TryLock(ctx context.Context, name string) (*Lock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.local[name] != nil {
+		return nil, ErrLocked
+	}
+	l := &Lock{s: s, name: name}
+	if s.dialect.TryLock != "" {
+		conn, err := s.db.Conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		err = conn.QueryRowContext(ctx, s.bind(s.dialect.TryLock),
+			lockKey(name)).Scan(&ok)
+		if err != nil || !ok {
+			conn.Close()
+			if err == nil {
+				err = ErrLocked
+			}
+			return nil, err
+		}
+		l.conn = conn
+	}
+	s.local[name] = l
+	return l, nil
+}
+
+// Release releases the Lock
+func (l *Lock) Release(ctx context.Context) error {
+	l.s.mu.Lock()
+	defer l.s.mu.Unlock()
+	if l.s.local[l.name] != l {
+		return nil
+	}
+	delete(l.s.local, l.name)
+	if l.conn == nil {
+		return nil
+	}
+	defer l.conn.Close()
+	_, err := l.conn.ExecContext(ctx, l.s.bind(l.s.dialect.Unlock),
+		lockKey(l.name))
+	return err
+}
+
+// lockKey returns the integer identifying the named advisory lock
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("neato/store:" + name))
+	return int64(h.Sum64())
+}
+
+// query selects the records matching q from the table queried by sel, whose
+// time column is col, newest first
+func (s *SQLStore) query(ctx context.Context, sel, col string,
+	q Query) (*sql.Rows, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if q.Robot != "" {
+		where = append(where, "robot = ?")
+		args = append(args, q.Robot)
+	}
+	if !q.Since.IsZero() {
+		where = append(where, col+" >= ?")
+		args = append(args, unixNano(q.Since))
+	}
+	if !q.Until.IsZero() {
+		where = append(where, col+" < ?")
+		args = append(args, unixNano(q.Until))
+	}
+	stmt := sel
+	if len(where) > 0 {
+		stmt += " WHERE " + strings.Join(where, " AND ")
+	}
+	stmt += " ORDER BY " + col + " DESC"
+	if q.Limit > 0 {
+		stmt += " LIMIT " + strconv.Itoa(q.Limit)
+	}
+	return s.db.QueryContext(ctx, s.bind(stmt), args...)
+}
+
+// bind replaces the ? placeholders of stmt with the Dialect's own
+func (s *SQLStore) bind(stmt string) string {
+	if s.dialect.Placeholder == nil {
+		return stmt
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range stmt {
+		if c != '?' {
+			b.WriteRune(c)
+			continue
+		}
+		n++
+		b.WriteString(s.dialect.Placeholder(n))
+	}
+	return b.String()
+}
+
+// unixNano returns t in nanoseconds since the epoch, or 0 for the zero time
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// fromUnixNano is the inverse of unixNano
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n).UTC()
+}
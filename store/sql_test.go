@@ -0,0 +1,595 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBind(t *testing.T) {
+	tests := []struct {
+		d    Dialect
+		stmt string
+		want string
+	}{
+		{SQLite, "SELECT a FROM t WHERE b = ? AND c < ?",
+			"SELECT a FROM t WHERE b = ? AND c < ?"},
+		{Postgres, "SELECT a FROM t WHERE b = ? AND c < ?",
+			"SELECT a FROM t WHERE b = $1 AND c < $2"},
+		{Dialect{}, "SELECT ?", "SELECT ?"},
+	}
+	for _, tt := range tests {
+		s := &SQLStore{dialect: tt.d}
+		if got := s.bind(tt.stmt); got != tt.want {
+			t.Errorf("%s: bind(%q) = %q, want %q", tt.d.Name,
+				tt.stmt, got, tt.want)
+		}
+	}
+}
+
+var (
+	createRE = regexp.MustCompile(`^CREATE TABLE (\w+) \((.*)\)$`)
+	alterRE  = regexp.MustCompile(`^ALTER TABLE (\w+) ADD COLUMN (\w+) `)
+	insertRE = regexp.MustCompile(`^INSERT INTO (\w+) \(([^)]*)\) ` +
+		`VALUES \(([^)]*)\)(?: ON CONFLICT \(([^)]*)\) ` +
+		`DO UPDATE SET (.*))?$`)
+	selectRE      = regexp.MustCompile(`^SELECT (.*) FROM (\w+)`)
+	placeholderRE = regexp.MustCompile(`\$(\d+)`)
+)
+
+// table is the schema of a table built by the migrations
+type table struct {
+	columns map[string]bool
+	key     []string
+}
+
+// schema returns the tables built by the migrations
+func schema(t *testing.T) map[string]*table {
+	t.Helper()
+	tables := make(map[string]*table)
+	for _, m := range migrations {
+		m = strings.Join(strings.Fields(m), " ")
+		if c := createRE.FindStringSubmatch(m); c != nil {
+			tb := &table{columns: make(map[string]bool)}
+			for _, def := range splitColumns(c[2]) {
+				const pk = "PRIMARY KEY"
+				if strings.HasPrefix(def, pk) {
+					k := strings.Trim(def[len(pk):], " ()")
+					tb.key = splitList(k)
+					continue
+				}
+				name := strings.Fields(def)[0]
+				tb.columns[name] = true
+				if strings.Contains(def, "PRIMARY KEY") {
+					tb.key = []string{name}
+				}
+			}
+			tables[c[1]] = tb
+		} else if a := alterRE.FindStringSubmatch(m); a != nil {
+			tables[a[1]].columns[a[2]] = true
+		}
+	}
+	return tables
+}
+
+// splitColumns splits the body of a CREATE TABLE statement at the commas
+// outside parentheses
+func splitColumns(body string) []string {
+	var (
+		result []string
+		depth  int
+		start  int
+	)
+	for i, c := range body {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				result = append(result,
+					strings.TrimSpace(body[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(result, strings.TrimSpace(body[start:]))
+}
+
+func splitList(s string) []string {
+	var result []string
+	for _, f := range strings.Split(s, ",") {
+		result = append(result, strings.TrimSpace(f))
+	}
+	return result
+}
+
+// checkPlaceholders reports placeholders in st which are not those of d,
+// or which do not match its arguments
+func checkPlaceholders(t *testing.T, d Dialect, st fakeStmt) {
+	t.Helper()
+	if d.Name == "sqlite" {
+		if n := strings.Count(st.stmt, "?"); n != len(st.args) ||
+			strings.Contains(st.stmt, "$") {
+			t.Errorf("%s: %q has %d placeholders for %d arguments",
+				d.Name, st.stmt, n, len(st.args))
+		}
+		return
+	}
+	ps := placeholderRE.FindAllStringSubmatch(st.stmt, -1)
+	ok := len(ps) == len(st.args) && !strings.Contains(st.stmt, "?")
+	for i, p := range ps {
+		ok = ok && p[1] == strconv.Itoa(i+1)
+	}
+	if !ok {
+		t.Errorf("%s: %q has placeholders %v for %d arguments", d.Name,
+			st.stmt, ps, len(st.args))
+	}
+}
+
+// checkInsert reports columns inserted by st which the schema lacks, and
+// upserts whose conflict target is not the table's key or which do not
+// update every other column inserted
+func checkInsert(t *testing.T, d Dialect, tables map[string]*table,
+	st fakeStmt) {
+	t.Helper()
+	checkPlaceholders(t, d, st)
+	m := insertRE.FindStringSubmatch(st.stmt)
+	if m == nil {
+		t.Errorf("%s: unexpected statement %q", d.Name, st.stmt)
+		return
+	}
+	tb, columns := tables[m[1]], splitList(m[2])
+	if tb == nil {
+		t.Errorf("%s: no table %s", d.Name, m[1])
+		return
+	}
+	if n := len(splitList(m[3])); n != len(columns) {
+		t.Errorf("%s: %d values for %d columns of %s", d.Name, n,
+			len(columns), m[1])
+	}
+	for _, c := range columns {
+		if !tb.columns[c] {
+			t.Errorf("%s: no column %s.%s", d.Name, m[1], c)
+		}
+	}
+	if tb.key == nil {
+		if m[4] != "" {
+			t.Errorf("%s: upsert into keyless %s", d.Name, m[1])
+		}
+		return
+	}
+	if !reflect.DeepEqual(splitList(m[4]), tb.key) {
+		t.Errorf("%s: %s conflicts on (%s), want %v", d.Name, m[1],
+			m[4], tb.key)
+	}
+	want := make(map[string]bool)
+	for _, c := range columns {
+		want[c] = true
+	}
+	for _, k := range tb.key {
+		delete(want, k)
+	}
+	for _, set := range splitList(m[5]) {
+		var col, value string
+		if _, err := fmt.Sscanf(set, "%s = %s", &col,
+			&value); err != nil || value != "excluded."+col ||
+			!want[col] {
+			t.Errorf("%s: %s: unexpected update %q", d.Name, m[1],
+				set)
+		}
+		delete(want, col)
+	}
+	for c := range want {
+		t.Errorf("%s: %s: upsert does not update %s", d.Name, m[1], c)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		d      Dialect
+		lock   string
+		insert string
+	}{
+		{SQLite, "",
+			"INSERT INTO schema_migrations (version) VALUES (?)"},
+		{Postgres, "SELECT pg_advisory_xact_lock($1)",
+			"INSERT INTO schema_migrations (version) VALUES ($1)"},
+	}
+	for _, tt := range tests {
+		for _, version := range []int{0, len(migrations) - 1,
+			len(migrations)} {
+			db := &fakeDB{version: int64(version)}
+			_, err := OpenSQL(context.Background(), sql.OpenDB(db),
+				tt.d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{"BEGIN"}
+			if tt.lock != "" {
+				want = append(want, tt.lock)
+			}
+			want = append(want, "CREATE TABLE IF NOT EXISTS "+
+				"schema_migrations (version INTEGER NOT NULL)",
+				"SELECT COALESCE(MAX(version), 0) FROM "+
+					"schema_migrations")
+			for _, m := range migrations[version:] {
+				want = append(want,
+					strings.Join(strings.Fields(m), " "))
+			}
+			if version < len(migrations) {
+				want = append(want, tt.insert)
+			}
+			want = append(want, "COMMIT")
+			var got []string
+			for _, st := range db.statements() {
+				got = append(got, st.stmt)
+				switch st.stmt {
+				case tt.lock:
+					checkArgs(t, tt.d, st,
+						lockKey("migrate"))
+				case tt.insert:
+					checkArgs(t, tt.d, st,
+						int64(len(migrations)))
+				}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: from version %d: %q, want %q",
+					tt.d.Name, version, got, want)
+			}
+		}
+	}
+}
+
+func checkArgs(t *testing.T, d Dialect, st fakeStmt,
+	want ...interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(st.args, want) {
+		t.Errorf("%s: %q: arguments %v, want %v", d.Name, st.stmt,
+			st.args, want)
+	}
+}
+
+func TestStatements(t *testing.T) {
+	tables := schema(t)
+	at := time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)
+	for _, d := range []Dialect{SQLite, Postgres} {
+		s, db := openFake(t, d, len(migrations))
+		ctx := context.Background()
+		for _, err := range []error{
+			s.PutRun(ctx, Run{Robot: "OPS1", Start: at}),
+			s.AddState(ctx, State{Robot: "OPS1", Time: at,
+				Command: "startCleaning"}),
+			s.AddBattery(ctx, BatterySample{Robot: "OPS1",
+				Time: at}),
+			s.PutMap(ctx, MapMeta{Robot: "OPS1", ID: "map-1"}),
+			s.PutSchedule(ctx, Schedule{Robot: "OPS1", Time: at}),
+			s.PutToken(ctx, Token{Name: "beehive"}),
+		} {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		inserts := db.statements()
+		if len(inserts) != 6 {
+			t.Fatalf("%s: %d statements, want 6", d.Name,
+				len(inserts))
+		}
+		for _, st := range inserts {
+			checkInsert(t, d, tables, st)
+		}
+		args := inserts[1].args
+		if args[len(args)-1] != "startCleaning" {
+			t.Errorf("%s: AddState arguments %v", d.Name, args)
+		}
+
+		q := Query{Robot: "OPS1", Since: at, Until: at.Add(time.Hour),
+			Limit: 10}
+		s.Runs(ctx, q)
+		s.States(ctx, q)
+		s.Battery(ctx, q)
+		s.Maps(ctx, q)
+		for _, st := range db.statements() {
+			checkPlaceholders(t, d, st)
+			m := selectRE.FindStringSubmatch(st.stmt)
+			if m == nil || tables[m[2]] == nil {
+				t.Errorf("%s: unexpected query %q", d.Name,
+					st.stmt)
+				continue
+			}
+			for _, c := range splitList(m[1]) {
+				if !tables[m[2]].columns[c] {
+					t.Errorf("%s: no column %s.%s", d.Name,
+						m[2], c)
+				}
+			}
+			if !strings.HasSuffix(st.stmt, " DESC LIMIT 10") {
+				t.Errorf("%s: %q does not limit to the most "+
+					"recent", d.Name, st.stmt)
+			}
+			checkArgs(t, d, st, "OPS1", at.UnixNano(),
+				at.Add(time.Hour).UnixNano())
+		}
+	}
+}
+
+func TestCompact(t *testing.T) {
+	now := time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)
+	p := Policy{StateMonths: 6, DownsampleAfter: 24 * time.Hour,
+		DownsampleInterval: time.Hour, RunMonths: 12}
+	for _, d := range []Dialect{SQLite, Postgres} {
+		s, db := openFake(t, d, len(migrations))
+		if err := s.Compact(context.Background(), p, now); err != nil {
+			t.Fatal(err)
+		}
+		log := db.statements()
+		if d.TryLock != "" {
+			if len(log) < 2 {
+				t.Fatalf("%s: statements %v", d.Name, log)
+			}
+			lock, unlock := log[0], log[len(log)-1]
+			checkPlaceholders(t, d, lock)
+			checkPlaceholders(t, d, unlock)
+			if lock.stmt != s.bind(d.TryLock) ||
+				unlock.stmt != s.bind(d.Unlock) ||
+				lock.conn != unlock.conn {
+				t.Errorf("%s: locked by %v, unlocked by %v",
+					d.Name, lock, unlock)
+			}
+			checkArgs(t, d, lock, lockKey("compact"))
+			checkArgs(t, d, unlock, lockKey("compact"))
+			log = log[1 : len(log)-1]
+		}
+		// The cutoffs which are zero, for maps and battery samples,
+		// delete nothing
+		runs := now.AddDate(-1, 0, 0).UnixNano()
+		states := now.AddDate(0, -6, 0).UnixNano()
+		sample := now.Add(-24 * time.Hour).UnixNano()
+		want := []struct {
+			prefix string
+			args   []interface{}
+		}{
+			{"BEGIN", nil},
+			{"DELETE FROM runs WHERE start_at <",
+				[]interface{}{runs}},
+			{"DELETE FROM states WHERE at <",
+				[]interface{}{states}},
+			{"DELETE FROM states WHERE at < ? AND alert = '' AND " +
+				"error = '' AND EXISTS",
+				[]interface{}{sample, int64(time.Hour),
+					int64(time.Hour)}},
+			{"COMMIT", nil},
+		}
+		if len(log) != len(want) {
+			t.Fatalf("%s: statements %v", d.Name, log)
+		}
+		for i, st := range log {
+			checkPlaceholders(t, d, st)
+			prefix := s.bind(want[i].prefix)
+			if st.conn != log[0].conn ||
+				!strings.HasPrefix(st.stmt, prefix) {
+				t.Errorf("%s: statement %d is %v, want %q",
+					d.Name, i, st, want[i].prefix)
+			}
+			checkArgs(t, d, st, want[i].args...)
+		}
+
+		// Only the daemon holding the lock compacts
+		if d.TryLock == "" {
+			continue
+		}
+		db.locked = true
+		if err := s.Compact(context.Background(), p, now); err != nil {
+			t.Fatal(err)
+		}
+		if log := db.statements(); len(log) != 1 {
+			t.Errorf("%s: compacted while locked: %v", d.Name, log)
+		}
+	}
+}
+
+func TestTryLock(t *testing.T) {
+	ctx := context.Background()
+	for _, d := range []Dialect{SQLite, Postgres} {
+		s, db := openFake(t, d, len(migrations))
+		db.locked = true
+		if _, err := s.TryLock(ctx, "poll"); d.TryLock != "" &&
+			err != ErrLocked {
+			t.Errorf("%s: TryLock held elsewhere = %v", d.Name, err)
+		} else if d.TryLock == "" && err != nil {
+			t.Errorf("%s: TryLock = %v", d.Name, err)
+		}
+		if d.TryLock == "" {
+			// SQLite locks are local to the process, so the lock
+			// taken above is still held
+			if _, err := s.TryLock(ctx, "poll"); err != ErrLocked {
+				t.Errorf("%s: TryLock twice = %v", d.Name, err)
+			}
+			if log := db.statements(); len(log) != 0 {
+				t.Errorf("%s: statements %v", d.Name, log)
+			}
+			continue
+		}
+		db.statements()
+		db.locked = false
+		l, err := s.TryLock(ctx, "poll")
+		if err != nil {
+			t.Fatalf("%s: TryLock = %v", d.Name, err)
+		}
+		if _, err := s.TryLock(ctx, "poll"); err != ErrLocked {
+			t.Errorf("%s: TryLock twice = %v", d.Name, err)
+		}
+		if err := l.Release(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Release(ctx); err != nil {
+			t.Errorf("%s: Release twice = %v", d.Name, err)
+		}
+		log := db.statements()
+		if len(log) != 2 || log[0].stmt != s.bind(d.TryLock) ||
+			log[1].stmt != s.bind(d.Unlock) ||
+			log[0].conn != log[1].conn {
+			t.Errorf("%s: statements %v", d.Name, log)
+		}
+		for _, st := range log {
+			checkArgs(t, d, st, lockKey("poll"))
+		}
+		if l, err = s.TryLock(ctx, "poll"); err != nil {
+			t.Errorf("%s: TryLock after Release = %v", d.Name, err)
+		}
+	}
+}
+
+// fakeDB is a database reached through a database/sql driver which records
+// the statements executed rather than running them. It answers only the
+// queries made by migrate and TryLock.
+type fakeDB struct {
+	mu      sync.Mutex
+	log     []fakeStmt
+	conns   int
+	version int64
+	// locked is whether another process holds the advisory locks
+	locked bool
+}
+
+// fakeStmt is a statement executed on the numbered connection, with its
+// whitespace normalised
+type fakeStmt struct {
+	conn int
+	stmt string
+	args []interface{}
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.conns++
+	return &fakeConn{db: db, id: db.conns}, nil
+}
+
+func (db *fakeDB) Driver() driver.Driver { return fakeDriver{} }
+
+func (db *fakeDB) record(conn int, stmt string, args []driver.NamedValue) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	s := fakeStmt{conn: conn, stmt: strings.Join(strings.Fields(stmt), " ")}
+	for _, a := range args {
+		s.args = append(s.args, a.Value)
+	}
+	db.log = append(db.log, s)
+}
+
+// statements returns the statements recorded since the log was last taken
+func (db *fakeDB) statements() []fakeStmt {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	log := db.log
+	db.log = nil
+	return log
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fakeDriver: use sql.OpenDB")
+}
+
+type fakeConn struct {
+	db *fakeDB
+	id int
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakeConn: Prepare is not supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx,
+	error) {
+	c.db.record(c.id, "BEGIN", nil)
+	return fakeTx{c}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, stmt string,
+	args []driver.NamedValue) (driver.Result, error) {
+	c.db.record(c.id, stmt, args)
+	return driver.RowsAffected(0), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, stmt string,
+	args []driver.NamedValue) (driver.Rows, error) {
+	c.db.record(c.id, stmt, args)
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	switch {
+	case strings.Contains(stmt, "FROM schema_migrations"):
+		return &fakeRows{row: []driver.Value{c.db.version}}, nil
+	case strings.Contains(stmt, "pg_try_advisory_lock"):
+		return &fakeRows{row: []driver.Value{!c.db.locked}}, nil
+	}
+	return &fakeRows{}, nil
+}
+
+type fakeTx struct{ c *fakeConn }
+
+func (tx fakeTx) Commit() error {
+	tx.c.db.record(tx.c.id, "COMMIT", nil)
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.c.db.record(tx.c.id, "ROLLBACK", nil)
+	return nil
+}
+
+// fakeRows is a result of at most one single-column row
+type fakeRows struct {
+	row []driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	if r.row == nil {
+		return nil
+	}
+	return []string{"result"}
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.row == nil {
+		return io.EOF
+	}
+	copy(dest, r.row)
+	r.row = nil
+	return nil
+}
+
+// openFake returns a SQLStore in the Dialect d over a fakeDB whose schema
+// is at version, and the fakeDB, having discarded the migration statements
+func openFake(t *testing.T, d Dialect, version int) (*SQLStore, *fakeDB) {
+	t.Helper()
+	db := &fakeDB{version: int64(version)}
+	s, err := OpenSQL(context.Background(), sql.OpenDB(db), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.statements()
+	return s, db
+}
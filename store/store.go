@@ -0,0 +1,246 @@
+// store persists the history gathered about robots: their cleaning runs, the
+// states observed by a watch.Watcher, their schedules, the metadata of their
+// maps, and tokens such as Beehive access tokens. The Store interface is
+// implemented by a pure-Go file store for embedded devices and by a
+// database/sql store for servers, so that the code recording history need not
+// know which is in use. Offline serves the last recorded state, schedule and
+// maps of a robot while it cannot be reached.
+
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/richlj/neato/beehive"
+	"github.com/richlj/neato/nucleo"
+	"github.com/richlj/neato/watch"
+)
+
+// ErrNotFound is returned when a requested record does not exist
+var ErrNotFound = errors.New("store: not found")
+
+// Store is implemented by the storage backends
+type Store interface {
+	// PutRun records a cleaning run, replacing any run recorded for the
+	// same robot with the same start time
+	PutRun(ctx context.Context, r Run) error
+
+	// Runs returns the runs matching q, oldest first
+	Runs(ctx context.Context, q Query) ([]Run, error)
+
+	// AddState records an observed state
+	AddState(ctx context.Context, s State) error
+
+	// States returns the states matching q, oldest first
+	States(ctx context.Context, q Query) ([]State, error)
+
+	// AddBattery records a sample of a robot's battery
+	AddBattery(ctx context.Context, b BatterySample) error
+
+	// Battery returns the battery samples matching q, oldest first
+	Battery(ctx context.Context, q Query) ([]BatterySample, error)
+
+	// PutMap records the metadata of a map, replacing any recorded for
+	// the same robot with the same ID
+	PutMap(ctx context.Context, m MapMeta) error
+
+	// Maps returns the metadata of the maps matching q, oldest first
+	Maps(ctx context.Context, q Query) ([]MapMeta, error)
+
+	// PutSchedule records the last known schedule of a robot, replacing
+	// any recorded before
+	PutSchedule(ctx context.Context, sc Schedule) error
+
+	// Schedule returns the last known schedule of the robot with serial,
+	// or ErrNotFound
+	Schedule(ctx context.Context, robot string) (Schedule, error)
+
+	// PutToken stores a token under its name
+	PutToken(ctx context.Context, t Token) error
+
+	// Token returns the named token, or ErrNotFound
+	Token(ctx context.Context, name string) (Token, error)
+
+	// DeleteToken removes the named token
+	DeleteToken(ctx context.Context, name string) error
+
+	// Compact removes the records which p does not keep at now
+	Compact(ctx context.Context, p Policy, now time.Time) error
+
+	// Close releases the Store's resources
+	Close() error
+}
+
+// Query selects records. Zero values select everything.
+type Query struct {
+	// Robot selects the records of the robot with this serial number
+	Robot string
+
+	// Since and Until select records from Since up to but excluding Until
+	Since time.Time
+	Until time.Time
+
+	// Limit returns at most this many of the most recent records
+	Limit int
+}
+
+// Run is a recorded cleaning run
+type Run struct {
+	Robot        string    `json:"robot"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	Category     int       `json:"category"`
+	Mode         int       `json:"mode"`
+	Area         float64   `json:"area"`
+	LaunchedFrom string    `json:"launched_from"`
+	Completed    bool      `json:"completed"`
+}
+
+// State is a recorded observation of a robot
+type State struct {
+	Robot    string    `json:"robot"`
+	Time     time.Time `json:"time"`
+	State    int       `json:"state"`
+	Action   int       `json:"action"`
+	Charge   int       `json:"charge"`
+	Charging bool      `json:"charging"`
+	Docked   bool      `json:"docked"`
+	Alert    string    `json:"alert,omitempty"`
+	Error    string    `json:"error,omitempty"`
+
+	// Command is the command which led to the state, if known
+	Command string `json:"command,omitempty"`
+}
+
+// BatterySample is a recorded reading of a robot's battery. The durations
+// are zero when the robot does not report them.
+type BatterySample struct {
+	Robot        string        `json:"robot"`
+	Time         time.Time     `json:"time"`
+	Level        int           `json:"level"`
+	Charging     bool          `json:"charging"`
+	TimeToEmpty  time.Duration `json:"time_to_empty"`
+	TimeToFull   time.Duration `json:"time_to_full"`
+	TotalCharges int           `json:"total_charges"`
+}
+
+// MapMeta is the metadata of a map. The map image itself is not stored.
+type MapMeta struct {
+	Robot  string    `json:"robot"`
+	ID     string    `json:"id"`
+	RunID  string    `json:"run_id"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Status string    `json:"status"`
+	Area   float64   `json:"area"`
+	URL    string    `json:"url"`
+}
+
+// Schedule is the cleaning schedule of a robot, as fetched at Time
+type Schedule struct {
+	Robot   string         `json:"robot"`
+	Time    time.Time      `json:"time"`
+	Enabled bool           `json:"enabled"`
+	Events  []nucleo.Event `json:"events"`
+}
+
+// Token is a stored credential
+type Token struct {
+	Name    string    `json:"name"`
+	Value   string    `json:"value"`
+	Expires time.Time `json:"expires"`
+}
+
+// RunFromHistory returns the Run recorded by a robot's statistics
+func RunFromHistory(robot, category string, h nucleo.RunHistory) Run {
+	r := Run{
+		Robot:        robot,
+		Start:        h.Start,
+		End:          h.End,
+		Mode:         h.Mode,
+		Area:         h.Area,
+		LaunchedFrom: h.LaunchedFrom,
+		Completed:    h.Completed,
+	}
+	switch category {
+	case "houseCleaning":
+		r.Category = nucleo.CategoryHouse
+	case "spotCleaning":
+		r.Category = nucleo.CategorySpot
+	}
+	return r
+}
+
+// ScheduleFromResponse returns the Schedule in the response to getSchedule,
+// fetched at t
+func ScheduleFromResponse(robot string, t time.Time,
+	resp *nucleo.Response) Schedule {
+	return Schedule{
+		Robot:   robot,
+		Time:    t,
+		Enabled: resp.Data.Enabled,
+		Events:  resp.Data.Events,
+	}
+}
+
+// StateFromEvent returns the State observed in a watch.Event
+func StateFromEvent(e watch.Event) State {
+	return State{
+		Robot:    e.Robot,
+		Time:     e.Time,
+		State:    e.State,
+		Action:   e.Action,
+		Charge:   e.Charge,
+		Charging: e.Charging,
+		Docked:   e.Docked,
+		Alert:    e.Alert,
+		Error:    e.Error,
+	}
+}
+
+// MapFromBeehive returns the metadata of a map fetched from Beehive
+func MapFromBeehive(robot string, m *beehive.Map) MapMeta {
+	return MapMeta{
+		Robot:  robot,
+		ID:     m.ID,
+		RunID:  m.RunID,
+		Start:  m.StartAt,
+		End:    m.EndAt,
+		Status: m.Status,
+		Area:   m.CleanedArea,
+		URL:    m.URL,
+	}
+}
+
+// Record adds the States observed in the Events of w to s until ctx is
+// cancelled or w is shut down. Failed polls are ignored.
+func Record(ctx context.Context, s Store, w *watch.Watcher) error {
+	events, cancel := w.Subscribe()
+	defer cancel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if e.Type == watch.PollFailed {
+				continue
+			}
+			err := s.AddState(ctx, StateFromEvent(e))
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// matches reports whether a record of robot at t is selected by q
+func (q Query) matches(robot string, t time.Time) bool {
+	return (q.Robot == "" || q.Robot == robot) &&
+		(q.Since.IsZero() || !t.Before(q.Since)) &&
+		(q.Until.IsZero() || t.Before(q.Until))
+}
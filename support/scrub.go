@@ -0,0 +1,38 @@
+package support
+
+import (
+	"bytes"
+	"regexp"
+)
+
+const (
+	redacted = "[REDACTED]"
+)
+
+var (
+	// secretFields matches JSON fields whose names suggest credentials
+	secretFields = regexp.MustCompile(`(?i)("[a-z_]*(?:secret|token|` +
+		`password|passwd|key|authorization|cookie)[a-z_]*"\s*:\s*)` +
+		`"(?:[^"\\]|\\.)*"`)
+
+	// secretHeaders matches credentials in logged HTTP headers
+	secretHeaders = regexp.MustCompile(`(?i)((?:authorization|cookie|` +
+		`x-neato-signature)\s*[:=]\s*)[^\r\n]+`)
+
+	// emails matches email addresses, which identify account holders
+	emails = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.` +
+		`[A-Za-z]{2,}`)
+)
+
+// Scrub returns b with the supplied secrets, credentials in JSON fields and
+// HTTP headers, and email addresses replaced by "[REDACTED]"
+func Scrub(b []byte, secrets ...string) []byte {
+	for _, s := range secrets {
+		if s != "" {
+			b = bytes.Replace(b, []byte(s), []byte(redacted), -1)
+		}
+	}
+	b = secretFields.ReplaceAll(b, []byte(`$1"`+redacted+`"`))
+	b = secretHeaders.ReplaceAll(b, []byte("${1}"+redacted))
+	return emails.ReplaceAll(b, []byte(redacted))
+}
@@ -0,0 +1,207 @@
+// support gathers a diagnostic bundle to attach to a bug report against the
+// SDK: the model, firmware and raw state of each robot, a doctor report,
+// the configuration and the tail of recent logs, in a gzipped tarball.
+// Secret keys, credentials, email addresses and all but the end of each
+// serial number are scrubbed from everything in the bundle.
+
+package support
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/richlj/neato/clock"
+	"github.com/richlj/neato/nucleo"
+)
+
+const (
+	defaultMaxLogBytes = 1 << 20
+)
+
+// Bundle describes what to collect
+type Bundle struct {
+	Robots []*nucleo.Robot
+
+	// Config, if not nil, is included as JSON, e.g. a *config.Config
+	Config interface{}
+
+	// Logs are the paths of log files, of which the last MaxLogBytes are
+	// included. MaxLogBytes defaults to 1 MiB.
+	Logs        []string
+	MaxLogBytes int64
+
+	// Secrets are scrubbed in addition to the robots' secret keys, e.g.
+	// Beehive passwords and tokens
+	Secrets []string
+
+	// Clock dates the bundle. It defaults to clock.Real.
+	Clock clock.Clock
+}
+
+// Manifest describes a bundle, and lists what could not be collected
+type Manifest struct {
+	Created time.Time `json:"created"`
+	Go      string    `json:"go"`
+	OS      string    `json:"os"`
+	Arch    string    `json:"arch"`
+	Robots  []string  `json:"robots"`
+	Errors  []string  `json:"errors,omitempty"`
+}
+
+// Write collects the bundle and writes it to w as a gzipped tarball.
+// Failures to collect a part are listed in the bundle's manifest.json rather
+// than failing the whole bundle.
+func (b *Bundle) Write(ctx context.Context, w io.Writer) error {
+	now := clock.Or(b.Clock).Now()
+	secrets := append([]string(nil), b.Secrets...)
+	for _, r := range b.Robots {
+		secrets = append(secrets, r.SecretKey)
+	}
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	bw := &bundleWriter{tw: tw, now: now, secrets: secrets,
+		robots: b.Robots}
+	m := Manifest{Created: now, Go: runtime.Version(), OS: runtime.GOOS,
+		Arch: runtime.GOARCH}
+	for i, r := range b.Robots {
+		m.Robots = append(m.Robots, nucleo.MaskSerial(r.Serial))
+		dir := fmt.Sprintf("robots/%d/", i+1)
+		for _, err := range bw.robot(ctx, dir, r) {
+			m.Errors = append(m.Errors, fmt.Sprintf("robot %s: %v",
+				nucleo.MaskSerial(r.Serial), err))
+		}
+	}
+	if b.Config != nil {
+		if err := bw.json("config.json", b.Config); err != nil {
+			m.Errors = append(m.Errors,
+				fmt.Sprintf("config: %v", err))
+		}
+	}
+	for _, path := range b.Logs {
+		if err := bw.log(path, b.maxLogBytes()); err != nil {
+			m.Errors = append(m.Errors, fmt.Sprintf("log %s: %v",
+				filepath.Base(path), err))
+		}
+	}
+	if err := bw.json("manifest.json", &m); err != nil {
+		return err
+	}
+	if bw.err != nil {
+		return bw.err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func (b *Bundle) maxLogBytes() int64 {
+	if b.MaxLogBytes > 0 {
+		return b.MaxLogBytes
+	}
+	return defaultMaxLogBytes
+}
+
+// bundleWriter adds scrubbed files to a tarball, recording the first error
+// writing to it
+type bundleWriter struct {
+	tw      *tar.Writer
+	now     time.Time
+	secrets []string
+	robots  []*nucleo.Robot
+	err     error
+}
+
+// robot adds the information about r under dir, returning the errors
+// collecting it
+func (bw *bundleWriter) robot(ctx context.Context, dir string,
+	r *nucleo.Robot) []error {
+	var result []error
+	add := func(name string, v interface{}, err error) {
+		if err == nil {
+			err = bw.json(dir+name, v)
+		}
+		if err != nil {
+			result = append(result, fmt.Errorf("%s: %v", name, err))
+		}
+	}
+	add("robot.json", map[string]string{"name": r.Name, "model": r.Model},
+		nil)
+	info, err := r.GetGeneralInfo(nil)
+	add("general_info.json", info, err)
+	hw, err := r.GetRobotInfo(nil)
+	add("robot_info.json", hw, err)
+	state, err := r.GetRobotState(nil)
+	add("state.json", state, err)
+	caps, err := r.Capabilities()
+	add("capabilities.json", caps, err)
+	bw.file(dir+"doctor.txt", []byte(r.Doctor(ctx).String()))
+	return result
+}
+
+// log adds the last max bytes of the log file at path
+func (bw *bundleWriter) log(path string, max int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() > max {
+		if _, err := f.Seek(-max, io.SeekEnd); err != nil {
+			return err
+		}
+	}
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	bw.file("logs/"+filepath.Base(path), b)
+	return nil
+}
+
+// json adds v as an indented JSON file
+func (bw *bundleWriter) json(name string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	bw.file(name, b)
+	return nil
+}
+
+// file adds b, scrubbed, as the named file
+func (bw *bundleWriter) file(name string, b []byte) {
+	if bw.err != nil {
+		return
+	}
+	b = Scrub(b, bw.secrets...)
+	for _, r := range bw.robots {
+		if r.Serial != "" {
+			b = bytes.Replace(b, []byte(r.Serial),
+				[]byte(nucleo.MaskSerial(r.Serial)), -1)
+		}
+	}
+	bw.err = bw.tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(b)),
+		ModTime: bw.now.Truncate(time.Second),
+	})
+	if bw.err == nil {
+		_, bw.err = bw.tw.Write(b)
+	}
+}
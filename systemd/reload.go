@@ -0,0 +1,38 @@
+//go:build !js
+
+package systemd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// HandleReload calls reload on each SIGHUP until ctx is cancelled, telling
+// the service manager that the service is reloading and then ready again.
+// A failed reload is shown in the service's status and passed to report, if
+// not nil.
+func HandleReload(ctx context.Context, reload func() error,
+	report func(error)) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-hup:
+		}
+		Reloading()
+		err := reload()
+		if err != nil {
+			Notify("READY=1", "STATUS=reload failed: "+err.Error())
+			if report != nil {
+				report(err)
+			}
+			continue
+		}
+		Ready()
+	}
+}
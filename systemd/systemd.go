@@ -0,0 +1,136 @@
+// systemd integrates a long-running service built on the SDK with systemd:
+// readiness and status notifications over the sd_notify protocol, watchdog
+// pings which stop when the robots stop being polled successfully, and
+// reloading configuration on SIGHUP. Outside systemd, where NOTIFY_SOCKET is
+// unset, notifications are silently skipped. There are no signals under js,
+// so HandleReload is not built there.
+
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/richlj/neato/clock"
+	"github.com/richlj/neato/watch"
+)
+
+// Notify sends state, one or more lines of the form "KEY=value", to the
+// service manager. It does nothing when not run by systemd.
+func Notify(state ...string) error {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return nil
+	}
+	if strings.HasPrefix(path, "@") {
+		path = "\x00" + path[1:]
+	}
+	c, err := net.DialUnix("unixgram", nil,
+		&net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("systemd: %v", err)
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte(strings.Join(state, "\n"))); err != nil {
+		return fmt.Errorf("systemd: %v", err)
+	}
+	return nil
+}
+
+// Ready tells the service manager that start-up, or a reload, is complete
+func Ready() error {
+	return Notify("READY=1")
+}
+
+// Reloading tells the service manager that configuration is being reloaded
+func Reloading() error {
+	return Notify("RELOADING=1")
+}
+
+// Stopping tells the service manager that the service is shutting down
+func Stopping() error {
+	return Notify("STOPPING=1")
+}
+
+// Status sets the status line shown by systemctl status
+func Status(s string) error {
+	return Notify("STATUS=" + s)
+}
+
+// WatchdogInterval returns the interval within which the service manager
+// expects watchdog pings, and false if the watchdog is not enabled for this
+// process
+func WatchdogInterval() (time.Duration, bool) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+	if s := os.Getenv("WATCHDOG_PID"); s != "" {
+		pid, err := strconv.Atoi(s)
+		if err != nil || pid != os.Getpid() {
+			return 0, false
+		}
+	}
+	return time.Duration(usec) * time.Microsecond, true
+}
+
+// Watchdog pings the service manager's watchdog, but only while Kick has
+// been called since the previous ping, so that a service which is alive but
+// no longer polling its robots is restarted
+type Watchdog struct {
+	// Interval is the watchdog timeout, as returned by WatchdogInterval.
+	// Pings are sent at half of it.
+	Interval time.Duration
+
+	// Clock paces the pings. It defaults to clock.Real.
+	Clock clock.Clock
+
+	mu     sync.Mutex
+	kicked bool
+}
+
+// Kick records that the service made progress
+func (d *Watchdog) Kick() {
+	d.mu.Lock()
+	d.kicked = true
+	d.mu.Unlock()
+}
+
+// Watch sets w's OnPoll to Kick the Watchdog after each successful poll
+func (d *Watchdog) Watch(w *watch.Watcher) {
+	w.OnPoll = func(id string, err error) {
+		if err == nil {
+			d.Kick()
+		}
+	}
+}
+
+// Run pings the watchdog until ctx is cancelled. Failures to notify are
+// passed to report, if not nil.
+func (d *Watchdog) Run(ctx context.Context, report func(error)) error {
+	t := clock.Or(d.Clock).NewTicker(d.Interval / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C():
+		}
+		d.mu.Lock()
+		kicked := d.kicked
+		d.kicked = false
+		d.mu.Unlock()
+		if !kicked {
+			continue
+		}
+		if err := Notify("WATCHDOG=1"); err != nil && report != nil {
+			report(err)
+		}
+	}
+}
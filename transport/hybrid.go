@@ -0,0 +1,165 @@
+// transport chooses how requests reach each robot. Hybrid prefers the robot's
+// address on the local network, found by a discover.Tracker, and falls back
+// to the Nucleo cloud proxy when the robot cannot be found or reached there.
+//
+// Neato has not documented a local API, so the local endpoint must speak the
+// Nucleo message protocol, e.g. a bridge running beside the robot. Its URL is
+// built from the robot's address by LocalURL.
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/richlj/neato/clock"
+	"github.com/richlj/neato/discover"
+	"github.com/richlj/neato/nucleo"
+)
+
+const (
+	defaultLocalTimeout   = 2 * time.Second
+	defaultResolveTimeout = 3 * time.Second
+	defaultRetryLocal     = 5 * time.Minute
+)
+
+// Use records the route by which a call reached a robot
+type Use struct {
+	Serial string
+	Cmd    string
+	Route  string
+	Err    error
+	Time   time.Time
+}
+
+// Hybrid is a nucleo.Router which tries the local network before the cloud
+type Hybrid struct {
+	Tracker *discover.Tracker
+
+	// LocalURL returns the base URL of the local endpoint of the robot at
+	// ip. It defaults to plain HTTP on the address itself.
+	LocalURL func(ip net.IP) *url.URL
+
+	// Cloud is the base URL of the cloud route, as for nucleo.WithEndpoint.
+	// If nil, the Robot's own endpoint is used.
+	Cloud *url.URL
+
+	// LocalTimeout limits attempts by the local route, so that an
+	// unreachable robot leaves time for the cloud. It defaults to two
+	// seconds.
+	LocalTimeout time.Duration
+
+	// ResolveTimeout limits the discovery made when the robot's address is
+	// not known. It defaults to three seconds.
+	ResolveTimeout time.Duration
+
+	// RetryLocal is how long the local route is skipped after the robot
+	// could not be found or reached locally. It defaults to five minutes.
+	RetryLocal time.Duration
+
+	// Report, if not nil, is called after each attempt
+	Report func(Use)
+
+	// Clock dates the Uses and paces RetryLocal. It defaults to clock.Real.
+	Clock clock.Clock
+
+	mu        sync.Mutex
+	last      map[string]Use
+	skipUntil map[string]time.Time
+}
+
+// Routes returns the local route followed by the cloud route, or the cloud
+// route alone while the robot is not known to be reachable locally
+func (h *Hybrid) Routes(serial string) []nucleo.Route {
+	cloud := nucleo.Route{Name: nucleo.RouteCloud, Endpoint: h.Cloud}
+	if u := h.local(serial); u != nil {
+		timeout := h.LocalTimeout
+		if timeout <= 0 {
+			timeout = defaultLocalTimeout
+		}
+		return []nucleo.Route{{Name: nucleo.RouteLocal, Endpoint: u,
+			Timeout: timeout}, cloud}
+	}
+	return []nucleo.Route{cloud}
+}
+
+// Done records the outcome of an attempt. A local attempt which received no
+// response invalidates the robot's address, and the local route is skipped
+// for RetryLocal.
+func (h *Hybrid) Done(serial, cmd string, route nucleo.Route, err error) {
+	u := Use{Serial: serial, Cmd: cmd, Route: route.Name, Err: err,
+		Time: clock.Or(h.Clock).Now()}
+	var ue *url.Error
+	if route.Name == nucleo.RouteLocal && errors.As(err, &ue) {
+		h.Tracker.Invalidate(serial)
+		h.skip(serial)
+	}
+	h.mu.Lock()
+	if h.last == nil {
+		h.last = make(map[string]Use)
+	}
+	h.last[serial] = u
+	h.mu.Unlock()
+	if h.Report != nil {
+		h.Report(u)
+	}
+}
+
+// Last returns the most recent attempt to reach the robot with serial
+func (h *Hybrid) Last(serial string) (Use, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	u, ok := h.last[serial]
+	return u, ok
+}
+
+// local returns the base URL of the robot's local endpoint, or nil if the
+// local route is to be skipped
+func (h *Hybrid) local(serial string) *url.URL {
+	if h.Tracker == nil {
+		return nil
+	}
+	h.mu.Lock()
+	until := h.skipUntil[serial]
+	h.mu.Unlock()
+	if clock.Or(h.Clock).Now().Before(until) {
+		return nil
+	}
+	limit := h.ResolveTimeout
+	if limit <= 0 {
+		limit = defaultResolveTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), limit)
+	defer cancel()
+	ip, err := h.Tracker.Resolve(ctx, serial)
+	if err != nil {
+		h.skip(serial)
+		return nil
+	}
+	if h.LocalURL != nil {
+		return h.LocalURL(ip)
+	}
+	host := ip.String()
+	if ip.To4() == nil {
+		host = "[" + host + "]"
+	}
+	return &url.URL{Scheme: "http", Host: host}
+}
+
+// skip skips the local route to the robot with serial for RetryLocal
+func (h *Hybrid) skip(serial string) {
+	d := h.RetryLocal
+	if d <= 0 {
+		d = defaultRetryLocal
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.skipUntil == nil {
+		h.skipUntil = make(map[string]time.Time)
+	}
+	h.skipUntil[serial] = clock.Or(h.Clock).Now().Add(d)
+}
@@ -0,0 +1,68 @@
+// units wraps the quantities reported by the robots, which give cleaned areas
+// in square metres and durations in seconds, so that they can be converted
+// and formatted for the user's locale rather than converted by hand.
+
+package units
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	squareFeetPerSquareMeter = 10.7639104
+)
+
+// Area is a floor area in square metres
+type Area float64
+
+// SquareMeters returns the Area in square metres
+func (a Area) SquareMeters() float64 {
+	return float64(a)
+}
+
+// SquareFeet returns the Area in square feet
+func (a Area) SquareFeet() float64 {
+	return float64(a) * squareFeetPerSquareMeter
+}
+
+// String returns the Area in square metres, e.g. "23.4 m²". Use an
+// i18n.Formatter for locale-aware output.
+func (a Area) String() string {
+	return fmt.Sprintf("%.1f m²", a.SquareMeters())
+}
+
+// Duration is the length of a cleaning run or similar robot activity
+type Duration time.Duration
+
+// Seconds converts a number of seconds, as reported by the robots, into a
+// Duration
+func Seconds(s int) Duration {
+	return Duration(time.Duration(s) * time.Second)
+}
+
+// Minutes returns the Duration as a floating point number of minutes
+func (d Duration) Minutes() float64 {
+	return time.Duration(d).Minutes()
+}
+
+// RoundedMinutes returns the Duration rounded to the nearest whole minute
+func (d Duration) RoundedMinutes() int {
+	return int(math.Round(d.Minutes()))
+}
+
+// String returns the Duration to the nearest minute, e.g. "41 min". Use an
+// i18n.Formatter for locale-aware output.
+func (d Duration) String() string {
+	return fmt.Sprintf("%d min", d.RoundedMinutes())
+}
+
+// UnitSystem determines which units quantities are presented in
+type UnitSystem int
+
+// The supported UnitSystems
+const (
+	Metric UnitSystem = iota
+	Imperial
+)
@@ -0,0 +1,57 @@
+package units
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestArea(t *testing.T) {
+	tests := []struct {
+		area       Area
+		squareFeet float64
+		str        string
+	}{
+		{0, 0, "0.0 m²"},
+		{1, 10.7639104, "1.0 m²"},
+		{23.4, 251.8755, "23.4 m²"},
+	}
+	for _, tt := range tests {
+		a := tt.area
+		if got := a.SquareMeters(); got != float64(a) {
+			t.Errorf("Area(%v).SquareMeters() = %v", a, got)
+		}
+		if got := a.SquareFeet(); math.Abs(got-tt.squareFeet) > 1e-3 {
+			t.Errorf("Area(%v).SquareFeet() = %v, want %v", a, got,
+				tt.squareFeet)
+		}
+		if got := a.String(); got != tt.str {
+			t.Errorf("Area(%v).String() = %q, want %q", a, got,
+				tt.str)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		d       Duration
+		minutes int
+		str     string
+	}{
+		{Seconds(0), 0, "0 min"},
+		{Seconds(29), 0, "0 min"},
+		{Seconds(30), 1, "1 min"},
+		{Seconds(41 * 60), 41, "41 min"},
+		{Duration(72*time.Minute + 31*time.Second), 73, "73 min"},
+	}
+	for _, tt := range tests {
+		d := time.Duration(tt.d)
+		if got := tt.d.RoundedMinutes(); got != tt.minutes {
+			t.Errorf("%v.RoundedMinutes() = %d, want %d", d, got,
+				tt.minutes)
+		}
+		if got := tt.d.String(); got != tt.str {
+			t.Errorf("%v.String() = %q, want %q", d, got, tt.str)
+		}
+	}
+}
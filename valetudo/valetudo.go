@@ -0,0 +1,238 @@
+// valetudo converts data exported from robots running Valetudo-class local
+// firmware into this SDK's models, for fleets which mix them with Neato
+// robots. Virtual walls, no-go areas, zone presets and timers are converted
+// where they mean the same thing to a Neato robot. Restrictions and timers
+// with no Neato equivalent, such as no-mop areas, are listed in the Result's
+// Skipped rather than approximated. Segments (rooms) are not converted, as
+// Neato maps have none.
+//
+// Valetudo positions are in centimetres from the top left of its map. They
+// are placed on a Neato persistent map by a floorplan.Transform, given the
+// position in metres on the Neato map of the Valetudo map's origin.
+
+package valetudo
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/richlj/neato/decode"
+	"github.com/richlj/neato/floorplan"
+	"github.com/richlj/neato/nucleo"
+)
+
+const (
+	centimetersPerMeter = 100
+
+	modeEco = 1
+
+	entityVirtualWall = "virtual_wall"
+	entityNoGoArea    = "no_go_area"
+	entityNoMopArea   = "no_mop_area"
+
+	actionFullCleanup = "full_cleanup"
+)
+
+// Result is the outcome of a conversion
+type Result struct {
+	Boundaries []nucleo.Boundary
+	Events     []nucleo.Event
+
+	// Skipped describes each item which could not be converted, and why
+	Skipped []string
+}
+
+// Placement positions Valetudo coordinates on a Neato persistent map
+type Placement struct {
+	Transform floorplan.Transform
+
+	// Origin is the position in metres on the Neato map of the Valetudo
+	// map's origin
+	Origin floorplan.Point
+}
+
+type point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type mapEntity struct {
+	Type   string    `json:"type"`
+	Points []float64 `json:"points"`
+}
+
+type valetudoMap struct {
+	Class    string      `json:"__class"`
+	Entities []mapEntity `json:"entities"`
+}
+
+type zonePreset struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Zones []struct {
+		Points map[string]point `json:"points"`
+	} `json:"zones"`
+}
+
+type timer struct {
+	ID      string `json:"id"`
+	Label   string `json:"label"`
+	Enabled bool   `json:"enabled"`
+	Dow     []int  `json:"dow"`
+	Hour    int    `json:"hour"`
+	Minute  int    `json:"minute"`
+	Action  struct {
+		Type string `json:"type"`
+	} `json:"action"`
+}
+
+// Map converts the virtual walls and no-go areas of a ValetudoMap to no-go
+// line Boundaries. Neato robots have no no-go areas, so each is drawn as a
+// closed line around the area, which the robot will not cross.
+func Map(r io.Reader, p Placement) (*Result, error) {
+	var m valetudoMap
+	if err := decode.Decode(r, &m); err != nil {
+		return nil, fmt.Errorf("valetudo map: %v", err)
+	}
+	if m.Class != "" && m.Class != "ValetudoMap" {
+		return nil, fmt.Errorf("valetudo map: unexpected class %q",
+			m.Class)
+	}
+	var result Result
+	for i, e := range m.Entities {
+		switch e.Type {
+		case entityVirtualWall, entityNoGoArea:
+		case entityNoMopArea:
+			result.skip("entity %d (%s): Neato robots do not "+
+				"mop", i, e.Type)
+			continue
+		default:
+			continue
+		}
+		points, err := p.points(e.Points)
+		if err != nil {
+			result.skip("entity %d (%s): %v", i, e.Type, err)
+			continue
+		}
+		if e.Type == entityNoGoArea {
+			points = append(points, points[0])
+		}
+		result.Boundaries = append(result.Boundaries,
+			p.Transform.LineFromMeters(points...))
+	}
+	return &result, nil
+}
+
+// ZonePresets converts Valetudo zone presets to zone Boundaries. A preset of
+// several zones becomes one Boundary per zone, numbered after the first.
+func ZonePresets(r io.Reader, p Placement) (*Result, error) {
+	var presets map[string]zonePreset
+	if err := decode.Decode(r, &presets); err != nil {
+		return nil, fmt.Errorf("valetudo zone presets: %v", err)
+	}
+	var result Result
+	ids := make([]string, 0, len(presets))
+	for id := range presets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		z := presets[id]
+		for i, zone := range z.Zones {
+			var coords []float64
+			for _, k := range []string{"pA", "pB", "pC", "pD"} {
+				c, ok := zone.Points[k]
+				if !ok {
+					coords = nil
+					break
+				}
+				coords = append(coords, c.X, c.Y)
+			}
+			points, err := p.points(coords)
+			if err != nil {
+				result.skip("zone preset %q: %v", z.Name, err)
+				continue
+			}
+			b := p.Transform.PolygonFromMeters(points...)
+			b.Name = z.Name
+			if i > 0 {
+				b.Name = fmt.Sprintf("%s %d", z.Name, i+1)
+			}
+			result.Boundaries = append(result.Boundaries, b)
+		}
+	}
+	return &result, nil
+}
+
+// Timers converts Valetudo timers, which are kept in UTC, to schedule Events
+// in loc, using the offset from UTC in effect at the supplied time. Only
+// enabled timers which clean the whole home are converted.
+func Timers(r io.Reader, loc *time.Location, at time.Time) (*Result,
+	error) {
+	var timers map[string]timer
+	if err := decode.Decode(r, &timers); err != nil {
+		return nil, fmt.Errorf("valetudo timers: %v", err)
+	}
+	_, offset := at.In(loc).Zone()
+	var result Result
+	ids := make([]string, 0, len(timers))
+	for id := range timers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		t := timers[id]
+		name := t.Label
+		if name == "" {
+			name = id
+		}
+		switch {
+		case !t.Enabled:
+			result.skip("timer %q: disabled", name)
+			continue
+		case t.Action.Type != actionFullCleanup:
+			result.skip("timer %q: action %q is not supported",
+				name, t.Action.Type)
+			continue
+		}
+		for _, d := range t.Dow {
+			m := d*24*60 + t.Hour*60 + t.Minute + offset/60
+			m = (m%(7*24*60) + 7*24*60) % (7 * 24 * 60)
+			day, hour, minute := m/(24*60), m/60%24, m%60
+			start := fmt.Sprintf("%02d:%02d", hour, minute)
+			e := nucleo.NewEvent(time.Weekday(day), start, modeEco)
+			result.Events = append(result.Events, e)
+		}
+	}
+	sort.SliceStable(result.Events, func(a, b int) bool {
+		ea, eb := result.Events[a], result.Events[b]
+		if ea.Day != eb.Day {
+			return ea.Day < eb.Day
+		}
+		return ea.StartTime < eb.StartTime
+	})
+	return &result, nil
+}
+
+// skip records an item which could not be converted
+func (r *Result) skip(format string, a ...interface{}) {
+	r.Skipped = append(r.Skipped, fmt.Sprintf(format, a...))
+}
+
+// points converts flat Valetudo coordinates, x then y in centimetres, to
+// positions in metres on the Neato map
+func (p Placement) points(coords []float64) ([]floorplan.Point, error) {
+	if len(coords) < 4 || len(coords)%2 != 0 {
+		return nil, fmt.Errorf("malformed points")
+	}
+	result := make([]floorplan.Point, len(coords)/2)
+	for i := range result {
+		result[i] = floorplan.Point{
+			X: p.Origin.X + coords[2*i]/centimetersPerMeter,
+			Y: p.Origin.Y + coords[2*i+1]/centimetersPerMeter,
+		}
+	}
+	return result, nil
+}
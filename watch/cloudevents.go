@@ -0,0 +1,49 @@
+package watch
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"time"
+)
+
+const (
+	// CloudEventsContentType is the media type of a CloudEvent in the
+	// structured JSON mode
+	CloudEventsContentType = "application/cloudevents+json"
+
+	cloudEventsVersion = "1.0"
+	cloudEventsPrefix  = "io.github.richlj.neato."
+	cloudEventsIDBytes = 16
+)
+
+// CloudEvent is an Event in the CloudEvents 1.0 envelope, which every sink
+// publishes so that downstream routers can handle them generically. Its
+// Type is the Event type prefixed with "io.github.richlj.neato.", its
+// Source the robot's ID and its Data the Event itself.
+type CloudEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            Event     `json:"data"`
+}
+
+// CloudEvent returns the Event in a CloudEvents envelope with a new ID
+func (e Event) CloudEvent() (CloudEvent, error) {
+	b := make([]byte, cloudEventsIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		return CloudEvent{}, err
+	}
+	return CloudEvent{
+		SpecVersion:     cloudEventsVersion,
+		ID:              hex.EncodeToString(b),
+		Source:          url.PathEscape(e.Robot),
+		Type:            cloudEventsPrefix + string(e.Type),
+		Time:            e.Time,
+		DataContentType: "application/json",
+		Data:            e,
+	}, nil
+}
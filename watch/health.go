@@ -0,0 +1,113 @@
+package watch
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultStaleAfter is the time without a successful poll after which
+	// a robot is unhealthy, when no other is given
+	DefaultStaleAfter = time.Hour
+)
+
+// HealthStatus summarises the health of a robot, or of all of them
+type HealthStatus string
+
+// The HealthStatuses, from best to worst
+const (
+	Healthy   HealthStatus = "healthy"
+	Degraded  HealthStatus = "degraded"
+	Unhealthy HealthStatus = "unhealthy"
+)
+
+// RobotHealth is the health of a single robot. A robot is Healthy if its
+// last poll succeeded, Degraded if it failed or it has yet to be polled, and
+// Unhealthy if it has not been polled successfully for the staleness limit.
+type RobotHealth struct {
+	Robot               string       `json:"robot"`
+	Name                string       `json:"name,omitempty"`
+	Status              HealthStatus `json:"status"`
+	LastSuccess         *time.Time   `json:"last_success,omitempty"`
+	LastFailure         *time.Time   `json:"last_failure,omitempty"`
+	LastError           string       `json:"last_error,omitempty"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+
+	// Staleness is the time since the last successful poll, or since the
+	// robot was added if it has never been polled successfully
+	Staleness float64 `json:"staleness_seconds"`
+}
+
+// Health is the health of every robot of a Watcher. Its Status is that of
+// the least healthy robot, or Healthy if there are none.
+type Health struct {
+	Status HealthStatus  `json:"status"`
+	Time   time.Time     `json:"time"`
+	Robots []RobotHealth `json:"robots"`
+}
+
+// Health reports the health of the robots, treating those not polled
+// successfully within staleAfter as Unhealthy. A zero staleAfter means
+// DefaultStaleAfter.
+func (w *Watcher) Health(staleAfter time.Duration) Health {
+	if staleAfter <= 0 {
+		staleAfter = DefaultStaleAfter
+	}
+	now := w.now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	result := Health{Status: Healthy, Time: now,
+		Robots: make([]RobotHealth, 0, len(w.robots))}
+	for _, r := range w.robots {
+		h := RobotHealth{Robot: r.id, Status: Healthy,
+			ConsecutiveFailures: r.failures}
+		if r.last != nil {
+			h.Name = r.last.Robot
+		}
+		since := r.added
+		if !r.succeeded.IsZero() {
+			t := r.succeeded
+			h.LastSuccess, since = &t, t
+		}
+		if !r.failed.IsZero() {
+			t := r.failed
+			h.LastFailure, h.LastError = &t, r.err.Error()
+		}
+		h.Staleness = now.Sub(since).Seconds()
+		switch {
+		case now.Sub(since) >= staleAfter:
+			h.Status = Unhealthy
+		case r.failures > 0 || r.succeeded.IsZero():
+			h.Status = Degraded
+		}
+		if worse(h.Status, result.Status) {
+			result.Status = h.Status
+		}
+		result.Robots = append(result.Robots, h)
+	}
+	return result
+}
+
+// HealthCheck returns a handler which reports the Health of a Watcher as
+// JSON, for a /healthz endpoint. It responds 503 Service Unavailable when
+// any robot is Unhealthy, so that orchestrators can alert on a robot which
+// has been unreachable for staleAfter although the process is alive.
+func HealthCheck(w *Watcher, staleAfter time.Duration) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter,
+		req *http.Request) {
+		h := w.Health(staleAfter)
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Cache-Control", "no-cache")
+		if h.Status == Unhealthy {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(rw).Encode(&h)
+	})
+}
+
+// worse reports whether a is a worse HealthStatus than b
+func worse(a, b HealthStatus) bool {
+	rank := map[HealthStatus]int{Healthy: 0, Degraded: 1, Unhealthy: 2}
+	return rank[a] > rank[b]
+}
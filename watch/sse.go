@@ -0,0 +1,65 @@
+package watch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	heartbeatInterval = 30 * time.Second
+)
+
+// EventStream returns a handler which streams the Events of a Watcher as
+// Server-Sent Events, one flat JSON object per event, for consumption by
+// Node-RED and similar tools. With the query parameter
+// format=cloudevents, each event is sent as a CloudEvent instead.
+func EventStream(w *Watcher) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter,
+		req *http.Request) {
+		f, ok := rw.(http.Flusher)
+		if !ok {
+			http.Error(rw, "streaming unsupported",
+				http.StatusInternalServerError)
+			return
+		}
+		cloud := req.URL.Query().Get("format") == "cloudevents"
+		events, cancel := w.Subscribe()
+		defer cancel()
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.Header().Set("Cache-Control", "no-cache")
+		rw.Header().Set("Connection", "keep-alive")
+		rw.WriteHeader(http.StatusOK)
+		f.Flush()
+		t := time.NewTicker(heartbeatInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-req.Context().Done():
+				return
+			case <-t.C:
+				fmt.Fprint(rw, ": heartbeat\n\n")
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				var v interface{} = e
+				if cloud {
+					ce, err := e.CloudEvent()
+					if err != nil {
+						continue
+					}
+					v = ce
+				}
+				b, err := json.Marshal(v)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(rw, "event: %s\ndata: %s\n\n",
+					e.Type, b)
+			}
+			f.Flush()
+		}
+	})
+}
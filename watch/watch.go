@@ -0,0 +1,539 @@
+// watch polls robots for their state and turns the differences between
+// successive observations into Events: state changes, alerts, and the start
+// and completion of cleaning runs. Events are delivered to subscribers, and
+// can be streamed over HTTP as Server-Sent Events. The health of each robot,
+// judged by the success of its polls, is reported for health checks.
+
+package watch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/richlj/neato/clock"
+	"github.com/richlj/neato/nucleo"
+)
+
+const (
+	subscriberBuffer = 64
+)
+
+// EventType identifies the kind of an Event
+type EventType string
+
+// The types of Event emitted by a Watcher
+const (
+	StateChanged EventType = "state_changed"
+	AlertRaised  EventType = "alert"
+	RunStarted   EventType = "run_started"
+	RunCompleted EventType = "run_completed"
+	PollFailed   EventType = "poll_failed"
+
+	// BatteryDegraded is emitted by battery.Monitor rather than by a
+	// Watcher, so that it can be delivered by the same sinks
+	BatteryDegraded EventType = "battery_degraded"
+)
+
+// Event is a single observation of note about a robot. Its fields are flat so
+// that it is straightforward to consume as JSON from low-code tools.
+type Event struct {
+	Type     EventType `json:"type"`
+	Robot    string    `json:"robot"`
+	Name     string    `json:"name"`
+	Time     time.Time `json:"time"`
+	State    int       `json:"state"`
+	Action   int       `json:"action"`
+	Charge   int       `json:"charge"`
+	Charging bool      `json:"charging"`
+	Docked   bool      `json:"docked"`
+	Alert    string    `json:"alert,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	Summary  string    `json:"summary,omitempty"`
+}
+
+// Robot is the subset of a nucleo.RobotService used by a Watcher
+type Robot interface {
+	State() (*nucleo.RobotState, error)
+}
+
+type watched struct {
+	id    string
+	robot Robot
+	last  *nucleo.RobotState
+	next  time.Time
+	// running records that the robot is part way through a run, from
+	// RunStarted until it is next idle, including whilst it docks at the
+	// end of the run. It is guarded, with last, by the Watcher's mu.
+	running bool
+
+	// added, succeeded, failed, err and failures record the robot's
+	// health, guarded by the Watcher's mu
+	added     time.Time
+	succeeded time.Time
+	failed    time.Time
+	err       error
+	failures  int
+}
+
+// Watcher polls a set of robots. Each robot is polled every Interval, unless
+// ActiveInterval or IdleInterval is set, in which case robots that are
+// cleaning or returning to base are polled every ActiveInterval and robots
+// idle on their base every IdleInterval.
+//
+// Polls are staggered so that no two fall within Spacing of one another,
+// rather than every robot being polled in the same burst. A zero Spacing
+// spreads the robots evenly across Interval.
+type Watcher struct {
+	Interval       time.Duration
+	ActiveInterval time.Duration
+	IdleInterval   time.Duration
+	Spacing        time.Duration
+
+	// Clock schedules the polls and dates Events. It defaults to
+	// clock.Real.
+	Clock clock.Clock
+
+	// OnShutdown, if set, is called by Shutdown for each robot last seen
+	// part way through a run, e.g. PauseOnShutdown or DockOnShutdown
+	OnShutdown func(id string, r Robot) error
+
+	// OnPoll, if set, is called after each poll of a robot with its error,
+	// if any, e.g. to feed a watchdog
+	OnPoll func(id string, err error)
+
+	mu          sync.Mutex
+	robots      []*watched
+	subscribers map[chan Event]struct{}
+	wake        chan struct{}
+	stop        chan struct{}
+	stopped     bool
+	closed      bool
+	running     sync.WaitGroup
+}
+
+// New returns a Watcher which polls its robots every interval
+func New(interval time.Duration) *Watcher {
+	return &Watcher{
+		Interval:    interval,
+		subscribers: make(map[chan Event]struct{}),
+		wake:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+	}
+}
+
+// Add starts watching a robot, identified in Events by id, typically its
+// serial number
+func (w *Watcher) Add(id string, r Robot) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	wr := &watched{id: id, robot: r, added: w.now()}
+	w.robots = append(w.robots, wr)
+	wr.next = w.slot(wr, w.now())
+}
+
+// Remove stops watching the identified robot, reporting whether it was being
+// watched
+func (w *Watcher) Remove(id string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, r := range w.robots {
+		if r.id == id {
+			w.robots = append(w.robots[:i], w.robots[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// IDs returns the identifiers of the robots being watched
+func (w *Watcher) IDs() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	result := make([]string, len(w.robots))
+	for i, r := range w.robots {
+		result[i] = r.id
+	}
+	return result
+}
+
+// Intervals are the polling intervals of a Watcher, as described there
+type Intervals struct {
+	Interval       time.Duration
+	ActiveInterval time.Duration
+	IdleInterval   time.Duration
+	Spacing        time.Duration
+}
+
+// SetIntervals changes the polling intervals, which is safe whilst Run is
+// polling, unlike setting the fields directly. Polls already scheduled
+// further ahead than the new intervals allow are brought forward.
+func (w *Watcher) SetIntervals(iv Intervals) {
+	w.mu.Lock()
+	w.Interval, w.ActiveInterval = iv.Interval, iv.ActiveInterval
+	w.IdleInterval, w.Spacing = iv.IdleInterval, iv.Spacing
+	now := w.now()
+	for _, r := range w.robots {
+		if limit := now.Add(w.interval(r.last)); r.next.After(limit) {
+			r.next = w.slot(r, limit)
+		}
+	}
+	w.mu.Unlock()
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Subscribe returns a channel on which Events are delivered, and a function
+// which cancels the subscription and closes the channel. Events are dropped
+// rather than delaying polling if the subscriber falls behind. The channel
+// is also closed by Shutdown, once the last Events have been sent.
+func (w *Watcher) Subscribe() (<-chan Event, func()) {
+	c := make(chan Event, subscriberBuffer)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		close(c)
+		return c, func() {}
+	}
+	w.subscribers[c] = struct{}{}
+	return c, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if _, ok := w.subscribers[c]; ok {
+			delete(w.subscribers, c)
+			close(c)
+		}
+	}
+}
+
+// Run polls each robot as it falls due until ctx is cancelled or the Watcher
+// is shut down, when it returns nil
+func (w *Watcher) Run(ctx context.Context) error {
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return nil
+	}
+	w.running.Add(1)
+	w.mu.Unlock()
+	defer w.running.Done()
+	for {
+		select {
+		case <-w.stop:
+			return nil
+		default:
+		}
+		now := w.now()
+		for _, r := range w.due(now) {
+			w.poll(r)
+			w.mu.Lock()
+			r.next = w.slot(r, now.Add(w.interval(r.last)))
+			w.mu.Unlock()
+		}
+		t := clock.Or(w.Clock).NewTimer(w.untilNext(w.now()))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-w.stop:
+			t.Stop()
+			return nil
+		case <-w.wake:
+			t.Stop()
+		case <-t.C():
+		}
+	}
+}
+
+// Shutdown stops polling, waiting for Run to finish any poll in progress,
+// then calls OnShutdown for the robots part way through a run and closes the
+// subscribers' channels, so that consumers such as journal.Journal.Follow
+// record the last Events and return. It gives up waiting when ctx is done.
+// The first error from OnShutdown is returned.
+func (w *Watcher) Shutdown(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.stopped {
+		w.stopped = true
+		close(w.stop)
+	}
+	w.mu.Unlock()
+	stopped := make(chan struct{})
+	go func() {
+		w.running.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	w.mu.Lock()
+	var robots []*watched
+	for _, r := range w.robots {
+		if r.last != nil && inRun(r.last) {
+			robots = append(robots, r)
+		}
+	}
+	w.mu.Unlock()
+	var result error
+	if w.OnShutdown != nil {
+		for _, r := range robots {
+			if err := w.OnShutdown(r.id, r.robot); err != nil &&
+				result == nil {
+				result = err
+			}
+		}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		w.closed = true
+		for c := range w.subscribers {
+			delete(w.subscribers, c)
+			close(c)
+		}
+	}
+	return result
+}
+
+// Close shuts the Watcher down without a deadline
+func (w *Watcher) Close() error {
+	return w.Shutdown(context.Background())
+}
+
+// PauseOnShutdown may be used as a Watcher's OnShutdown to pause the runs
+// of robots which implement nucleo.CleaningService
+func PauseOnShutdown(id string, r Robot) error {
+	c, ok := r.(nucleo.CleaningService)
+	if !ok {
+		return errors.New("watch: " + id + " cannot be paused")
+	}
+	_, err := c.PauseCleaning(nil)
+	return err
+}
+
+// DockOnShutdown may be used as a Watcher's OnShutdown to send robots which
+// implement nucleo.CleaningService back to their base
+func DockOnShutdown(id string, r Robot) error {
+	c, ok := r.(nucleo.CleaningService)
+	if !ok {
+		return errors.New("watch: " + id + " cannot be sent to base")
+	}
+	_, err := c.SendToBase(nil)
+	return err
+}
+
+// Recheck makes Run poll the identified robot immediately, e.g. after a
+// command has been issued to it, rather than waiting for its next poll
+func (w *Watcher) Recheck(id string) {
+	w.mu.Lock()
+	for _, r := range w.robots {
+		if r.id == id {
+			r.next = time.Time{}
+		}
+	}
+	w.mu.Unlock()
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// slot returns the earliest time from t at which r may be polled without
+// falling within the spacing of another robot's poll. w.mu must be held.
+func (w *Watcher) slot(r *watched, t time.Time) time.Time {
+	gap := w.Spacing
+	if gap <= 0 {
+		gap = w.Interval / time.Duration(len(w.robots))
+	}
+	if gap <= 0 {
+		return t
+	}
+	for moved := true; moved; {
+		moved = false
+		for _, o := range w.robots {
+			if o == r || o.next.IsZero() {
+				continue
+			}
+			if d := t.Sub(o.next); d > -gap && d < gap {
+				t = o.next.Add(gap)
+				moved = true
+			}
+		}
+	}
+	return t
+}
+
+// due returns the robots whose next poll is no later than now
+func (w *Watcher) due(now time.Time) []*watched {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var result []*watched
+	for _, r := range w.robots {
+		if !r.next.After(now) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// untilNext returns the time from now until the next robot falls due
+func (w *Watcher) untilNext(now time.Time) time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	result := w.Interval
+	for _, r := range w.robots {
+		if d := r.next.Sub(now); d < result {
+			result = d
+		}
+	}
+	if result < 0 {
+		return 0
+	}
+	return result
+}
+
+// interval returns how long to wait before polling a robot last seen in
+// state s
+func (w *Watcher) interval(s *nucleo.RobotState) time.Duration {
+	switch {
+	case s == nil:
+	case w.ActiveInterval > 0 &&
+		(inRun(s) || s.Action == nucleo.ActionDocking):
+		return w.ActiveInterval
+	case w.IdleInterval > 0 && s.Docked && s.State == nucleo.StateIdle:
+		return w.IdleInterval
+	}
+	return w.Interval
+}
+
+// Poll checks every robot once, emitting any resulting Events
+func (w *Watcher) Poll() {
+	w.mu.Lock()
+	robots := make([]*watched, len(w.robots))
+	copy(robots, w.robots)
+	w.mu.Unlock()
+	for _, r := range robots {
+		w.poll(r)
+	}
+}
+
+func (w *Watcher) poll(r *watched) {
+	s, err := r.robot.State()
+	now := w.now()
+	if w.OnPoll != nil {
+		w.OnPoll(r.id, err)
+	}
+	w.mu.Lock()
+	if err != nil {
+		r.failed, r.err = now, err
+		r.failures++
+		e := Event{Type: PollFailed, Robot: r.id, Time: now,
+			Message: err.Error()}
+		if r.last != nil {
+			e.Name = r.last.Robot
+		}
+		w.mu.Unlock()
+		w.publish(e)
+		return
+	}
+	r.succeeded, r.failures = now, 0
+	var types []EventType
+	types, r.running = changes(r.last, s, r.running)
+	r.last = s
+	w.mu.Unlock()
+	for _, t := range types {
+		w.publish(newEvent(t, r.id, now, s))
+	}
+}
+
+// changes returns the types of Event represented by the transition from
+// prev to cur, where prev is nil for the first observation, and whether the
+// robot is part way through a run after it, given whether it was before. A
+// run lasts from when the robot starts cleaning until it is next idle, so
+// that returning to base at the end of a run is part of it.
+func changes(prev, cur *nucleo.RobotState,
+	running bool) ([]EventType, bool) {
+	if prev == nil {
+		return []EventType{StateChanged}, inRun(cur)
+	}
+	var result []EventType
+	if prev.State != cur.State || prev.Action != cur.Action ||
+		prev.Docked != cur.Docked || prev.Charging != cur.Charging {
+		result = append(result, StateChanged)
+	}
+	if (cur.Alert != "" && cur.Alert != prev.Alert) ||
+		(cur.Error != "" && cur.Error != prev.Error) {
+		result = append(result, AlertRaised)
+	}
+	switch {
+	case !running && cleaning(cur):
+		result = append(result, RunStarted)
+		running = true
+	case running && cur.State == nucleo.StateIdle:
+		result = append(result, RunCompleted)
+		running = false
+	}
+	return result, running
+}
+
+// cleaning reports whether the robot is actively cleaning
+func cleaning(s *nucleo.RobotState) bool {
+	return s.State == nucleo.StateBusy && runAction(s.Action)
+}
+
+// inRun reports whether the robot is part way through a run, including
+// whilst paused or recharging mid-run
+func inRun(s *nucleo.RobotState) bool {
+	return s.State != nucleo.StateIdle &&
+		(runAction(s.Action) || s.Action == nucleo.ActionSuspended)
+}
+
+func runAction(a nucleo.Action) bool {
+	switch a {
+	case nucleo.ActionHouseCleaning, nucleo.ActionSpotCleaning,
+		nucleo.ActionManualCleaning, nucleo.ActionMapCleaning:
+		return true
+	}
+	return false
+}
+
+func newEvent(t EventType, id string, now time.Time,
+	s *nucleo.RobotState) Event {
+	return Event{
+		Type:     t,
+		Robot:    id,
+		Name:     s.Robot,
+		Time:     now,
+		State:    int(s.State),
+		Action:   int(s.Action),
+		Charge:   s.Charge,
+		Charging: s.Charging,
+		Docked:   s.Docked,
+		Alert:    s.Alert,
+		Error:    s.Error,
+		Summary:  s.Summary(),
+	}
+}
+
+func (w *Watcher) now() time.Time {
+	return clock.Or(w.Clock).Now()
+}
+
+func (w *Watcher) publish(e Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	for c := range w.subscribers {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+}
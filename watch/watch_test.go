@@ -0,0 +1,108 @@
+package watch
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/richlj/neato/nucleo"
+)
+
+// scripted is a Robot reporting each of its states in turn
+type scripted struct {
+	mu     sync.Mutex
+	states []nucleo.RobotState
+}
+
+func (r *scripted) State() (*nucleo.RobotState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.states[0]
+	if len(r.states) > 1 {
+		r.states = r.states[1:]
+	}
+	return &s, nil
+}
+
+var (
+	docked = nucleo.RobotState{State: nucleo.StateIdle, Docked: true}
+	busy   = nucleo.RobotState{State: nucleo.StateBusy,
+		Action: nucleo.ActionHouseCleaning}
+	paused = nucleo.RobotState{State: nucleo.StatePaused,
+		Action: nucleo.ActionHouseCleaning}
+	recharging = nucleo.RobotState{State: nucleo.StateBusy,
+		Action: nucleo.ActionSuspended, Docked: true, Charging: true}
+	docking = nucleo.RobotState{State: nucleo.StateBusy,
+		Action: nucleo.ActionDocking}
+	stopped = nucleo.RobotState{State: nucleo.StateIdle}
+)
+
+func TestRuns(t *testing.T) {
+	const run = "run_started,run_completed"
+	tests := []struct {
+		name   string
+		states []nucleo.RobotState
+		want   string
+	}{
+		{"docking then idle", []nucleo.RobotState{docked, busy,
+			docking, docked}, run},
+		{"stopped", []nucleo.RobotState{docked, busy, stopped}, run},
+		{"paused and resumed", []nucleo.RobotState{docked, busy,
+			paused, busy, docking, docked}, run},
+		{"recharged mid-run", []nucleo.RobotState{docked, busy,
+			docking, recharging, busy, docking, docked}, run},
+		{"sent to base", []nucleo.RobotState{stopped, docking, docked},
+			""},
+		{"first seen cleaning",
+			[]nucleo.RobotState{busy, docking, docked},
+			"run_completed"},
+		{"two runs", []nucleo.RobotState{docked, busy, docking, docked,
+			busy, stopped}, run + "," + run},
+	}
+	for _, tt := range tests {
+		w := New(time.Minute)
+		w.Add("robot-1", &scripted{states: tt.states})
+		events, cancel := w.Subscribe()
+		for range tt.states {
+			w.Poll()
+		}
+		cancel()
+		var got []string
+		for e := range events {
+			if e.Type == RunStarted || e.Type == RunCompleted {
+				got = append(got, string(e.Type))
+			}
+		}
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("%s: %v, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestShutdownMidRun(t *testing.T) {
+	w := New(time.Minute)
+	w.Add("robot-1", &scripted{states: []nucleo.RobotState{busy}})
+	w.Add("robot-2", &scripted{states: []nucleo.RobotState{docked}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+	// Poll may run alongside Run
+	w.Poll()
+	var paused []string
+	w.OnShutdown = func(id string, r Robot) error {
+		paused = append(paused, id)
+		return nil
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Run = %v", err)
+	}
+	if strings.Join(paused, ",") != "robot-1" {
+		t.Errorf("OnShutdown called for %v", paused)
+	}
+}
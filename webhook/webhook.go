@@ -0,0 +1,388 @@
+// webhook delivers the Events of a watch.Watcher to external systems as
+// signed HTTP POSTs. Each endpoint receives only the types of Event it asks
+// for, in order. Failed deliveries are retried with exponential backoff, and
+// those which cannot be delivered are written to a dead-letter log rather
+// than lost silently.
+//
+// Each request carries the Event as a CloudEvent in the structured JSON
+// mode, and the headers:
+//
+//	X-Neato-Delivery: the CloudEvent's ID, the same for every attempt
+//	X-Neato-Timestamp: the time of the attempt, in seconds since the epoch
+//	X-Neato-Signature: sha256=HMAC-SHA256(secret, timestamp + "." + body)
+//
+// Receivers check them with Verify.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/richlj/neato/clock"
+	"github.com/richlj/neato/notify"
+	"github.com/richlj/neato/watch"
+)
+
+const (
+	deliveryHeader  = "X-Neato-Delivery"
+	timestampHeader = "X-Neato-Timestamp"
+	signatureHeader = "X-Neato-Signature"
+	signaturePrefix = "sha256="
+
+	defaultAttempts       = 5
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = 5 * time.Minute
+	queueLength           = 256
+)
+
+var (
+	// ErrBadSignature is returned by Verify when a request was not signed
+	// with the secret, or its timestamp is outside the tolerance
+	ErrBadSignature = errors.New("webhook: bad signature")
+
+	// errQueueFull is recorded in the dead-letter log when an endpoint
+	// falls so far behind that Events are dropped
+	errQueueFull = errors.New("delivery queue full")
+)
+
+// Endpoint is a URL to which Events are delivered
+type Endpoint struct {
+	URL string
+
+	// Secret signs the requests
+	Secret string
+
+	// Events selects the types of Event delivered. Empty selects all.
+	Events []watch.EventType
+
+	// Templates, if set, word the Summary of the Events delivered
+	Templates *notify.Templates
+}
+
+// DeadLetter records an Event which could not be delivered
+type DeadLetter struct {
+	Time     time.Time   `json:"time"`
+	URL      string      `json:"url"`
+	Delivery string      `json:"delivery"`
+	Attempts int         `json:"attempts"`
+	Error    string      `json:"error"`
+	Event    watch.Event `json:"event"`
+}
+
+// Dispatcher delivers Events to a set of Endpoints
+type Dispatcher struct {
+	Client *http.Client
+
+	// Attempts is the number of times a delivery is tried. It defaults to
+	// 5.
+	Attempts int
+
+	// InitialBackoff is the wait before the first retry, doubling for each
+	// retry after it up to MaxBackoff. They default to a second and five
+	// minutes.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// DeadLetters receives a JSON line for each Event which could not be
+	// delivered, if not nil
+	DeadLetters io.Writer
+
+	// Clock dates and paces the deliveries. It defaults to clock.Real.
+	Clock clock.Clock
+
+	mu        sync.Mutex
+	endpoints []Endpoint
+}
+
+type delivery struct {
+	id    string
+	event watch.CloudEvent
+}
+
+// queued is a delivery waiting in an Endpoint's queue, with the Endpoint as
+// it was configured when the Event arrived
+type queued struct {
+	delivery
+	endpoint Endpoint
+}
+
+// New returns a Dispatcher delivering to endpoints
+func New(endpoints ...Endpoint) *Dispatcher {
+	return &Dispatcher{endpoints: endpoints}
+}
+
+// SetEndpoints replaces the Endpoints, which is safe whilst Follow is
+// delivering. Events already queued are delivered as the Endpoints were
+// configured when they arrived, even to Endpoints which have been removed.
+func (d *Dispatcher) SetEndpoints(endpoints ...Endpoint) {
+	d.mu.Lock()
+	d.endpoints = endpoints
+	d.mu.Unlock()
+}
+
+// Endpoints returns the Endpoints
+func (d *Dispatcher) Endpoints() []Endpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Endpoint(nil), d.endpoints...)
+}
+
+// Follow delivers the Events of w until ctx is cancelled, or until w is shut
+// down, when the Events already queued are delivered before it returns. Each
+// Endpoint has its own queue, so that a slow or failing Endpoint does not
+// delay the others.
+func (d *Dispatcher) Follow(ctx context.Context, w *watch.Watcher) error {
+	events, cancel := w.Subscribe()
+	defer cancel()
+	queues := make(map[string]chan queued)
+	var wg sync.WaitGroup
+	defer func() {
+		for _, q := range queues {
+			close(q)
+		}
+		wg.Wait()
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			ce, err := e.CloudEvent()
+			if err != nil {
+				return err
+			}
+			endpoints := d.Endpoints()
+			d.follow(ctx, queues, endpoints, &wg)
+			for i := range endpoints {
+				ep := &endpoints[i]
+				if !ep.wants(e.Type) {
+					continue
+				}
+				dl := delivery{id: ce.ID, event: ep.apply(ce)}
+				select {
+				case queues[ep.URL] <- queued{dl, *ep}:
+				default:
+					d.deadLetter(ep, dl, 0, errQueueFull)
+				}
+			}
+		}
+	}
+}
+
+// follow brings queues into line with endpoints, starting a delivery
+// goroutine for each new Endpoint's queue and closing the queues of those
+// removed
+func (d *Dispatcher) follow(ctx context.Context,
+	queues map[string]chan queued, endpoints []Endpoint,
+	wg *sync.WaitGroup) {
+	kept := make(map[string]bool)
+	for _, ep := range endpoints {
+		kept[ep.URL] = true
+		if _, ok := queues[ep.URL]; ok {
+			continue
+		}
+		q := make(chan queued, queueLength)
+		queues[ep.URL] = q
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for dl := range q {
+				d.deliver(ctx, &dl.endpoint, dl.delivery)
+			}
+		}()
+	}
+	for url, q := range queues {
+		if !kept[url] {
+			close(q)
+			delete(queues, url)
+		}
+	}
+}
+
+// Deliver delivers an Event to every Endpoint which selects it, retrying as
+// necessary, and returns once every delivery has succeeded or been dead
+// lettered
+func (d *Dispatcher) Deliver(ctx context.Context, e watch.Event) error {
+	ce, err := e.CloudEvent()
+	if err != nil {
+		return err
+	}
+	endpoints := d.Endpoints()
+	for i := range endpoints {
+		if ep := &endpoints[i]; ep.wants(e.Type) {
+			dl := delivery{id: ce.ID, event: ep.apply(ce)}
+			d.deliver(ctx, ep, dl)
+		}
+	}
+	return nil
+}
+
+// deliver makes up to d.Attempts attempts to deliver dl to ep, dead
+// lettering it if none succeeds
+func (d *Dispatcher) deliver(ctx context.Context, ep *Endpoint,
+	dl delivery) {
+	body, err := json.Marshal(&dl.event)
+	if err != nil {
+		d.deadLetter(ep, dl, 0, err)
+		return
+	}
+	attempts := d.Attempts
+	if attempts <= 0 {
+		attempts = defaultAttempts
+	}
+	backoff := d.InitialBackoff
+	if backoff <= 0 {
+		backoff = defaultInitialBackoff
+	}
+	for n := 1; ; n++ {
+		retry, err := d.post(ctx, ep, dl.id, body)
+		if err == nil {
+			return
+		}
+		if !retry || n >= attempts || ctx.Err() != nil {
+			d.deadLetter(ep, dl, n, err)
+			return
+		}
+		t := clock.Or(d.Clock).NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			d.deadLetter(ep, dl, n, err)
+			return
+		case <-t.C():
+		}
+		backoff *= 2
+		if max := d.maxBackoff(); backoff > max {
+			backoff = max
+		}
+	}
+}
+
+// post makes a single delivery attempt, reporting whether a failure may
+// succeed if retried
+func (d *Dispatcher) post(ctx context.Context, ep *Endpoint, id string,
+	body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, ep.URL,
+		bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	ts := strconv.FormatInt(clock.Or(d.Clock).Now().Unix(), 10)
+	req.Header.Set("Content-Type", watch.CloudEventsContentType)
+	req.Header.Set(deliveryHeader, id)
+	req.Header.Set(timestampHeader, ts)
+	req.Header.Set(signatureHeader, signaturePrefix+sign(ep.Secret, ts,
+		body))
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return true, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode/100 == 2:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode/100 == 5:
+		return true, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return false, fmt.Errorf("endpoint responded %s", resp.Status)
+}
+
+func (d *Dispatcher) maxBackoff() time.Duration {
+	if d.MaxBackoff > 0 {
+		return d.MaxBackoff
+	}
+	return defaultMaxBackoff
+}
+
+// deadLetter records an Event which could not be delivered
+func (d *Dispatcher) deadLetter(ep *Endpoint, dl delivery, attempts int,
+	err error) {
+	if d.DeadLetters == nil {
+		return
+	}
+	b, merr := json.Marshal(&DeadLetter{
+		Time:     clock.Or(d.Clock).Now(),
+		URL:      ep.URL,
+		Delivery: dl.id,
+		Attempts: attempts,
+		Error:    err.Error(),
+		Event:    dl.event.Data,
+	})
+	if merr != nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.DeadLetters.Write(append(b, '\n'))
+}
+
+// apply returns ce with its Event worded by the Endpoint's Templates
+func (ep *Endpoint) apply(ce watch.CloudEvent) watch.CloudEvent {
+	ce.Data = ep.Templates.Apply(ce.Data)
+	return ce
+}
+
+// wants reports whether the Endpoint selects Events of type t
+func (ep *Endpoint) wants(t watch.EventType) bool {
+	if len(ep.Events) == 0 {
+		return true
+	}
+	for _, e := range ep.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify checks the signature of a webhook request with the supplied body,
+// which must be read by the caller, rejecting those whose timestamp is more
+// than tolerance from now
+func Verify(req *http.Request, body []byte, secret string,
+	tolerance time.Duration, now time.Time) error {
+	ts := req.Header.Get(timestampHeader)
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrBadSignature
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > tolerance || d < -tolerance {
+		return ErrBadSignature
+	}
+	got := req.Header.Get(signatureHeader)
+	want := signaturePrefix + sign(secret, ts, body)
+	if !hmac.Equal([]byte(got), []byte(want)) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// sign returns the hex encoded signature of body sent at timestamp ts
+func sign(secret, ts string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}